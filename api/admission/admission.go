@@ -0,0 +1,89 @@
+// Package admission bounds the work the API accepts at once. Requests over the limit wait in a bounded queue for a
+// bounded time and are then refused, so a spike is shed with fast 503s instead of piling up latency for everyone.
+package admission
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"aeshanw.com/accountApi/api/metrics"
+)
+
+var (
+	// ErrQueueFull is returned when the queue already holds its maximum of waiting requests
+	ErrQueueFull = errors.New("admission queue full")
+	// ErrWaitTimeout is returned when a request waited the maximum wait without being admitted
+	ErrWaitTimeout = errors.New("admission wait timed out")
+)
+
+// Queue admits up to a fixed number of concurrent requests. Further requests wait in turn, up to maxQueue of them
+// for at most maxWait each.
+type Queue struct {
+	name     string
+	slots    chan struct{}
+	waiting  atomic.Int64
+	maxQueue int64
+	maxWait  time.Duration
+}
+
+// NewQueue creates a queue named name in its metrics
+func NewQueue(name string, maxConcurrent int, maxQueue int, maxWait time.Duration) *Queue {
+	return &Queue{
+		name:     name,
+		slots:    make(chan struct{}, maxConcurrent),
+		maxQueue: int64(maxQueue),
+		maxWait:  maxWait,
+	}
+}
+
+// MaxWait is the longest a request waits for admission
+func (q *Queue) MaxWait() time.Duration {
+	return q.maxWait
+}
+
+// Acquire admits the request or returns ErrQueueFull, ErrWaitTimeout or the error of ctx. An admitted request must
+// call release once served.
+func (q *Queue) Acquire(ctx context.Context) (release func(), err error) {
+	select {
+	case q.slots <- struct{}{}:
+		q.record()
+		return q.release, nil
+	default:
+	}
+
+	if q.waiting.Add(1) > q.maxQueue {
+		q.waiting.Add(-1)
+		metrics.RecordAdmissionRejection(q.name, metrics.RejectQueueFull)
+		return nil, ErrQueueFull
+	}
+	q.record()
+	defer func() {
+		q.waiting.Add(-1)
+		q.record()
+	}()
+
+	waitStart := time.Now()
+	timer := time.NewTimer(q.maxWait)
+	defer timer.Stop()
+	select {
+	case q.slots <- struct{}{}:
+		metrics.ObserveAdmissionWait(q.name, waitStart)
+		return q.release, nil
+	case <-timer.C:
+		metrics.RecordAdmissionRejection(q.name, metrics.RejectWaitTimeout)
+		return nil, ErrWaitTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (q *Queue) release() {
+	<-q.slots
+	q.record()
+}
+
+func (q *Queue) record() {
+	metrics.SetAdmissionQueue(q.name, q.waiting.Load(), len(q.slots))
+}
@@ -0,0 +1,57 @@
+package admission
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueue(t *testing.T) {
+	q := NewQueue("test", 1, 1, 50*time.Millisecond)
+	ctx := context.Background()
+
+	release, err := q.Acquire(ctx)
+	assert.NoError(t, err)
+
+	//The second request waits for the slot and is admitted once it is released
+	admitted := make(chan error)
+	go func() {
+		release, err := q.Acquire(ctx)
+		if err == nil {
+			release()
+		}
+		admitted <- err
+	}()
+	assert.Eventually(t, func() bool { return q.waiting.Load() == 1 }, time.Second, time.Millisecond)
+
+	//The queue is full
+	_, err = q.Acquire(ctx)
+	assert.ErrorIs(t, err, ErrQueueFull)
+
+	release()
+	assert.NoError(t, <-admitted)
+	assert.Equal(t, int64(0), q.waiting.Load())
+	assert.Empty(t, q.slots)
+}
+
+func TestQueueWaitTimeout(t *testing.T) {
+	q := NewQueue("test_timeout", 1, 10, 10*time.Millisecond)
+
+	release, err := q.Acquire(context.Background())
+	assert.NoError(t, err)
+	defer release()
+
+	start := time.Now()
+	_, err = q.Acquire(context.Background())
+	assert.ErrorIs(t, err, ErrWaitTimeout)
+	assert.Less(t, time.Since(start), time.Second)
+
+	//A request whose context ends stops waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = q.Acquire(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int64(0), q.waiting.Load())
+}
@@ -0,0 +1,324 @@
+// Package audit keeps the append-only audit trail of every mutation. Each record is written in the DB txn of the
+// change it describes and carries a hash over its contents and the previous record's hash, so editing, removing or
+// reordering a record breaks the chain from that record on.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"aeshanw.com/accountApi/api/tracing"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionAccountCreate     = "account.create"
+	ActionAccountUpdate     = "account.update"
+	ActionTransactionCreate = "transaction.create"
+	ActionAPIKeyCreate      = "api_key.create"
+	ActionAPIKeyRevoke      = "api_key.revoke"
+)
+
+// Resource types changed by the actions
+const (
+	ResourceAccount     = "account"
+	ResourceTransaction = "transaction"
+	ResourceAPIKey      = "api_key"
+)
+
+// ActorSystem is the actor of changes made outside any request, e.g. by the transfer job worker
+const ActorSystem = "system"
+
+// GenesisHash is the previous hash of the first record
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// ErrChainBroken is returned by Verify when a record does not follow from the one before it
+var ErrChainBroken = errors.New("audit chain broken")
+
+// lockKey is the advisory lock serializing writers, the chain's order is then the order its records commit in
+const lockKey = 0x61756469746c6f67
+
+// Source is who made the changes of a request and where from
+type Source struct {
+	Actor     string
+	RequestID string
+	SourceIP  string
+}
+
+type sourceKey struct{}
+
+// WithSource returns a context whose changes are recorded as made by source
+func WithSource(ctx context.Context, source Source) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// SourceFromContext returns the source set by WithSource, changes without one are recorded as made by ActorSystem
+func SourceFromContext(ctx context.Context) Source {
+	if source, ok := ctx.Value(sourceKey{}).(Source); ok {
+		return source
+	}
+	return Source{Actor: ActorSystem}
+}
+
+// Entry is a change to record. Before and After are encoded as JSON, nil for a resource that did not exist before
+// or no longer exists after.
+type Entry struct {
+	Action       string
+	ResourceType string
+	ResourceID   string
+	Before       any
+	After        any
+}
+
+type Record struct {
+	Seq          int64
+	OccurredAt   time.Time
+	Actor        string
+	RequestID    string
+	SourceIP     string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	Before       json.RawMessage
+	After        json.RawMessage
+	PrevHash     string
+	Hash         string
+}
+
+// ComputeHash hashes the record's contents along with PrevHash
+func (rec *Record) ComputeHash() string {
+	//Field order is fixed by the struct, the timestamp is in UTC at the microsecond precision Postgres keeps
+	contents, _ := json.Marshal(struct {
+		Seq          int64           `json:"seq"`
+		OccurredAt   string          `json:"occurred_at"`
+		Actor        string          `json:"actor"`
+		RequestID    string          `json:"request_id"`
+		SourceIP     string          `json:"source_ip"`
+		Action       string          `json:"action"`
+		ResourceType string          `json:"resource_type"`
+		ResourceID   string          `json:"resource_id"`
+		Before       json.RawMessage `json:"before"`
+		After        json.RawMessage `json:"after"`
+	}{rec.Seq, rec.OccurredAt.UTC().Format(time.RFC3339Nano), rec.Actor, rec.RequestID, rec.SourceIP, rec.Action,
+		rec.ResourceType, rec.ResourceID, rec.Before, rec.After})
+	sum := sha256.Sum256(append([]byte(rec.PrevHash+"\n"), contents...))
+	return hex.EncodeToString(sum[:])
+}
+
+// Write appends entries to the audit log within txn, they are only recorded if txn commits. Writers are serialized
+// until txn ends, so callers write their entries last, once their own row locks are held.
+func Write(ctx context.Context, txn *sql.Tx, entries ...Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	ctx, span := tracing.Start(ctx, "audit.Write")
+	defer span.End()
+
+	sqlLockChain := `SELECT pg_advisory_xact_lock($1)`
+	sqlChainHead := `SELECT seq,hash FROM audit_log ORDER BY seq DESC LIMIT 1`
+
+	if _, err := txn.ExecContext(ctx, sqlLockChain, lockKey); err != nil {
+		return fmt.Errorf("unable to lock audit log due to :%w", err)
+	}
+	var seq int64
+	prevHash := GenesisHash
+	if err := txn.QueryRowContext(ctx, sqlChainHead).Scan(&seq, &prevHash); err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("unable to read audit log head due to :%w", err)
+	}
+
+	//Records are streamed with COPY, an import writes one per account
+	stmt, err := txn.PrepareContext(ctx, pq.CopyIn("audit_log", "seq", "occurred_at", "actor", "request_id", "source_ip", "action",
+		"resource_type", "resource_id", "before_state", "after_state", "prev_hash", "hash"))
+	if err != nil {
+		return fmt.Errorf("unable to insert audit records due to :%w", err)
+	}
+	defer stmt.Close()
+
+	source := SourceFromContext(ctx)
+	occurredAt := time.Now().UTC().Truncate(time.Microsecond)
+	for _, entry := range entries {
+		rec := &Record{
+			Seq:          seq + 1,
+			OccurredAt:   occurredAt,
+			Actor:        source.Actor,
+			RequestID:    source.RequestID,
+			SourceIP:     source.SourceIP,
+			Action:       entry.Action,
+			ResourceType: entry.ResourceType,
+			ResourceID:   entry.ResourceID,
+			PrevHash:     prevHash,
+		}
+		var err error
+		if rec.Before, err = encodeState(entry.Before); err != nil {
+			return fmt.Errorf("unable to encode audit record due to :%w", err)
+		}
+		if rec.After, err = encodeState(entry.After); err != nil {
+			return fmt.Errorf("unable to encode audit record due to :%w", err)
+		}
+		rec.Hash = rec.ComputeHash()
+
+		if _, err := stmt.ExecContext(ctx, rec.Seq, rec.OccurredAt, rec.Actor, nullIfEmpty(rec.RequestID), nullIfEmpty(rec.SourceIP),
+			rec.Action, rec.ResourceType, rec.ResourceID, nullIfNil(rec.Before), nullIfNil(rec.After), rec.PrevHash, rec.Hash); err != nil {
+			return fmt.Errorf("unable to insert audit records due to :%w", err)
+		}
+		seq, prevHash = rec.Seq, rec.Hash
+	}
+	//Flush the buffered COPY data
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return fmt.Errorf("unable to insert audit records due to :%w", err)
+	}
+	return stmt.Close()
+}
+
+func encodeState(state any) (json.RawMessage, error) {
+	if state == nil {
+		return nil, nil
+	}
+	return json.Marshal(state)
+}
+
+// nullIfEmpty stores optional text columns as NULL rather than an empty string
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullIfNil stores a missing state as NULL. The column is of type json, which keeps the text exactly as hashed.
+func nullIfNil(state json.RawMessage) sql.NullString {
+	return sql.NullString{String: string(state), Valid: state != nil}
+}
+
+const sqlRecordColumns = `seq,occurred_at,actor,request_id,source_ip,action,resource_type,resource_id,before_state,after_state,prev_hash,hash`
+
+func scanRecord(row interface{ Scan(...any) error }) (*Record, error) {
+	var rec Record
+	var requestID, sourceIP sql.NullString
+	var before, after []byte
+	if err := row.Scan(&rec.Seq, &rec.OccurredAt, &rec.Actor, &requestID, &sourceIP, &rec.Action, &rec.ResourceType, &rec.ResourceID,
+		&before, &after, &rec.PrevHash, &rec.Hash); err != nil {
+		return nil, err
+	}
+	rec.OccurredAt = rec.OccurredAt.UTC()
+	rec.RequestID, rec.SourceIP = requestID.String, sourceIP.String
+	if before != nil {
+		rec.Before = json.RawMessage(before)
+	}
+	if after != nil {
+		rec.After = json.RawMessage(after)
+	}
+	return &rec, nil
+}
+
+// VerifyResult describes a verified chain. Removing the newest records leaves a shorter valid chain, comparing Head
+// with one noted earlier catches that.
+type VerifyResult struct {
+	Records  int64  `json:"records"`
+	HeadSeq  int64  `json:"head_seq"`
+	HeadHash string `json:"head_hash"`
+}
+
+// Verify walks the whole chain in order and fails with ErrChainBroken at the first record whose sequence, previous
+// hash or own hash does not follow from the record before it
+func Verify(ctx context.Context, db *sql.DB) (*VerifyResult, error) {
+	ctx, span := tracing.Start(ctx, "audit.Verify")
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx, `SELECT `+sqlRecordColumns+` FROM audit_log ORDER BY seq`)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read audit log due to :%w", err)
+	}
+	defer rows.Close()
+
+	result := &VerifyResult{HeadHash: GenesisHash}
+	for rows.Next() {
+		rec, err := scanRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read audit record due to :%w", err)
+		}
+		switch {
+		case rec.Seq != result.HeadSeq+1:
+			return result, fmt.Errorf("%w: record %d follows record %d", ErrChainBroken, rec.Seq, result.HeadSeq)
+		case rec.PrevHash != result.HeadHash:
+			return result, fmt.Errorf("%w: record %d does not link to the hash of record %d", ErrChainBroken, rec.Seq, result.HeadSeq)
+		case rec.ComputeHash() != rec.Hash:
+			return result, fmt.Errorf("%w: record %d does not match its hash", ErrChainBroken, rec.Seq)
+		}
+		result.Records++
+		result.HeadSeq, result.HeadHash = rec.Seq, rec.Hash
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read audit log due to :%w", err)
+	}
+	return result, nil
+}
+
+// Query selects records, every set field narrows the selection
+type Query struct {
+	Actor        string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	RequestID    string
+	Since        *time.Time
+	Until        *time.Time
+	// AfterSeq skips the records up to and including it, pass the last seq of a page to fetch the next one
+	AfterSeq int64
+	Limit    int
+}
+
+// Find returns up to q.Limit records matching q, oldest first
+func Find(ctx context.Context, db *sql.DB, q Query) ([]*Record, error) {
+	ctx, span := tracing.Start(ctx, "audit.Find")
+	defer span.End()
+
+	conditions := []string{"seq>$1"}
+	args := []any{q.AfterSeq}
+	filter := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	for _, f := range []struct {
+		column string
+		value  string
+	}{{"actor", q.Actor}, {"action", q.Action}, {"resource_type", q.ResourceType}, {"resource_id", q.ResourceID}, {"request_id", q.RequestID}} {
+		if f.value != "" {
+			filter(f.column+"=$%d", f.value)
+		}
+	}
+	if q.Since != nil {
+		filter("occurred_at>=$%d", *q.Since)
+	}
+	if q.Until != nil {
+		filter("occurred_at<$%d", *q.Until)
+	}
+	args = append(args, q.Limit)
+	sqlFindRecords := `SELECT ` + sqlRecordColumns + ` FROM audit_log WHERE ` + strings.Join(conditions, " AND ") +
+		fmt.Sprintf(` ORDER BY seq LIMIT $%d`, len(args))
+
+	rows, err := db.QueryContext(ctx, sqlFindRecords, args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query audit log due to :%w", err)
+	}
+	defer rows.Close()
+
+	records := []*Record{}
+	for rows.Next() {
+		rec, err := scanRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read audit record due to :%w", err)
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to query audit log due to :%w", err)
+	}
+	return records, nil
+}
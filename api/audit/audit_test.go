@@ -0,0 +1,203 @@
+package audit
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+var recordColumns = []string{"seq", "occurred_at", "actor", "request_id", "source_ip", "action", "resource_type", "resource_id",
+	"before_state", "after_state", "prev_hash", "hash"}
+
+// chain links n account updates the way Write does
+func chain(n int) []*Record {
+	records := []*Record{}
+	prevHash := GenesisHash
+	for i := 1; i <= n; i++ {
+		rec := &Record{
+			Seq:          int64(i),
+			OccurredAt:   time.Date(2024, 5, 1, 0, 0, i, 123000, time.UTC),
+			Actor:        "api_key:0123456789ab",
+			RequestID:    "req-1",
+			Action:       ActionAccountUpdate,
+			ResourceType: ResourceAccount,
+			ResourceID:   "1",
+			Before:       json.RawMessage(`{"id":1,"balance":10}`),
+			After:        json.RawMessage(`{"id":1,"balance":20}`),
+			PrevHash:     prevHash,
+		}
+		rec.Hash = rec.ComputeHash()
+		prevHash = rec.Hash
+		records = append(records, rec)
+	}
+	return records
+}
+
+func recordRows(records []*Record) *sqlmock.Rows {
+	rows := sqlmock.NewRows(recordColumns)
+	for _, rec := range records {
+		var sourceIP any
+		if rec.SourceIP != "" {
+			sourceIP = rec.SourceIP
+		}
+		rows.AddRow(rec.Seq, rec.OccurredAt, rec.Actor, rec.RequestID, sourceIP, rec.Action, rec.ResourceType, rec.ResourceID,
+			[]byte(rec.Before), []byte(rec.After), rec.PrevHash, rec.Hash)
+	}
+	return rows
+}
+
+func TestComputeHash(t *testing.T) {
+	rec := chain(1)[0]
+	assert.Len(t, rec.Hash, 64)
+
+	//The hash is independent of the timestamp's zone and covers every field
+	inZone := *rec
+	inZone.OccurredAt = rec.OccurredAt.In(time.FixedZone("UTC+8", 8*60*60))
+	assert.Equal(t, rec.Hash, inZone.ComputeHash())
+
+	for _, tamper := range []func(rec *Record){
+		func(rec *Record) { rec.Actor = "system" },
+		func(rec *Record) { rec.After = json.RawMessage(`{"id":1,"balance":2000}`) },
+		func(rec *Record) { rec.OccurredAt = rec.OccurredAt.Add(time.Microsecond) },
+		func(rec *Record) { rec.PrevHash = rec.Hash },
+	} {
+		tampered := *rec
+		tamper(&tampered)
+		assert.NotEqual(t, rec.Hash, tampered.ComputeHash())
+	}
+}
+
+func TestVerify(t *testing.T) {
+	sqlReadChain := regexp.QuoteMeta(`SELECT ` + sqlRecordColumns + ` FROM audit_log ORDER BY seq`)
+
+	tests := []struct {
+		name           string
+		records        func() []*Record
+		expectedResult *VerifyResult
+		expectedErr    string
+	}{
+		{
+			name:           "empty log",
+			records:        func() []*Record { return nil },
+			expectedResult: &VerifyResult{HeadHash: GenesisHash},
+		},
+		{
+			name:    "intact chain",
+			records: func() []*Record { return chain(3) },
+		},
+		{
+			name: "edited record",
+			records: func() []*Record {
+				records := chain(3)
+				records[1].After = json.RawMessage(`{"id":1,"balance":2000}`)
+				return records
+			},
+			expectedErr: "audit chain broken: record 2 does not match its hash",
+		},
+		{
+			name: "edited and rehashed record",
+			records: func() []*Record {
+				records := chain(3)
+				records[1].After = json.RawMessage(`{"id":1,"balance":2000}`)
+				records[1].Hash = records[1].ComputeHash()
+				return records
+			},
+			expectedErr: "audit chain broken: record 3 does not link to the hash of record 2",
+		},
+		{
+			name: "removed record",
+			records: func() []*Record {
+				records := chain(3)
+				return append(records[:1], records[2])
+			},
+			expectedErr: "audit chain broken: record 3 follows record 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			records := tt.records()
+			mock.ExpectQuery(sqlReadChain).WillReturnRows(recordRows(records))
+
+			result, err := Verify(context.Background(), db)
+			if tt.expectedErr != "" {
+				assert.ErrorIs(t, err, ErrChainBroken)
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			expected := tt.expectedResult
+			if expected == nil {
+				head := records[len(records)-1]
+				expected = &VerifyResult{Records: int64(len(records)), HeadSeq: head.Seq, HeadHash: head.Hash}
+			}
+			assert.Equal(t, expected, result)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	head := chain(1)[0]
+	var first, second Record
+	captureRecord := func(rec *Record) sqlmock.Argument { return recordArg{rec} }
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT seq,hash FROM audit_log ORDER BY seq DESC LIMIT 1")).
+		WillReturnRows(sqlmock.NewRows([]string{"seq", "hash"}).AddRow(head.Seq, head.Hash))
+	prep := mock.ExpectPrepare(regexp.QuoteMeta(`COPY "audit_log"`))
+	prep.ExpectExec().WithArgs(int64(2), captureRecord(&first), "api_key:0123456789ab", "req-1", "10.0.0.1", ActionAccountCreate,
+		ResourceAccount, "2", nil, `{"id":2}`, head.Hash, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+	prep.ExpectExec().WithArgs(int64(3), captureRecord(&second), "api_key:0123456789ab", "req-1", "10.0.0.1", ActionAccountUpdate,
+		ResourceAccount, "1", `{"id":1}`, `{"id":1,"status":"frozen"}`, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+	prep.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	ctx := WithSource(context.Background(), Source{Actor: "api_key:0123456789ab", RequestID: "req-1", SourceIP: "10.0.0.1"})
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	err = Write(ctx, txn,
+		Entry{Action: ActionAccountCreate, ResourceType: ResourceAccount, ResourceID: "2", After: map[string]int{"id": 2}},
+		Entry{Action: ActionAccountUpdate, ResourceType: ResourceAccount, ResourceID: "1", Before: map[string]int{"id": 1},
+			After: map[string]any{"id": 1, "status": "frozen"}},
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit())
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	//Both records share the txn's timestamp
+	assert.False(t, first.OccurredAt.IsZero())
+	assert.Equal(t, first.OccurredAt, second.OccurredAt)
+	assert.Equal(t, first.OccurredAt, first.OccurredAt.Truncate(time.Microsecond))
+}
+
+func TestWriteWithoutSource(t *testing.T) {
+	assert.Equal(t, Source{Actor: ActorSystem}, SourceFromContext(context.Background()))
+	assert.NoError(t, Write(context.Background(), nil))
+}
+
+// recordArg captures the timestamp passed for a record
+type recordArg struct {
+	rec *Record
+}
+
+func (a recordArg) Match(v driver.Value) bool {
+	occurredAt, ok := v.(time.Time)
+	a.rec.OccurredAt = occurredAt
+	return ok
+}
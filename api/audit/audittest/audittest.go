@@ -0,0 +1,18 @@
+// Package audittest sets up sqlmock expectations for the audit records services write
+package audittest
+
+import (
+	"regexp"
+
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+// ExpectWrite expects an audit.Write of n records on an empty audit log
+func ExpectWrite(mock sqlmock.Sqlmock, n int) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT seq,hash FROM audit_log ORDER BY seq DESC LIMIT 1")).WillReturnRows(sqlmock.NewRows([]string{"seq", "hash"}))
+	prep := mock.ExpectPrepare(regexp.QuoteMeta(`COPY "audit_log"`))
+	for i := 0; i <= n; i++ {
+		prep.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+}
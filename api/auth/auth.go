@@ -0,0 +1,78 @@
+// Package auth holds the authenticated caller of a request and the scopes that gate the API's routes.
+// The handlers authenticate the caller and store it in the request context, routes then check its scopes.
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// Scopes granted to callers, every API route requires one of them
+const (
+	ScopeAccountsRead      = "accounts:read"
+	ScopeAccountsWrite     = "accounts:write"
+	ScopeTransactionsRead  = "transactions:read"
+	ScopeTransactionsWrite = "transactions:write"
+	// ScopeAdmin manages API keys and grants every other scope
+	ScopeAdmin = "admin"
+)
+
+// Scopes lists every valid scope
+var Scopes = []string{ScopeAccountsRead, ScopeAccountsWrite, ScopeTransactionsRead, ScopeTransactionsWrite, ScopeAdmin}
+
+// IsScope reports whether scope is one of Scopes
+func IsScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
+
+// Authentication methods a Principal can come from
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	// ID identifies the caller in logs and rate limits, e.g. the API key's public ID
+	ID     string
+	Name   string
+	Method string
+	Scopes []string
+	// AccountIDs are the accounts the caller owns, only enforced when RestrictedToAccounts is set.
+	// API keys belong to trusted services and are not restricted, end-user tokens are.
+	AccountIDs           []int64
+	RestrictedToAccounts bool
+}
+
+// HasScope reports whether the caller was granted scope, directly or through ScopeAdmin
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+// CanAccessAccount reports whether the caller may read or debit accountID: admins and unrestricted callers may act on
+// any account, other callers only on the accounts they own
+func (p *Principal) CanAccessAccount(accountID int64) bool {
+	if !p.RestrictedToAccounts || p.HasScope(ScopeAdmin) {
+		return true
+	}
+	return slices.Contains(p.AccountIDs, accountID)
+}
+
+// Restricted reports whether the caller is limited to its own accounts, such callers cannot use routes spanning
+// every account, e.g. listing accounts
+func (p *Principal) Restricted() bool {
+	return p.RestrictedToAccounts && !p.HasScope(ScopeAdmin)
+}
+
+type ctxKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the authenticated caller
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext returns the authenticated caller carried by ctx, or nil when the request is unauthenticated
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(ctxKey{}).(*Principal)
+	return p
+}
@@ -0,0 +1,339 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken covers every bearer token that fails verification, the cause is wrapped for logs
+var ErrInvalidToken = errors.New("invalid bearer token")
+
+// JWTOptions configures bearer token verification. Keys are only ever read from local files or config, so tokens are
+// verified without any network call.
+type JWTOptions struct {
+	// JWKSFile is a JSON Web Key Set file with the issuer's public keys, matched by the token's kid
+	JWKSFile string
+	// PublicKeysFile is a PEM file of one or more PKIX public keys, tried in turn
+	PublicKeysFile string
+	// HMACSecret verifies HS256/384/512 tokens signed with a shared secret
+	HMACSecret string
+	Issuer     string
+	Audience   string
+	// AccountsClaim names the claim listing the account IDs the caller owns
+	AccountsClaim string
+	// ScopesClaim names the claim with the caller's scopes, space separated as in OAuth 2.0 or a JSON array
+	ScopesClaim string
+	// Leeway tolerates clock skew between the issuer and the API on exp, nbf and iat
+	Leeway time.Duration
+}
+
+type verificationKey struct {
+	kid string
+	key any
+}
+
+// JWTVerifier checks bearer tokens and maps their claims to a Principal
+type JWTVerifier struct {
+	opts   JWTOptions
+	keys   []verificationKey
+	parser *jwt.Parser
+}
+
+// NewJWTVerifier loads the configured key material. At least one key source is required.
+func NewJWTVerifier(opts JWTOptions) (*JWTVerifier, error) {
+	v := &JWTVerifier{opts: opts}
+
+	if opts.JWKSFile != "" {
+		keys, err := loadJWKS(opts.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = append(v.keys, keys...)
+	}
+	if opts.PublicKeysFile != "" {
+		keys, err := loadPublicKeys(opts.PublicKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = append(v.keys, keys...)
+	}
+	if opts.HMACSecret != "" {
+		v.keys = append(v.keys, verificationKey{key: []byte(opts.HMACSecret)})
+	}
+	if len(v.keys) == 0 {
+		return nil, errors.New("no JWT verification keys configured")
+	}
+
+	v.parser = jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA", "HS256", "HS384", "HS512"}),
+		jwt.WithIssuer(opts.Issuer),
+		jwt.WithAudience(opts.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(opts.Leeway),
+	)
+	return v, nil
+}
+
+// keyFunc offers the keys that match the token's kid and whose type fits the token's algorithm, so a token cannot
+// for instance be HMAC-signed with a public key as the secret
+func (v *JWTVerifier) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	var set jwt.VerificationKeySet
+	for _, k := range v.keys {
+		if kid != "" && k.kid != "" && k.kid != kid {
+			continue
+		}
+		if !keyFitsMethod(k.key, token.Method) {
+			continue
+		}
+		set.Keys = append(set.Keys, k.key)
+	}
+	if len(set.Keys) == 0 {
+		return nil, fmt.Errorf("no %s key with kid %q", token.Method.Alg(), kid)
+	}
+	return set, nil
+}
+
+func keyFitsMethod(key any, method jwt.SigningMethod) bool {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		switch method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			return true
+		}
+	case *ecdsa.PublicKey:
+		_, ok := method.(*jwt.SigningMethodECDSA)
+		return ok
+	case ed25519.PublicKey:
+		_, ok := method.(*jwt.SigningMethodEd25519)
+		return ok
+	case []byte:
+		_, ok := method.(*jwt.SigningMethodHMAC)
+		return ok
+	}
+	return false
+}
+
+// Verify checks the token's signature, issuer, audience and expiry and returns its caller. Callers holding the admin
+// scope may act on any account, every other caller only on the accounts listed in the accounts claim.
+func (v *JWTVerifier) Verify(tokenString string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(tokenString, claims, v.keyFunc); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, fmt.Errorf("%w: sub claim is required", ErrInvalidToken)
+	}
+	scopes, err := stringsClaim(claims[v.opts.ScopesClaim])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s claim %w", ErrInvalidToken, v.opts.ScopesClaim, err)
+	}
+	accountIDs, err := accountIDsClaim(claims[v.opts.AccountsClaim])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s claim %w", ErrInvalidToken, v.opts.AccountsClaim, err)
+	}
+
+	return &Principal{
+		ID:                   subject,
+		Name:                 subject,
+		Method:               MethodJWT,
+		Scopes:               scopes,
+		AccountIDs:           accountIDs,
+		RestrictedToAccounts: true,
+	}, nil
+}
+
+// stringsClaim reads a space separated string or an array of strings
+func stringsClaim(value any) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return strings.Fields(v), nil
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, errors.New("must hold strings")
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	}
+	return nil, errors.New("must be a string or an array of strings")
+}
+
+// accountIDsClaim reads an array of account IDs, given as numbers or numeric strings
+func accountIDsClaim(value any) ([]int64, error) {
+	if value == nil {
+		return nil, nil
+	}
+	items, ok := value.([]any)
+	if !ok {
+		return nil, errors.New("must be an array of account IDs")
+	}
+	ids := make([]int64, 0, len(items))
+	for _, item := range items {
+		var id int64
+		switch v := item.(type) {
+		case float64:
+			if v != float64(int64(v)) {
+				return nil, fmt.Errorf("holds non-integer account ID %v", v)
+			}
+			id = int64(v)
+		case string:
+			parsed, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("holds invalid account ID %q", v)
+			}
+			id = parsed
+		default:
+			return nil, errors.New("must be an array of account IDs")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// loadJWKS reads the RSA, EC and Ed25519 signing keys of a JWKS file, encryption keys are skipped
+func loadJWKS(path string) ([]verificationKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read JWKS file due to :%w", err)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS file %s: %w", path, err)
+	}
+
+	var keys []verificationKey
+	for i, k := range set.Keys {
+		if k.Use == "enc" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS file %s: key %d (kid %q): %w", path, i, k.Kid, err)
+		}
+		keys = append(keys, verificationKey{kid: k.Kid, key: key})
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("n: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("e: %w", err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("x: invalid Ed25519 public key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported kty %q", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid base64url value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// loadPublicKeys reads every PUBLIC KEY block of a PEM file. A block may name its key with a "kid" header.
+func loadPublicKeys(path string) ([]verificationKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read public keys file due to :%w", err)
+	}
+
+	var keys []verificationKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public keys file %s: %w", path, err)
+		}
+		keys = append(keys, verificationKey{kid: block.Headers["kid"], key: key})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("invalid public keys file %s: no PUBLIC KEY block", path)
+	}
+	return keys, nil
+}
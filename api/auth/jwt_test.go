@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// writeKeys writes rsaKey's public half to a JWKS file as kid "rsa-1" and ecKey's to a PEM file
+func writeKeys(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) (jwksFile, pemFile string) {
+	dir := t.TempDir()
+
+	jwks, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": "AQAB", "e": "AQAB"},
+	}})
+	assert.NoError(t, err)
+	jwksFile = filepath.Join(dir, "jwks.json")
+	assert.NoError(t, os.WriteFile(jwksFile, jwks, 0o600))
+
+	der, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	assert.NoError(t, err)
+	pemFile = filepath.Join(dir, "keys.pem")
+	assert.NoError(t, os.WriteFile(pemFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Headers: map[string]string{"kid": "ec-1"}, Bytes: der}), 0o600))
+	return jwksFile, pemFile
+}
+
+func TestJWTVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	jwksFile, pemFile := writeKeys(t, rsaKey, ecKey)
+
+	verifier, err := NewJWTVerifier(JWTOptions{
+		JWKSFile:       jwksFile,
+		PublicKeysFile: pemFile,
+		Issuer:         "https://idp.example.com",
+		Audience:       "accounts-api",
+		AccountsClaim:  "accounts",
+		ScopesClaim:    "scope",
+		Leeway:         30 * time.Second,
+	})
+	assert.NoError(t, err)
+
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":      "https://idp.example.com",
+			"aud":      "accounts-api",
+			"sub":      "user-42",
+			"exp":      time.Now().Add(time.Minute).Unix(),
+			"scope":    "accounts:read transactions:write",
+			"accounts": []any{1, "2"},
+		}
+	}
+	sign := func(method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		s, err := token.SignedString(key)
+		assert.NoError(t, err)
+		return s
+	}
+	with := func(key string, value any) jwt.MapClaims {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{name: "RSA key from the JWKS", token: sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, validClaims()), valid: true},
+		{name: "EC key from the PEM file", token: sign(jwt.SigningMethodES256, "ec-1", ecKey, validClaims()), valid: true},
+		{name: "token without kid", token: sign(jwt.SigningMethodRS256, "", rsaKey, validClaims()), valid: true},
+		{name: "expired within the clock skew", token: sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, with("exp", time.Now().Add(-10*time.Second).Unix())), valid: true},
+		{name: "expired", token: sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, with("exp", time.Now().Add(-time.Minute).Unix()))},
+		{name: "without expiry", token: sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, with("exp", nil))},
+		{name: "wrong issuer", token: sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, with("iss", "https://evil.example.com"))},
+		{name: "wrong audience", token: sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, with("aud", "other-api"))},
+		{name: "without subject", token: sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, with("sub", nil))},
+		{name: "invalid accounts claim", token: sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, with("accounts", "1,2"))},
+		{name: "unknown signer", token: sign(jwt.SigningMethodRS256, "rsa-1", otherKey, validClaims())},
+		{name: "unknown kid", token: sign(jwt.SigningMethodRS256, "rsa-2", rsaKey, validClaims())},
+		{name: "encryption key kid", token: sign(jwt.SigningMethodRS256, "enc-1", rsaKey, validClaims())},
+		{name: "public key used as HMAC secret", token: sign(jwt.SigningMethodHS256, "rsa-1", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey), validClaims())},
+		{name: "unsigned", token: sign(jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, validClaims())},
+		{name: "malformed", token: "not-a-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := verifier.Verify(tt.token)
+			if !tt.valid {
+				assert.ErrorIs(t, err, ErrInvalidToken)
+				assert.Nil(t, principal)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, &Principal{
+				ID:                   "user-42",
+				Name:                 "user-42",
+				Method:               MethodJWT,
+				Scopes:               []string{ScopeAccountsRead, ScopeTransactionsWrite},
+				AccountIDs:           []int64{1, 2},
+				RestrictedToAccounts: true,
+			}, principal)
+		})
+	}
+}
+
+func TestNewJWTVerifierErrors(t *testing.T) {
+	dir := t.TempDir()
+	badJWKS := filepath.Join(dir, "jwks.json")
+	assert.NoError(t, os.WriteFile(badJWKS, []byte(`{"keys":[{"kty":"EC","kid":"ec-1","crv":"P-256","x":"AQ","y":"AQ"}]}`), 0o600))
+	emptyPEM := filepath.Join(dir, "keys.pem")
+	assert.NoError(t, os.WriteFile(emptyPEM, []byte("no keys here\n"), 0o600))
+
+	_, err := NewJWTVerifier(JWTOptions{})
+	assert.EqualError(t, err, "no JWT verification keys configured")
+	_, err = NewJWTVerifier(JWTOptions{JWKSFile: badJWKS})
+	assert.ErrorContains(t, err, `key 0 (kid "ec-1"): point is not on the curve`)
+	_, err = NewJWTVerifier(JWTOptions{PublicKeysFile: emptyPEM})
+	assert.ErrorContains(t, err, "no PUBLIC KEY block")
+	_, err = NewJWTVerifier(JWTOptions{JWKSFile: filepath.Join(dir, "missing.json")})
+	assert.ErrorContains(t, err, "unable to read JWKS file")
+}
+
+func TestCanAccessAccount(t *testing.T) {
+	owner := &Principal{Scopes: []string{ScopeAccountsRead}, AccountIDs: []int64{1}, RestrictedToAccounts: true}
+	assert.True(t, owner.CanAccessAccount(1))
+	assert.False(t, owner.CanAccessAccount(2))
+	assert.True(t, owner.Restricted())
+
+	admin := &Principal{Scopes: []string{ScopeAdmin}, RestrictedToAccounts: true}
+	assert.True(t, admin.CanAccessAccount(2))
+	assert.False(t, admin.Restricted())
+
+	apiKey := &Principal{Scopes: []string{ScopeAccountsRead}}
+	assert.True(t, apiKey.CanAccessAccount(2))
+	assert.False(t, apiKey.Restricted())
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"container/heap"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"aeshanw.com/accountApi/api/tracing"
+)
+
+// ErrNonceCacheFull is returned by MemoryNonceStore when every slot holds an unexpired nonce. Evicting one would let
+// its request be replayed, so new requests are refused until nonces expire.
+var ErrNonceCacheFull = errors.New("nonce cache full")
+
+type nonceEntry struct {
+	key       string
+	expiresAt time.Time
+}
+
+// nonceHeap orders nonces by expiry, the soonest first
+type nonceHeap []nonceEntry
+
+func (h nonceHeap) Len() int           { return len(h) }
+func (h nonceHeap) Less(i, j int) bool { return h[i].expiresAt.Before(h[j].expiresAt) }
+func (h nonceHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *nonceHeap) Push(x any)        { *h = append(*h, x.(nonceEntry)) }
+func (h *nonceHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// MemoryNonceStore keeps up to a fixed number of nonces in process. It only catches replays sent to the same
+// replica, use DBNonceStore when several replicas serve signed requests.
+type MemoryNonceStore struct {
+	mu       sync.Mutex
+	capacity int
+	seen     map[string]time.Time
+	expiries nonceHeap
+	now      func() time.Time
+}
+
+func NewMemoryNonceStore(capacity int) *MemoryNonceStore {
+	return &MemoryNonceStore{capacity: capacity, seen: make(map[string]time.Time), now: time.Now}
+}
+
+func (s *MemoryNonceStore) Claim(ctx context.Context, clientID string, nonce string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for len(s.expiries) > 0 && !s.expiries[0].expiresAt.After(now) {
+		delete(s.seen, heap.Pop(&s.expiries).(nonceEntry).key)
+	}
+
+	key := clientID + "\x00" + nonce
+	if _, ok := s.seen[key]; ok {
+		return false, nil
+	}
+	if len(s.seen) >= s.capacity {
+		return false, ErrNonceCacheFull
+	}
+	s.seen[key] = expiresAt
+	heap.Push(&s.expiries, nonceEntry{key: key, expiresAt: expiresAt})
+	return true, nil
+}
+
+// dbNoncePurgeEvery is how many claims DBNonceStore makes between purges of expired nonces
+const dbNoncePurgeEvery = 1000
+
+// DBNonceStore keeps nonces in the request_nonces table, shared by every replica
+type DBNonceStore struct {
+	db     *sql.DB
+	claims atomic.Int64
+}
+
+func NewDBNonceStore(db *sql.DB) *DBNonceStore {
+	return &DBNonceStore{db: db}
+}
+
+func (s *DBNonceStore) Claim(ctx context.Context, clientID string, nonce string, expiresAt time.Time) (bool, error) {
+	ctx, span := tracing.Start(ctx, "DBNonceStore.Claim")
+	defer span.End()
+
+	if s.claims.Add(1)%dbNoncePurgeEvery == 0 {
+		if _, err := s.db.ExecContext(ctx, "DELETE FROM request_nonces WHERE expires_at<NOW()"); err != nil {
+			return false, fmt.Errorf("unable to purge expired nonces due to :%w", err)
+		}
+	}
+
+	//An expired row left behind by the purge is taken over, it no longer guards against anything
+	res, err := s.db.ExecContext(ctx, "INSERT INTO request_nonces(client_id,nonce,expires_at) VALUES ($1,$2,$3) "+
+		"ON CONFLICT (client_id,nonce) DO UPDATE SET expires_at=EXCLUDED.expires_at WHERE request_nonces.expires_at<NOW()",
+		clientID, nonce, expiresAt)
+	if err != nil {
+		return false, fmt.Errorf("unable to record nonce due to :%w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("unable to record nonce due to :%w", err)
+	}
+	return n == 1, nil
+}
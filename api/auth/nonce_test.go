@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestMemoryNonceStore(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	store := NewMemoryNonceStore(2)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	fresh, err := store.Claim(ctx, "payouts", "a", now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, fresh)
+	fresh, err = store.Claim(ctx, "payouts", "a", now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, fresh)
+	//Nonces are per client
+	fresh, err = store.Claim(ctx, "billing", "a", now.Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, fresh)
+
+	_, err = store.Claim(ctx, "payouts", "b", now.Add(time.Minute))
+	assert.ErrorIs(t, err, ErrNonceCacheFull)
+
+	//Expired nonces free their slot
+	now = now.Add(time.Minute)
+	fresh, err = store.Claim(ctx, "payouts", "b", now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, fresh)
+	fresh, err = store.Claim(ctx, "billing", "a", now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, fresh)
+}
+
+func TestDBNonceStore(t *testing.T) {
+	sqlClaimNonce := regexp.QuoteMeta("INSERT INTO request_nonces(client_id,nonce,expires_at) VALUES ($1,$2,$3) " +
+		"ON CONFLICT (client_id,nonce) DO UPDATE SET expires_at=EXCLUDED.expires_at WHERE request_nonces.expires_at<NOW()")
+	expiresAt := time.Date(2026, 1, 2, 3, 9, 5, 0, time.UTC)
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec(sqlClaimNonce).WithArgs("payouts", "a", expiresAt).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(sqlClaimNonce).WithArgs("payouts", "a", expiresAt).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(sqlClaimNonce).WithArgs("payouts", "b", expiresAt).WillReturnError(errors.New("connection refused"))
+
+	store := NewDBNonceStore(db)
+	fresh, err := store.Claim(context.Background(), "payouts", "a", expiresAt)
+	assert.NoError(t, err)
+	assert.True(t, fresh)
+	fresh, err = store.Claim(context.Background(), "payouts", "a", expiresAt)
+	assert.NoError(t, err)
+	assert.False(t, fresh)
+	_, err = store.Claim(context.Background(), "payouts", "b", expiresAt)
+	assert.ErrorContains(t, err, "unable to record nonce due to :connection refused")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
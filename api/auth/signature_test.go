@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testClientSecret = "0123456789abcdef0123456789abcdef"
+
+func writeClients(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "clients.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestSignatureVerifier(t *testing.T) {
+	clientsFile := writeClients(t, "clients:\n  - id: payouts\n    secret: "+testClientSecret+"\n    scopes: [transactions:write]\n")
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	const body = `{"source_account_id":1,"destination_account_id":2,"amount":"10"}`
+	signed := func(nonce string, signedAt time.Time) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(body))
+		SignRequest(req, "payouts", testClientSecret, []byte(body), signedAt, nonce)
+		return req
+	}
+
+	tests := []struct {
+		name        string
+		req         func() *http.Request
+		expectedErr error
+	}{
+		{
+			name: "valid signature",
+			req:  func() *http.Request { return signed("nonce-1", now) },
+		},
+		{
+			name: "timestamp within the window",
+			req:  func() *http.Request { return signed("nonce-2", now.Add(-4*time.Minute)) },
+		},
+		{
+			name:        "reused nonce",
+			req:         func() *http.Request { return signed("nonce-1", now) },
+			expectedErr: ErrNonceReused,
+		},
+		{
+			name:        "stale timestamp",
+			req:         func() *http.Request { return signed("nonce-3", now.Add(-6*time.Minute)) },
+			expectedErr: ErrStaleTimestamp,
+		},
+		{
+			name:        "timestamp in the future",
+			req:         func() *http.Request { return signed("nonce-4", now.Add(6*time.Minute)) },
+			expectedErr: ErrStaleTimestamp,
+		},
+		{
+			name: "tampered body",
+			req: func() *http.Request {
+				req := signed("nonce-5", now)
+				req.Body = io.NopCloser(strings.NewReader(strings.Replace(body, `"10"`, `"1000"`, 1)))
+				return req
+			},
+			expectedErr: ErrInvalidSignature,
+		},
+		{
+			name: "other path",
+			req: func() *http.Request {
+				req := signed("nonce-6", now)
+				req.URL.Path = "/transactions/batch"
+				return req
+			},
+			expectedErr: ErrInvalidSignature,
+		},
+		{
+			name: "unknown client",
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(body))
+				SignRequest(req, "intruder", testClientSecret, []byte(body), now, "nonce-7")
+				return req
+			},
+			expectedErr: ErrInvalidSignature,
+		},
+		{
+			name: "missing nonce",
+			req: func() *http.Request {
+				req := signed("nonce-8", now)
+				req.Header.Del(SignatureNonceHeader)
+				return req
+			},
+			expectedErr: ErrInvalidSignature,
+		},
+		{
+			name: "body over the limit",
+			req: func() *http.Request {
+				big := strings.Repeat("x", 1025)
+				req := httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(big))
+				SignRequest(req, "payouts", testClientSecret, []byte(big), now, "nonce-9")
+				return req
+			},
+			expectedErr: ErrBodyTooLarge,
+		},
+	}
+
+	nonces := NewMemoryNonceStore(10)
+	nonces.now = func() time.Time { return now }
+	verifier, err := NewSignatureVerifier(SignatureOptions{ClientsFile: clientsFile, MaxSkew: 5 * time.Minute, MaxBodyBytes: 1024}, nonces)
+	assert.NoError(t, err)
+	verifier.now = func() time.Time { return now }
+
+	//The cases run in order, "reused nonce" replays the first one
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req()
+			principal, err := verifier.Verify(req)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Nil(t, principal)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, &Principal{ID: "payouts", Name: "payouts", Method: MethodSignature, Scopes: []string{ScopeTransactionsWrite}}, principal)
+			//The handler can still read the body
+			b, err := io.ReadAll(req.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, body, string(b))
+		})
+	}
+}
+
+func TestNewSignatureVerifierErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		clients     string
+		expectedErr string
+	}{
+		{name: "short secret", clients: "clients:\n  - id: payouts\n    secret: short\n", expectedErr: `client "payouts" secret must be at least 32 characters`},
+		{name: "unknown scope", clients: "clients:\n  - id: payouts\n    secret: " + testClientSecret + "\n    scopes: [everything]\n", expectedErr: `client "payouts" has unknown scope "everything"`},
+		{name: "duplicate client", clients: "clients:\n  - id: a\n    secret: " + testClientSecret + "\n  - id: a\n    secret: " + testClientSecret + "\n", expectedErr: `duplicate client "a"`},
+		{name: "no clients", clients: "clients: []\n", expectedErr: "no clients"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSignatureVerifier(SignatureOptions{ClientsFile: writeClients(t, tt.clients)}, NewMemoryNonceStore(1))
+			assert.ErrorContains(t, err, tt.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,228 @@
+// Package breaker guards the database with a circuit breaker. It wraps the driver's connector, so only failures to
+// connect count against the database: statements failing on a working connection, e.g. a transfer refused for
+// insufficient funds, never trip it.
+package breaker
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"aeshanw.com/accountApi/api/logging"
+	"aeshanw.com/accountApi/api/metrics"
+)
+
+// ErrCircuitOpen is returned instead of connecting while the circuit is open
+var ErrCircuitOpen = errors.New("database circuit breaker open")
+
+// Circuit states
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half_open"
+)
+
+type Options struct {
+	// FailureThreshold is how many connection attempts in a row must fail to open the circuit
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before a probe is let through
+	OpenTimeout time.Duration
+	// ProbeTimeout bounds each probe's connect and ping
+	ProbeTimeout time.Duration
+	Logger       *slog.Logger
+}
+
+// Breaker is a driver.Connector that stops connecting once FailureThreshold connection attempts in a row failed.
+// While open every connection attempt fails at once with ErrCircuitOpen. After OpenTimeout it half-opens and probes
+// the database with a connection of its own, closing on success and opening for another OpenTimeout otherwise.
+type Breaker struct {
+	connector driver.Connector
+	opts      Options
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	now      func() time.Time
+}
+
+func New(connector driver.Connector, opts Options) *Breaker {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	b := &Breaker{connector: connector, opts: opts, state: StateClosed, now: time.Now}
+	metrics.SetDBCircuitState(StateClosed)
+	return b
+}
+
+// Connect connects through the wrapped connector unless the circuit is open
+func (b *Breaker) Connect(ctx context.Context) (driver.Conn, error) {
+	if !b.Allow() {
+		markUnavailable(ctx)
+		return nil, ErrCircuitOpen
+	}
+	conn, err := b.connector.Connect(ctx)
+	//A caller giving up is no sign of the database being down, a deadline passing while connecting is
+	if err != nil && !errors.Is(ctx.Err(), context.Canceled) {
+		markUnavailable(ctx)
+		b.recordFailure(err)
+	} else if err == nil {
+		b.recordSuccess()
+	}
+	return conn, err
+}
+
+func (b *Breaker) Driver() driver.Driver {
+	return b.connector.Driver()
+}
+
+// Allow reports whether the database may be used, counting a rejection when it may not
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateClosed {
+		return true
+	}
+	metrics.RecordDBCircuitRejection()
+	return false
+}
+
+// State is the circuit's current state
+func (b *Breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// RetryAfter is how long until the next probe, zero while the circuit is closed
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateClosed:
+		return 0
+	case StateHalfOpen:
+		return b.opts.ProbeTimeout
+	}
+	return max(0, b.openedAt.Add(b.opts.OpenTimeout).Sub(b.now()))
+}
+
+// Check is a readiness check failing while the circuit is not closed
+func (b *Breaker) Check(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateOpen:
+		return fmt.Errorf("circuit open since %s after %d connection failures", b.openedAt.UTC().Format(time.RFC3339), b.failures)
+	case StateHalfOpen:
+		return errors.New("circuit half open, probing the database")
+	}
+	return nil
+}
+
+func (b *Breaker) recordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+}
+
+func (b *Breaker) recordFailure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != StateClosed {
+		return
+	}
+	b.failures++
+	if b.failures < b.opts.FailureThreshold {
+		return
+	}
+	b.open()
+	metrics.RecordDBCircuitTrip()
+	b.opts.Logger.Error("database circuit opened", slog.Int("failures", b.failures), slog.String(logging.KeyError, err.Error()))
+	go b.probeLoop()
+}
+
+// open must be called with mu held
+func (b *Breaker) open() {
+	b.state = StateOpen
+	b.openedAt = b.now()
+	metrics.SetDBCircuitState(StateOpen)
+}
+
+// probeLoop half-opens the circuit every OpenTimeout and probes the database until a probe succeeds
+func (b *Breaker) probeLoop() {
+	for {
+		time.Sleep(b.opts.OpenTimeout)
+
+		b.mu.Lock()
+		b.state = StateHalfOpen
+		metrics.SetDBCircuitState(StateHalfOpen)
+		b.mu.Unlock()
+
+		err := b.probe()
+
+		b.mu.Lock()
+		if err == nil {
+			b.state = StateClosed
+			b.failures = 0
+			metrics.SetDBCircuitState(StateClosed)
+			b.mu.Unlock()
+			b.opts.Logger.Info("database circuit closed")
+			return
+		}
+		b.open()
+		b.mu.Unlock()
+		b.opts.Logger.Warn("database circuit probe failed", slog.String(logging.KeyError, err.Error()))
+	}
+}
+
+// probe opens a connection of its own and pings the database through it
+func (b *Breaker) probe() error {
+	ctx, cancel := context.WithTimeout(context.Background(), b.opts.ProbeTimeout)
+	defer cancel()
+	conn, err := b.connector.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if pinger, ok := conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+type trackerKey struct{}
+
+type tracker struct {
+	mu          sync.Mutex
+	unavailable bool
+}
+
+// Track returns a context recording whether the database could not be reached by the work done under it
+func Track(ctx context.Context) context.Context {
+	return context.WithValue(ctx, trackerKey{}, &tracker{})
+}
+
+// Unavailable reports whether a connection attempt made under ctx, a context from Track, failed or was refused by
+// the breaker
+func Unavailable(ctx context.Context) bool {
+	t, ok := ctx.Value(trackerKey{}).(*tracker)
+	if !ok {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.unavailable
+}
+
+func markUnavailable(ctx context.Context) {
+	if t, ok := ctx.Value(trackerKey{}).(*tracker); ok {
+		t.mu.Lock()
+		t.unavailable = true
+		t.mu.Unlock()
+	}
+}
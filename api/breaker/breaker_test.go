@@ -0,0 +1,126 @@
+package breaker
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errRefused = errors.New("dial tcp: connection refused")
+
+type fakeConn struct{ driver.Conn }
+
+func (fakeConn) Close() error { return nil }
+
+// fakeConnector fails while down is set
+type fakeConnector struct {
+	mu       sync.Mutex
+	down     bool
+	attempts int
+}
+
+func (c *fakeConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attempts++
+	if c.down {
+		return nil, errRefused
+	}
+	return fakeConn{}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver { return nil }
+
+func (c *fakeConnector) setDown(down bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.down = down
+}
+
+func (c *fakeConnector) attemptCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.attempts
+}
+
+func TestBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	connector := &fakeConnector{down: true}
+	b := New(connector, Options{FailureThreshold: 3, OpenTimeout: time.Hour, ProbeTimeout: time.Second})
+
+	for i := 0; i < 2; i++ {
+		_, err := b.Connect(context.Background())
+		assert.ErrorIs(t, err, errRefused)
+	}
+	//A success in between starts the count over
+	connector.setDown(false)
+	_, err := b.Connect(context.Background())
+	assert.NoError(t, err)
+	connector.setDown(true)
+	for i := 0; i < 2; i++ {
+		b.Connect(context.Background())
+	}
+	assert.Equal(t, StateClosed, b.State())
+	assert.NoError(t, b.Check(context.Background()))
+
+	_, err = b.Connect(context.Background())
+	assert.ErrorIs(t, err, errRefused)
+	assert.Equal(t, StateOpen, b.State())
+	assert.Error(t, b.Check(context.Background()))
+
+	//While open the database is not tried at all
+	attempts := connector.attemptCount()
+	_, err = b.Connect(context.Background())
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, attempts, connector.attemptCount())
+	assert.False(t, b.Allow())
+	assert.InDelta(t, time.Hour.Seconds(), b.RetryAfter().Seconds(), 1)
+}
+
+func TestBreakerIgnoresCancelledCallers(t *testing.T) {
+	b := New(&fakeConnector{down: true}, Options{FailureThreshold: 1, OpenTimeout: time.Hour, ProbeTimeout: time.Second})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := b.Connect(ctx)
+	assert.ErrorIs(t, err, errRefused)
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func TestBreakerProbesUntilTheDatabaseIsBack(t *testing.T) {
+	connector := &fakeConnector{down: true}
+	b := New(connector, Options{FailureThreshold: 1, OpenTimeout: 10 * time.Millisecond, ProbeTimeout: time.Second})
+
+	b.Connect(context.Background())
+	assert.Equal(t, StateOpen, b.State())
+
+	//Failing probes keep it open
+	assert.Eventually(t, func() bool { return connector.attemptCount() >= 3 }, time.Second, time.Millisecond)
+	assert.NotEqual(t, StateClosed, b.State())
+
+	connector.setDown(false)
+	assert.Eventually(t, func() bool { return b.State() == StateClosed }, time.Second, time.Millisecond)
+	_, err := b.Connect(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestTrackMarksUnavailable(t *testing.T) {
+	b := New(&fakeConnector{down: true}, Options{FailureThreshold: 1, OpenTimeout: time.Hour, ProbeTimeout: time.Second})
+
+	assert.False(t, Unavailable(context.Background()))
+
+	ctx := Track(context.Background())
+	assert.False(t, Unavailable(ctx))
+	b.Connect(ctx)
+	assert.True(t, Unavailable(ctx))
+
+	//Refused by the open circuit
+	ctx = Track(context.Background())
+	_, err := b.Connect(ctx)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.True(t, Unavailable(ctx))
+}
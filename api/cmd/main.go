@@ -1,60 +1,372 @@
 package main
 
 import (
-	"database/sql"
-	"log"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"flag"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/XSAM/otelsql"
+	"github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 
+	"aeshanw.com/accountApi/api/admission"
+	"aeshanw.com/accountApi/api/auth"
+	"aeshanw.com/accountApi/api/breaker"
+	"aeshanw.com/accountApi/api/config"
 	"aeshanw.com/accountApi/api/handlers"
+	"aeshanw.com/accountApi/api/health"
+	"aeshanw.com/accountApi/api/ledger"
+	"aeshanw.com/accountApi/api/logging"
+	"aeshanw.com/accountApi/api/metrics"
+	"aeshanw.com/accountApi/api/ratelimit"
+	apikeyservice "aeshanw.com/accountApi/api/services/APIKeyService"
 	accountservice "aeshanw.com/accountApi/api/services/AccountService"
 	transactionservice "aeshanw.com/accountApi/api/services/TransactionService"
+	transferjobservice "aeshanw.com/accountApi/api/services/TransferJobService"
+	"aeshanw.com/accountApi/api/tracing"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 )
 
+// schemaVersion is the version of initdb/init.sql this build needs, checked by /readyz
+const schemaVersion = 9
+
+// fatal logs the error and exits, slog has no Fatal level
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, slog.String(logging.KeyError, err.Error()))
+	os.Exit(1)
+}
+
 func main() {
-	connStr := os.Getenv("DB_URL")
-	if connStr == "" {
-		log.Fatal("DB_URL is empty")
+	//Settings come from defaults, then -config/CONFIG_FILE, then env vars, then flags, see config.Config
+	cfg, printOnly, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fatal(slog.Default(), "invalid config", err)
+	}
+	if printOnly {
+		cfg.Print(os.Stdout)
+		return
+	}
+
+	logger := logging.New(os.Stdout, cfg.LoggingOptions())
+	slog.SetDefault(logger)
+	logger.Info("config loaded", slog.Any("config", cfg))
+
+	shutdownTracing, err := tracing.Setup(cfg.TracingOptions())
+	if err != nil {
+		fatal(logger, "unable to set up tracing", err)
 	}
 
 	// Connect to database
-	db, err := sql.Open("postgres", connStr)
+	connector, err := pq.NewConnector(cfg.DB.URL)
 	if err != nil {
-		log.Fatal(err)
+		fatal(logger, "unable to open database", err)
+	}
+	//Once connections keep failing the circuit opens, requests are then answered with a 503 without waiting on the
+	//database until a probe reaches it again
+	var dbBreaker *breaker.Breaker
+	var dbConnector driver.Connector = connector
+	if cfg.DB.Breaker.Enabled {
+		dbBreaker = breaker.New(connector, breaker.Options{
+			FailureThreshold: cfg.DB.Breaker.FailureThreshold,
+			OpenTimeout:      cfg.DB.Breaker.OpenTimeout,
+			ProbeTimeout:     cfg.DB.Breaker.ProbeTimeout,
+			Logger:           logger.With(slog.String("component", "db-breaker")),
+		})
+		dbConnector = dbBreaker
+	}
+	// Every statement gets a span, the statement text is recorded but never its arguments
+	db := otelsql.OpenDB(dbConnector,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{OmitConnResetSession: true, OmitRows: true, DisableErrSkip: true}),
+	)
+	db.SetMaxOpenConns(cfg.DB.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.DB.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DB.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.DB.ConnMaxIdleTime)
+	if cfg.Features.Metrics {
+		metrics.RegisterDB(db, "postgres")
+	}
+
+	//The API still starts without the database, /readyz stays unready until it can be reached
+	pingCtx, cancelPing := context.WithTimeout(context.Background(), health.DefaultCheckTimeout)
+	if err := db.PingContext(pingCtx); err != nil {
+		logger.Warn("database unreachable at startup", slog.String(logging.KeyError, err.Error()))
+	}
+	cancelPing()
+
+	//account_id_mode=generated makes the API allocate account numbers in account_number_format, e.g. 10NNNNNNNNCC
+	accountNumbers, err := accountservice.AccountNumberFormatForMode(cfg.Features.AccountIDMode, cfg.Features.AccountNumberFormat)
+	if err != nil {
+		fatal(logger, "invalid account number config", err)
 	}
 
 	as := accountservice.NewAccountService()
+	ts := transactionservice.NewTransactionService()
+	if accountNumbers != nil {
+		as = accountservice.NewAccountServiceWithAccountNumbers(accountNumbers)
+		ts = transactionservice.NewTransactionServiceWithAccountNumbers(accountNumbers)
+	}
+	ts.SetIsolationLevel(cfg.IsolationLevel())
 	accHandler := handlers.NewAccountHandler(db, as)
 
-	ts := transactionservice.NewTransactionService()
 	trHandler := handlers.NewTransactionHandler(db, ts)
 
+	tjs := transferjobservice.NewTransferJobService(ts)
+	tjs.SetIsolationLevel(cfg.IsolationLevel())
+	akHandler := handlers.NewAPIKeyHandler(db, apikeyservice.NewAPIKeyService())
+	if cfg.Auth.JWT.Enabled() {
+		jwtVerifier, err := auth.NewJWTVerifier(cfg.JWTOptions())
+		if err != nil {
+			fatal(logger, "unable to load JWT verification keys", err)
+		}
+		akHandler.SetJWTVerifier(jwtVerifier)
+	}
+	if cfg.Auth.Signing.ClientsFile != "" {
+		var nonces auth.NonceStore = auth.NewMemoryNonceStore(cfg.Auth.Signing.NonceCacheSize)
+		if cfg.Auth.Signing.NonceStore == config.NonceStoreDB {
+			nonces = auth.NewDBNonceStore(db)
+		}
+		signatureVerifier, err := auth.NewSignatureVerifier(cfg.SignatureOptions(), nonces)
+		if err != nil {
+			fatal(logger, "unable to load signing clients", err)
+		}
+		akHandler.SetSignatureVerifier(signatureVerifier)
+	}
+
+	tjHandler := handlers.NewTransferJobHandlerWithUploadLimit(db, tjs, cfg.Limits.MaxUploadBytes)
+	alHandler := handlers.NewAuditLogHandler(db)
+
+	checker := health.NewChecker(health.DefaultCheckTimeout)
+	checker.Add("database", health.PingCheck(db))
+	checker.Add("migrations", health.SchemaVersionCheck(db, schemaVersion))
+	if dbBreaker != nil {
+		checker.Add("database_circuit", dbBreaker.Check)
+	}
+
+	//Processes uploaded bulk transfer files in the background, instances with the worker disabled only accept uploads.
+	//An interrupted job keeps its lease and is resumed from its last processed row.
+	workerCtx, stopWorker := context.WithCancel(logging.WithLogger(context.Background(), logger.With(slog.String("component", "transfer-job-worker"))))
+	workerDone := make(chan struct{})
+	if cfg.Worker.Enabled {
+		worker := transferjobservice.NewWorker(db, tjs, cfg.Worker.PollInterval)
+		checker.Add("transfer_job_worker", worker.Check)
+		go func() {
+			defer close(workerDone)
+			worker.Run(workerCtx)
+		}()
+	} else {
+		close(workerDone)
+	}
+
+	//Links transactions missing from the chain, e.g. those created before it existed, and signs the chain head
+	var checkpointSigner *ledger.Signer
+	if cfg.Ledger.CheckpointKeyFile != "" {
+		checkpointSigner, err = ledger.LoadSigner(cfg.Ledger.CheckpointKeyFile)
+		if err != nil {
+			fatal(logger, "unable to load checkpoint signing key", err)
+		}
+		logger.Info("transaction chain checkpoints enabled", slog.String("key_id", checkpointSigner.KeyID()))
+	} else {
+		logger.Warn("ledger.checkpoint_key_file not set, the transaction chain head is not signed")
+	}
+	checkpointCtx, stopCheckpointer := context.WithCancel(logging.WithLogger(context.Background(), logger.With(slog.String("component", "ledger-checkpointer"))))
+	checkpointerDone := make(chan struct{})
+	checkpointer := ledger.NewCheckpointer(db, checkpointSigner, cfg.Ledger.CheckpointInterval)
+	checker.Add("ledger_checkpointer", checkpointer.Check)
+	go func() {
+		defer close(checkpointerDone)
+		checkpointer.Run(checkpointCtx)
+	}()
+
+	//Keeps point-in-time balance queries to the transfers since a recent snapshot
+	snapshotCtx, stopSnapshotter := context.WithCancel(logging.WithLogger(context.Background(), logger.With(slog.String("component", "balance-snapshotter"))))
+	snapshotterDone := make(chan struct{})
+	snapshotter := accountservice.NewBalanceSnapshotter(db, cfg.Balances.SnapshotInterval)
+	checker.Add("balance_snapshotter", snapshotter.Check)
+	go func() {
+		defer close(snapshotterDone)
+		snapshotter.Run(snapshotCtx)
+	}()
+
 	r := chi.NewRouter()
 	// A good base middleware stack
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
+	r.Use(tracing.Middleware)
+	r.Use(logging.Middleware(logger))
+	if cfg.Features.Metrics {
+		r.Use(metrics.Middleware)
+	}
 	r.Use(middleware.Recoverer)
 	r.Use(render.SetContentType(render.ContentTypeJSON))
 
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("welcome")) //This is just to test the site-uptime
 	})
-	// RESTy routes for "accounts" resource
-	r.Route("/accounts", func(r chi.Router) {
-		r.Post("/", accHandler.CreateAccount)                // POST /accounts
-		r.Get("/{account_id}", accHandler.GetAccountDetails) // GET /accounts/{account_id}
-	})
+	r.Get("/healthz", health.Healthz) // liveness probe
+	r.Get("/readyz", checker.Readyz)  // readiness probe
+	if cfg.Features.Metrics {
+		r.Method(http.MethodGet, "/metrics", metrics.Handler()) // Prometheus scrape endpoint
+	}
+	//JSON bodies and uploaded files have separate size limits
+	jsonBody := middleware.RequestSize(cfg.Limits.MaxRequestBodyBytes)
+	upload := middleware.RequestSize(cfg.Limits.MaxUploadBytes)
+	//Each route's deadline reaches its SQL calls, a request running out of time is rolled back and answered with a 504
+	defaultTimeout := handlers.Timeout(cfg.Timeouts.Default)
+	transferTimeout := handlers.Timeout(cfg.Timeouts.Transfer)
+	uploadTimeout := handlers.Timeout(cfg.Timeouts.Upload)
+	//Stands in for middleware turned off by config
+	passthrough := func(next http.Handler) http.Handler { return next }
+	//Every API route declares the scope its caller needs, auth.enabled=false turns the checks off for local development
+	requireScope := handlers.RequireScope
+	if !cfg.Auth.Enabled {
+		logger.Warn("authentication disabled, every API route is open")
+		requireScope = func(string) func(http.Handler) http.Handler { return passthrough }
+	}
+	accountsRead := requireScope(auth.ScopeAccountsRead)
+	accountsWrite := requireScope(auth.ScopeAccountsWrite)
+	transactionsRead := requireScope(auth.ScopeTransactionsRead)
+	transactionsWrite := requireScope(auth.ScopeTransactionsWrite)
+	admin := requireScope(auth.ScopeAdmin)
+	//Bearer token callers are limited to the accounts they own, the routes spanning every account are closed to them
+	unrestricted := handlers.RequireUnrestricted
+	//Services may be required to sign their transfers, guarding them against tampering and replay
+	signedTransfers := passthrough
+	if cfg.Auth.Enabled && cfg.Auth.Signing.RequiredForTransfers {
+		signedTransfers = handlers.RequireSignature
+	}
+
+	//Each client has a token bucket per budget, keyed by its authenticated caller or else its IP
+	reads, writes, transfers, bulk, transferConcurrency := passthrough, passthrough, passthrough, passthrough, passthrough
+	if cfg.RateLimit.Enabled {
+		var store ratelimit.Store = ratelimit.NewMemoryStore()
+		if cfg.RateLimit.Store == config.RateLimitStoreDB {
+			store = ratelimit.NewDBStore(db)
+		}
+		reads = handlers.RateLimit(store, ratelimit.BudgetReads, cfg.RateLimitFor(ratelimit.BudgetReads))
+		writes = handlers.RateLimit(store, ratelimit.BudgetWrites, cfg.RateLimitFor(ratelimit.BudgetWrites))
+		transfers = handlers.RateLimit(store, ratelimit.BudgetTransfers, cfg.RateLimitFor(ratelimit.BudgetTransfers))
+		bulk = handlers.RateLimit(store, ratelimit.BudgetBulk, cfg.RateLimitFor(ratelimit.BudgetBulk))
+		if cfg.RateLimit.TransferConcurrency > 0 {
+			transferConcurrency = handlers.ConcurrencyLimit(ratelimit.NewConcurrencyLimiter(cfg.RateLimit.TransferConcurrency))
+		}
+	}
+
+	//Write requests over the admission limit queue briefly, then are shed with a 503 instead of piling up behind the
+	//transfer lock and the DB pool
+	admit := passthrough
+	if cfg.Admission.Enabled {
+		admit = handlers.Admit(admission.NewQueue("writes", cfg.Admission.MaxConcurrent, cfg.Admission.MaxQueue, cfg.Admission.MaxWait))
+	}
 
-	r.Route("/transactions", func(r chi.Router) {
-		r.Post("/", trHandler.CreateTransaction) // POST /transactions
+	r.Group(func(r chi.Router) {
+		if dbBreaker != nil {
+			r.Use(handlers.CircuitBreaker(dbBreaker))
+		}
+		if cfg.Auth.Enabled {
+			r.Use(akHandler.Authenticate)
+		}
+		//Every change is audited with the caller, request ID and client IP
+		r.Use(handlers.AuditSource)
+
+		// RESTy routes for "accounts" resource
+		r.Route("/accounts", func(r chi.Router) {
+			r.With(accountsWrite, unrestricted, writes, admit, defaultTimeout, jsonBody).Post("/", accHandler.CreateAccount)   // POST /accounts
+			r.With(accountsRead, unrestricted, reads, defaultTimeout).Get("/", accHandler.ListAccounts)                        // GET /accounts
+			r.With(accountsWrite, unrestricted, bulk, admit, uploadTimeout, upload).Post("/import", accHandler.ImportAccounts) // POST /accounts/import
+			r.With(accountsRead, reads, defaultTimeout).Get("/{account_id}", accHandler.GetAccountDetails)                     // GET /accounts/{account_id}
+			r.With(accountsWrite, writes, admit, defaultTimeout, jsonBody).Patch("/{account_id}", accHandler.UpdateAccount)    // PATCH /accounts/{account_id}
+			r.With(accountsRead, reads, defaultTimeout).Get("/{account_id}/balance", accHandler.GetAccountBalance)             // GET /accounts/{account_id}/balance?as_of=
+		})
+
+		r.Route("/transactions", func(r chi.Router) {
+			r.With(transactionsWrite, signedTransfers, transfers, transferConcurrency, admit, transferTimeout, jsonBody).Post("/", trHandler.CreateTransaction)           // POST /transactions
+			r.With(transactionsRead, unrestricted, reads, defaultTimeout).Get("/", trHandler.SearchTransactions)                                                          // GET /transactions?reference=
+			r.With(transactionsRead, reads, defaultTimeout).Get("/{transaction_id}", trHandler.GetTransaction)                                                            // GET /transactions/{transaction_id}
+			r.With(transactionsRead, reads, defaultTimeout).Get("/{transaction_id}/proof", trHandler.GetTransactionProof)                                                 // GET /transactions/{transaction_id}/proof
+			r.With(transactionsWrite, signedTransfers, transfers, transferConcurrency, admit, transferTimeout, jsonBody).Post("/batch", trHandler.CreateBatchTransaction) // POST /transactions/batch
+			r.With(transactionsWrite, unrestricted, bulk, admit, uploadTimeout).Post("/bulk", tjHandler.CreateTransferJob)                                                // POST /transactions/bulk
+			r.With(transactionsRead, unrestricted, reads, defaultTimeout).Get("/bulk/{job_id}", tjHandler.GetTransferJob)                                                 // GET /transactions/bulk/{job_id}
+			r.With(transactionsRead, unrestricted, reads, uploadTimeout).Get("/bulk/{job_id}/result", tjHandler.GetTransferJobResult)                                     // GET /transactions/bulk/{job_id}/result
+		})
+
+		r.Route("/admin/api-keys", func(r chi.Router) {
+			r.Use(admin, defaultTimeout)
+			r.With(jsonBody).Post("/", akHandler.CreateAPIKey) // POST /admin/api-keys
+			r.Get("/", akHandler.ListAPIKeys)                  // GET /admin/api-keys
+			r.Delete("/{key_id}", akHandler.RevokeAPIKey)      // DELETE /admin/api-keys/{key_id}
+		})
+
+		r.With(admin, reads, defaultTimeout).Get("/admin/audit-log", alHandler.ListAuditLog) // GET /admin/audit-log
 	})
 
-	log.Println("API running at :3000 port")
-	http.ListenAndServe(":3000", r)
+	srv := &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           r,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Limits.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+
+	//On SIGTERM/SIGINT report unready first, then stop accepting connections and let in-flight requests finish
+	signalCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		<-signalCtx.Done()
+		checker.SetShuttingDown()
+		logger.Info("shutting down", slog.Duration("readiness_drain_delay", cfg.Server.ReadinessDrainDelay))
+		time.Sleep(cfg.Server.ReadinessDrainDelay)
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			logger.Error("unable to drain in-flight requests", slog.String(logging.KeyError, err.Error()))
+			//Whatever is still running is cut off, its DB transactions roll back
+			srv.Close()
+		}
+	}()
+
+	logger.Info("API running", slog.String("addr", srv.Addr))
+	listenErr := srv.ListenAndServe()
+	if errors.Is(listenErr, http.ErrServerClosed) {
+		//ListenAndServe returns as soon as Shutdown starts, wait for the in-flight requests
+		<-drained
+		listenErr = nil
+	} else {
+		logger.Error("API listener failed", slog.String(logging.KeyError, listenErr.Error()))
+	}
+
+	//Requests are done, now the background work and the resources they share
+	stopWorker()
+	stopCheckpointer()
+	stopSnapshotter()
+	<-workerDone
+	<-checkpointerDone
+	<-snapshotterDone
+	if err := db.Close(); err != nil {
+		logger.Error("unable to close database", slog.String(logging.KeyError, err.Error()))
+	}
+	shutdownTracing(context.Background())
+
+	if listenErr != nil {
+		os.Exit(1)
+	}
+	logger.Info("API stopped")
 }
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"aeshanw.com/accountApi/api/handlers"
+	"aeshanw.com/accountApi/api/logging"
+	accountservice "aeshanw.com/accountApi/api/services/AccountService"
+	transactionservice "aeshanw.com/accountApi/api/services/TransactionService"
+	transferjobservice "aeshanw.com/accountApi/api/services/TransferJobService"
+)
+
+func runBulkTransfer(ctx context.Context, db *sql.DB, args []string) error {
+	fs := flag.NewFlagSet("bulk-transfer", flag.ContinueOnError)
+	file := fs.String("file", "", "payout CSV with columns source_account_id,destination_account_id,amount,reference")
+	out := fs.String("out", "", "where to write the result CSV (defaults to stdout)")
+	async := fs.Bool("async", false, "only queue the job for the API worker instead of processing it here")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("-file is required")
+	}
+
+	in, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	req, errRes := handlers.ParseTransferJobCSV(in)
+	if errRes != nil {
+		return errors.New(errRes.Message)
+	}
+
+	accountNumbers, err := accountservice.AccountNumberFormatForMode(os.Getenv("ACCOUNT_ID_MODE"), os.Getenv("ACCOUNT_NUMBER_FORMAT"))
+	if err != nil {
+		return err
+	}
+	ts := transactionservice.NewTransactionService()
+	if accountNumbers != nil {
+		ts = transactionservice.NewTransactionServiceWithAccountNumbers(accountNumbers)
+	}
+	tjs := transferjobservice.NewTransferJobService(ts)
+
+	job, created, err := tjs.CreateJob(ctx, db, req)
+	if err != nil {
+		return err
+	}
+	if created {
+		slog.Info("created transfer job", slog.Int64(logging.KeyJobID, job.ID), slog.Int("total_rows", job.TotalRows))
+	} else {
+		slog.Info("file was already uploaded", slog.Int64(logging.KeyJobID, job.ID), slog.String("status", job.Status))
+	}
+
+	if *async {
+		fmt.Println(job.ID)
+		return nil
+	}
+
+	//Rows are locked individually so processing here is safe even if the API worker picks up the same job
+	if err := tjs.ProcessJob(ctx, db, job.ID); err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return handlers.WriteTransferJobResultCSV(ctx, w, db, tjs, job.ID)
+}
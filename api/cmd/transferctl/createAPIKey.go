@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"strings"
+
+	"aeshanw.com/accountApi/api/handlers"
+	"aeshanw.com/accountApi/api/models"
+	apikeyservice "aeshanw.com/accountApi/api/services/APIKeyService"
+)
+
+// runCreateAPIKey issues a key directly in the database, it is how the first admin key is created
+func runCreateAPIKey(ctx context.Context, db *sql.DB, args []string) error {
+	fs := flag.NewFlagSet("create-api-key", flag.ContinueOnError)
+	name := fs.String("name", "", "who or what the key is for")
+	scopes := fs.String("scopes", "", "comma-separated scopes, e.g. accounts:read,transactions:write or admin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	req := models.CreateAPIKeyRequest{Name: *name}
+	if *scopes != "" {
+		req.Scopes = strings.Split(*scopes, ",")
+	}
+	if errRes := handlers.ValidateCreateAPIKeyRequest(req); errRes != nil {
+		return errors.New(errRes.Message)
+	}
+
+	key, rawKey, err := apikeyservice.NewAPIKeyService().CreateKey(ctx, db, req)
+	if err != nil {
+		return err
+	}
+
+	resp, err := handlers.NewAPIKeyResponse(key)
+	if err != nil {
+		return err
+	}
+	resp.Key = rawKey
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(resp)
+}
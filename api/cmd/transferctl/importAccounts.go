@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"aeshanw.com/accountApi/api/handlers"
+	accountservice "aeshanw.com/accountApi/api/services/AccountService"
+)
+
+func runImportAccounts(ctx context.Context, db *sql.DB, args []string) error {
+	fs := flag.NewFlagSet("import-accounts", flag.ContinueOnError)
+	file := fs.String("file", "", "accounts to import, CSV with columns account_id,initial_balance or NDJSON")
+	format := fs.String("format", "", "csv or ndjson (defaults to the file extension)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("-file is required")
+	}
+	if *format == "" {
+		*format = handlers.AccountImportFormatCSV
+		if ext := strings.ToLower(filepath.Ext(*file)); ext == ".ndjson" || ext == ".jsonl" {
+			*format = handlers.AccountImportFormatNDJSON
+		}
+	}
+
+	in, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	src, err := handlers.NewAccountImportSource(in, *format)
+	if err != nil {
+		return err
+	}
+
+	accountNumbers, err := accountservice.AccountNumberFormatForMode(os.Getenv("ACCOUNT_ID_MODE"), os.Getenv("ACCOUNT_NUMBER_FORMAT"))
+	if err != nil {
+		return err
+	}
+	as := accountservice.NewAccountService()
+	if accountNumbers != nil {
+		as = accountservice.NewAccountServiceWithAccountNumbers(accountNumbers)
+	}
+
+	report, err := as.ImportAccounts(ctx, db, src)
+	if err != nil {
+		return err
+	}
+
+	resp, err := handlers.NewImportAccountsResponse(report)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(resp)
+}
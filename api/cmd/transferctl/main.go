@@ -0,0 +1,91 @@
+// transferctl is the operations CLI for the transfer API. It talks to the database directly using DB_URL.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	_ "github.com/lib/pq"
+
+	"aeshanw.com/accountApi/api/audit"
+	"aeshanw.com/accountApi/api/logging"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, db *sql.DB, args []string) error
+	// offline commands run without DB_URL and get a nil db
+	offline bool
+}
+
+var commands = []command{
+	{name: "bulk-transfer", usage: "upload a payout CSV as a bulk transfer job and write its result report", run: runBulkTransfer},
+	{name: "create-api-key", usage: "issue an API key, e.g. the first admin key", run: runCreateAPIKey},
+	{name: "export-transactions", usage: "dump the transaction chain and its checkpoints as NDJSON", run: runExportTransactions},
+	{name: "import-accounts", usage: "bulk import accounts from a CSV or NDJSON file", run: runImportAccounts},
+	{name: "verify-audit-log", usage: "walk the audit log's hash chain and report its head", run: runVerifyAuditLog},
+	{name: "verify-transactions", usage: "verify an exported transaction chain offline against the checkpoint keys", run: runVerifyTransactions, offline: true},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: transferctl <command> [flags]")
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", c.name, c.usage)
+	}
+}
+
+// fatal logs the error and exits, slog has no Fatal level
+func fatal(msg string, err error) {
+	slog.Error(msg, slog.String(logging.KeyError, err.Error()))
+	os.Exit(1)
+}
+
+func main() {
+	//Logs go to stderr so stdout stays free for command output such as the result CSV
+	logOpts, err := logging.OptionsFromEnv(os.Getenv)
+	if err != nil {
+		fatal("invalid logging config", err)
+	}
+	slog.SetDefault(logging.New(os.Stderr, logOpts))
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, c := range commands {
+		if c.name != os.Args[1] {
+			continue
+		}
+
+		var db *sql.DB
+		if !c.offline {
+			connStr := os.Getenv("DB_URL")
+			if connStr == "" {
+				fatal("invalid config", errors.New("DB_URL is empty"))
+			}
+
+			db, err = sql.Open("postgres", connStr)
+			if err != nil {
+				fatal("unable to open database", err)
+			}
+			defer db.Close()
+		}
+
+		//Changes made through the CLI are audited as the OS user running it
+		ctx := audit.WithSource(context.Background(), audit.Source{Actor: "cli:" + os.Getenv("USER")})
+		if err := c.run(ctx, db, os.Args[2:]); err != nil {
+			fatal(c.name+" failed", err)
+		}
+		return
+	}
+
+	usage()
+	os.Exit(2)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"os"
+
+	"aeshanw.com/accountApi/api/audit"
+)
+
+// runVerifyAuditLog recomputes every audit record's hash in order. The head it prints should be kept somewhere the
+// database's users cannot write, a later run reporting an older head means the newest records were removed.
+func runVerifyAuditLog(ctx context.Context, db *sql.DB, args []string) error {
+	fs := flag.NewFlagSet("verify-audit-log", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	result, err := audit.Verify(ctx, db)
+	if result != nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if encErr := enc.Encode(result); encErr != nil {
+			return encErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,637 @@
+// Package config loads the API's settings from defaults, an optional YAML or JSON file, env vars and command-line
+// flags, in that order of precedence, so each environment can tune the service without a rebuild.
+//
+// Every setting is a tagged field of Config: `yaml` names it in the file, `env` is its env var and the flag is the
+// dotted yaml path with dashes, e.g. db.max_open_conns is -db-max-open-conns. Fields tagged `secret` are redacted when
+// the config is printed.
+package config
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"aeshanw.com/accountApi/api/auth"
+	"aeshanw.com/accountApi/api/logging"
+	"aeshanw.com/accountApi/api/ratelimit"
+	accountservice "aeshanw.com/accountApi/api/services/AccountService"
+	"aeshanw.com/accountApi/api/tracing"
+)
+
+// Isolation levels accepted by db.isolation_level, Postgres runs READ UNCOMMITTED as READ COMMITTED so it is not offered
+const (
+	IsolationReadCommitted  = "read_committed"
+	IsolationRepeatableRead = "repeatable_read"
+	IsolationSerializable   = "serializable"
+)
+
+var isolationLevels = map[string]sql.IsolationLevel{
+	IsolationReadCommitted:  sql.LevelReadCommitted,
+	IsolationRepeatableRead: sql.LevelRepeatableRead,
+	IsolationSerializable:   sql.LevelSerializable,
+}
+
+type Config struct {
+	Listen    string          `yaml:"listen" env:"LISTEN_ADDR" usage:"address the HTTP server listens on"`
+	DB        DBConfig        `yaml:"db"`
+	Server    ServerConfig    `yaml:"server"`
+	Timeouts  TimeoutsConfig  `yaml:"timeouts"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Log       LogConfig       `yaml:"log"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Limits    LimitsConfig    `yaml:"limits"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Admission AdmissionConfig `yaml:"admission"`
+	Worker    WorkerConfig    `yaml:"worker"`
+	Ledger    LedgerConfig    `yaml:"ledger"`
+	Balances  BalancesConfig  `yaml:"balances"`
+	Features  FeaturesConfig  `yaml:"features"`
+}
+
+type DBConfig struct {
+	URL             string        `yaml:"url" env:"DB_URL" secret:"true" usage:"Postgres connection string"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" usage:"maximum open connections, 0 is unlimited"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" usage:"maximum idle connections kept in the pool"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" usage:"maximum age of a connection, 0 is unlimited"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" usage:"maximum idle time of a connection, 0 is unlimited"`
+	IsolationLevel  string        `yaml:"isolation_level" env:"DB_ISOLATION_LEVEL" usage:"isolation level of transfer transactions: read_committed, repeatable_read or serializable"`
+	Breaker         BreakerConfig `yaml:"breaker"`
+}
+
+// BreakerConfig opens the database circuit after consecutive connection failures, requests then fail fast with a 503
+type BreakerConfig struct {
+	Enabled          bool          `yaml:"enabled" env:"DB_BREAKER_ENABLED" usage:"fail requests fast while the database cannot be reached"`
+	FailureThreshold int           `yaml:"failure_threshold" env:"DB_BREAKER_FAILURE_THRESHOLD" usage:"connection failures in a row that open the circuit"`
+	OpenTimeout      time.Duration `yaml:"open_timeout" env:"DB_BREAKER_OPEN_TIMEOUT" usage:"time the circuit stays open before the database is probed"`
+	ProbeTimeout     time.Duration `yaml:"probe_timeout" env:"DB_BREAKER_PROBE_TIMEOUT" usage:"time a probe gets to connect and ping the database"`
+}
+
+type ServerConfig struct {
+	ReadHeaderTimeout   time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" usage:"time allowed to read request headers"`
+	ReadTimeout         time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT" usage:"time allowed to read a whole request"`
+	WriteTimeout        time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" usage:"time allowed to write a response"`
+	IdleTimeout         time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" usage:"time a keep-alive connection may stay idle"`
+	ShutdownTimeout     time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" usage:"time in-flight requests get to finish on shutdown"`
+	ReadinessDrainDelay time.Duration `yaml:"readiness_drain_delay" env:"READINESS_DRAIN_DELAY" usage:"time /readyz reports shutting_down before the listener closes"`
+}
+
+// TimeoutsConfig bounds the time each group of routes may spend on a request, including its DB txn
+type TimeoutsConfig struct {
+	Default  time.Duration `yaml:"default" env:"REQUEST_TIMEOUT" usage:"deadline of API requests without a more specific timeout"`
+	Transfer time.Duration `yaml:"transfer" env:"TRANSFER_REQUEST_TIMEOUT" usage:"deadline of POST /transactions and /transactions/batch"`
+	Upload   time.Duration `yaml:"upload" env:"UPLOAD_REQUEST_TIMEOUT" usage:"deadline of account imports and bulk transfer uploads"`
+}
+
+type AuthConfig struct {
+	Enabled bool          `yaml:"enabled" env:"AUTH_ENABLED" usage:"require an API key with the route's scope on every API route"`
+	JWT     JWTConfig     `yaml:"jwt"`
+	Signing SigningConfig `yaml:"signing"`
+}
+
+// JWTConfig enables bearer tokens once a key source is set, their keys are read from local files only
+type JWTConfig struct {
+	JWKSFile       string        `yaml:"jwks_file" env:"JWT_JWKS_FILE" usage:"JWKS file with the token issuer's public keys"`
+	PublicKeysFile string        `yaml:"public_keys_file" env:"JWT_PUBLIC_KEYS_FILE" usage:"PEM file with the token issuer's public keys"`
+	HMACKey        string        `yaml:"hmac_key" env:"JWT_HMAC_KEY" secret:"true" usage:"shared key of HS256 tokens"`
+	Issuer         string        `yaml:"issuer" env:"JWT_ISSUER" usage:"required iss claim"`
+	Audience       string        `yaml:"audience" env:"JWT_AUDIENCE" usage:"required aud claim"`
+	AccountsClaim  string        `yaml:"accounts_claim" env:"JWT_ACCOUNTS_CLAIM" usage:"claim listing the account IDs the caller owns"`
+	ScopesClaim    string        `yaml:"scopes_claim" env:"JWT_SCOPES_CLAIM" usage:"claim with the caller's scopes"`
+	ClockSkew      time.Duration `yaml:"clock_skew" env:"JWT_CLOCK_SKEW" usage:"tolerated clock skew on exp and nbf"`
+}
+
+// Nonce stores accepted by auth.signing.nonce_store
+const (
+	NonceStoreMemory = "memory"
+	NonceStoreDB     = "db"
+)
+
+// SigningConfig enables HMAC signed requests once a clients file is set
+type SigningConfig struct {
+	ClientsFile          string        `yaml:"clients_file" env:"SIGNING_CLIENTS_FILE" usage:"YAML or JSON file with the id, secret and scopes of each signing client"`
+	MaxSkew              time.Duration `yaml:"max_skew" env:"SIGNING_MAX_SKEW" usage:"how far a signed request's timestamp may be from the server's clock"`
+	NonceStore           string        `yaml:"nonce_store" env:"SIGNING_NONCE_STORE" usage:"where nonces are remembered: memory (this replica only) or db (shared)"`
+	NonceCacheSize       int           `yaml:"nonce_cache_size" env:"SIGNING_NONCE_CACHE_SIZE" usage:"nonces the memory store holds, requests are refused once it is full"`
+	RequiredForTransfers bool          `yaml:"required_for_transfers" env:"SIGNING_REQUIRED_FOR_TRANSFERS" usage:"refuse unsigned API key calls to POST /transactions and /transactions/batch"`
+}
+
+// Enabled reports whether a key source is configured
+func (j JWTConfig) Enabled() bool {
+	return j.JWKSFile != "" || j.PublicKeysFile != "" || j.HMACKey != ""
+}
+
+// Rate limit stores accepted by rate_limit.store
+const (
+	RateLimitStoreMemory = "memory"
+	RateLimitStoreDB     = "db"
+)
+
+// RateLimitConfig sets each client's budgets, a budget with zero requests per minute is unlimited
+type RateLimitConfig struct {
+	Enabled             bool   `yaml:"enabled" env:"RATE_LIMIT_ENABLED" usage:"limit the requests each client may send"`
+	Store               string `yaml:"store" env:"RATE_LIMIT_STORE" usage:"where budgets are kept: memory (per replica) or db (shared by every replica)"`
+	ReadsPerMinute      int    `yaml:"reads_per_minute" env:"RATE_LIMIT_READS_PER_MINUTE" usage:"GET requests a client may send per minute"`
+	ReadsBurst          int    `yaml:"reads_burst" env:"RATE_LIMIT_READS_BURST" usage:"GET requests a client may send at once"`
+	WritesPerMinute     int    `yaml:"writes_per_minute" env:"RATE_LIMIT_WRITES_PER_MINUTE" usage:"account changes a client may send per minute"`
+	WritesBurst         int    `yaml:"writes_burst" env:"RATE_LIMIT_WRITES_BURST" usage:"account changes a client may send at once"`
+	TransfersPerMinute  int    `yaml:"transfers_per_minute" env:"RATE_LIMIT_TRANSFERS_PER_MINUTE" usage:"transfers and batches a client may send per minute"`
+	TransfersBurst      int    `yaml:"transfers_burst" env:"RATE_LIMIT_TRANSFERS_BURST" usage:"transfers and batches a client may send at once"`
+	BulkPerMinute       int    `yaml:"bulk_per_minute" env:"RATE_LIMIT_BULK_PER_MINUTE" usage:"bulk uploads and imports a client may send per minute"`
+	BulkBurst           int    `yaml:"bulk_burst" env:"RATE_LIMIT_BULK_BURST" usage:"bulk uploads and imports a client may send at once"`
+	TransferConcurrency int    `yaml:"transfer_concurrency" env:"RATE_LIMIT_TRANSFER_CONCURRENCY" usage:"transfers a client may have in flight on each replica, 0 is unlimited"`
+}
+
+// AdmissionConfig bounds the write requests served at once and those queueing behind them
+type AdmissionConfig struct {
+	Enabled       bool          `yaml:"enabled" env:"ADMISSION_ENABLED" usage:"queue write requests and shed them once the queue is full"`
+	MaxConcurrent int           `yaml:"max_concurrent" env:"ADMISSION_MAX_CONCURRENT" usage:"write requests served at once"`
+	MaxQueue      int           `yaml:"max_queue" env:"ADMISSION_MAX_QUEUE" usage:"write requests waiting for admission, further ones get a 503"`
+	MaxWait       time.Duration `yaml:"max_wait" env:"ADMISSION_MAX_WAIT" usage:"longest a write request waits for admission before a 503"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" usage:"debug, info, warn or error"`
+	Redact bool   `yaml:"redact" env:"LOG_REDACT" usage:"replace balances and amounts in logs with [REDACTED]"`
+}
+
+type TracingConfig struct {
+	Exporter    string `yaml:"exporter" env:"OTEL_TRACES_EXPORTER" usage:"none, stdout or file"`
+	File        string `yaml:"file" env:"OTEL_TRACES_FILE" usage:"file the file exporter appends spans to"`
+	ServiceName string `yaml:"service_name" env:"OTEL_SERVICE_NAME" usage:"service.name of exported spans"`
+}
+
+type LimitsConfig struct {
+	MaxHeaderBytes      int   `yaml:"max_header_bytes" env:"MAX_HEADER_BYTES" usage:"maximum size of request headers"`
+	MaxRequestBodyBytes int64 `yaml:"max_request_body_bytes" env:"MAX_REQUEST_BODY_BYTES" usage:"maximum size of a JSON request body"`
+	MaxUploadBytes      int64 `yaml:"max_upload_bytes" env:"MAX_UPLOAD_BYTES" usage:"maximum size of an uploaded account import or bulk transfer file"`
+}
+
+type WorkerConfig struct {
+	Enabled      bool          `yaml:"enabled" env:"TRANSFER_JOB_WORKER_ENABLED" usage:"process bulk transfer jobs in this instance"`
+	PollInterval time.Duration `yaml:"poll_interval" env:"TRANSFER_JOB_POLL_INTERVAL" usage:"how often the worker looks for jobs"`
+}
+
+// LedgerConfig sets how the transaction chain is checkpointed, checkpoints are only signed once a key file is set
+type LedgerConfig struct {
+	CheckpointKeyFile  string        `yaml:"checkpoint_key_file" env:"LEDGER_CHECKPOINT_KEY_FILE" usage:"PEM file with the Ed25519 private key signing chain checkpoints"`
+	CheckpointInterval time.Duration `yaml:"checkpoint_interval" env:"LEDGER_CHECKPOINT_INTERVAL" usage:"how often pending transactions are chained and the chain head is signed"`
+}
+
+// BalancesConfig sets how often balances are snapshotted for point-in-time balance queries
+type BalancesConfig struct {
+	SnapshotInterval time.Duration `yaml:"snapshot_interval" env:"BALANCE_SNAPSHOT_INTERVAL" usage:"how often the balances of changed accounts are snapshotted"`
+}
+
+type FeaturesConfig struct {
+	Metrics             bool   `yaml:"metrics" env:"METRICS_ENABLED" usage:"serve Prometheus metrics on /metrics"`
+	AccountIDMode       string `yaml:"account_id_mode" env:"ACCOUNT_ID_MODE" usage:"client (default) or generated account numbers"`
+	AccountNumberFormat string `yaml:"account_number_format" env:"ACCOUNT_NUMBER_FORMAT" usage:"format of generated account numbers, e.g. 10NNNNNNNNCC"`
+}
+
+// Default returns the settings used when nothing overrides them
+func Default() *Config {
+	return &Config{
+		Listen: ":3000",
+		DB: DBConfig{
+			MaxOpenConns:    20,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			IsolationLevel:  IsolationReadCommitted,
+			Breaker:         BreakerConfig{Enabled: true, FailureThreshold: 5, OpenTimeout: 10 * time.Second, ProbeTimeout: 2 * time.Second},
+		},
+		Server: ServerConfig{
+			ReadHeaderTimeout:   5 * time.Second,
+			ReadTimeout:         15 * time.Second,
+			WriteTimeout:        30 * time.Second,
+			IdleTimeout:         60 * time.Second,
+			ShutdownTimeout:     20 * time.Second,
+			ReadinessDrainDelay: 5 * time.Second,
+		},
+		Timeouts: TimeoutsConfig{
+			Default:  10 * time.Second,
+			Transfer: 10 * time.Second,
+			Upload:   25 * time.Second,
+		},
+		Auth: AuthConfig{
+			Enabled: true,
+			JWT:     JWTConfig{AccountsClaim: "accounts", ScopesClaim: "scope", ClockSkew: 30 * time.Second},
+			Signing: SigningConfig{MaxSkew: 5 * time.Minute, NonceStore: NonceStoreMemory, NonceCacheSize: 100000},
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:             true,
+			Store:               RateLimitStoreMemory,
+			ReadsPerMinute:      600,
+			ReadsBurst:          100,
+			WritesPerMinute:     120,
+			WritesBurst:         20,
+			TransfersPerMinute:  120,
+			TransfersBurst:      20,
+			BulkPerMinute:       6,
+			BulkBurst:           2,
+			TransferConcurrency: 4,
+		},
+		Admission: AdmissionConfig{Enabled: true, MaxConcurrent: 16, MaxQueue: 64, MaxWait: 2 * time.Second},
+		Log:       LogConfig{Level: "info", Redact: true},
+		Tracing:   TracingConfig{Exporter: tracing.ExporterNone, ServiceName: tracing.DefaultServiceName},
+		Limits: LimitsConfig{
+			MaxHeaderBytes:      64 << 10,
+			MaxRequestBodyBytes: 1 << 20,
+			MaxUploadBytes:      10 << 20,
+		},
+		Worker:   WorkerConfig{Enabled: true, PollInterval: 5 * time.Second},
+		Ledger:   LedgerConfig{CheckpointInterval: 15 * time.Minute},
+		Balances: BalancesConfig{SnapshotInterval: time.Hour},
+		Features: FeaturesConfig{
+			Metrics:             true,
+			AccountIDMode:       accountservice.AccountIDModeClient,
+			AccountNumberFormat: accountservice.DefaultAccountNumberFormat,
+		},
+	}
+}
+
+// setting is one leaf field of Config
+type setting struct {
+	key    string
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+func (c *Config) settings() []setting {
+	var settings []setting
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			key := prefix + field.Tag.Get("yaml")
+			if field.Type.Kind() == reflect.Struct {
+				walk(key+".", v.Field(i))
+				continue
+			}
+			settings = append(settings, setting{
+				key:    key,
+				env:    field.Tag.Get("env"),
+				flag:   strings.NewReplacer(".", "-", "_", "-").Replace(key),
+				usage:  field.Tag.Get("usage"),
+				secret: field.Tag.Get("secret") == "true",
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk("", reflect.ValueOf(c).Elem())
+	return settings
+}
+
+func (s setting) set(raw string) error {
+	switch s.value.Interface().(type) {
+	case string:
+		s.value.SetString(raw)
+	case bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		s.value.SetBool(b)
+	case time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return errors.New("must be a duration such as 500ms, 5s or 1m")
+		}
+		s.value.SetInt(int64(d))
+	case int, int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		s.value.SetInt(n)
+	default:
+		return fmt.Errorf("unsupported type %s", s.value.Type())
+	}
+	return nil
+}
+
+func (s setting) String() string {
+	if s.secret && !s.value.IsZero() {
+		return logging.RedactedValue
+	}
+	return fmt.Sprint(s.value.Interface())
+}
+
+// Load builds the config from args (without the program name) and env vars looked up with getenv. The config file is
+// named by -config or CONFIG_FILE; it is YAML, and as JSON is valid YAML a JSON file works too. The loaded config is
+// validated. printOnly reports -print-config, asking for the config to be printed instead of serving.
+func Load(args []string, getenv func(string) string) (cfg *Config, printOnly bool, err error) {
+	cfg = Default()
+	settings := cfg.settings()
+
+	//Flags are collected as strings first, they override the file and env vars which are applied before them
+	fs := flag.NewFlagSet("api", flag.ContinueOnError)
+	configFile := fs.String("config", getenv("CONFIG_FILE"), "YAML or JSON config file (env CONFIG_FILE)")
+	fs.BoolVar(&printOnly, "print-config", false, "print the effective config with secrets redacted and exit")
+	flagValues := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flagValues[s.flag] = fs.String(s.flag, "", fmt.Sprintf("%s (env %s, default %s)", s.usage, s.env, s))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, false, err
+	}
+
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, false, err
+		}
+	}
+
+	var errs []error
+	for _, s := range settings {
+		if raw := getenv(s.env); raw != "" {
+			if err := s.set(raw); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s %q: %w", s.env, raw, err))
+			}
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name {
+				if err := s.set(*flagValues[s.flag]); err != nil {
+					errs = append(errs, fmt.Errorf("invalid -%s %q: %w", s.flag, f.Value, err))
+				}
+			}
+		}
+	})
+	if len(errs) > 0 {
+		return nil, false, errors.Join(errs...)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, false, err
+	}
+	return cfg, printOnly, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to read config file due to :%w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	//A misspelt key would otherwise be silently ignored
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once, naming each by its config key
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(key string, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		invalid("listen", "must be host:port or :port, got %q", c.Listen)
+	}
+
+	if c.DB.URL == "" {
+		invalid("db.url", "is required")
+	}
+	if c.DB.MaxOpenConns < 0 {
+		invalid("db.max_open_conns", "must not be negative")
+	}
+	if c.DB.MaxIdleConns < 0 {
+		invalid("db.max_idle_conns", "must not be negative")
+	}
+	if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		invalid("db.max_idle_conns", "must not exceed db.max_open_conns (%d)", c.DB.MaxOpenConns)
+	}
+	if c.DB.ConnMaxLifetime < 0 {
+		invalid("db.conn_max_lifetime", "must not be negative")
+	}
+	if c.DB.ConnMaxIdleTime < 0 {
+		invalid("db.conn_max_idle_time", "must not be negative")
+	}
+	if _, ok := isolationLevels[c.DB.IsolationLevel]; !ok {
+		invalid("db.isolation_level", "must be one of %s,%s,%s, got %q", IsolationReadCommitted, IsolationRepeatableRead, IsolationSerializable, c.DB.IsolationLevel)
+	}
+
+	for key, d := range map[string]time.Duration{
+		"server.read_header_timeout": c.Server.ReadHeaderTimeout,
+		"server.read_timeout":        c.Server.ReadTimeout,
+		"server.write_timeout":       c.Server.WriteTimeout,
+		"server.idle_timeout":        c.Server.IdleTimeout,
+		"server.shutdown_timeout":    c.Server.ShutdownTimeout,
+		"worker.poll_interval":       c.Worker.PollInterval,
+	} {
+		if d <= 0 {
+			invalid(key, "must be positive")
+		}
+	}
+	//The write deadline must leave time to answer a timed-out request
+	for key, d := range map[string]time.Duration{
+		"timeouts.default":  c.Timeouts.Default,
+		"timeouts.transfer": c.Timeouts.Transfer,
+		"timeouts.upload":   c.Timeouts.Upload,
+	} {
+		if d <= 0 {
+			invalid(key, "must be positive")
+		} else if c.Server.WriteTimeout > 0 && d >= c.Server.WriteTimeout {
+			invalid(key, "must be shorter than server.write_timeout (%s)", c.Server.WriteTimeout)
+		}
+	}
+	if c.Server.ReadinessDrainDelay < 0 {
+		invalid("server.readiness_drain_delay", "must not be negative")
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.ToUpper(c.Log.Level))); err != nil {
+		invalid("log.level", "must be one of debug,info,warn,error, got %q", c.Log.Level)
+	}
+
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout:
+	case tracing.ExporterFile:
+		if c.Tracing.File == "" {
+			invalid("tracing.file", "is required when tracing.exporter is %s", tracing.ExporterFile)
+		}
+	default:
+		invalid("tracing.exporter", "must be one of %s,%s,%s, got %q", tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterFile, c.Tracing.Exporter)
+	}
+
+	if c.Auth.JWT.Enabled() {
+		//Without them a token minted for another service would be accepted
+		if c.Auth.JWT.Issuer == "" {
+			invalid("auth.jwt.issuer", "is required when JWT verification keys are set")
+		}
+		if c.Auth.JWT.Audience == "" {
+			invalid("auth.jwt.audience", "is required when JWT verification keys are set")
+		}
+		if c.Auth.JWT.AccountsClaim == "" {
+			invalid("auth.jwt.accounts_claim", "is required when JWT verification keys are set")
+		}
+		if c.Auth.JWT.ScopesClaim == "" {
+			invalid("auth.jwt.scopes_claim", "is required when JWT verification keys are set")
+		}
+	}
+	if c.Auth.JWT.ClockSkew < 0 {
+		invalid("auth.jwt.clock_skew", "must not be negative")
+	}
+
+	if c.Auth.Signing.MaxSkew <= 0 {
+		invalid("auth.signing.max_skew", "must be positive")
+	}
+	switch c.Auth.Signing.NonceStore {
+	case NonceStoreMemory, NonceStoreDB:
+	default:
+		invalid("auth.signing.nonce_store", "must be one of %s,%s, got %q", NonceStoreMemory, NonceStoreDB, c.Auth.Signing.NonceStore)
+	}
+	if c.Auth.Signing.NonceCacheSize <= 0 {
+		invalid("auth.signing.nonce_cache_size", "must be positive")
+	}
+	if c.Auth.Signing.RequiredForTransfers && c.Auth.Signing.ClientsFile == "" {
+		invalid("auth.signing.required_for_transfers", "needs auth.signing.clients_file")
+	}
+
+	switch c.RateLimit.Store {
+	case RateLimitStoreMemory, RateLimitStoreDB:
+	default:
+		invalid("rate_limit.store", "must be one of %s,%s, got %q", RateLimitStoreMemory, RateLimitStoreDB, c.RateLimit.Store)
+	}
+	for _, budget := range []string{ratelimit.BudgetReads, ratelimit.BudgetWrites, ratelimit.BudgetTransfers, ratelimit.BudgetBulk} {
+		limit := c.RateLimitFor(budget)
+		if limit.PerMinute < 0 {
+			invalid("rate_limit."+budget+"_per_minute", "must not be negative")
+		} else if !limit.Unlimited() && limit.Burst < 1 {
+			invalid("rate_limit."+budget+"_burst", "must be at least 1")
+		}
+	}
+	if c.RateLimit.TransferConcurrency < 0 {
+		invalid("rate_limit.transfer_concurrency", "must not be negative")
+	}
+
+	if c.DB.Breaker.FailureThreshold < 1 {
+		invalid("db.breaker.failure_threshold", "must be at least 1")
+	}
+	if c.DB.Breaker.OpenTimeout <= 0 {
+		invalid("db.breaker.open_timeout", "must be positive")
+	}
+	if c.DB.Breaker.ProbeTimeout <= 0 {
+		invalid("db.breaker.probe_timeout", "must be positive")
+	}
+	if c.Admission.MaxConcurrent < 1 {
+		invalid("admission.max_concurrent", "must be at least 1")
+	}
+	if c.Admission.MaxQueue < 0 {
+		invalid("admission.max_queue", "must not be negative")
+	}
+	if c.Admission.MaxWait <= 0 {
+		invalid("admission.max_wait", "must be positive")
+	}
+
+	if c.Ledger.CheckpointInterval <= 0 {
+		invalid("ledger.checkpoint_interval", "must be positive")
+	}
+	if c.Balances.SnapshotInterval <= 0 {
+		invalid("balances.snapshot_interval", "must be positive")
+	}
+
+	if c.Limits.MaxHeaderBytes <= 0 {
+		invalid("limits.max_header_bytes", "must be positive")
+	}
+	if c.Limits.MaxRequestBodyBytes <= 0 {
+		invalid("limits.max_request_body_bytes", "must be positive")
+	}
+	if c.Limits.MaxUploadBytes <= 0 {
+		invalid("limits.max_upload_bytes", "must be positive")
+	}
+
+	return errors.Join(errs...)
+}
+
+// IsolationLevel is the sql.IsolationLevel of db.isolation_level
+func (c *Config) IsolationLevel() sql.IsolationLevel {
+	return isolationLevels[c.DB.IsolationLevel]
+}
+
+// LoggingOptions converts the log settings, the config must be valid
+func (c *Config) LoggingOptions() logging.Options {
+	opts := logging.Options{Redact: c.Log.Redact}
+	opts.Level.UnmarshalText([]byte(strings.ToUpper(c.Log.Level)))
+	return opts
+}
+
+// TracingOptions converts the tracing settings
+func (c *Config) TracingOptions() tracing.Options {
+	return tracing.Options{Exporter: c.Tracing.Exporter, File: c.Tracing.File, ServiceName: c.Tracing.ServiceName}
+}
+
+// JWTOptions converts the JWT settings
+func (c *Config) JWTOptions() auth.JWTOptions {
+	return auth.JWTOptions{
+		JWKSFile:       c.Auth.JWT.JWKSFile,
+		PublicKeysFile: c.Auth.JWT.PublicKeysFile,
+		HMACSecret:     c.Auth.JWT.HMACKey,
+		Issuer:         c.Auth.JWT.Issuer,
+		Audience:       c.Auth.JWT.Audience,
+		AccountsClaim:  c.Auth.JWT.AccountsClaim,
+		ScopesClaim:    c.Auth.JWT.ScopesClaim,
+		Leeway:         c.Auth.JWT.ClockSkew,
+	}
+}
+
+// SignatureOptions converts the request signing settings
+func (c *Config) SignatureOptions() auth.SignatureOptions {
+	return auth.SignatureOptions{
+		ClientsFile:  c.Auth.Signing.ClientsFile,
+		MaxSkew:      c.Auth.Signing.MaxSkew,
+		MaxBodyBytes: c.Limits.MaxUploadBytes,
+	}
+}
+
+// RateLimitFor is the limit of one of the ratelimit budgets
+func (c *Config) RateLimitFor(budget string) ratelimit.Limit {
+	switch budget {
+	case ratelimit.BudgetReads:
+		return ratelimit.Limit{PerMinute: c.RateLimit.ReadsPerMinute, Burst: c.RateLimit.ReadsBurst}
+	case ratelimit.BudgetWrites:
+		return ratelimit.Limit{PerMinute: c.RateLimit.WritesPerMinute, Burst: c.RateLimit.WritesBurst}
+	case ratelimit.BudgetTransfers:
+		return ratelimit.Limit{PerMinute: c.RateLimit.TransfersPerMinute, Burst: c.RateLimit.TransfersBurst}
+	case ratelimit.BudgetBulk:
+		return ratelimit.Limit{PerMinute: c.RateLimit.BulkPerMinute, Burst: c.RateLimit.BulkBurst}
+	}
+	return ratelimit.Limit{}
+}
+
+// Print writes every setting as key=value, one per line, with secrets redacted
+func (c *Config) Print(w io.Writer) {
+	for _, s := range c.settings() {
+		fmt.Fprintf(w, "%s=%s\n", s.key, s)
+	}
+}
+
+// LogValue logs the config as a group of its settings with secrets redacted
+func (c *Config) LogValue() slog.Value {
+	var attrs []slog.Attr
+	for _, s := range c.settings() {
+		attrs = append(attrs, slog.String(s.key, s.String()))
+	}
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,216 @@
+package config
+
+import (
+	"bytes"
+	"database/sql"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"aeshanw.com/accountApi/api/logging"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, printOnly, err := Load(nil, func(key string) string {
+		return map[string]string{"DB_URL": "postgres://api:secret@db/accounts"}[key]
+	})
+	assert.NoError(t, err)
+	assert.False(t, printOnly)
+
+	expected := Default()
+	expected.DB.URL = "postgres://api:secret@db/accounts"
+	assert.Equal(t, expected, cfg)
+	assert.Equal(t, sql.LevelReadCommitted, cfg.IsolationLevel())
+	assert.Equal(t, logging.Options{Level: slog.LevelInfo, Redact: true}, cfg.LoggingOptions())
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := writeFile(t, "api.yaml", `
+listen: ":8080"
+db:
+  url: postgres://file@db/accounts
+  max_open_conns: 50
+  isolation_level: repeatable_read
+server:
+  write_timeout: 45s
+log:
+  level: warn
+`)
+	jsonFile := writeFile(t, "api.json", `{"listen": ":8080", "db": {"url": "postgres://file@db/accounts", "max_open_conns": 50, "isolation_level": "repeatable_read"}, "server": {"write_timeout": "45s"}, "log": {"level": "warn"}}`)
+
+	for name, file := range map[string]string{"yaml": yamlFile, "json": jsonFile} {
+		t.Run(name, func(t *testing.T) {
+			env := map[string]string{
+				"CONFIG_FILE":       file,
+				"DB_MAX_OPEN_CONNS": "40",
+				"LOG_LEVEL":         "error",
+			}
+			cfg, _, err := Load([]string{"-log-level", "debug", "-worker-enabled=false"}, func(key string) string { return env[key] })
+			assert.NoError(t, err)
+
+			//File over defaults
+			assert.Equal(t, ":8080", cfg.Listen)
+			assert.Equal(t, "postgres://file@db/accounts", cfg.DB.URL)
+			assert.Equal(t, sql.LevelRepeatableRead, cfg.IsolationLevel())
+			assert.Equal(t, 45*time.Second, cfg.Server.WriteTimeout)
+			assert.Equal(t, 15*time.Second, cfg.Server.ReadTimeout)
+			//Env over file
+			assert.Equal(t, 40, cfg.DB.MaxOpenConns)
+			//Flags over env
+			assert.Equal(t, "debug", cfg.Log.Level)
+			assert.False(t, cfg.Worker.Enabled)
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		env         map[string]string
+		expectedErr []string
+	}{
+		{
+			name:        "missing database",
+			expectedErr: []string{"db.url: is required"},
+		},
+		{
+			name: "unparsable values",
+			args: []string{"-server-read-timeout", "soon"},
+			env:  map[string]string{"DB_URL": "postgres://db", "DB_MAX_OPEN_CONNS": "many", "LOG_REDACT": "maybe"},
+			expectedErr: []string{
+				`invalid DB_MAX_OPEN_CONNS "many": must be an integer`,
+				`invalid LOG_REDACT "maybe": must be true or false`,
+				`invalid -server-read-timeout "soon": must be a duration such as 500ms, 5s or 1m`,
+			},
+		},
+		{
+			name: "every invalid setting is reported",
+			env: map[string]string{
+				"DB_URL":               "postgres://db",
+				"LISTEN_ADDR":          "3000",
+				"DB_MAX_OPEN_CONNS":    "5",
+				"DB_MAX_IDLE_CONNS":    "10",
+				"DB_ISOLATION_LEVEL":   "snapshot",
+				"HTTP_WRITE_TIMEOUT":   "0s",
+				"LOG_LEVEL":            "loud",
+				"OTEL_TRACES_EXPORTER": "file",
+				"MAX_UPLOAD_BYTES":     "0",
+			},
+			expectedErr: []string{
+				`listen: must be host:port or :port, got "3000"`,
+				"db.max_idle_conns: must not exceed db.max_open_conns (5)",
+				`db.isolation_level: must be one of read_committed,repeatable_read,serializable, got "snapshot"`,
+				"server.write_timeout: must be positive",
+				`log.level: must be one of debug,info,warn,error, got "loud"`,
+				"tracing.file: is required when tracing.exporter is file",
+				"limits.max_upload_bytes: must be positive",
+			},
+		},
+		{
+			name:        "timeout outlasting the write deadline",
+			env:         map[string]string{"DB_URL": "postgres://db", "UPLOAD_REQUEST_TIMEOUT": "30s"},
+			expectedErr: []string{"timeouts.upload: must be shorter than server.write_timeout (30s)"},
+		},
+		{
+			name: "JWT keys without issuer and audience",
+			env:  map[string]string{"DB_URL": "postgres://db", "JWT_JWKS_FILE": "/etc/api/jwks.json"},
+			expectedErr: []string{
+				"auth.jwt.issuer: is required when JWT verification keys are set",
+				"auth.jwt.audience: is required when JWT verification keys are set",
+			},
+		},
+		{
+			name: "invalid request signing",
+			env:  map[string]string{"DB_URL": "postgres://db", "SIGNING_NONCE_STORE": "redis", "SIGNING_REQUIRED_FOR_TRANSFERS": "true"},
+			expectedErr: []string{
+				`auth.signing.nonce_store: must be one of memory,db, got "redis"`,
+				"auth.signing.required_for_transfers: needs auth.signing.clients_file",
+			},
+		},
+		{
+			name: "invalid rate limits",
+			env:  map[string]string{"DB_URL": "postgres://db", "RATE_LIMIT_STORE": "redis", "RATE_LIMIT_TRANSFERS_BURST": "0", "RATE_LIMIT_READS_PER_MINUTE": "-1"},
+			expectedErr: []string{
+				`rate_limit.store: must be one of memory,db, got "redis"`,
+				"rate_limit.transfers_burst: must be at least 1",
+				"rate_limit.reads_per_minute: must not be negative",
+			},
+		},
+		{
+			name: "invalid database circuit breaker",
+			env:  map[string]string{"DB_URL": "postgres://db", "DB_BREAKER_FAILURE_THRESHOLD": "0", "DB_BREAKER_OPEN_TIMEOUT": "0s"},
+			expectedErr: []string{
+				"db.breaker.failure_threshold: must be at least 1",
+				"db.breaker.open_timeout: must be positive",
+			},
+		},
+		{
+			name: "invalid admission queue",
+			env:  map[string]string{"DB_URL": "postgres://db", "ADMISSION_MAX_CONCURRENT": "0", "ADMISSION_MAX_WAIT": "0s"},
+			expectedErr: []string{
+				"admission.max_concurrent: must be at least 1",
+				"admission.max_wait: must be positive",
+			},
+		},
+		{
+			name:        "invalid ledger checkpoint interval",
+			env:         map[string]string{"DB_URL": "postgres://db", "LEDGER_CHECKPOINT_INTERVAL": "0s"},
+			expectedErr: []string{"ledger.checkpoint_interval: must be positive"},
+		},
+		{
+			name:        "invalid balance snapshot interval",
+			env:         map[string]string{"DB_URL": "postgres://db", "BALANCE_SNAPSHOT_INTERVAL": "-1h"},
+			expectedErr: []string{"balances.snapshot_interval: must be positive"},
+		},
+		{
+			name:        "unknown key in file",
+			env:         map[string]string{"CONFIG_FILE": "testdata/unknown-key.yaml"},
+			expectedErr: []string{"field max_open_connections not found"},
+		},
+		{
+			name:        "missing file",
+			args:        []string{"-config", "testdata/missing.yaml"},
+			expectedErr: []string{"unable to read config file"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Load(tt.args, func(key string) string { return tt.env[key] })
+			assert.Error(t, err)
+			for _, expected := range tt.expectedErr {
+				assert.Contains(t, err.Error(), expected)
+			}
+		})
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	cfg, printOnly, err := Load([]string{"-print-config", "-db-url", "postgres://api:secret@db/accounts"}, func(string) string { return "" })
+	assert.NoError(t, err)
+	assert.True(t, printOnly)
+
+	var buf bytes.Buffer
+	cfg.Print(&buf)
+	assert.NotContains(t, buf.String(), "secret")
+	assert.Contains(t, buf.String(), "db.url=[REDACTED]\n")
+	assert.Contains(t, buf.String(), "db.max_open_conns=20\n")
+	assert.Contains(t, buf.String(), "server.write_timeout=30s\n")
+
+	buf.Reset()
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("config loaded", slog.Any("config", cfg))
+	assert.NotContains(t, buf.String(), "secret")
+	assert.True(t, strings.Contains(buf.String(), "config.db.url=[REDACTED]"))
+}
@@ -3,7 +3,8 @@ package handlers
 import "aeshanw.com/accountApi/api/models"
 
 func ValidateCreateAccountRequest(req models.CreateAccountRequest) *ErrorResponse {
-	if req.AccountID == 0 {
+	//account_id may be omitted (0) for the service to allocate one
+	if req.AccountID < 0 {
 		return NewErrorResponse(ErrBadRequest, "invalid AccountID")
 	}
 	if req.InitialBalance == "" {
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/render"
+
+	"aeshanw.com/accountApi/api/admission"
+	"aeshanw.com/accountApi/api/logging"
+)
+
+// Admit runs requests once the queue admits them. A request the queue refuses is answered with ErrServiceUnavailable
+// and a Retry-After of the queue's maximum wait, by then the spike may have drained.
+func Admit(queue *admission.Queue) func(http.Handler) http.Handler {
+	retryAfter := strconv.Itoa(max(1, ceilSeconds(queue.MaxWait())))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			release, err := queue.Acquire(r.Context())
+			if err != nil {
+				if errors.Is(err, admission.ErrQueueFull) || errors.Is(err, admission.ErrWaitTimeout) {
+					logging.FromContext(r.Context()).Warn("request shed", slog.String(logging.KeyError, err.Error()))
+				}
+				w.Header().Set("Retry-After", retryAfter)
+				render.Status(r, http.StatusServiceUnavailable)
+				render.Render(w, r, NewErrorResponse(ErrServiceUnavailable, "the server is overloaded, "+err.Error()))
+				return
+			}
+			defer release()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"aeshanw.com/accountApi/api/admission"
+)
+
+func TestAdmit(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := Admit(admission.NewQueue("test", 1, 0, 1500*time.Millisecond))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Block") != "" {
+			close(started)
+			<-release
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan int)
+	go func() {
+		req := httptest.NewRequest(http.MethodPost, "/transactions", nil)
+		req.Header.Set("X-Block", "1")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		done <- rr.Code
+	}()
+	<-started
+
+	//No room to queue, the request is shed at once
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/transactions", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "2", rr.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"status":503,"detail":"service_unavailable","message":"the server is overloaded, admission queue full","retryable":true}`, rr.Body.String())
+
+	close(release)
+	assert.Equal(t, http.StatusCreated, <-done)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/transactions", nil))
+	assert.Equal(t, http.StatusCreated, rr.Code)
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"aeshanw.com/accountApi/api/auth"
+	"aeshanw.com/accountApi/api/models"
+	apikeyservice "aeshanw.com/accountApi/api/services/APIKeyService"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+type APIKeyHandler struct {
+	db            *sql.DB
+	apikeyservice apikeyservice.APIKeyServiceInt
+	// jwtVerifier accepts bearer tokens next to API keys when set
+	jwtVerifier *auth.JWTVerifier
+	// signatureVerifier accepts HMAC signed requests when set
+	signatureVerifier *auth.SignatureVerifier
+}
+
+// NewAPIKeyHandler creates a new instance of Handlers with the provided dependencies.
+func NewAPIKeyHandler(db *sql.DB, aks apikeyservice.APIKeyServiceInt) *APIKeyHandler {
+	return &APIKeyHandler{
+		db:            db,
+		apikeyservice: aks,
+	}
+}
+
+// SetJWTVerifier makes Authenticate accept bearer tokens checked by v
+func (akh *APIKeyHandler) SetJWTVerifier(v *auth.JWTVerifier) {
+	akh.jwtVerifier = v
+}
+
+// SetSignatureVerifier makes Authenticate accept requests signed by a client of v
+func (akh *APIKeyHandler) SetSignatureVerifier(v *auth.SignatureVerifier) {
+	akh.signatureVerifier = v
+}
+
+type APIKeyResponse struct {
+	KeyID     string     `json:"key_id"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	// Key is the raw key, only returned when the key is created
+	Key string `json:"key,omitempty"`
+}
+
+func (akr *APIKeyResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	// TODO Pre-processing before a response is marshalled and sent across the wire
+	return nil
+}
+
+func NewAPIKeyResponse(key *apikeyservice.APIKeyModel) (*APIKeyResponse, error) {
+	if key == nil {
+		return nil, errors.New("apiKeyModel is nil")
+	}
+
+	resp := &APIKeyResponse{
+		KeyID:     key.ID,
+		Name:      key.Name,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+	}
+	if key.RevokedAt.Valid {
+		resp.RevokedAt = &key.RevokedAt.Time
+	}
+	return resp, nil
+}
+
+type ListAPIKeysResponse struct {
+	Keys []*APIKeyResponse `json:"keys"`
+}
+
+func (lkr *ListAPIKeysResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	// TODO Pre-processing before a response is marshalled and sent across the wire
+	return nil
+}
+
+// CreateAPIKey issues a new key. The raw key is in the response only, it is stored hashed and cannot be retrieved later.
+func (akh *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, NewDefaultErrorResponse(ErrBadRequest))
+		return
+	}
+
+	if errRes := ValidateCreateAPIKeyRequest(req); errRes != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, errRes)
+		return
+	}
+
+	key, rawKey, err := akh.apikeyservice.CreateKey(r.Context(), akh.db, req)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+		return
+	}
+
+	resp, err := NewAPIKeyResponse(key)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+		return
+	}
+	resp.Key = rawKey
+
+	w.Header().Set("Location", fmt.Sprintf("/admin/api-keys/%s", key.ID))
+	//The raw key must not end up in a shared cache
+	w.Header().Set("Cache-Control", "no-store")
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, resp)
+}
+
+func (akh *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := akh.apikeyservice.ListKeys(r.Context(), akh.db)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+		return
+	}
+
+	resp := &ListAPIKeysResponse{Keys: make([]*APIKeyResponse, 0, len(keys))}
+	for _, key := range keys {
+		keyResp, err := NewAPIKeyResponse(key)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+			return
+		}
+		resp.Keys = append(resp.Keys, keyResp)
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, resp)
+}
+
+// RevokeAPIKey disables a key for every later request, the key stays listed with its revocation time
+func (akh *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	key, err := akh.apikeyservice.RevokeKey(r.Context(), akh.db, chi.URLParam(r, "key_id"))
+	if errors.Is(err, apikeyservice.ErrAPIKeyNotFound) {
+		render.Status(r, http.StatusNotFound)
+		render.Render(w, r, NewErrorResponse(ErrNotFound, err.Error()))
+		return
+	}
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+		return
+	}
+
+	resp, err := NewAPIKeyResponse(key)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, resp)
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"aeshanw.com/accountApi/api/mocks"
+	"aeshanw.com/accountApi/api/models"
+	apikeyservice "aeshanw.com/accountApi/api/services/APIKeyService"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newAPIKeyRouter(akh *APIKeyHandler) *chi.Mux {
+	r := chi.NewRouter()
+	r.Post("/admin/api-keys", akh.CreateAPIKey)
+	r.Get("/admin/api-keys", akh.ListAPIKeys)
+	r.Delete("/admin/api-keys/{key_id}", akh.RevokeAPIKey)
+	return r
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		body           string
+		setupMock      func(m *mocks.MockAPIKeyService)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "valid key",
+			body: `{"name":"payouts","scopes":["transactions:write","accounts:read"]}`,
+			setupMock: func(m *mocks.MockAPIKeyService) {
+				m.On("CreateKey", mock.Anything, mock.Anything, models.CreateAPIKeyRequest{Name: "payouts", Scopes: []string{"transactions:write", "accounts:read"}}).
+					Return(&apikeyservice.APIKeyModel{ID: "0123456789ab", Name: "payouts", Scopes: []string{"transactions:write", "accounts:read"}, CreatedAt: createdAt}, "aak_0123456789ab_secret", nil)
+			},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"key_id":"0123456789ab","name":"payouts","scopes":["transactions:write","accounts:read"],"created_at":"2026-01-02T03:04:05Z","key":"aak_0123456789ab_secret"}`,
+		},
+		{
+			name:           "unknown scope",
+			body:           `{"name":"payouts","scopes":["transactions:delete"]}`,
+			setupMock:      func(m *mocks.MockAPIKeyService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"Scopes must be among accounts:read,accounts:write,transactions:read,transactions:write,admin, got \"transactions:delete\""}`,
+		},
+		{
+			name:           "no scopes",
+			body:           `{"name":"payouts"}`,
+			setupMock:      func(m *mocks.MockAPIKeyService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"Scopes is empty"}`,
+		},
+		{
+			name:           "missing name",
+			body:           `{"scopes":["admin"]}`,
+			setupMock:      func(m *mocks.MockAPIKeyService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"Name is required"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(mocks.MockAPIKeyService)
+			tt.setupMock(mockService)
+			r := newAPIKeyRouter(NewAPIKeyHandler(new(sql.DB), mockService))
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/api-keys", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			if tt.expectedStatus == http.StatusCreated {
+				assert.Equal(t, "/admin/api-keys/0123456789ab", rr.Header().Get("Location"))
+				assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestListAndRevokeAPIKeys(t *testing.T) {
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	revokedAt := createdAt.Add(time.Hour)
+	revoked := &apikeyservice.APIKeyModel{ID: "0123456789ab", Name: "payouts", Scopes: []string{"transactions:write"}, CreatedAt: createdAt,
+		RevokedAt: sql.NullTime{Time: revokedAt, Valid: true}}
+
+	mockService := new(mocks.MockAPIKeyService)
+	mockService.On("ListKeys", mock.Anything, mock.Anything).Return([]*apikeyservice.APIKeyModel{
+		{ID: "ba9876543210", Name: "ops", Scopes: []string{"admin"}, CreatedAt: createdAt},
+		revoked,
+	}, nil)
+	mockService.On("RevokeKey", mock.Anything, mock.Anything, "0123456789ab").Return(revoked, nil)
+	mockService.On("RevokeKey", mock.Anything, mock.Anything, "ffffffffffff").Return(nil, apikeyservice.ErrAPIKeyNotFound)
+
+	r := newAPIKeyRouter(NewAPIKeyHandler(new(sql.DB), mockService))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/api-keys", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	//Raw keys and hashes are never listed
+	assert.JSONEq(t, `{"keys":[
+		{"key_id":"ba9876543210","name":"ops","scopes":["admin"],"created_at":"2026-01-02T03:04:05Z"},
+		{"key_id":"0123456789ab","name":"payouts","scopes":["transactions:write"],"created_at":"2026-01-02T03:04:05Z","revoked_at":"2026-01-02T04:04:05Z"}
+	]}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/admin/api-keys/0123456789ab", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"revoked_at":"2026-01-02T04:04:05Z"`)
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/admin/api-keys/ffffffffffff", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), "api key not found")
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"aeshanw.com/accountApi/api/audit"
+)
+
+const (
+	DefaultAuditLogLimit = 100
+	MaxAuditLogLimit     = 1000
+)
+
+type AuditLogHandler struct {
+	db *sql.DB
+}
+
+func NewAuditLogHandler(db *sql.DB) *AuditLogHandler {
+	return &AuditLogHandler{db: db}
+}
+
+type AuditRecordResponse struct {
+	Seq          int64           `json:"seq"`
+	OccurredAt   time.Time       `json:"occurred_at"`
+	Actor        string          `json:"actor"`
+	RequestID    string          `json:"request_id,omitempty"`
+	SourceIP     string          `json:"source_ip,omitempty"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   string          `json:"resource_id"`
+	Before       json.RawMessage `json:"before,omitempty"`
+	After        json.RawMessage `json:"after,omitempty"`
+	PrevHash     string          `json:"prev_hash"`
+	Hash         string          `json:"hash"`
+}
+
+type ListAuditLogResponse struct {
+	Records []*AuditRecordResponse `json:"records"`
+	// NextAfterSeq fetches the next page as ?after_seq=, omitted on the last page
+	NextAfterSeq int64 `json:"next_after_seq,omitempty"`
+}
+
+func (lar *ListAuditLogResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	// TODO Pre-processing before a response is marshalled and sent across the wire
+	return nil
+}
+
+// ParseAuditLogQuery reads the GET /admin/audit-log query string, applying the default limit
+func ParseAuditLogQuery(values url.Values) (audit.Query, *ErrorResponse) {
+	query := audit.Query{
+		Actor:        values.Get("actor"),
+		Action:       values.Get("action"),
+		ResourceType: values.Get("resource_type"),
+		ResourceID:   values.Get("resource_id"),
+		RequestID:    values.Get("request_id"),
+		Limit:        DefaultAuditLogLimit,
+	}
+
+	if afterSeq := values.Get("after_seq"); afterSeq != "" {
+		parsed, err := strconv.ParseInt(afterSeq, 10, 64)
+		if err != nil || parsed < 0 {
+			return query, NewErrorResponse(ErrBadRequest, "after_seq must be a non-negative integer")
+		}
+		query.AfterSeq = parsed
+	}
+	if limit := values.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > MaxAuditLogLimit {
+			return query, NewErrorResponse(ErrBadRequest, fmt.Sprintf("limit must be an integer between 1 and %d", MaxAuditLogLimit))
+		}
+		query.Limit = parsed
+	}
+
+	var errRes *ErrorResponse
+	if query.Since, errRes = parseTimeParam(values, "since"); errRes != nil {
+		return query, errRes
+	}
+	if query.Until, errRes = parseTimeParam(values, "until"); errRes != nil {
+		return query, errRes
+	}
+	return query, nil
+}
+
+// ListAuditLog returns audit records oldest first, filtered by the query string. The records carry their hashes so a
+// client can check a page links up with the ones before it.
+func (alh *AuditLogHandler) ListAuditLog(w http.ResponseWriter, r *http.Request) {
+	query, errRes := ParseAuditLogQuery(r.URL.Query())
+	if errRes != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, errRes)
+		return
+	}
+
+	records, err := audit.Find(r.Context(), alh.db, query)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+		return
+	}
+
+	resp := &ListAuditLogResponse{Records: make([]*AuditRecordResponse, 0, len(records))}
+	for _, rec := range records {
+		resp.Records = append(resp.Records, &AuditRecordResponse{
+			Seq:          rec.Seq,
+			OccurredAt:   rec.OccurredAt,
+			Actor:        rec.Actor,
+			RequestID:    rec.RequestID,
+			SourceIP:     rec.SourceIP,
+			Action:       rec.Action,
+			ResourceType: rec.ResourceType,
+			ResourceID:   rec.ResourceID,
+			Before:       rec.Before,
+			After:        rec.After,
+			PrevHash:     rec.PrevHash,
+			Hash:         rec.Hash,
+		})
+	}
+	if len(records) == query.Limit {
+		resp.NextAfterSeq = records[len(records)-1].Seq
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, resp)
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+
+	"aeshanw.com/accountApi/api/audit"
+	"aeshanw.com/accountApi/api/auth"
+)
+
+var auditRecordColumns = []string{"seq", "occurred_at", "actor", "request_id", "source_ip", "action", "resource_type", "resource_id",
+	"before_state", "after_state", "prev_hash", "hash"}
+
+func TestListAuditLog(t *testing.T) {
+	occurredAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	sqlFindRecords := regexp.QuoteMeta(`SELECT seq,occurred_at,actor,request_id,source_ip,action,resource_type,resource_id,before_state,after_state,prev_hash,hash FROM audit_log`)
+
+	tests := []struct {
+		name           string
+		url            string
+		mockSetup      func(mock sqlmock.Sqlmock)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "filtered page",
+			url:  "/admin/audit-log?resource_type=account&resource_id=1&since=2024-01-01T00:00:00Z&after_seq=4&limit=1",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sqlFindRecords+regexp.QuoteMeta(` WHERE seq>$1 AND resource_type=$2 AND resource_id=$3 AND occurred_at>=$4 ORDER BY seq LIMIT $5`)).
+					WithArgs(4, "account", "1", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 1).
+					WillReturnRows(sqlmock.NewRows(auditRecordColumns).
+						AddRow(5, occurredAt, "api_key:0123456789ab", "req-1", "10.0.0.1", audit.ActionAccountCreate, audit.ResourceAccount, "1",
+							nil, []byte(`{"id":1}`), audit.GenesisHash, "ab"))
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"records":[{"seq":5,"occurred_at":"2024-05-01T00:00:00Z","actor":"api_key:0123456789ab","request_id":"req-1","source_ip":"10.0.0.1",` +
+				`"action":"account.create","resource_type":"account","resource_id":"1","after":{"id":1},"prev_hash":"` + audit.GenesisHash + `","hash":"ab"}],` +
+				`"next_after_seq":5}`,
+		},
+		{
+			name: "last page",
+			url:  "/admin/audit-log?actor=system",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sqlFindRecords+regexp.QuoteMeta(` WHERE seq>$1 AND actor=$2 ORDER BY seq LIMIT $3`)).
+					WithArgs(0, "system", DefaultAuditLogLimit).
+					WillReturnRows(sqlmock.NewRows(auditRecordColumns))
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"records":[]}`,
+		},
+		{
+			name:           "invalid limit",
+			url:            "/admin/audit-log?limit=0",
+			mockSetup:      func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"limit must be an integer between 1 and 1000"}`,
+		},
+		{
+			name:           "invalid since",
+			url:            "/admin/audit-log?since=yesterday",
+			mockSetup:      func(mock sqlmock.Sqlmock) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"since must be an RFC 3339 timestamp"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+			tt.mockSetup(mock)
+
+			r := chi.NewRouter()
+			r.Get("/admin/audit-log", NewAuditLogHandler(db).ListAuditLog)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestAuditSource(t *testing.T) {
+	var source audit.Source
+	handler := middleware.RequestID(AuditSource(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		source = audit.SourceFromContext(r.Context())
+	})))
+
+	req := httptest.NewRequest(http.MethodPost, "/accounts", nil)
+	req.RemoteAddr = "10.0.0.1:51234"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, actorAnonymous, source.Actor)
+	assert.Equal(t, "10.0.0.1", source.SourceIP)
+	assert.NotEmpty(t, source.RequestID)
+
+	req = httptest.NewRequest(http.MethodPost, "/accounts", nil)
+	req.RemoteAddr = "192.0.2.7"
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{ID: "0123456789ab", Method: auth.MethodAPIKey}))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "api_key:0123456789ab", source.Actor)
+	assert.Equal(t, "192.0.2.7", source.SourceIP)
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"aeshanw.com/accountApi/api/audit"
+	"aeshanw.com/accountApi/api/auth"
+)
+
+// actorAnonymous is the actor of requests made while authentication is turned off
+const actorAnonymous = "anonymous"
+
+// AuditSource records the caller, request ID and client IP of a request in its context, every change the request
+// makes is audited as theirs. It runs after Authenticate, the actor is the authenticated principal as
+// "<method>:<id>", e.g. "api_key:0123456789ab".
+func AuditSource(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		source := audit.Source{Actor: actorAnonymous, RequestID: middleware.GetReqID(r.Context()), SourceIP: r.RemoteAddr}
+		if principal := auth.FromContext(r.Context()); principal != nil {
+			source.Actor = principal.Method + ":" + principal.ID
+		}
+		//RealIP replaces RemoteAddr with a bare IP when the request came through a proxy
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			source.SourceIP = host
+		}
+		next.ServeHTTP(w, r.WithContext(audit.WithSource(r.Context(), source)))
+	})
+}
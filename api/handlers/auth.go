@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/render"
+
+	"aeshanw.com/accountApi/api/auth"
+	"aeshanw.com/accountApi/api/logging"
+	apikeyservice "aeshanw.com/accountApi/api/services/APIKeyService"
+)
+
+// APIKeyHeader carries the caller's API key
+const APIKeyHeader = "X-API-Key"
+
+const bearerPrefix = "Bearer "
+
+// Authenticate rejects requests without a valid API key, bearer token or request signature with ErrUnauthorized, the
+// latter two only when their verifier is set. The caller is stored in the request context as the auth.Principal and
+// tags the request's log lines.
+func (akh *APIKeyHandler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(auth.SignatureHeader) != "" {
+			akh.authenticateSignature(w, r, next)
+			return
+		}
+		if authorization := r.Header.Get("Authorization"); authorization != "" {
+			akh.authenticateBearer(w, r, next, authorization)
+			return
+		}
+
+		rawKey := r.Header.Get(APIKeyHeader)
+		if rawKey == "" {
+			renderUnauthorized(w, r, "missing "+APIKeyHeader+" header")
+			return
+		}
+
+		key, err := akh.apikeyservice.Authenticate(r.Context(), akh.db, rawKey)
+		if errors.Is(err, apikeyservice.ErrInvalidAPIKey) {
+			renderUnauthorized(w, r, err.Error())
+			return
+		}
+		if err != nil {
+			//The key could not be checked, the caller is not at fault and may retry
+			logging.FromContext(r.Context()).Error("unable to authenticate request", slog.String(logging.KeyError, err.Error()))
+			render.Status(r, http.StatusServiceUnavailable)
+			render.Render(w, r, NewDefaultErrorResponse(ErrServiceUnavailable))
+			return
+		}
+
+		principal := &auth.Principal{ID: key.ID, Name: key.Name, Method: auth.MethodAPIKey, Scopes: key.Scopes}
+		serveAuthenticated(w, r, next, principal)
+	})
+}
+
+// authenticateBearer verifies the JWT of an Authorization header. The reason a token was refused is only logged, the
+// caller learns no more than that it is invalid.
+func (akh *APIKeyHandler) authenticateBearer(w http.ResponseWriter, r *http.Request, next http.Handler, authorization string) {
+	if akh.jwtVerifier == nil {
+		renderUnauthorized(w, r, "bearer tokens are not accepted, use the "+APIKeyHeader+" header")
+		return
+	}
+	if len(authorization) <= len(bearerPrefix) || !strings.EqualFold(authorization[:len(bearerPrefix)], bearerPrefix) {
+		renderUnauthorized(w, r, "Authorization header must be a Bearer token")
+		return
+	}
+
+	principal, err := akh.jwtVerifier.Verify(strings.TrimSpace(authorization[len(bearerPrefix):]))
+	if err != nil {
+		logging.FromContext(r.Context()).Info("bearer token refused", slog.String(logging.KeyError, err.Error()))
+		renderUnauthorized(w, r, auth.ErrInvalidToken.Error())
+		return
+	}
+	serveAuthenticated(w, r, next, principal)
+}
+
+// authenticateSignature verifies an HMAC signed request. Replays and stale requests are told apart from bad signatures
+// so a client can fix its clock or nonce generation.
+func (akh *APIKeyHandler) authenticateSignature(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if akh.signatureVerifier == nil {
+		renderUnauthorized(w, r, "signed requests are not accepted, use the "+APIKeyHeader+" header")
+		return
+	}
+
+	principal, err := akh.signatureVerifier.Verify(r)
+	switch {
+	case errors.Is(err, auth.ErrInvalidSignature), errors.Is(err, auth.ErrStaleTimestamp), errors.Is(err, auth.ErrNonceReused):
+		logging.FromContext(r.Context()).Info("request signature refused", slog.String(logging.KeyClientID, r.Header.Get(auth.ClientIDHeader)), slog.String(logging.KeyError, err.Error()))
+		renderUnauthorized(w, r, err.Error())
+		return
+	case errors.Is(err, auth.ErrBodyTooLarge):
+		render.Status(r, http.StatusRequestEntityTooLarge)
+		render.Render(w, r, NewErrorResponse(ErrPayloadTooLarge, err.Error()))
+		return
+	case err != nil:
+		logging.FromContext(r.Context()).Error("unable to verify request signature", slog.String(logging.KeyError, err.Error()))
+		render.Status(r, http.StatusServiceUnavailable)
+		render.Render(w, r, NewDefaultErrorResponse(ErrServiceUnavailable))
+		return
+	}
+	serveAuthenticated(w, r, next, principal)
+}
+
+func serveAuthenticated(w http.ResponseWriter, r *http.Request, next http.Handler, principal *auth.Principal) {
+	ctx := auth.WithPrincipal(r.Context(), principal)
+	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With(slog.String(logging.KeyPrincipalID, principal.ID)))
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func renderUnauthorized(w http.ResponseWriter, r *http.Request, msg string) {
+	w.Header().Add("WWW-Authenticate", `ApiKey header="`+APIKeyHeader+`"`)
+	w.Header().Add("WWW-Authenticate", `Bearer`)
+	w.Header().Add("WWW-Authenticate", `HMAC-SHA256 headers="`+auth.ClientIDHeader+` `+auth.SignatureTimestampHeader+` `+auth.SignatureNonceHeader+` `+auth.SignatureHeader+`"`)
+	render.Status(r, http.StatusUnauthorized)
+	render.Render(w, r, NewErrorResponse(ErrUnauthorized, msg))
+}
+
+// RequireScope answers ErrForbidden unless the authenticated caller was granted scope, and ErrUnauthorized when there is
+// no authenticated caller. Every API route declares the scope it needs with it.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := auth.FromContext(r.Context())
+			if principal == nil {
+				renderUnauthorized(w, r, "")
+				return
+			}
+			if !principal.HasScope(scope) {
+				render.Status(r, http.StatusForbidden)
+				render.Render(w, r, NewErrorResponse(ErrForbidden, fmt.Sprintf("requires scope %s", scope)))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// canAccessAccount reports whether the request's caller may act on accountID. Without an authenticated caller, i.e.
+// with authentication disabled, every account is open.
+func canAccessAccount(r *http.Request, accountID int64) bool {
+	principal := auth.FromContext(r.Context())
+	return principal == nil || principal.CanAccessAccount(accountID)
+}
+
+func renderAccountForbidden(w http.ResponseWriter, r *http.Request, accountID int64) {
+	render.Status(r, http.StatusForbidden)
+	render.Render(w, r, NewErrorResponse(ErrForbidden, fmt.Sprintf("account %d is not owned by the caller", accountID)))
+}
+
+// RequireUnrestricted answers ErrForbidden to callers limited to their own accounts. It guards the routes that span
+// every account, e.g. listing accounts or bulk uploads, which cannot be narrowed to the caller's accounts.
+func RequireUnrestricted(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if principal := auth.FromContext(r.Context()); principal != nil && principal.Restricted() {
+			render.Status(r, http.StatusForbidden)
+			render.Render(w, r, NewErrorResponse(ErrForbidden, "not available to account-restricted callers"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireSignature answers ErrUnauthorized to callers authenticated with a plain API key, so services must sign their
+// requests to the route. End users calling with a bearer token are let through.
+func RequireSignature(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if principal := auth.FromContext(r.Context()); principal != nil && principal.Method == auth.MethodAPIKey {
+			renderUnauthorized(w, r, "requests to this route must be signed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
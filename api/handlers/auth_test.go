@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"aeshanw.com/accountApi/api/auth"
+	"aeshanw.com/accountApi/api/mocks"
+	apikeyservice "aeshanw.com/accountApi/api/services/APIKeyService"
+	accountservice "aeshanw.com/accountApi/api/services/AccountService"
+	transactionservice "aeshanw.com/accountApi/api/services/TransactionService"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAuthenticateAndRequireScope(t *testing.T) {
+	mockService := new(mocks.MockAPIKeyService)
+	mockService.On("Authenticate", mock.Anything, mock.Anything, "aak_reader").
+		Return(&apikeyservice.APIKeyModel{ID: "0123456789ab", Name: "reporting", Scopes: []string{auth.ScopeAccountsRead}}, nil)
+	mockService.On("Authenticate", mock.Anything, mock.Anything, "aak_admin").
+		Return(&apikeyservice.APIKeyModel{ID: "ba9876543210", Name: "ops", Scopes: []string{auth.ScopeAdmin}}, nil)
+	mockService.On("Authenticate", mock.Anything, mock.Anything, "aak_revoked").Return(nil, apikeyservice.ErrInvalidAPIKey)
+	mockService.On("Authenticate", mock.Anything, mock.Anything, "aak_dbdown").Return(nil, errors.New("unable to look up api key due to :connection refused"))
+
+	var principal *auth.Principal
+	handler := NewAPIKeyHandler(new(sql.DB), mockService).Authenticate(RequireScope(auth.ScopeAccountsWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal = auth.FromContext(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	tests := []struct {
+		name           string
+		apiKey         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "missing key",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"status":401,"detail":"unauthorized","message":"missing X-API-Key header"}`,
+		},
+		{
+			name:           "revoked key",
+			apiKey:         "aak_revoked",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"status":401,"detail":"unauthorized","message":"invalid api key"}`,
+		},
+		{
+			name:           "key without the scope",
+			apiKey:         "aak_reader",
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"status":403,"detail":"forbidden","message":"requires scope accounts:write"}`,
+		},
+		{
+			name:           "key store unavailable",
+			apiKey:         "aak_dbdown",
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   `{"status":503,"detail":"service_unavailable","message":"The service is temporarily unavailable. Please try again later.","retryable":true}`,
+		},
+		{
+			name:           "admin key",
+			apiKey:         "aak_admin",
+			expectedStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal = nil
+			req := httptest.NewRequest(http.MethodPost, "/accounts", nil)
+			if tt.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			}
+			if tt.expectedStatus == http.StatusUnauthorized {
+				assert.Equal(t, `ApiKey header="X-API-Key"`, rr.Header().Get("WWW-Authenticate"))
+			}
+			if tt.expectedStatus == http.StatusNoContent {
+				assert.Equal(t, &auth.Principal{ID: "ba9876543210", Name: "ops", Method: auth.MethodAPIKey, Scopes: []string{auth.ScopeAdmin}}, principal)
+			}
+		})
+	}
+}
+
+func TestAuthenticateBearerToken(t *testing.T) {
+	verifier, err := auth.NewJWTVerifier(auth.JWTOptions{HMACSecret: "test-secret", Issuer: "idp", Audience: "accounts-api", AccountsClaim: "accounts", ScopesClaim: "scope"})
+	assert.NoError(t, err)
+	sign := func(claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+		assert.NoError(t, err)
+		return token
+	}
+	validToken := sign(jwt.MapClaims{"iss": "idp", "aud": "accounts-api", "sub": "user-42", "exp": time.Now().Add(time.Minute).Unix(), "scope": "accounts:read", "accounts": []int64{7}})
+	expiredToken := sign(jwt.MapClaims{"iss": "idp", "aud": "accounts-api", "sub": "user-42", "exp": time.Now().Add(-time.Minute).Unix(), "scope": "accounts:read"})
+
+	var principal *auth.Principal
+	next := RequireScope(auth.ScopeAccountsRead)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal = auth.FromContext(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	withJWT := NewAPIKeyHandler(new(sql.DB), new(mocks.MockAPIKeyService))
+	withJWT.SetJWTVerifier(verifier)
+
+	tests := []struct {
+		name           string
+		handler        *APIKeyHandler
+		authorization  string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "valid token",
+			handler:        withJWT,
+			authorization:  "Bearer " + validToken,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "expired token",
+			handler:        withJWT,
+			authorization:  "Bearer " + expiredToken,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"status":401,"detail":"unauthorized","message":"invalid bearer token"}`,
+		},
+		{
+			name:           "other scheme",
+			handler:        withJWT,
+			authorization:  "Basic dXNlcjpwYXNz",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"status":401,"detail":"unauthorized","message":"Authorization header must be a Bearer token"}`,
+		},
+		{
+			name:           "JWT not configured",
+			handler:        NewAPIKeyHandler(new(sql.DB), new(mocks.MockAPIKeyService)),
+			authorization:  "Bearer " + validToken,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"status":401,"detail":"unauthorized","message":"bearer tokens are not accepted, use the X-API-Key header"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal = nil
+			req := httptest.NewRequest(http.MethodGet, "/accounts/7", nil)
+			req.Header.Set("Authorization", tt.authorization)
+			rr := httptest.NewRecorder()
+			tt.handler.Authenticate(next).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+				assert.Contains(t, rr.Header().Values("WWW-Authenticate"), "Bearer")
+			} else {
+				assert.Equal(t, "user-42", principal.ID)
+				assert.Equal(t, []int64{7}, principal.AccountIDs)
+				assert.True(t, principal.Restricted())
+			}
+		})
+	}
+}
+
+func TestAccountOwnership(t *testing.T) {
+	mockAccountService := new(mocks.MockAccountService)
+	mockAccountService.On("GetAccount", mock.Anything, mock.Anything, int64(1)).Return(&accountservice.AccountModel{ID: 1, Status: accountservice.AccountStatusActive}, nil)
+	mockTransactionService := new(MockTransactionService)
+	mockTransactionService.On("CreateTransaction", mock.Anything, mock.Anything, mock.Anything).
+		Return(&transactionservice.TransactionModel{ID: uuid.New(), SourceAccountID: 1, DestinationAccountID: 2, Amount: 10}, nil)
+
+	accountHandler := &AccountHandler{db: new(sql.DB), accountservice: mockAccountService}
+	transactionHandler := &TransactionHandler{db: new(sql.DB), transactionservice: mockTransactionService}
+
+	owner := &auth.Principal{ID: "user-42", Scopes: []string{auth.ScopeAccountsRead, auth.ScopeTransactionsWrite}, AccountIDs: []int64{1}, RestrictedToAccounts: true}
+	admin := &auth.Principal{ID: "ops", Scopes: []string{auth.ScopeAdmin}, RestrictedToAccounts: true}
+
+	r := chi.NewRouter()
+	r.Get("/accounts/{account_id}", accountHandler.GetAccountDetails)
+	r.Post("/transactions", transactionHandler.CreateTransaction)
+	r.With(RequireUnrestricted).Get("/accounts", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+	tests := []struct {
+		name           string
+		principal      *auth.Principal
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "owner reads own account", principal: owner, method: http.MethodGet, path: "/accounts/1", expectedStatus: http.StatusOK},
+		{
+			name: "owner reads other account", principal: owner, method: http.MethodGet, path: "/accounts/2",
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"status":403,"detail":"forbidden","message":"account 2 is not owned by the caller"}`,
+		},
+		{name: "admin reads any account", principal: admin, method: http.MethodGet, path: "/accounts/1", expectedStatus: http.StatusOK},
+		{
+			name: "owner debits own account", principal: owner, method: http.MethodPost, path: "/transactions",
+			body:           `{"source_account_id":1,"destination_account_id":2,"amount":"10"}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "owner debits other account", principal: owner, method: http.MethodPost, path: "/transactions",
+			body:           `{"source_account_id":2,"destination_account_id":1,"amount":"10"}`,
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"status":403,"detail":"forbidden","message":"account 2 is not owned by the caller"}`,
+		},
+		{
+			name: "owner lists accounts", principal: owner, method: http.MethodGet, path: "/accounts",
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"status":403,"detail":"forbidden","message":"not available to account-restricted callers"}`,
+		},
+		{name: "admin lists accounts", principal: admin, method: http.MethodGet, path: "/accounts", expectedStatus: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req = req.WithContext(auth.WithPrincipal(req.Context(), tt.principal))
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+	mockTransactionService.AssertNumberOfCalls(t, "CreateTransaction", 1)
+}
+
+func TestAuthenticateSignedRequest(t *testing.T) {
+	clientsFile := filepath.Join(t.TempDir(), "clients.yaml")
+	assert.NoError(t, os.WriteFile(clientsFile, []byte("clients:\n  - id: payouts\n    secret: 0123456789abcdef0123456789abcdef\n    scopes: [transactions:write]\n"), 0o600))
+	verifier, err := auth.NewSignatureVerifier(auth.SignatureOptions{ClientsFile: clientsFile, MaxSkew: 5 * time.Minute, MaxBodyBytes: 1 << 10}, auth.NewMemoryNonceStore(10))
+	assert.NoError(t, err)
+
+	mockService := new(mocks.MockAPIKeyService)
+	mockService.On("Authenticate", mock.Anything, mock.Anything, "aak_payouts").
+		Return(&apikeyservice.APIKeyModel{ID: "0123456789ab", Name: "payouts", Scopes: []string{auth.ScopeTransactionsWrite}}, nil)
+	akHandler := NewAPIKeyHandler(new(sql.DB), mockService)
+	akHandler.SetSignatureVerifier(verifier)
+
+	var body string
+	handler := akHandler.Authenticate(RequireSignature(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	const transfer = `{"source_account_id":1,"destination_account_id":2,"amount":"10"}`
+	signed := func(nonce string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(transfer))
+		auth.SignRequest(req, "payouts", "0123456789abcdef0123456789abcdef", []byte(transfer), time.Now(), nonce)
+		return req
+	}
+
+	tests := []struct {
+		name           string
+		req            *http.Request
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "signed request", req: signed("nonce-1"), expectedStatus: http.StatusNoContent},
+		{
+			name:           "replayed request",
+			req:            signed("nonce-1"),
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"status":401,"detail":"unauthorized","message":"request nonce already used"}`,
+		},
+		{
+			name: "unsigned API key call",
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(transfer))
+				req.Header.Set(APIKeyHeader, "aak_payouts")
+				return req
+			}(),
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"status":401,"detail":"unauthorized","message":"requests to this route must be signed"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body = ""
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, tt.req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			} else {
+				assert.Equal(t, transfer, body)
+			}
+		})
+	}
+}
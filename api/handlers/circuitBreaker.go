@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/render"
+
+	"aeshanw.com/accountApi/api/breaker"
+)
+
+// CircuitBreaker answers ErrServiceUnavailable at once while the database circuit is open, with a Retry-After of the
+// time until its next probe. Otherwise it tracks the request's connection attempts, so an error response caused by
+// the database being unreachable is answered with ErrServiceUnavailable too instead of the raw driver error.
+func CircuitBreaker(b *breaker.Breaker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !b.Allow() {
+				renderDatabaseUnavailable(w, r, b)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(breaker.Track(r.Context())))
+		})
+	}
+}
+
+const databaseUnavailableMessage = "the database is unavailable"
+
+func renderDatabaseUnavailable(w http.ResponseWriter, r *http.Request, b *breaker.Breaker) {
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(b.RetryAfter()))))
+	render.Status(r, http.StatusServiceUnavailable)
+	render.Render(w, r, NewErrorResponse(ErrServiceUnavailable, databaseUnavailableMessage))
+}
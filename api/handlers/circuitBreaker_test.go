@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/stretchr/testify/assert"
+
+	"aeshanw.com/accountApi/api/breaker"
+)
+
+// unreachableConnector fails every connection attempt like a database that is down
+type unreachableConnector struct{}
+
+func (unreachableConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return nil, errors.New("dial tcp 127.0.0.1:5432: connect: connection refused")
+}
+
+func (unreachableConnector) Driver() driver.Driver { return nil }
+
+func TestCircuitBreaker(t *testing.T) {
+	b := breaker.New(unreachableConnector{}, breaker.Options{FailureThreshold: 2, OpenTimeout: time.Minute, ProbeTimeout: time.Second})
+	db := sql.OpenDB(b)
+	defer db.Close()
+
+	//Handlers answer service errors with a 400 carrying the error, e.g. a validation failure
+	calls := 0
+	handler := CircuitBreaker(b)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if err := db.PingContext(r.Context()); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.Render(w, r, NewErrorResponse(ErrBadRequest, err.Error()))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	//Requests failing to connect are answered with a 503 instead of the driver's error
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/accounts/1", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.JSONEq(t, `{"status":503,"detail":"service_unavailable","message":"the database is unavailable","retryable":true}`, rr.Body.String())
+	}
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, breaker.StateOpen, b.State())
+
+	//Once open, requests fail fast without reaching the handler
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/accounts/1", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "60", rr.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"status":503,"detail":"service_unavailable","message":"the database is unavailable","retryable":true}`, rr.Body.String())
+	assert.Equal(t, 2, calls)
+}
+
+func TestCircuitBreakerKeepsBusinessErrors(t *testing.T) {
+	b := breaker.New(unreachableConnector{}, breaker.Options{FailureThreshold: 1, OpenTimeout: time.Minute, ProbeTimeout: time.Second})
+	handler := CircuitBreaker(b)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, NewErrorResponse(ErrBadRequest, "insufficient funds"))
+	}))
+
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/transactions", nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.JSONEq(t, `{"status":400,"detail":"bad_request","message":"insufficient funds"}`, rr.Body.String())
+	}
+	assert.Equal(t, breaker.StateClosed, b.State())
+}
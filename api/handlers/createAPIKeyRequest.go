@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"aeshanw.com/accountApi/api/auth"
+	"aeshanw.com/accountApi/api/models"
+)
+
+const MaxAPIKeyNameLength = 128
+
+func ValidateCreateAPIKeyRequest(req models.CreateAPIKeyRequest) *ErrorResponse {
+	if req.Name == "" {
+		return NewErrorResponse(ErrBadRequest, "Name is required")
+	}
+	if len(req.Name) > MaxAPIKeyNameLength {
+		return NewErrorResponse(ErrBadRequest, fmt.Sprintf("Name cannot exceed %d characters", MaxAPIKeyNameLength))
+	}
+	if len(req.Scopes) == 0 {
+		return NewErrorResponse(ErrBadRequest, "Scopes is empty")
+	}
+	seen := make(map[string]bool, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !auth.IsScope(scope) {
+			return NewErrorResponse(ErrBadRequest, fmt.Sprintf("Scopes must be among %s, got %q", strings.Join(auth.Scopes, ","), scope))
+		}
+		if seen[scope] {
+			return NewErrorResponse(ErrBadRequest, fmt.Sprintf("Scopes contains %q more than once", scope))
+		}
+		seen[scope] = true
+	}
+	return nil
+}
@@ -42,14 +42,23 @@ func (ah *AccountHandler) CreateAccount(w http.ResponseWriter, r *http.Request)
 	ctx := r.Context()
 
 	//ServiceMethod to Validate & Save Account to DB
-	err := ah.accountservice.CreateAccount(ctx, ah.db, req)
+	account, err := ah.accountservice.CreateAccount(ctx, ah.db, req)
 	if err != nil {
 		render.Status(r, http.StatusBadRequest)
 		render.Render(w, r, NewErrorResponse(ErrBadRequest, err.Error()))
 		return
 	}
-	//TODO replace with logger
-	fmt.Printf("account-model created: %d\n", req.AccountID)
 
-	w.WriteHeader(http.StatusCreated) //Empty response is ok
+	//The body carries the account_id, which the service allocates when the request has none
+	resp, err := NewGetAccountDetailsResponse(account)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/accounts/%d", account.ID))
+	w.Header().Set("ETag", accountETag(account.Version))
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, resp)
 }
@@ -9,8 +9,11 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"aeshanw.com/accountApi/api/mocks"
+	"aeshanw.com/accountApi/api/models"
+	accountservice "aeshanw.com/accountApi/api/services/AccountService"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -19,12 +22,15 @@ import (
 func TestCreateAccount(t *testing.T) {
 	mockDB := new(sql.DB)
 	mockAccountService := new(mocks.MockAccountService)
+	createdAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
 	// Test cases
 	tests := []struct {
 		name               string
 		inputTestPath      string
 		expectedStatusCode int
 		expectedResponse   *ErrorResponse
+		expectedLocation   string
+		expectedBody       string
 	}{
 		{
 			name:               "Invalid JSON",
@@ -55,6 +61,18 @@ func TestCreateAccount(t *testing.T) {
 			inputTestPath:      "testdata/validCreateAccountRequest.json",
 			expectedStatusCode: http.StatusCreated,
 			expectedResponse:   nil,
+			expectedLocation:   "/accounts/123",
+			expectedBody: `{"account_id":123,"balance":"100.23344","status":"active","created_at":"2024-05-01T00:00:00Z",` +
+				`"updated_at":"2024-05-01T00:00:00Z","version":1}`,
+		},
+		{
+			name:               "Successful Request: account_id allocated by the service",
+			inputTestPath:      "testdata/validCreateAccountRequest_GeneratedID.json",
+			expectedStatusCode: http.StatusCreated,
+			expectedResponse:   nil,
+			expectedLocation:   "/accounts/100000000190",
+			expectedBody: `{"account_id":100000000190,"balance":"100.23344","status":"active","created_at":"2024-05-01T00:00:00Z",` +
+				`"updated_at":"2024-05-01T00:00:00Z","version":1}`,
 		},
 	}
 
@@ -82,7 +100,13 @@ func TestCreateAccount(t *testing.T) {
 			// Create a ResponseRecorder to capture the response
 			rr := httptest.NewRecorder()
 
-			mockAccountService.On("CreateAccount", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockAccountService.On("CreateAccount", mock.Anything, mock.Anything, mock.MatchedBy(func(req models.CreateAccountRequest) bool {
+				return req.AccountID != 0
+			})).Return(&accountservice.AccountModel{ID: 123, Balance: 100.23344, Status: accountservice.AccountStatusActive,
+				CreatedAt: createdAt, UpdatedAt: createdAt, Version: 1}, nil)
+			mockAccountService.On("CreateAccount", mock.Anything, mock.Anything, models.CreateAccountRequest{InitialBalance: "100.23344"}).
+				Return(&accountservice.AccountModel{ID: 100000000190, Balance: 100.23344, Status: accountservice.AccountStatusActive,
+					CreatedAt: createdAt, UpdatedAt: createdAt, Version: 1}, nil)
 
 			ah := NewAccountHandler(mockDB, mockAccountService)
 
@@ -92,6 +116,11 @@ func TestCreateAccount(t *testing.T) {
 
 			// Check the status code
 			assert.Equal(t, tc.expectedStatusCode, rr.Code)
+			assert.Equal(t, tc.expectedLocation, rr.Header().Get("Location"))
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, rr.Body.String())
+				assert.Equal(t, `"1"`, rr.Header().Get("ETag"))
+			}
 
 			// Check the response body if an error is expected
 			if tc.expectedResponse != nil {
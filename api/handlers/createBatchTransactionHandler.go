@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"aeshanw.com/accountApi/api/logging"
+	"aeshanw.com/accountApi/api/models"
+	transactionservice "aeshanw.com/accountApi/api/services/TransactionService"
+	"github.com/go-chi/render"
+)
+
+type BatchTransactionLegResponse struct {
+	Index                int    `json:"index"`
+	Status               string `json:"status"`
+	TransactionID        string `json:"transaction_id,omitempty"`
+	SourceAccountID      int64  `json:"source_account_id"`
+	DestinationAccountID int64  `json:"destination_account_id"`
+	Amount               string `json:"amount"`
+	Error                string `json:"error,omitempty"`
+}
+
+type CreateBatchTransactionResponse struct {
+	Mode         string                        `json:"mode"`
+	Status       string                        `json:"status"`
+	Transactions []BatchTransactionLegResponse `json:"transactions"`
+}
+
+func (cbtr *CreateBatchTransactionResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	// TODO Pre-processing before a response is marshalled and sent across the wire
+	return nil
+}
+
+func NewCreateBatchTransactionResponse(btm *transactionservice.BatchTransactionModel) (*CreateBatchTransactionResponse, error) {
+	if btm == nil {
+		return nil, errors.New("batchTransactionModel is nil")
+	}
+
+	legs := make([]BatchTransactionLegResponse, len(btm.Legs))
+	for i, leg := range btm.Legs {
+		legs[i] = BatchTransactionLegResponse{
+			Index:                leg.Index,
+			Status:               leg.Status,
+			SourceAccountID:      leg.Request.SourceAccountID,
+			DestinationAccountID: leg.Request.DestinationAccountID,
+			Amount:               leg.Request.Amount,
+		}
+		if leg.Transaction != nil {
+			legs[i].TransactionID = leg.Transaction.ID.String()
+		}
+		if leg.Err != nil {
+			legs[i].Error = legErrorMessage(leg.Err)
+		}
+	}
+
+	return &CreateBatchTransactionResponse{
+		Mode:         btm.Mode,
+		Status:       btm.Status,
+		Transactions: legs,
+	}, nil
+}
+
+// legErrorMessage is the error reported for a failed leg: why the leg was refused, or a generic message when the
+// service failed, whose cause is only logged
+func legErrorMessage(err error) string {
+	if transactionservice.IsRejected(err) {
+		return err.Error()
+	}
+	return ErrInternalServerError.Message
+}
+
+// batchStatusCode maps the overall batch outcome onto the HTTP status returned to the client
+func batchStatusCode(status string) int {
+	switch status {
+	case transactionservice.BatchStatusCommitted:
+		return http.StatusCreated
+	case transactionservice.BatchStatusPartial:
+		return http.StatusMultiStatus
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func (th *TransactionHandler) CreateBatchTransaction(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateBatchTransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, NewDefaultErrorResponse(ErrBadRequest))
+		return
+	}
+
+	if errRes := ValidateCreateBatchTransactionRequest(req); errRes != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, errRes)
+		return
+	}
+
+	for _, leg := range req.Transactions {
+		if !canAccessAccount(r, leg.SourceAccountID) {
+			renderAccountForbidden(w, r, leg.SourceAccountID)
+			return
+		}
+	}
+
+	batch, err := th.transactionservice.CreateBatchTransaction(r.Context(), th.db, req)
+	if transactionservice.IsRejected(err) {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, NewErrorResponse(ErrBadRequest, err.Error()))
+		return
+	}
+	if err != nil {
+		//Nothing was committed and the batch may be sent again, the cause is logged rather than shown to the client
+		logging.FromContext(r.Context()).Error("unable to create batch transaction", slog.String(logging.KeyError, err.Error()))
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewDefaultErrorResponse(ErrInternalServerError))
+		return
+	}
+	for _, leg := range batch.Legs {
+		if leg.Err != nil && !transactionservice.IsRejected(leg.Err) {
+			logging.FromContext(r.Context()).Error("batch transaction leg failed", slog.Int("index", leg.Index), slog.String(logging.KeyError, leg.Err.Error()))
+		}
+	}
+
+	resp, err := NewCreateBatchTransactionResponse(batch)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+		return
+	}
+
+	render.Status(r, batchStatusCode(resp.Status))
+	render.Render(w, r, resp)
+}
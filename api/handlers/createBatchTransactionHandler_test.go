@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"aeshanw.com/accountApi/api/models"
+	transactionservice "aeshanw.com/accountApi/api/services/TransactionService"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateBatchTransaction(t *testing.T) {
+	validLegs := []models.CreateTransactionRequest{
+		{SourceAccountID: 1, DestinationAccountID: 2, Amount: "10.00"},
+		{SourceAccountID: 1, DestinationAccountID: 3, Amount: "20.00"},
+	}
+	firstID := uuid.MustParse("018f3c1e-8a40-7000-8000-000000000011")
+	secondID := uuid.MustParse("018f3c1e-8a40-7000-8000-000000000012")
+	tests := []struct {
+		name           string
+		requestBody    models.CreateBatchTransactionRequest
+		mockSetup      func(m *MockTransactionService)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:        "committed batch",
+			requestBody: models.CreateBatchTransactionRequest{Transactions: validLegs},
+			mockSetup: func(m *MockTransactionService) {
+				m.On("CreateBatchTransaction", mock.Anything, mock.Anything, mock.Anything).
+					Return(&transactionservice.BatchTransactionModel{
+						Mode:   models.BatchModeAtomic,
+						Status: transactionservice.BatchStatusCommitted,
+						Legs: []*transactionservice.BatchLegModel{
+							{Index: 0, Request: validLegs[0], Status: transactionservice.LegStatusSucceeded, Transaction: &transactionservice.TransactionModel{ID: firstID}},
+							{Index: 1, Request: validLegs[1], Status: transactionservice.LegStatusSucceeded, Transaction: &transactionservice.TransactionModel{ID: secondID}},
+						},
+					}, nil)
+			},
+			expectedStatus: http.StatusCreated,
+			expectedBody: `{"mode":"atomic","status":"committed","transactions":[` +
+				`{"index":0,"status":"succeeded","transaction_id":"018f3c1e-8a40-7000-8000-000000000011","source_account_id":1,"destination_account_id":2,"amount":"10.00"},` +
+				`{"index":1,"status":"succeeded","transaction_id":"018f3c1e-8a40-7000-8000-000000000012","source_account_id":1,"destination_account_id":3,"amount":"20.00"}]}`,
+		},
+		{
+			name:        "rolled back batch",
+			requestBody: models.CreateBatchTransactionRequest{Transactions: validLegs},
+			mockSetup: func(m *MockTransactionService) {
+				m.On("CreateBatchTransaction", mock.Anything, mock.Anything, mock.Anything).
+					Return(&transactionservice.BatchTransactionModel{
+						Mode:   models.BatchModeAtomic,
+						Status: transactionservice.BatchStatusRolledBack,
+						Legs: []*transactionservice.BatchLegModel{
+							{Index: 0, Request: validLegs[0], Status: transactionservice.LegStatusRolledBack},
+							{Index: 1, Request: validLegs[1], Status: transactionservice.LegStatusFailed, Err: fmt.Errorf("%w: finalSourceAccountBalance:-10", transactionservice.ErrInsufficientFunds)},
+						},
+					}, nil)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: `{"mode":"atomic","status":"rolled_back","transactions":[` +
+				`{"index":0,"status":"rolled_back","source_account_id":1,"destination_account_id":2,"amount":"10.00"},` +
+				`{"index":1,"status":"failed","source_account_id":1,"destination_account_id":3,"amount":"20.00","error":"source account has insufficent funds: finalSourceAccountBalance:-10"}]}`,
+		},
+		{
+			name:        "partial best effort batch",
+			requestBody: models.CreateBatchTransactionRequest{Mode: models.BatchModeBestEffort, Transactions: validLegs},
+			mockSetup: func(m *MockTransactionService) {
+				m.On("CreateBatchTransaction", mock.Anything, mock.Anything, mock.Anything).
+					Return(&transactionservice.BatchTransactionModel{
+						Mode:   models.BatchModeBestEffort,
+						Status: transactionservice.BatchStatusPartial,
+						Legs: []*transactionservice.BatchLegModel{
+							{Index: 0, Request: validLegs[0], Status: transactionservice.LegStatusSucceeded, Transaction: &transactionservice.TransactionModel{ID: firstID}},
+							{Index: 1, Request: validLegs[1], Status: transactionservice.LegStatusFailed, Err: errors.New("driver: bad connection")},
+						},
+					}, nil)
+			},
+			expectedStatus: http.StatusMultiStatus,
+			expectedBody: `{"mode":"best_effort","status":"partial","transactions":[` +
+				`{"index":0,"status":"succeeded","transaction_id":"018f3c1e-8a40-7000-8000-000000000011","source_account_id":1,"destination_account_id":2,"amount":"10.00"},` +
+				`{"index":1,"status":"failed","source_account_id":1,"destination_account_id":3,"amount":"20.00","error":"An unexpected error occurred on the server."}]}`,
+		},
+		{
+			name:           "empty batch",
+			requestBody:    models.CreateBatchTransactionRequest{},
+			mockSetup:      func(m *MockTransactionService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"Transactions is empty"}`,
+		},
+		{
+			name: "invalid mode",
+			requestBody: models.CreateBatchTransactionRequest{
+				Mode:         "eventually",
+				Transactions: validLegs,
+			},
+			mockSetup:      func(m *MockTransactionService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"Mode must be one of atomic,best_effort"}`,
+		},
+		{
+			name: "invalid leg",
+			requestBody: models.CreateBatchTransactionRequest{
+				Transactions: []models.CreateTransactionRequest{
+					validLegs[0],
+					{SourceAccountID: 1, DestinationAccountID: 0, Amount: "1.00"},
+				},
+			},
+			mockSetup:      func(m *MockTransactionService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"transactions[1]: invalid DestinationAccountID"}`,
+		},
+		{
+			name:        "rejected batch",
+			requestBody: models.CreateBatchTransactionRequest{Transactions: validLegs},
+			mockSetup: func(m *MockTransactionService) {
+				m.On("CreateBatchTransaction", mock.Anything, mock.Anything, mock.Anything).
+					Return(nil, fmt.Errorf("%w due to:unsupported batch mode:eventually", transactionservice.ErrInvalidTransaction))
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"invalid create-transaction-request due to:unsupported batch mode:eventually"}`,
+		},
+		{
+			name:        "service error",
+			requestBody: models.CreateBatchTransactionRequest{Transactions: validLegs},
+			mockSetup: func(m *MockTransactionService) {
+				m.On("CreateBatchTransaction", mock.Anything, mock.Anything, mock.Anything).
+					Return(nil, fmt.Errorf("unable to commit batch-transaction txn due to :driver: bad connection"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":500,"detail":"internal_server_error","message":"An unexpected error occurred on the server."}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(sql.DB)
+			mockService := new(MockTransactionService)
+			tt.mockSetup(mockService)
+
+			handler := NewTransactionHandler(mockDB, mockService)
+
+			body, err := json.Marshal(tt.requestBody)
+			assert.NoError(t, err)
+
+			req, err := http.NewRequest("POST", "/transactions/batch", bytes.NewBuffer(body))
+			assert.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(handler.CreateBatchTransaction).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, strings.TrimSpace(tt.expectedBody), strings.TrimSpace(rr.Body.String()))
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"fmt"
+
+	"aeshanw.com/accountApi/api/models"
+)
+
+// MaxBatchTransactionLegs caps the number of legs accepted in a single batch request
+const MaxBatchTransactionLegs = 100
+
+func ValidateCreateBatchTransactionRequest(req models.CreateBatchTransactionRequest) *ErrorResponse {
+	if req.Mode != "" && req.Mode != models.BatchModeAtomic && req.Mode != models.BatchModeBestEffort {
+		return NewErrorResponse(ErrBadRequest, fmt.Sprintf("Mode must be one of %s,%s", models.BatchModeAtomic, models.BatchModeBestEffort))
+	}
+	if len(req.Transactions) == 0 {
+		return NewErrorResponse(ErrBadRequest, "Transactions is empty")
+	}
+	if len(req.Transactions) > MaxBatchTransactionLegs {
+		return NewErrorResponse(ErrBadRequest, fmt.Sprintf("Transactions cannot exceed %d legs", MaxBatchTransactionLegs))
+	}
+	for i, leg := range req.Transactions {
+		if errRes := ValidateCreateTransactionRequest(leg); errRes != nil {
+			errRes.Message = fmt.Sprintf("transactions[%d]: %s", i, errRes.Message)
+			return errRes
+		}
+	}
+	return nil
+}
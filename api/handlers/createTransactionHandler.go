@@ -3,6 +3,7 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"aeshanw.com/accountApi/api/models"
@@ -38,12 +39,32 @@ func (th *TransactionHandler) CreateTransaction(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	_, err := th.transactionservice.CreateTransaction(r.Context(), th.db, req)
+	//Only the source account is debited, crediting any account is allowed
+	if !canAccessAccount(r, req.SourceAccountID) {
+		renderAccountForbidden(w, r, req.SourceAccountID)
+		return
+	}
+
+	transaction, err := th.transactionservice.CreateTransaction(r.Context(), th.db, req)
+	if errors.Is(err, transactionservice.ErrDuplicateReference) {
+		render.Status(r, http.StatusConflict)
+		render.Render(w, r, NewErrorResponse(ErrConflict, err.Error()))
+		return
+	}
 	if err != nil {
 		render.Status(r, http.StatusBadRequest)
 		render.Render(w, r, NewErrorResponse(ErrBadRequest, err.Error()))
 		return
 	}
+
+	resp, err := NewTransactionResponse(transaction)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+		return
+	}
+
+	w.Header().Set("Location", "/transactions/"+resp.TransactionID)
 	render.Status(r, http.StatusCreated)
-	render.Render(w, r, req)
+	render.Render(w, r, resp)
 }
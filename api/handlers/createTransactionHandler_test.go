@@ -10,10 +10,12 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	transactionservice "aeshanw.com/accountApi/api/services/TransactionService"
 
 	"aeshanw.com/accountApi/api/models"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -31,19 +33,45 @@ func (m *MockTransactionService) CreateTransaction(ctx context.Context, db *sql.
 	return args.Get(0).(*transactionservice.TransactionModel), args.Error(1)
 }
 
+func (m *MockTransactionService) CreateBatchTransaction(ctx context.Context, db *sql.DB, req models.CreateBatchTransactionRequest) (*transactionservice.BatchTransactionModel, error) {
+	args := m.Called(ctx, db, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*transactionservice.BatchTransactionModel), args.Error(1)
+}
+
+func (m *MockTransactionService) GetTransaction(ctx context.Context, db *sql.DB, transactionID string) (*transactionservice.TransactionModel, error) {
+	args := m.Called(ctx, db, transactionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*transactionservice.TransactionModel), args.Error(1)
+}
+
+func (m *MockTransactionService) FindTransactionsByReference(ctx context.Context, db *sql.DB, reference string) ([]*transactionservice.TransactionModel, error) {
+	args := m.Called(ctx, db, reference)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*transactionservice.TransactionModel), args.Error(1)
+}
+
 func TestCreateTransaction(t *testing.T) {
 	validTransactionModel := transactionservice.TransactionModel{
-		ID:                   1,
+		ID:                   uuid.MustParse("018f3c1e-8a40-7000-8000-000000000001"),
 		SourceAccountID:      1,
 		DestinationAccountID: 2,
 		Amount:               100.50,
+		CreatedAt:            time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
 	}
 	tests := []struct {
-		name           string
-		requestBody    models.CreateTransactionRequest
-		mockSetup      func(m *MockTransactionService)
-		expectedStatus int
-		expectedBody   string
+		name             string
+		requestBody      models.CreateTransactionRequest
+		mockSetup        func(m *MockTransactionService)
+		expectedStatus   int
+		expectedBody     string
+		expectedLocation string
 	}{
 		{
 			name: "successful creation",
@@ -56,8 +84,9 @@ func TestCreateTransaction(t *testing.T) {
 				m.On("CreateTransaction", mock.Anything, mock.Anything, mock.Anything).
 					Return(&validTransactionModel, nil)
 			},
-			expectedStatus: http.StatusCreated,
-			expectedBody:   `{"source_account_id":1,"destination_account_id":2,"amount":"100.50"}`,
+			expectedStatus:   http.StatusCreated,
+			expectedBody:     `{"transaction_id":"018f3c1e-8a40-7000-8000-000000000001","source_account_id":1,"destination_account_id":2,"amount":"100.50000","created_at":"2024-05-01T00:00:00Z"}`,
+			expectedLocation: "/transactions/018f3c1e-8a40-7000-8000-000000000001",
 		},
 		{
 			name: "invalid request body",
@@ -97,6 +126,35 @@ func TestCreateTransaction(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			expectedBody:   "{\"status\":400,\"detail\":\"bad_request\",\"message\":\"Amount is empty\"}", // Expected error message for invalid body
 		},
+		{
+			name: "invalid request body - metadata too large",
+			requestBody: models.CreateTransactionRequest{
+				SourceAccountID:      1,
+				DestinationAccountID: 2,
+				Amount:               "50.00",
+				Metadata:             map[string]string{"note": strings.Repeat("x", MaxMetadataValueLength+1)},
+			},
+			mockSetup: func(m *MockTransactionService) {
+				// No mock setup needed for this case
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "{\"status\":400,\"detail\":\"bad_request\",\"message\":\"Metadata value for \\\"note\\\" cannot exceed 512 characters\"}",
+		},
+		{
+			name: "duplicate reference",
+			requestBody: models.CreateTransactionRequest{
+				SourceAccountID:      1,
+				DestinationAccountID: 2,
+				Amount:               "100.50",
+				Reference:            "inv-1",
+			},
+			mockSetup: func(m *MockTransactionService) {
+				m.On("CreateTransaction", mock.Anything, mock.Anything, mock.Anything).
+					Return(nil, fmt.Errorf("unable to insert new transaction due to :%w", transactionservice.ErrDuplicateReference))
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   "{\"status\":409,\"detail\":\"conflict\",\"message\":\"unable to insert new transaction due to :reference already used for this source account\"}",
+		},
 		{
 			name: "service error",
 			requestBody: models.CreateTransactionRequest{
@@ -141,6 +199,7 @@ func TestCreateTransaction(t *testing.T) {
 
 			// Check the response body
 			assert.Equal(t, strings.TrimSpace(tt.expectedBody), strings.TrimSpace(rr.Body.String()))
+			assert.Equal(t, tt.expectedLocation, rr.Header().Get("Location"))
 
 			// Assert that the mock expectations were met
 			mockService.AssertExpectations(t)
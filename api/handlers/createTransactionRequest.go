@@ -1,6 +1,20 @@
 package handlers
 
-import "aeshanw.com/accountApi/api/models"
+import (
+	"fmt"
+
+	"aeshanw.com/accountApi/api/models"
+)
+
+const (
+	MaxTransactionReferenceLength   = 128
+	MaxTransactionDescriptionLength = 1024
+
+	// Limits on the free-form metadata maps of transactions and accounts
+	MaxMetadataEntries     = 20
+	MaxMetadataKeyLength   = 64
+	MaxMetadataValueLength = 512
+)
 
 func ValidateCreateTransactionRequest(req models.CreateTransactionRequest) *ErrorResponse {
 	if req.SourceAccountID <= 0 {
@@ -12,5 +26,26 @@ func ValidateCreateTransactionRequest(req models.CreateTransactionRequest) *Erro
 	if req.Amount == "" {
 		return NewErrorResponse(ErrBadRequest, "Amount is empty")
 	}
+	if len(req.Reference) > MaxTransactionReferenceLength {
+		return NewErrorResponse(ErrBadRequest, fmt.Sprintf("Reference cannot exceed %d characters", MaxTransactionReferenceLength))
+	}
+	if len(req.Description) > MaxTransactionDescriptionLength {
+		return NewErrorResponse(ErrBadRequest, fmt.Sprintf("Description cannot exceed %d characters", MaxTransactionDescriptionLength))
+	}
+	return validateMetadata(req.Metadata)
+}
+
+func validateMetadata(metadata map[string]string) *ErrorResponse {
+	if len(metadata) > MaxMetadataEntries {
+		return NewErrorResponse(ErrBadRequest, fmt.Sprintf("Metadata cannot exceed %d entries", MaxMetadataEntries))
+	}
+	for key, value := range metadata {
+		if key == "" || len(key) > MaxMetadataKeyLength {
+			return NewErrorResponse(ErrBadRequest, fmt.Sprintf("Metadata keys must be 1-%d characters", MaxMetadataKeyLength))
+		}
+		if len(value) > MaxMetadataValueLength {
+			return NewErrorResponse(ErrBadRequest, fmt.Sprintf("Metadata value for %q cannot exceed %d characters", key, MaxMetadataValueLength))
+		}
+	}
 	return nil
 }
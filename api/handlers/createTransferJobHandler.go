@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"aeshanw.com/accountApi/api/logging"
+	transferjobservice "aeshanw.com/accountApi/api/services/TransferJobService"
+	"github.com/go-chi/render"
+)
+
+// MaxTransferJobUploadBytes is the default cap on the size of an uploaded bulk transfer file
+const MaxTransferJobUploadBytes = 10 << 20
+
+type TransferJobHandler struct {
+	db                 *sql.DB
+	transferjobservice transferjobservice.TransferJobServiceInt
+	maxUploadBytes     int64
+}
+
+// NewTransferJobHandler creates a new instance of Handlers with the provided dependencies.
+func NewTransferJobHandler(db *sql.DB, tjs transferjobservice.TransferJobServiceInt) *TransferJobHandler {
+	return NewTransferJobHandlerWithUploadLimit(db, tjs, MaxTransferJobUploadBytes)
+}
+
+// NewTransferJobHandlerWithUploadLimit accepts uploaded files of up to maxUploadBytes instead of MaxTransferJobUploadBytes
+func NewTransferJobHandlerWithUploadLimit(db *sql.DB, tjs transferjobservice.TransferJobServiceInt, maxUploadBytes int64) *TransferJobHandler {
+	return &TransferJobHandler{
+		db:                 db,
+		transferjobservice: tjs,
+		maxUploadBytes:     maxUploadBytes,
+	}
+}
+
+type TransferJobResponse struct {
+	JobID         int64     `json:"job_id"`
+	Status        string    `json:"status"`
+	TotalRows     int       `json:"total_rows"`
+	PendingRows   int       `json:"pending_rows"`
+	SucceededRows int       `json:"succeeded_rows"`
+	FailedRows    int       `json:"failed_rows"`
+	DuplicateRows int       `json:"duplicate_rows"`
+	InvalidRows   int       `json:"invalid_rows"`
+	ResultURL     string    `json:"result_url"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (tjr *TransferJobResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	// TODO Pre-processing before a response is marshalled and sent across the wire
+	return nil
+}
+
+func NewTransferJobResponse(job *transferjobservice.TransferJobModel) (*TransferJobResponse, error) {
+	if job == nil {
+		return nil, errors.New("transferJobModel is nil")
+	}
+
+	return &TransferJobResponse{
+		JobID:         job.ID,
+		Status:        job.Status,
+		TotalRows:     job.TotalRows,
+		PendingRows:   job.RowCounts[transferjobservice.RowStatusPending],
+		SucceededRows: job.RowCounts[transferjobservice.RowStatusSucceeded],
+		FailedRows:    job.RowCounts[transferjobservice.RowStatusFailed],
+		DuplicateRows: job.RowCounts[transferjobservice.RowStatusDuplicate],
+		InvalidRows:   job.RowCounts[transferjobservice.RowStatusInvalid],
+		ResultURL:     fmt.Sprintf("/transactions/bulk/%d/result", job.ID),
+		CreatedAt:     job.CreatedAt,
+		UpdatedAt:     job.UpdatedAt,
+	}, nil
+}
+
+// transferJobUpload returns the uploaded file, accepting either a multipart form with a "file" field or a raw CSV body
+func transferJobUpload(r *http.Request) (io.ReadCloser, error) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.Body, nil
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, fmt.Errorf("multipart upload requires a file field: %w", err)
+	}
+	return file, nil
+}
+
+func (tjh *TransferJobHandler) CreateTransferJob(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, tjh.maxUploadBytes)
+
+	file, err := transferJobUpload(r)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, NewErrorResponse(ErrBadRequest, err.Error()))
+		return
+	}
+	defer file.Close()
+
+	req, errRes := ParseTransferJobCSV(file)
+	if errRes != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, errRes)
+		return
+	}
+
+	job, created, err := tjh.transferjobservice.CreateJob(r.Context(), tjh.db, req)
+	if errors.Is(err, transferjobservice.ErrInvalidJob) {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, NewErrorResponse(ErrBadRequest, err.Error()))
+		return
+	}
+	if err != nil {
+		//The upload may be sent again as is, the cause is logged rather than shown to the client
+		logging.FromContext(r.Context()).Error("unable to create transfer job", slog.String(logging.KeyError, err.Error()))
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewDefaultErrorResponse(ErrInternalServerError))
+		return
+	}
+
+	resp, err := NewTransferJobResponse(job)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/transactions/bulk/%d", job.ID))
+	if created {
+		render.Status(r, http.StatusAccepted)
+	} else {
+		//Same file was uploaded before, nothing new will be paid
+		render.Status(r, http.StatusOK)
+	}
+	render.Render(w, r, resp)
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"aeshanw.com/accountApi/api/mocks"
+	"aeshanw.com/accountApi/api/models"
+	transferjobservice "aeshanw.com/accountApi/api/services/TransferJobService"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestParseTransferJobCSV(t *testing.T) {
+	file, err := os.Open("testdata/validTransferJob.csv")
+	assert.NoError(t, err)
+	defer file.Close()
+
+	req, errRes := ParseTransferJobCSV(file)
+	assert.Nil(t, errRes)
+	assert.Len(t, req.FileSHA256, 64)
+
+	expectedErrors := []string{
+		"",
+		`strconv.ParseFloat: parsing "abc": invalid syntax`,
+		"sourceAccountID and destinationAccountID cannot be the same",
+		"source_account_id must be an integer",
+		"reference is empty",
+	}
+	assert.Len(t, req.Rows, len(expectedErrors))
+	for i, row := range req.Rows {
+		assert.Equal(t, i+1, row.RowNumber)
+		assert.Equal(t, expectedErrors[i], row.Error, "row %d", row.RowNumber)
+	}
+	assert.Equal(t, models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "10.00"}, req.Rows[0].Transaction)
+	assert.Equal(t, "inv-1", req.Rows[0].Reference)
+}
+
+func TestCreateTransferJob(t *testing.T) {
+	job := &transferjobservice.TransferJobModel{
+		ID:        7,
+		Status:    transferjobservice.JobStatusPending,
+		TotalRows: 5,
+		RowCounts: map[string]int{transferjobservice.RowStatusPending: 1, transferjobservice.RowStatusInvalid: 4},
+		CreatedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name               string
+		inputTestPath      string
+		multipart          bool
+		mockSetup          func(m *mocks.MockTransferJobService)
+		expectedStatusCode int
+		expectedMessage    string
+	}{
+		{
+			name:          "new file is accepted as a job",
+			inputTestPath: "testdata/validTransferJob.csv",
+			mockSetup: func(m *mocks.MockTransferJobService) {
+				m.On("CreateJob", mock.Anything, mock.Anything, mock.Anything).Return(job, true, nil)
+			},
+			expectedStatusCode: http.StatusAccepted,
+		},
+		{
+			name:          "multipart upload",
+			inputTestPath: "testdata/validTransferJob.csv",
+			multipart:     true,
+			mockSetup: func(m *mocks.MockTransferJobService) {
+				m.On("CreateJob", mock.Anything, mock.Anything, mock.Anything).Return(job, true, nil)
+			},
+			expectedStatusCode: http.StatusAccepted,
+		},
+		{
+			name:          "re-uploaded file returns the existing job",
+			inputTestPath: "testdata/validTransferJob.csv",
+			mockSetup: func(m *mocks.MockTransferJobService) {
+				m.On("CreateJob", mock.Anything, mock.Anything, mock.Anything).Return(job, false, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "invalid header",
+			inputTestPath:      "testdata/invalidTransferJob_Header.csv",
+			mockSetup:          func(m *mocks.MockTransferJobService) {},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    "CSV header must be source_account_id,destination_account_id,amount,reference",
+		},
+		{
+			name:          "invalid job",
+			inputTestPath: "testdata/validTransferJob.csv",
+			mockSetup: func(m *mocks.MockTransferJobService) {
+				m.On("CreateJob", mock.Anything, mock.Anything, mock.Anything).Return(nil, false, fmt.Errorf("%w: transfer job has no rows", transferjobservice.ErrInvalidJob))
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMessage:    "invalid transfer job: transfer job has no rows",
+		},
+		{
+			name:          "service error",
+			inputTestPath: "testdata/validTransferJob.csv",
+			mockSetup: func(m *mocks.MockTransferJobService) {
+				m.On("CreateJob", mock.Anything, mock.Anything, mock.Anything).Return(nil, false, errors.New("driver: bad connection"))
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedMessage:    "An unexpected error occurred on the server.",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			csvData, err := os.ReadFile(tc.inputTestPath)
+			if err != nil {
+				t.Fatalf("Failed to read file: %v", err)
+			}
+
+			body := bytes.NewBuffer(csvData)
+			contentType := "text/csv"
+			if tc.multipart {
+				body = &bytes.Buffer{}
+				mw := multipart.NewWriter(body)
+				part, err := mw.CreateFormFile("file", "payouts.csv")
+				assert.NoError(t, err)
+				part.Write(csvData)
+				mw.Close()
+				contentType = mw.FormDataContentType()
+			}
+
+			req, err := http.NewRequest(http.MethodPost, "/transactions/bulk", body)
+			assert.NoError(t, err)
+			req.Header.Set("Content-Type", contentType)
+
+			mockService := new(mocks.MockTransferJobService)
+			tc.mockSetup(mockService)
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(NewTransferJobHandler(new(sql.DB), mockService).CreateTransferJob).ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code)
+			if tc.expectedMessage != "" {
+				var response ErrorResponse
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+				assert.Equal(t, tc.expectedMessage, response.Message)
+			} else {
+				assert.Equal(t, "/transactions/bulk/7", rr.Header().Get("Location"))
+				assert.JSONEq(t, `{"job_id":7,"status":"pending","total_rows":5,"pending_rows":1,"succeeded_rows":0,"failed_rows":0,`+
+					`"duplicate_rows":0,"invalid_rows":4,"result_url":"/transactions/bulk/7/result",`+
+					`"created_at":"2024-05-01T00:00:00Z","updated_at":"2024-05-01T00:00:00Z"}`, rr.Body.String())
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
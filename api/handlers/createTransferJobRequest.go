@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"aeshanw.com/accountApi/api/models"
+	transactionservice "aeshanw.com/accountApi/api/services/TransactionService"
+)
+
+const (
+	// MaxTransferJobRows caps the number of rows accepted in a single bulk transfer file
+	MaxTransferJobRows = 10000
+	// MaxTransferJobReferenceLength caps the length of the reference column
+	MaxTransferJobReferenceLength = 128
+)
+
+// TransferJobCSVHeader is the header row expected at the top of every bulk transfer file
+var TransferJobCSVHeader = []string{"source_account_id", "destination_account_id", "amount", "reference"}
+
+// ParseTransferJobCSV reads a bulk transfer file. Rows failing validation are kept with their error so they show up
+// in the job's result report, only an unreadable file is rejected outright.
+func ParseTransferJobCSV(r io.Reader) (models.CreateTransferJobRequest, *ErrorResponse) {
+	var req models.CreateTransferJobRequest
+
+	hash := sha256.New()
+	reader := csv.NewReader(io.TeeReader(r, hash))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return req, NewErrorResponse(ErrBadRequest, "CSV file is empty")
+	}
+	if err != nil {
+		return req, NewErrorResponse(ErrBadRequest, fmt.Sprintf("invalid CSV header: %v", err))
+	}
+	if !isCSVHeader(header, TransferJobCSVHeader) {
+		return req, NewErrorResponse(ErrBadRequest, fmt.Sprintf("CSV header must be %s", strings.Join(TransferJobCSVHeader, ",")))
+	}
+
+	for rowNumber := 1; ; rowNumber++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return req, NewErrorResponse(ErrBadRequest, fmt.Sprintf("invalid CSV at row %d: %v", rowNumber, err))
+		}
+		if len(req.Rows) == MaxTransferJobRows {
+			return req, NewErrorResponse(ErrBadRequest, fmt.Sprintf("CSV file cannot exceed %d rows", MaxTransferJobRows))
+		}
+		req.Rows = append(req.Rows, NewTransferJobRow(rowNumber, record))
+	}
+
+	if len(req.Rows) == 0 {
+		return req, NewErrorResponse(ErrBadRequest, "CSV file has no rows")
+	}
+
+	req.FileSHA256 = hex.EncodeToString(hash.Sum(nil))
+	return req, nil
+}
+
+// isCSVHeader compares an uploaded header row against the expected columns, ignoring case and surrounding spaces
+func isCSVHeader(header []string, expected []string) bool {
+	if len(header) != len(expected) {
+		return false
+	}
+	for i, column := range header {
+		//Spreadsheet exports commonly prefix the first cell with a UTF-8 BOM
+		column = strings.TrimPrefix(column, "\ufeff")
+		if !strings.EqualFold(strings.TrimSpace(column), expected[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewTransferJobRow validates a CSV record with the same rules as a single POST /transactions request
+func NewTransferJobRow(rowNumber int, record []string) models.TransferJobRow {
+	row := models.TransferJobRow{RowNumber: rowNumber}
+
+	if len(record) != len(TransferJobCSVHeader) {
+		row.Error = fmt.Sprintf("expected %d columns, got %d", len(TransferJobCSVHeader), len(record))
+		return row
+	}
+
+	sourceAccountID, err := strconv.ParseInt(strings.TrimSpace(record[0]), 10, 64)
+	if err != nil {
+		row.Error = "source_account_id must be an integer"
+		return row
+	}
+	destinationAccountID, err := strconv.ParseInt(strings.TrimSpace(record[1]), 10, 64)
+	if err != nil {
+		row.Error = "destination_account_id must be an integer"
+		return row
+	}
+
+	row.Transaction = models.CreateTransactionRequest{
+		SourceAccountID:      sourceAccountID,
+		DestinationAccountID: destinationAccountID,
+		Amount:               strings.TrimSpace(record[2]),
+	}
+	row.Reference = strings.TrimSpace(record[3])
+
+	if errRes := ValidateCreateTransactionRequest(row.Transaction); errRes != nil {
+		row.Error = errRes.Message
+		return row
+	}
+	if err := transactionservice.NewTransactionModel().SetFromRequest(row.Transaction); err != nil {
+		row.Error = err.Error()
+		return row
+	}
+	if row.Reference == "" {
+		row.Error = "reference is empty"
+		return row
+	}
+	if len(row.Reference) > MaxTransferJobReferenceLength {
+		row.Error = fmt.Sprintf("reference cannot exceed %d characters", MaxTransferJobReferenceLength)
+		return row
+	}
+
+	return row
+}
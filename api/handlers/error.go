@@ -1,7 +1,14 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"net/http"
+
+	"github.com/go-chi/render"
+
+	"aeshanw.com/accountApi/api/breaker"
+	"aeshanw.com/accountApi/api/tracing"
 )
 
 // ErrorResponse: Generic ErrorResponse
@@ -9,6 +16,10 @@ type ErrorResponse struct {
 	StatusCode int    `json:"status"`
 	Error      string `json:"detail"`
 	Message    string `json:"message,omitempty"`
+	// Retryable tells clients the request had no effect and can be sent again as is
+	Retryable bool `json:"retryable,omitempty"`
+	// TraceID lets clients quote the failing request's trace when reporting a problem
+	TraceID string `json:"trace_id,omitempty"`
 }
 
 // NewDefaultErrorResponse: for default errors that need no override of the message
@@ -24,8 +35,18 @@ func NewErrorResponse(err ErrorResponse, msg string) *ErrorResponse {
 	return &err
 }
 
+// Render answers ErrRequestTimeout instead once the request's deadline has passed: whatever error the service
+// returned is then a consequence of the timeout, e.g. a cancelled statement, and its DB txn has been rolled back.
+// Likewise it answers ErrServiceUnavailable when the request could not connect to the database.
 func (re *ErrorResponse) Render(w http.ResponseWriter, r *http.Request) error {
-	// TODO Pre-processing before a response is marshalled and sent across the wire
+	if breaker.Unavailable(r.Context()) && re.StatusCode != http.StatusServiceUnavailable {
+		*re = *NewErrorResponse(ErrServiceUnavailable, databaseUnavailableMessage)
+		render.Status(r, http.StatusServiceUnavailable)
+	} else if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+		*re = ErrRequestTimeout
+		render.Status(r, ErrRequestTimeout.StatusCode)
+	}
+	re.TraceID = tracing.TraceID(r.Context())
 	return nil
 }
 
@@ -40,11 +61,37 @@ var (
 		Error:      "unauthorized",
 		Message:    "Authentication failed or user does not have permissions for the requested operation.",
 	}
+	ErrForbidden = ErrorResponse{
+		StatusCode: http.StatusForbidden,
+		Error:      "forbidden",
+		Message:    "The credentials are valid but do not grant access to the requested operation.",
+	}
 	ErrNotFound = ErrorResponse{
 		StatusCode: http.StatusNotFound,
 		Error:      "not_found",
 		Message:    "The requested resource could not be found.",
 	}
+	ErrConflict = ErrorResponse{
+		StatusCode: http.StatusConflict,
+		Error:      "conflict",
+		Message:    "The request conflicts with the current state of the resource.",
+	}
+	ErrPreconditionFailed = ErrorResponse{
+		StatusCode: http.StatusPreconditionFailed,
+		Error:      "precondition_failed",
+		Message:    "The resource changed since the version named by the If-Match header.",
+	}
+	ErrPayloadTooLarge = ErrorResponse{
+		StatusCode: http.StatusRequestEntityTooLarge,
+		Error:      "payload_too_large",
+		Message:    "The request body exceeds the allowed size.",
+	}
+	ErrTooManyRequests = ErrorResponse{
+		StatusCode: http.StatusTooManyRequests,
+		Error:      "too_many_requests",
+		Message:    "Too many requests. Please retry after the time given in the Retry-After header.",
+		Retryable:  true,
+	}
 	ErrInternalServerError = ErrorResponse{
 		StatusCode: http.StatusInternalServerError,
 		Error:      "internal_server_error",
@@ -54,5 +101,12 @@ var (
 		StatusCode: http.StatusServiceUnavailable,
 		Error:      "service_unavailable",
 		Message:    "The service is temporarily unavailable. Please try again later.",
+		Retryable:  true,
+	}
+	ErrRequestTimeout = ErrorResponse{
+		StatusCode: http.StatusGatewayTimeout,
+		Error:      "request_timeout",
+		Message:    "The request did not complete in time and its changes were rolled back. Please try again.",
+		Retryable:  true,
 	}
 )
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/render"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestErrorResponseCarriesTraceID(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/accounts/7", nil).WithContext(ctx)
+	render.Status(req, http.StatusNotFound)
+	render.Render(rr, req, NewErrorResponse(ErrNotFound, "account not found"))
+
+	assert.JSONEq(t, `{"status":404,"detail":"not_found","message":"account not found","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"}`, rr.Body.String())
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// accountETag is the strong entity tag of an account at version, the version is bumped on every change to the row
+func accountETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// entityTags returns the entity tags listed by every instance of a conditional header, nil when it is absent
+func entityTags(r *http.Request, header string) []string {
+	var tags []string
+	for _, value := range r.Header.Values(header) {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// notModified reports whether If-None-Match names etag or is "*". It uses the weak comparison, so W/"3" matches "3".
+func notModified(r *http.Request, etag string) bool {
+	for _, tag := range entityTags(r, "If-None-Match") {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersions returns the account versions an If-Match header allows, nil when the request is unconditional.
+// If-Match uses the strong comparison, weak tags and tags that are not an account version can never match and
+// leave the list empty rather than nil.
+func ifMatchVersions(r *http.Request) []int64 {
+	tags := entityTags(r, "If-Match")
+	if tags == nil {
+		return nil
+	}
+	versions := []int64{}
+	for _, tag := range tags {
+		if tag == "*" {
+			//Any current representation matches, and the update fails anyway when the account does not exist
+			return nil
+		}
+		unquoted, ok := strings.CutPrefix(tag, `"`)
+		if !ok {
+			continue
+		}
+		if unquoted, ok = strings.CutSuffix(unquoted, `"`); !ok {
+			continue
+		}
+		if version, err := strconv.ParseInt(unquoted, 10, 64); err == nil {
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	accountservice "aeshanw.com/accountApi/api/services/AccountService"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+type GetAccountBalanceResponse struct {
+	AccountID int64     `json:"account_id"`
+	Balance   string    `json:"balance"`
+	AsOf      time.Time `json:"as_of"`
+}
+
+func (gabr *GetAccountBalanceResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	// TODO Pre-processing before a response is marshalled and sent across the wire
+	return nil
+}
+
+func NewGetAccountBalanceResponse(bm *accountservice.BalanceModel) *GetAccountBalanceResponse {
+	//Formatted like GET /accounts/{account_id} so the two can be compared as is
+	return &GetAccountBalanceResponse{
+		AccountID: bm.AccountID,
+		Balance:   fmt.Sprintf("%.5f", bm.Balance),
+		AsOf:      bm.AsOf,
+	}
+}
+
+// GetAccountBalance answers the balance an account had at the as_of query parameter, the current one without it.
+func (ah *AccountHandler) GetAccountBalance(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.ParseInt(chi.URLParam(r, "account_id"), 10, 64)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, NewErrorResponse(ErrBadRequest, "account_id parameter must be an integer"))
+		return
+	}
+	if !canAccessAccount(r, accountID) {
+		renderAccountForbidden(w, r, accountID)
+		return
+	}
+
+	asOf, errRes := parseTimeParam(r.URL.Query(), "as_of")
+	if errRes != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, errRes)
+		return
+	}
+	if asOf == nil {
+		asOf = &time.Time{}
+	}
+
+	balance, err := ah.accountservice.GetBalance(r.Context(), ah.db, accountID, *asOf)
+	if errors.Is(err, accountservice.ErrAccountNotFound) || errors.Is(err, accountservice.ErrNotOpenAsOf) {
+		render.Status(r, http.StatusNotFound)
+		render.Render(w, r, NewErrorResponse(ErrNotFound, err.Error()))
+		return
+	}
+	if errors.Is(err, accountservice.ErrAsOfInFuture) {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, NewErrorResponse(ErrBadRequest, err.Error()))
+		return
+	}
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, NewGetAccountBalanceResponse(balance))
+}
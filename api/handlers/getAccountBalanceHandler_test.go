@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aeshanw.com/accountApi/api/mocks"
+	accountservice "aeshanw.com/accountApi/api/services/AccountService"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetAccountBalance(t *testing.T) {
+	monthEnd := time.Date(2024, 5, 31, 23, 59, 59, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		url            string
+		mockSetup      func(m *mocks.MockAccountService)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "as of month end",
+			url:  "/accounts/1/balance?as_of=2024-05-31T23:59:59Z",
+			mockSetup: func(m *mocks.MockAccountService) {
+				m.On("GetBalance", mock.Anything, mock.Anything, int64(1), monthEnd).
+					Return(&accountservice.BalanceModel{AccountID: 1, Balance: 80.5, AsOf: monthEnd}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"account_id":1,"balance":"80.50000","as_of":"2024-05-31T23:59:59Z"}`,
+		},
+		{
+			name: "current balance",
+			url:  "/accounts/1/balance",
+			mockSetup: func(m *mocks.MockAccountService) {
+				m.On("GetBalance", mock.Anything, mock.Anything, int64(1), time.Time{}).
+					Return(&accountservice.BalanceModel{AccountID: 1, Balance: 100, AsOf: monthEnd}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"account_id":1,"balance":"100.00000","as_of":"2024-05-31T23:59:59Z"}`,
+		},
+		{
+			name: "before the account was created",
+			url:  "/accounts/1/balance?as_of=2024-05-31T23:59:59Z",
+			mockSetup: func(m *mocks.MockAccountService) {
+				m.On("GetBalance", mock.Anything, mock.Anything, int64(1), monthEnd).Return(nil, accountservice.ErrNotOpenAsOf)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":404,"detail":"not_found","message":"account did not exist at as_of"}`,
+		},
+		{
+			name: "account not found",
+			url:  "/accounts/2/balance?as_of=2024-05-31T23:59:59Z",
+			mockSetup: func(m *mocks.MockAccountService) {
+				m.On("GetBalance", mock.Anything, mock.Anything, int64(2), monthEnd).Return(nil, accountservice.ErrAccountNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":404,"detail":"not_found","message":"account not found"}`,
+		},
+		{
+			name: "as of in the future",
+			url:  "/accounts/1/balance?as_of=2024-05-31T23:59:59Z",
+			mockSetup: func(m *mocks.MockAccountService) {
+				m.On("GetBalance", mock.Anything, mock.Anything, int64(1), monthEnd).Return(nil, accountservice.ErrAsOfInFuture)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"as_of is in the future"}`,
+		},
+		{
+			name: "service error",
+			url:  "/accounts/1/balance?as_of=2024-05-31T23:59:59Z",
+			mockSetup: func(m *mocks.MockAccountService) {
+				m.On("GetBalance", mock.Anything, mock.Anything, int64(1), monthEnd).Return(nil, errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":500,"detail":"internal_server_error","message":"database error"}`,
+		},
+		{
+			name:           "invalid as of",
+			url:            "/accounts/1/balance?as_of=2024-05-31",
+			mockSetup:      func(m *mocks.MockAccountService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"as_of must be an RFC 3339 timestamp"}`,
+		},
+		{
+			name:           "invalid account id",
+			url:            "/accounts/abc/balance",
+			mockSetup:      func(m *mocks.MockAccountService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"account_id parameter must be an integer"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAccountService := new(mocks.MockAccountService)
+			tt.mockSetup(mockAccountService)
+
+			r := chi.NewRouter()
+			r.Get("/accounts/{account_id}/balance", NewAccountHandler(new(sql.DB), mockAccountService).GetAccountBalance)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			mockAccountService.AssertExpectations(t)
+		})
+	}
+}
@@ -3,17 +3,30 @@ package handlers
 import (
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
+	"aeshanw.com/accountApi/api/logging"
 	accountservice "aeshanw.com/accountApi/api/services/AccountService"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 )
 
 type GetAccountDetailsResponse struct {
-	AccountID int64  `json:"account_id"`
-	Balance   string `json:"balance"`
+	AccountID      int64             `json:"account_id"`
+	Balance        string            `json:"balance"`
+	DisplayName    string            `json:"display_name,omitempty"`
+	OwnerReference string            `json:"owner_reference,omitempty"`
+	AccountType    string            `json:"account_type,omitempty"`
+	Currency       string            `json:"currency,omitempty"`
+	Status         string            `json:"status"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+	// Version is also sent as the ETag of the account's own responses
+	Version int64 `json:"version"`
 }
 
 func (gadr *GetAccountDetailsResponse) Render(w http.ResponseWriter, r *http.Request) error {
@@ -29,8 +42,17 @@ func NewGetAccountDetailsResponse(am *accountservice.AccountModel) (*GetAccountD
 	formattedBalance := fmt.Sprintf("%.5f", am.Balance)
 
 	return &GetAccountDetailsResponse{
-		AccountID: am.ID,
-		Balance:   formattedBalance,
+		AccountID:      am.ID,
+		Balance:        formattedBalance,
+		DisplayName:    am.DisplayName,
+		OwnerReference: am.OwnerReference,
+		AccountType:    am.AccountType,
+		Currency:       am.Currency,
+		Status:         am.Status,
+		Metadata:       am.Metadata,
+		CreatedAt:      am.CreatedAt,
+		UpdatedAt:      am.UpdatedAt,
+		Version:        am.Version,
 	}, nil
 }
 
@@ -50,15 +72,28 @@ func (ah *AccountHandler) GetAccountDetails(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if !canAccessAccount(r, accountID) {
+		renderAccountForbidden(w, r, accountID)
+		return
+	}
+
 	//ServiceMethod to Validate & Get AccountDetails from DB
 	accountModel, err := ah.accountservice.GetAccount(r.Context(), ah.db, int64(accountID))
 	if err != nil {
-		fmt.Printf("TEST err:%v\n", err)
+		logging.FromContext(r.Context()).Warn("unable to get account", slog.Int64(logging.KeyAccountID, accountID), slog.String(logging.KeyError, err.Error()))
 		render.Status(r, http.StatusBadRequest)
 		render.Render(w, r, NewErrorResponse(ErrBadRequest, err.Error()))
 		return
 	}
 
+	//A client polling with the ETag it already holds is answered without a body
+	etag := accountETag(accountModel.Version)
+	w.Header().Set("ETag", etag)
+	if notModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	resp, err := NewGetAccountDetailsResponse(accountModel)
 	if err != nil {
 		render.Status(r, http.StatusInternalServerError)
@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"strconv"
 	"testing"
+	"time"
 
 	"aeshanw.com/accountApi/api/mocks"
 	accountservice "aeshanw.com/accountApi/api/services/AccountService"
@@ -21,8 +22,17 @@ func TestGetAccountDetails(t *testing.T) {
 
 	accountID := int64(1)
 	accountModel := &accountservice.AccountModel{
-		ID:      accountID,
-		Balance: 100.23344,
+		ID:             accountID,
+		Balance:        100.23344,
+		DisplayName:    "Main wallet",
+		OwnerReference: "cust-1",
+		AccountType:    "personal",
+		Currency:       "SGD",
+		Status:         accountservice.AccountStatusActive,
+		Metadata:       map[string]string{"tier": "gold"},
+		CreatedAt:      time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		UpdatedAt:      time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC),
+		Version:        3,
 	}
 
 	mockAccountService.On("GetAccount", mock.Anything, mock.Anything, accountID).Return(accountModel, nil)
@@ -42,10 +52,49 @@ func TestGetAccountDetails(t *testing.T) {
 	r.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusOK, rr.Code)
-	expectedResponse := `{"account_id":1,"balance":"100.23344"}`
+	assert.Equal(t, `"3"`, rr.Header().Get("ETag"))
+	expectedResponse := `{"account_id":1,"balance":"100.23344","display_name":"Main wallet","owner_reference":"cust-1","account_type":"personal",` +
+		`"currency":"SGD","status":"active","metadata":{"tier":"gold"},"created_at":"2024-05-01T00:00:00Z","updated_at":"2024-05-02T00:00:00Z","version":3}`
 	assert.JSONEq(t, expectedResponse, rr.Body.String())
 }
 
+func TestGetAccountDetails_IfNoneMatch(t *testing.T) {
+	accountModel := &accountservice.AccountModel{ID: 1, Balance: 100, Status: accountservice.AccountStatusActive, Version: 3}
+
+	tests := []struct {
+		name           string
+		ifNoneMatch    string
+		expectedStatus int
+	}{
+		{name: "current version", ifNoneMatch: `"3"`, expectedStatus: http.StatusNotModified},
+		{name: "weak tag of the current version", ifNoneMatch: `W/"3"`, expectedStatus: http.StatusNotModified},
+		{name: "list holding the current version", ifNoneMatch: `"1", "3"`, expectedStatus: http.StatusNotModified},
+		{name: "any version", ifNoneMatch: `*`, expectedStatus: http.StatusNotModified},
+		{name: "older version", ifNoneMatch: `"2"`, expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAccountService := new(mocks.MockAccountService)
+			mockAccountService.On("GetAccount", mock.Anything, mock.Anything, int64(1)).Return(accountModel, nil)
+
+			r := chi.NewRouter()
+			r.Get("/accounts/{account_id}", NewAccountHandler(new(sql.DB), mockAccountService).GetAccountDetails)
+
+			req := httptest.NewRequest(http.MethodGet, "/accounts/1", nil)
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, `"3"`, rr.Header().Get("ETag"))
+			if tt.expectedStatus == http.StatusNotModified {
+				assert.Empty(t, rr.Body.String())
+			}
+		})
+	}
+}
+
 func TestGetAccountDetails_InvalidAccountID(t *testing.T) {
 	mockDB := new(sql.DB)
 	mockAccountService := new(mocks.MockAccountService)
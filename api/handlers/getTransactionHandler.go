@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	transactionservice "aeshanw.com/accountApi/api/services/TransactionService"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+type TransactionResponse struct {
+	TransactionID string `json:"transaction_id"`
+	// LegacyTransactionID is the integer ID of transactions created before UUIDs were introduced
+	LegacyTransactionID  int64             `json:"legacy_transaction_id,omitempty"`
+	SourceAccountID      int64             `json:"source_account_id"`
+	DestinationAccountID int64             `json:"destination_account_id"`
+	Amount               string            `json:"amount"`
+	Reference            string            `json:"reference,omitempty"`
+	Description          string            `json:"description,omitempty"`
+	Metadata             map[string]string `json:"metadata,omitempty"`
+	CreatedAt            time.Time         `json:"created_at"`
+}
+
+func (tr *TransactionResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	// TODO Pre-processing before a response is marshalled and sent across the wire
+	return nil
+}
+
+func NewTransactionResponse(tm *transactionservice.TransactionModel) (*TransactionResponse, error) {
+	if tm == nil {
+		return nil, errors.New("transactionModel is nil")
+	}
+
+	return &TransactionResponse{
+		TransactionID:        tm.ID.String(),
+		LegacyTransactionID:  tm.LegacyID,
+		SourceAccountID:      tm.SourceAccountID,
+		DestinationAccountID: tm.DestinationAccountID,
+		Amount:               fmt.Sprintf("%.5f", tm.Amount),
+		Reference:            tm.Reference,
+		Description:          tm.Description,
+		Metadata:             tm.Metadata,
+		CreatedAt:            tm.CreatedAt,
+	}, nil
+}
+
+type SearchTransactionsResponse struct {
+	Transactions []*TransactionResponse `json:"transactions"`
+}
+
+func (str *SearchTransactionsResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	// TODO Pre-processing before a response is marshalled and sent across the wire
+	return nil
+}
+
+func (th *TransactionHandler) GetTransaction(w http.ResponseWriter, r *http.Request) {
+	transaction, err := th.transactionservice.GetTransaction(r.Context(), th.db, chi.URLParam(r, "transaction_id"))
+	if errors.Is(err, transactionservice.ErrInvalidTransactionID) {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, NewErrorResponse(ErrBadRequest, err.Error()))
+		return
+	}
+	if errors.Is(err, transactionservice.ErrTransactionNotFound) {
+		render.Status(r, http.StatusNotFound)
+		render.Render(w, r, NewErrorResponse(ErrNotFound, err.Error()))
+		return
+	}
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, NewErrorResponse(ErrBadRequest, err.Error()))
+		return
+	}
+
+	//Either party of a transfer may read it
+	if !canAccessAccount(r, transaction.SourceAccountID) && !canAccessAccount(r, transaction.DestinationAccountID) {
+		render.Status(r, http.StatusForbidden)
+		render.Render(w, r, NewErrorResponse(ErrForbidden, "transaction does not involve an account owned by the caller"))
+		return
+	}
+
+	resp, err := NewTransactionResponse(transaction)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, resp)
+}
+
+// SearchTransactions finds transactions by their exact reference
+func (th *TransactionHandler) SearchTransactions(w http.ResponseWriter, r *http.Request) {
+	reference := r.URL.Query().Get("reference")
+	if reference == "" {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, NewErrorResponse(ErrBadRequest, "reference query parameter is required"))
+		return
+	}
+
+	transactions, err := th.transactionservice.FindTransactionsByReference(r.Context(), th.db, reference)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, NewErrorResponse(ErrBadRequest, err.Error()))
+		return
+	}
+
+	resp := &SearchTransactionsResponse{Transactions: make([]*TransactionResponse, 0, len(transactions))}
+	for _, transaction := range transactions {
+		transactionResp, err := NewTransactionResponse(transaction)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+			return
+		}
+		resp.Transactions = append(resp.Transactions, transactionResp)
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, resp)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	transactionservice "aeshanw.com/accountApi/api/services/TransactionService"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTransactionReadRouter(th *TransactionHandler) *chi.Mux {
+	r := chi.NewRouter()
+	r.Get("/transactions", th.SearchTransactions)
+	r.Get("/transactions/{transaction_id}", th.GetTransaction)
+	return r
+}
+
+func TestGetTransaction(t *testing.T) {
+	transaction := &transactionservice.TransactionModel{
+		ID:                   uuid.MustParse("018f3c1e-8a40-7000-8000-000000000001"),
+		SourceAccountID:      10,
+		DestinationAccountID: 20,
+		Amount:               5.5,
+		Reference:            "inv-1",
+		Description:          "May invoice",
+		Metadata:             map[string]string{"order": "42"},
+		CreatedAt:            time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name           string
+		url            string
+		mockSetup      func(m *MockTransactionService)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "found",
+			url:  "/transactions/018f3c1e-8a40-7000-8000-000000000001",
+			mockSetup: func(m *MockTransactionService) {
+				m.On("GetTransaction", mock.Anything, mock.Anything, "018f3c1e-8a40-7000-8000-000000000001").Return(transaction, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"transaction_id":"018f3c1e-8a40-7000-8000-000000000001","source_account_id":10,"destination_account_id":20,"amount":"5.50000",` +
+				`"reference":"inv-1","description":"May invoice","metadata":{"order":"42"},"created_at":"2024-05-01T00:00:00Z"}`,
+		},
+		{
+			name: "found by legacy id",
+			url:  "/transactions/42",
+			mockSetup: func(m *MockTransactionService) {
+				legacy := *transaction
+				legacy.LegacyID = 42
+				m.On("GetTransaction", mock.Anything, mock.Anything, "42").Return(&legacy, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"transaction_id":"018f3c1e-8a40-7000-8000-000000000001","legacy_transaction_id":42,"source_account_id":10,"destination_account_id":20,"amount":"5.50000",` +
+				`"reference":"inv-1","description":"May invoice","metadata":{"order":"42"},"created_at":"2024-05-01T00:00:00Z"}`,
+		},
+		{
+			name: "not found",
+			url:  "/transactions/2",
+			mockSetup: func(m *MockTransactionService) {
+				m.On("GetTransaction", mock.Anything, mock.Anything, "2").Return(nil, transactionservice.ErrTransactionNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":404,"detail":"not_found","message":"transaction not found"}`,
+		},
+		{
+			name: "invalid id",
+			url:  "/transactions/abc",
+			mockSetup: func(m *MockTransactionService) {
+				m.On("GetTransaction", mock.Anything, mock.Anything, "abc").Return(nil, transactionservice.ErrInvalidTransactionID)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"transaction_id must be a UUID or a legacy integer ID"}`,
+		},
+		{
+			name: "search by reference",
+			url:  "/transactions?reference=inv-1",
+			mockSetup: func(m *MockTransactionService) {
+				m.On("FindTransactionsByReference", mock.Anything, mock.Anything, "inv-1").
+					Return([]*transactionservice.TransactionModel{transaction}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"transactions":[{"transaction_id":"018f3c1e-8a40-7000-8000-000000000001","source_account_id":10,"destination_account_id":20,"amount":"5.50000",` +
+				`"reference":"inv-1","description":"May invoice","metadata":{"order":"42"},"created_at":"2024-05-01T00:00:00Z"}]}`,
+		},
+		{
+			name: "search without matches",
+			url:  "/transactions?reference=none",
+			mockSetup: func(m *MockTransactionService) {
+				m.On("FindTransactionsByReference", mock.Anything, mock.Anything, "none").
+					Return([]*transactionservice.TransactionModel{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"transactions":[]}`,
+		},
+		{
+			name:           "search requires a reference",
+			url:            "/transactions",
+			mockSetup:      func(m *MockTransactionService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"reference query parameter is required"}`,
+		},
+		{
+			name: "search error",
+			url:  "/transactions?reference=inv-1",
+			mockSetup: func(m *MockTransactionService) {
+				m.On("FindTransactionsByReference", mock.Anything, mock.Anything, "inv-1").Return(nil, errors.New("database error"))
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"database error"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockTransactionService)
+			tt.mockSetup(mockService)
+
+			rr := httptest.NewRecorder()
+			newTransactionReadRouter(NewTransactionHandler(new(sql.DB), mockService)).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"aeshanw.com/accountApi/api/ledger"
+	transactionservice "aeshanw.com/accountApi/api/services/TransactionService"
+)
+
+type TransactionProofResponse struct {
+	*ledger.Proof
+}
+
+func (tpr *TransactionProofResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	// TODO Pre-processing before a response is marshalled and sent across the wire
+	return nil
+}
+
+// GetTransactionProof returns a transaction's place in the hash chain and the path linking it to the first signed
+// checkpoint after it, which lets a counterparty check the transaction is part of the signed history
+func (th *TransactionHandler) GetTransactionProof(w http.ResponseWriter, r *http.Request) {
+	transaction, err := th.transactionservice.GetTransaction(r.Context(), th.db, chi.URLParam(r, "transaction_id"))
+	if errors.Is(err, transactionservice.ErrInvalidTransactionID) {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, NewErrorResponse(ErrBadRequest, err.Error()))
+		return
+	}
+	if errors.Is(err, transactionservice.ErrTransactionNotFound) {
+		render.Status(r, http.StatusNotFound)
+		render.Render(w, r, NewErrorResponse(ErrNotFound, err.Error()))
+		return
+	}
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+		return
+	}
+
+	//Either party of a transfer may prove it
+	if !canAccessAccount(r, transaction.SourceAccountID) && !canAccessAccount(r, transaction.DestinationAccountID) {
+		render.Status(r, http.StatusForbidden)
+		render.Render(w, r, NewErrorResponse(ErrForbidden, "transaction does not involve an account owned by the caller"))
+		return
+	}
+
+	proof, err := ledger.FindProof(r.Context(), th.db, transaction.ID)
+	if errors.Is(err, ledger.ErrNotChained) {
+		//Only transactions from before the chain existed wait to be linked, by the next checkpoint round
+		render.Status(r, http.StatusConflict)
+		render.Render(w, r, NewErrorResponse(ErrConflict, err.Error()))
+		return
+	}
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, &TransactionProofResponse{Proof: proof})
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+
+	"aeshanw.com/accountApi/api/auth"
+	"aeshanw.com/accountApi/api/ledger"
+	transactionservice "aeshanw.com/accountApi/api/services/TransactionService"
+)
+
+func TestGetTransactionProof(t *testing.T) {
+	const transactionID = "018f3c1e-8a40-7000-8000-000000000001"
+	createdAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	transaction := &transactionservice.TransactionModel{ID: uuid.MustParse(transactionID), SourceAccountID: 10, DestinationAccountID: 20, Amount: 5.5}
+
+	//The transaction is first in the chain, one more follows it before the checkpoint
+	postedAt := createdAt.Add(time.Millisecond)
+	contents := ledger.Contents{ID: transaction.ID, SourceAccountID: 10, DestinationAccountID: 20, Amount: "5.50", CreatedAt: createdAt, PostedAt: &postedAt}
+	next := ledger.Contents{ID: uuid.MustParse("018f3c1e-8a40-7000-8000-000000000002"), SourceAccountID: 20, DestinationAccountID: 30, Amount: "1.00",
+		Metadata: map[string]string{"order": "42"}, CreatedAt: createdAt}
+	hash := ledger.Link(ledger.GenesisHash, contents.Digest())
+	headHash := ledger.Link(hash, next.Digest())
+
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	signer := ledger.NewSigner(key)
+	signedAt := createdAt.Add(time.Minute)
+	checkpoint := &ledger.Checkpoint{Seq: 2, Hash: headHash, SignedAt: signedAt, KeyID: signer.KeyID()}
+	checkpoint.Signature = ed25519.Sign(key, checkpoint.Message())
+
+	sqlFindEntry := regexp.QuoteMeta("SELECT chain_seq,prev_hash,hash,id,")
+	sqlFindCheckpoint := regexp.QuoteMeta("SELECT seq,hash,signed_at,key_id,signature FROM transaction_chain_checkpoints WHERE seq>=$1 ORDER BY seq LIMIT 1")
+	sqlFindPath := regexp.QuoteMeta("FROM transactions WHERE chain_seq>$1 AND chain_seq<=$2 ORDER BY chain_seq")
+	contentsColumns := []string{"id", "legacy_id", "source_account_id", "destination_account_id", "amount", "reference", "description", "metadata", "created_at", "posted_at"}
+
+	tests := []struct {
+		name           string
+		principal      *auth.Principal
+		mockSetup      func(m *MockTransactionService, db sqlmock.Sqlmock)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "checkpointed",
+			mockSetup: func(m *MockTransactionService, db sqlmock.Sqlmock) {
+				m.On("GetTransaction", mock.Anything, mock.Anything, transactionID).Return(transaction, nil)
+				db.ExpectQuery(sqlFindEntry).WithArgs(transaction.ID).
+					WillReturnRows(sqlmock.NewRows(append([]string{"chain_seq", "prev_hash", "hash"}, contentsColumns...)).
+						AddRow(1, ledger.GenesisHash, hash, transactionID, 0, 10, 20, "5.50", "", "", []byte(`{}`), createdAt, postedAt))
+				db.ExpectQuery(sqlFindCheckpoint).WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"seq", "hash", "signed_at", "key_id", "signature"}).
+						AddRow(2, headHash, signedAt, signer.KeyID(), checkpoint.Signature))
+				db.ExpectQuery(sqlFindPath).WithArgs(1, 2).
+					WillReturnRows(sqlmock.NewRows(contentsColumns).
+						AddRow(next.ID.String(), 0, 20, 30, "1.00", "", "", []byte(`{"order": "42"}`), createdAt, nil))
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "not chained yet",
+			mockSetup: func(m *MockTransactionService, db sqlmock.Sqlmock) {
+				m.On("GetTransaction", mock.Anything, mock.Anything, transactionID).Return(transaction, nil)
+				db.ExpectQuery(sqlFindEntry).WithArgs(transaction.ID).WillReturnRows(sqlmock.NewRows([]string{"chain_seq"}))
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"status":409,"detail":"conflict","message":"transaction not chained yet"}`,
+		},
+		{
+			name:      "not a party",
+			principal: &auth.Principal{ID: "user-1", Method: auth.MethodJWT, AccountIDs: []int64{30}, RestrictedToAccounts: true},
+			mockSetup: func(m *MockTransactionService, db sqlmock.Sqlmock) {
+				m.On("GetTransaction", mock.Anything, mock.Anything, transactionID).Return(transaction, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"status":403,"detail":"forbidden","message":"transaction does not involve an account owned by the caller"}`,
+		},
+		{
+			name: "not found",
+			mockSetup: func(m *MockTransactionService, db sqlmock.Sqlmock) {
+				m.On("GetTransaction", mock.Anything, mock.Anything, transactionID).Return(nil, transactionservice.ErrTransactionNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":404,"detail":"not_found","message":"transaction not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, dbMock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+			mockService := new(MockTransactionService)
+			tt.mockSetup(mockService, dbMock)
+
+			r := chi.NewRouter()
+			r.Get("/transactions/{transaction_id}/proof", NewTransactionHandler(db, mockService).GetTransactionProof)
+			req := httptest.NewRequest(http.MethodGet, "/transactions/"+transactionID+"/proof", nil)
+			if tt.principal != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), tt.principal))
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			} else {
+				var proof ledger.Proof
+				assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &proof))
+				assert.Equal(t, []string{next.Digest()}, proof.Path)
+				assert.NoError(t, proof.Verify(map[string]ed25519.PublicKey{signer.KeyID(): key.Public().(ed25519.PublicKey)}))
+			}
+			mockService.AssertExpectations(t)
+			assert.NoError(t, dbMock.ExpectationsWereMet())
+		})
+	}
+}
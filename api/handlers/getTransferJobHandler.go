@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"aeshanw.com/accountApi/api/logging"
+	transferjobservice "aeshanw.com/accountApi/api/services/TransferJobService"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// TransferJobResultCSVHeader is the header row of a bulk transfer job's result report
+var TransferJobResultCSVHeader = []string{"row_number", "source_account_id", "destination_account_id", "amount", "reference", "status", "transaction_id", "error"}
+
+// WriteTransferJobResultCSV streams the per-row outcome of a job as CSV
+func WriteTransferJobResultCSV(ctx context.Context, w io.Writer, db *sql.DB, tjs transferjobservice.TransferJobServiceInt, jobID int64) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(TransferJobResultCSVHeader); err != nil {
+		return err
+	}
+
+	err := tjs.ListJobRows(ctx, db, jobID, func(row *transferjobservice.TransferJobRowModel) error {
+		transactionID := ""
+		if row.TransactionID.Valid {
+			transactionID = row.TransactionID.UUID.String()
+		}
+		return writer.Write([]string{
+			strconv.Itoa(row.RowNumber),
+			strconv.FormatInt(row.Transaction.SourceAccountID, 10),
+			strconv.FormatInt(row.Transaction.DestinationAccountID, 10),
+			row.Transaction.Amount,
+			row.Reference,
+			row.Status,
+			transactionID,
+			row.Error.String,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// transferJobFromURL loads the job referenced by the job_id URL param, rendering the error response when it cannot
+func (tjh *TransferJobHandler) transferJobFromURL(w http.ResponseWriter, r *http.Request) *transferjobservice.TransferJobModel {
+	jobID, err := strconv.ParseInt(chi.URLParam(r, "job_id"), 10, 64)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, NewErrorResponse(ErrBadRequest, "job_id parameter must be an integer"))
+		return nil
+	}
+
+	job, err := tjh.transferjobservice.GetJob(r.Context(), tjh.db, jobID)
+	if errors.Is(err, transferjobservice.ErrJobNotFound) {
+		render.Status(r, http.StatusNotFound)
+		render.Render(w, r, NewErrorResponse(ErrNotFound, err.Error()))
+		return nil
+	}
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+		return nil
+	}
+	return job
+}
+
+func (tjh *TransferJobHandler) GetTransferJob(w http.ResponseWriter, r *http.Request) {
+	job := tjh.transferJobFromURL(w, r)
+	if job == nil {
+		return
+	}
+
+	resp, err := NewTransferJobResponse(job)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, resp)
+}
+
+func (tjh *TransferJobHandler) GetTransferJobResult(w http.ResponseWriter, r *http.Request) {
+	job := tjh.transferJobFromURL(w, r)
+	if job == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="transfer-job-%d-result.csv"`, job.ID))
+	w.WriteHeader(http.StatusOK)
+
+	if err := WriteTransferJobResultCSV(r.Context(), w, tjh.db, tjh.transferjobservice, job.ID); err != nil {
+		//Headers are already sent, the truncated report is all the client gets
+		logging.FromContext(r.Context()).Error("transfer job result truncated", slog.Int64(logging.KeyJobID, job.ID), slog.String(logging.KeyError, err.Error()))
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"aeshanw.com/accountApi/api/mocks"
+	"aeshanw.com/accountApi/api/models"
+	transferjobservice "aeshanw.com/accountApi/api/services/TransferJobService"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTransferJobRouter(tjh *TransferJobHandler) *chi.Mux {
+	r := chi.NewRouter()
+	r.Get("/transactions/bulk/{job_id}", tjh.GetTransferJob)
+	r.Get("/transactions/bulk/{job_id}/result", tjh.GetTransferJobResult)
+	return r
+}
+
+func TestGetTransferJob(t *testing.T) {
+	mockService := new(mocks.MockTransferJobService)
+	mockService.On("GetJob", mock.Anything, mock.Anything, int64(7)).Return(&transferjobservice.TransferJobModel{
+		ID:        7,
+		Status:    transferjobservice.JobStatusCompleted,
+		TotalRows: 2,
+		RowCounts: map[string]int{transferjobservice.RowStatusSucceeded: 1, transferjobservice.RowStatusDuplicate: 1},
+	}, nil)
+	mockService.On("GetJob", mock.Anything, mock.Anything, int64(8)).Return(nil, transferjobservice.ErrJobNotFound)
+
+	r := newTransferJobRouter(NewTransferJobHandler(new(sql.DB), mockService))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/transactions/bulk/7", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"status":"completed"`)
+	assert.Contains(t, rr.Body.String(), `"succeeded_rows":1,"failed_rows":0,"duplicate_rows":1`)
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/transactions/bulk/8", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), "transfer job not found")
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/transactions/bulk/abc", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "job_id parameter must be an integer")
+}
+
+func TestGetTransferJobResult(t *testing.T) {
+	mockService := new(mocks.MockTransferJobService)
+	mockService.On("GetJob", mock.Anything, mock.Anything, int64(7)).Return(&transferjobservice.TransferJobModel{ID: 7}, nil)
+	mockService.On("ListJobRows", mock.Anything, mock.Anything, int64(7), mock.Anything).Return([]*transferjobservice.TransferJobRowModel{
+		{
+			RowNumber:     1,
+			Transaction:   models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "10.00"},
+			Reference:     "inv-1",
+			Status:        transferjobservice.RowStatusSucceeded,
+			TransactionID: uuid.NullUUID{UUID: uuid.MustParse("018f3c1e-8a40-7000-8000-000000000011"), Valid: true},
+		},
+		{
+			RowNumber:   2,
+			Transaction: models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 3, Amount: "20.00"},
+			Reference:   "inv-2",
+			Status:      transferjobservice.RowStatusFailed,
+			Error:       sql.NullString{String: "source account has insufficent funds, balance: 5", Valid: true},
+		},
+	}, nil)
+
+	r := newTransferJobRouter(NewTransferJobHandler(new(sql.DB), mockService))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/transactions/bulk/7/result", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/csv", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "row_number,source_account_id,destination_account_id,amount,reference,status,transaction_id,error\n"+
+		"1,1,2,10.00,inv-1,succeeded,018f3c1e-8a40-7000-8000-000000000011,\n"+
+		"2,1,3,20.00,inv-2,failed,,\"source account has insufficent funds, balance: 5\"\n", rr.Body.String())
+}
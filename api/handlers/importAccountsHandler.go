@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	accountservice "aeshanw.com/accountApi/api/services/AccountService"
+	"github.com/go-chi/render"
+)
+
+type ImportAccountsIssueResponse struct {
+	Line      int    `json:"line"`
+	AccountID int64  `json:"account_id,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error"`
+}
+
+type ImportAccountsResponse struct {
+	TotalRows       int                           `json:"total_rows"`
+	Imported        int                           `json:"imported"`
+	Duplicates      int                           `json:"duplicates"`
+	Invalid         int                           `json:"invalid"`
+	Issues          []ImportAccountsIssueResponse `json:"issues"`
+	IssuesTruncated bool                          `json:"issues_truncated"`
+}
+
+func (iar *ImportAccountsResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	// TODO Pre-processing before a response is marshalled and sent across the wire
+	return nil
+}
+
+func NewImportAccountsResponse(aim *accountservice.AccountImportModel) (*ImportAccountsResponse, error) {
+	if aim == nil {
+		return nil, errors.New("accountImportModel is nil")
+	}
+
+	issues := make([]ImportAccountsIssueResponse, len(aim.Issues))
+	for i, issue := range aim.Issues {
+		issues[i] = ImportAccountsIssueResponse{
+			Line:      issue.LineNumber,
+			AccountID: issue.AccountID,
+			Status:    issue.Kind,
+			Error:     issue.Error,
+		}
+	}
+
+	return &ImportAccountsResponse{
+		TotalRows:       aim.TotalRows,
+		Imported:        aim.Imported,
+		Duplicates:      aim.Duplicates,
+		Invalid:         aim.Invalid,
+		Issues:          issues,
+		IssuesTruncated: aim.IssuesTruncated,
+	}, nil
+}
+
+// accountImportFormat picks the input format from the format query param, falling back to the Content-Type
+func accountImportFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+	contentType := r.Header.Get("Content-Type")
+	switch {
+	case strings.Contains(contentType, "ndjson"), strings.Contains(contentType, "jsonl"):
+		return AccountImportFormatNDJSON
+	case strings.Contains(contentType, "csv"):
+		return AccountImportFormatCSV
+	}
+	return ""
+}
+
+func (ah *AccountHandler) ImportAccounts(w http.ResponseWriter, r *http.Request) {
+	src, err := NewAccountImportSource(r.Body, accountImportFormat(r))
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, NewErrorResponse(ErrBadRequest, err.Error()))
+		return
+	}
+
+	report, err := ah.accountservice.ImportAccounts(r.Context(), ah.db, src)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, NewErrorResponse(ErrBadRequest, err.Error()))
+		return
+	}
+
+	resp, err := NewImportAccountsResponse(report)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, resp)
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"aeshanw.com/accountApi/api/mocks"
+	"aeshanw.com/accountApi/api/models"
+	accountservice "aeshanw.com/accountApi/api/services/AccountService"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func drainAccountImportSource(t *testing.T, src models.AccountImportSource) []models.AccountImportRow {
+	var rows []models.AccountImportRow
+	for {
+		row, err := src.Next()
+		if err == io.EOF {
+			return rows
+		}
+		assert.NoError(t, err)
+		rows = append(rows, row)
+	}
+}
+
+func TestNewAccountImportSource(t *testing.T) {
+	tests := []struct {
+		name          string
+		inputTestPath string
+		format        string
+		expectedRows  []models.AccountImportRow
+	}{
+		{
+			name:          "csv",
+			inputTestPath: "testdata/validAccountImport.csv",
+			format:        AccountImportFormatCSV,
+			expectedRows: []models.AccountImportRow{
+				{LineNumber: 2, Account: models.CreateAccountRequest{AccountID: 1, InitialBalance: "100.50"}},
+				{LineNumber: 3, Account: models.CreateAccountRequest{AccountID: 0, InitialBalance: "10"}, Error: "invalid AccountID"},
+				{LineNumber: 4, Error: "account_id must be an integer"},
+				{LineNumber: 5, Error: `extraneous or missing " in quoted-field`},
+			},
+		},
+		{
+			name:          "ndjson",
+			inputTestPath: "testdata/validAccountImport.ndjson",
+			format:        AccountImportFormatNDJSON,
+			expectedRows: []models.AccountImportRow{
+				{LineNumber: 1, Account: models.CreateAccountRequest{AccountID: 1, InitialBalance: "100.50"}},
+				{LineNumber: 3, Account: models.CreateAccountRequest{AccountID: 2}, Error: "InitialBalance is empty"},
+				{LineNumber: 4, Error: "invalid JSON"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			file, err := os.Open(tc.inputTestPath)
+			if err != nil {
+				t.Fatalf("Failed to open file: %v", err)
+			}
+			defer file.Close()
+
+			src, err := NewAccountImportSource(file, tc.format)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedRows, drainAccountImportSource(t, src))
+		})
+	}
+}
+
+func TestImportAccounts(t *testing.T) {
+	report := &accountservice.AccountImportModel{
+		TotalRows:  3,
+		Imported:   1,
+		Duplicates: 1,
+		Invalid:    1,
+		Issues: []accountservice.AccountImportIssue{
+			{LineNumber: 3, Kind: accountservice.ImportIssueInvalid, Error: "account_id must be an integer"},
+			{LineNumber: 4, AccountID: 1, Kind: accountservice.ImportIssueDuplicate, Error: "account already exists"},
+		},
+	}
+
+	tests := []struct {
+		name               string
+		url                string
+		contentType        string
+		body               string
+		mockSetup          func(m *mocks.MockAccountService)
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:        "csv import",
+			url:         "/accounts/import",
+			contentType: "text/csv",
+			body:        "account_id,initial_balance\n1,10\n",
+			mockSetup: func(m *mocks.MockAccountService) {
+				m.On("ImportAccounts", mock.Anything, mock.Anything, mock.Anything).Return(report, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody: `{"total_rows":3,"imported":1,"duplicates":1,"invalid":1,"issues":[` +
+				`{"line":3,"status":"invalid","error":"account_id must be an integer"},` +
+				`{"line":4,"account_id":1,"status":"duplicate","error":"account already exists"}],"issues_truncated":false}`,
+		},
+		{
+			name:        "format query param overrides the content type",
+			url:         "/accounts/import?format=ndjson",
+			contentType: "application/octet-stream",
+			body:        `{"account_id":1,"initial_balance":"10"}`,
+			mockSetup: func(m *mocks.MockAccountService) {
+				m.On("ImportAccounts", mock.Anything, mock.Anything, mock.Anything).Return(report, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "unknown format",
+			url:                "/accounts/import",
+			contentType:        "application/octet-stream",
+			mockSetup:          func(m *mocks.MockAccountService) {},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"status":400,"detail":"bad_request","message":"format must be one of csv,ndjson"}`,
+		},
+		{
+			name:               "bad csv header",
+			url:                "/accounts/import",
+			contentType:        "text/csv",
+			body:               "id,balance\n1,10\n",
+			mockSetup:          func(m *mocks.MockAccountService) {},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"status":400,"detail":"bad_request","message":"CSV header must be account_id,initial_balance"}`,
+		},
+		{
+			name:        "service error",
+			url:         "/accounts/import",
+			contentType: "application/x-ndjson",
+			mockSetup: func(m *mocks.MockAccountService) {
+				m.On("ImportAccounts", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("service error"))
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"status":400,"detail":"bad_request","message":"service error"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mockAccountService := new(mocks.MockAccountService)
+			tc.mockSetup(mockAccountService)
+
+			req, err := http.NewRequest(http.MethodPost, tc.url, bytes.NewBufferString(tc.body))
+			assert.NoError(t, err)
+			req.Header.Set("Content-Type", tc.contentType)
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(NewAccountHandler(new(sql.DB), mockAccountService).ImportAccounts).ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatusCode, rr.Code)
+			if tc.expectedBody != "" {
+				assert.Equal(t, tc.expectedBody, strings.TrimSpace(rr.Body.String()))
+			}
+			mockAccountService.AssertExpectations(t)
+		})
+	}
+}
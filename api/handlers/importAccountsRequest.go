@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"aeshanw.com/accountApi/api/models"
+)
+
+const (
+	AccountImportFormatCSV    = "csv"
+	AccountImportFormatNDJSON = "ndjson"
+
+	// maxAccountImportLineBytes caps the length of a single NDJSON line
+	maxAccountImportLineBytes = 64 << 10
+)
+
+// AccountImportCSVHeader is the header row expected at the top of a CSV account import
+var AccountImportCSVHeader = []string{"account_id", "initial_balance"}
+
+// NewAccountImportSource streams rows from r in the given format, validating each with ValidateCreateAccountRequest
+func NewAccountImportSource(r io.Reader, format string) (models.AccountImportSource, error) {
+	switch format {
+	case AccountImportFormatCSV:
+		return newCSVAccountImportSource(r)
+	case AccountImportFormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 4096), maxAccountImportLineBytes)
+		return &ndjsonAccountImportSource{scanner: scanner}, nil
+	default:
+		return nil, fmt.Errorf("format must be one of %s,%s", AccountImportFormatCSV, AccountImportFormatNDJSON)
+	}
+}
+
+// validateAccountImportRow records the same validation error a single POST /accounts would have returned
+func validateAccountImportRow(row *models.AccountImportRow) {
+	//Imports keep the account numbers of the source system, they are never allocated
+	if row.Account.AccountID == 0 {
+		row.Error = "invalid AccountID"
+		return
+	}
+	if errRes := ValidateCreateAccountRequest(row.Account); errRes != nil {
+		row.Error = errRes.Message
+	}
+}
+
+type csvAccountImportSource struct {
+	reader *csv.Reader
+}
+
+func newCSVAccountImportSource(r io.Reader) (*csvAccountImportSource, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	if !isCSVHeader(header, AccountImportCSVHeader) {
+		return nil, fmt.Errorf("CSV header must be %s", strings.Join(AccountImportCSVHeader, ","))
+	}
+
+	return &csvAccountImportSource{reader: reader}, nil
+}
+
+func (s *csvAccountImportSource) Next() (models.AccountImportRow, error) {
+	record, err := s.reader.Read()
+	if err == io.EOF {
+		return models.AccountImportRow{}, io.EOF
+	}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		//A malformed line only invalidates that row
+		return models.AccountImportRow{LineNumber: parseErr.StartLine, Error: parseErr.Err.Error()}, nil
+	}
+	if err != nil {
+		return models.AccountImportRow{}, err
+	}
+
+	line, _ := s.reader.FieldPos(0)
+	row := models.AccountImportRow{LineNumber: line}
+	if len(record) != len(AccountImportCSVHeader) {
+		row.Error = fmt.Sprintf("expected %d columns, got %d", len(AccountImportCSVHeader), len(record))
+		return row, nil
+	}
+
+	accountID, err := strconv.ParseInt(strings.TrimSpace(record[0]), 10, 64)
+	if err != nil {
+		row.Error = "account_id must be an integer"
+		return row, nil
+	}
+	row.Account = models.CreateAccountRequest{
+		AccountID:      accountID,
+		InitialBalance: strings.TrimSpace(record[1]),
+	}
+
+	validateAccountImportRow(&row)
+	return row, nil
+}
+
+type ndjsonAccountImportSource struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (s *ndjsonAccountImportSource) Next() (models.AccountImportRow, error) {
+	for s.scanner.Scan() {
+		s.line++
+		text := strings.TrimSpace(s.scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		row := models.AccountImportRow{LineNumber: s.line}
+		if err := json.Unmarshal([]byte(text), &row.Account); err != nil {
+			row.Error = "invalid JSON"
+			return row, nil
+		}
+
+		validateAccountImportRow(&row)
+		return row, nil
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		return models.AccountImportRow{}, err
+	}
+	return models.AccountImportRow{}, io.EOF
+}
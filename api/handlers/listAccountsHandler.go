@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"aeshanw.com/accountApi/api/logging"
+	accountservice "aeshanw.com/accountApi/api/services/AccountService"
+	"github.com/go-chi/render"
+)
+
+// accountListWriter streams the GET /accounts response one account at a time. Nothing is sent until the first
+// account arrives, so a failure before that can still be rendered as a regular error response.
+type accountListWriter struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (alw *accountListWriter) start() error {
+	if alw.started {
+		return nil
+	}
+	alw.started = true
+	alw.w.Header().Set("Content-Type", "application/json")
+	alw.w.WriteHeader(http.StatusOK)
+	_, err := io.WriteString(alw.w, `{"accounts":[`)
+	return err
+}
+
+func (alw *accountListWriter) write(account *accountservice.AccountModel) error {
+	resp, err := NewGetAccountDetailsResponse(account)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	separator := ","
+	if !alw.started {
+		separator = ""
+	}
+	if err := alw.start(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(alw.w, separator+string(encoded))
+	return err
+}
+
+// finish closes the accounts array and appends the page information
+func (alw *accountListWriter) finish(result *accountservice.AccountListModel) error {
+	if err := alw.start(); err != nil {
+		return err
+	}
+	page, err := json.Marshal(struct {
+		NextCursor string `json:"next_cursor,omitempty"`
+		Total      *int   `json:"total,omitempty"`
+	}{result.NextCursor, result.Total})
+	if err != nil {
+		return err
+	}
+
+	tail := "]}"
+	if len(page) > len("{}") {
+		tail = "]," + string(page[1:])
+	}
+	_, err = io.WriteString(alw.w, tail)
+	return err
+}
+
+// ListAccounts returns a page of accounts. Pass the next_cursor of a page as ?cursor= to fetch the following one.
+func (ah *AccountHandler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	query, errRes := ParseListAccountsQuery(r.URL.Query())
+	if errRes != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, errRes)
+		return
+	}
+
+	lw := &accountListWriter{w: w}
+	result, err := ah.accountservice.ListAccounts(r.Context(), ah.db, query, lw.write)
+	if err != nil {
+		if lw.started {
+			//Headers are already sent, the truncated body is all the client gets
+			logging.FromContext(r.Context()).Error("list accounts response truncated", slog.String(logging.KeyError, err.Error()))
+			return
+		}
+		if errors.Is(err, accountservice.ErrInvalidCursor) {
+			render.Status(r, http.StatusBadRequest)
+			render.Render(w, r, NewErrorResponse(ErrBadRequest, err.Error()))
+			return
+		}
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+		return
+	}
+
+	if err := lw.finish(result); err != nil {
+		logging.FromContext(r.Context()).Error("list accounts response truncated", slog.String(logging.KeyError, err.Error()))
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aeshanw.com/accountApi/api/mocks"
+	"aeshanw.com/accountApi/api/models"
+	accountservice "aeshanw.com/accountApi/api/services/AccountService"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestListAccounts(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	accounts := []*accountservice.AccountModel{
+		{ID: 1, Balance: 10, Status: accountservice.AccountStatusActive, CreatedAt: createdAt, UpdatedAt: createdAt, Version: 1},
+		{ID: 2, Balance: 20, Status: accountservice.AccountStatusActive, CreatedAt: createdAt, UpdatedAt: createdAt, Version: 5},
+	}
+	total := 3
+	minBalance := 5.0
+	createdAfter := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		url            string
+		mockSetup      func(m *mocks.MockAccountService)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "page with cursor and total",
+			url:  "/accounts?sort=balance&order=desc&limit=2&min_balance=5&created_after=2024-01-01T00:00:00Z&status=active&metadata.tier=gold",
+			mockSetup: func(m *mocks.MockAccountService) {
+				m.On("ListAccounts", mock.Anything, mock.Anything, models.ListAccountsQuery{
+					Sort: models.AccountSortBalance, Order: models.SortOrderDesc, Limit: 2, MinBalance: &minBalance, CreatedAfter: &createdAfter,
+					Status: "active", Metadata: map[string]string{"tier": "gold"},
+				}, mock.Anything).Return(accounts, &accountservice.AccountListModel{NextCursor: "abc", Total: &total}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"accounts":[` +
+				`{"account_id":1,"balance":"10.00000","status":"active","created_at":"2024-05-01T00:00:00Z","updated_at":"2024-05-01T00:00:00Z","version":1},` +
+				`{"account_id":2,"balance":"20.00000","status":"active","created_at":"2024-05-01T00:00:00Z","updated_at":"2024-05-01T00:00:00Z","version":5}` +
+				`],"next_cursor":"abc","total":3}`,
+		},
+		{
+			name: "empty last page",
+			url:  "/accounts",
+			mockSetup: func(m *mocks.MockAccountService) {
+				m.On("ListAccounts", mock.Anything, mock.Anything, models.ListAccountsQuery{
+					Sort: models.AccountSortID, Order: models.SortOrderAsc, Limit: DefaultListAccountsLimit,
+				}, mock.Anything).Return(nil, &accountservice.AccountListModel{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"accounts":[]}`,
+		},
+		{
+			name: "invalid cursor",
+			url:  "/accounts?cursor=abc",
+			mockSetup: func(m *mocks.MockAccountService) {
+				m.On("ListAccounts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, nil, accountservice.ErrInvalidCursor)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"invalid cursor"}`,
+		},
+		{
+			name: "service error",
+			url:  "/accounts",
+			mockSetup: func(m *mocks.MockAccountService) {
+				m.On("ListAccounts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, nil, errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":500,"detail":"internal_server_error","message":"database error"}`,
+		},
+		{
+			name:           "invalid sort",
+			url:            "/accounts?sort=name",
+			mockSetup:      func(m *mocks.MockAccountService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"sort must be one of id, balance or created_at"}`,
+		},
+		{
+			name:           "limit too large",
+			url:            "/accounts?limit=5000",
+			mockSetup:      func(m *mocks.MockAccountService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"limit must be an integer between 1 and 1000"}`,
+		},
+		{
+			name:           "invalid balance",
+			url:            "/accounts?max_balance=lots",
+			mockSetup:      func(m *mocks.MockAccountService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"max_balance must be a number"}`,
+		},
+		{
+			name:           "invalid date",
+			url:            "/accounts?created_before=yesterday",
+			mockSetup:      func(m *mocks.MockAccountService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"created_before must be an RFC 3339 timestamp"}`,
+		},
+		{
+			name:           "invalid status",
+			url:            "/accounts?status=deleted",
+			mockSetup:      func(m *mocks.MockAccountService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"status must be one of active, frozen or closed"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAccountService := new(mocks.MockAccountService)
+			tt.mockSetup(mockAccountService)
+
+			rr := httptest.NewRecorder()
+			NewAccountHandler(new(sql.DB), mockAccountService).ListAccounts(rr, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			mockAccountService.AssertExpectations(t)
+		})
+	}
+}
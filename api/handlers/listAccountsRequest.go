@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"aeshanw.com/accountApi/api/models"
+)
+
+const (
+	DefaultListAccountsLimit = 100
+	MaxListAccountsLimit     = 1000
+
+	// listAccountsMetadataPrefix marks metadata filters, e.g. ?metadata.tier=gold
+	listAccountsMetadataPrefix = "metadata."
+)
+
+// ParseListAccountsQuery reads the GET /accounts query string, applying the default sort, order and limit
+func ParseListAccountsQuery(values url.Values) (models.ListAccountsQuery, *ErrorResponse) {
+	query := models.ListAccountsQuery{
+		Sort:   models.AccountSortID,
+		Order:  models.SortOrderAsc,
+		Cursor: values.Get("cursor"),
+		Limit:  DefaultListAccountsLimit,
+		Status: values.Get("status"),
+	}
+
+	if sort := values.Get("sort"); sort != "" {
+		switch sort {
+		case models.AccountSortID, models.AccountSortBalance, models.AccountSortCreatedAt:
+			query.Sort = sort
+		default:
+			return query, NewErrorResponse(ErrBadRequest, "sort must be one of id, balance or created_at")
+		}
+	}
+	if order := values.Get("order"); order != "" {
+		if order != models.SortOrderAsc && order != models.SortOrderDesc {
+			return query, NewErrorResponse(ErrBadRequest, "order must be asc or desc")
+		}
+		query.Order = order
+	}
+	if limit := values.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > MaxListAccountsLimit {
+			return query, NewErrorResponse(ErrBadRequest, fmt.Sprintf("limit must be an integer between 1 and %d", MaxListAccountsLimit))
+		}
+		query.Limit = parsed
+	}
+
+	var errRes *ErrorResponse
+	if query.MinBalance, errRes = parseBalanceParam(values, "min_balance"); errRes != nil {
+		return query, errRes
+	}
+	if query.MaxBalance, errRes = parseBalanceParam(values, "max_balance"); errRes != nil {
+		return query, errRes
+	}
+	if query.CreatedAfter, errRes = parseTimeParam(values, "created_after"); errRes != nil {
+		return query, errRes
+	}
+	if query.CreatedBefore, errRes = parseTimeParam(values, "created_before"); errRes != nil {
+		return query, errRes
+	}
+
+	if query.Status != "" && !isAccountStatus(query.Status) {
+		return query, NewErrorResponse(ErrBadRequest, "status must be one of active, frozen or closed")
+	}
+
+	for key := range values {
+		if !strings.HasPrefix(key, listAccountsMetadataPrefix) {
+			continue
+		}
+		if query.Metadata == nil {
+			query.Metadata = map[string]string{}
+		}
+		query.Metadata[strings.TrimPrefix(key, listAccountsMetadataPrefix)] = values.Get(key)
+	}
+	if errRes := validateMetadata(query.Metadata); errRes != nil {
+		return query, errRes
+	}
+
+	return query, nil
+}
+
+func parseBalanceParam(values url.Values, name string) (*float64, *ErrorResponse) {
+	raw := values.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, NewErrorResponse(ErrBadRequest, fmt.Sprintf("%s must be a number", name))
+	}
+	return &parsed, nil
+}
+
+func parseTimeParam(values url.Values, name string) (*time.Time, *ErrorResponse) {
+	raw := values.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, NewErrorResponse(ErrBadRequest, fmt.Sprintf("%s must be an RFC 3339 timestamp", name))
+	}
+	return &parsed, nil
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"aeshanw.com/accountApi/api/auth"
+	"aeshanw.com/accountApi/api/logging"
+	"aeshanw.com/accountApi/api/ratelimit"
+)
+
+// rateLimitKey identifies the client of a request: its authenticated caller, or its IP as set by middleware.RealIP
+func rateLimitKey(r *http.Request) string {
+	if principal := auth.FromContext(r.Context()); principal != nil {
+		return principal.Method + ":" + principal.ID
+	}
+	//Without a proxy RemoteAddr carries the client's port, which changes with every connection
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return "ip:" + host
+	}
+	return "ip:" + r.RemoteAddr
+}
+
+// RateLimit answers ErrTooManyRequests once the client has used up its budget of limit. Each budget is a separate
+// bucket per client, so e.g. reads cannot exhaust a client's transfers. The RateLimit-* headers report the bucket's
+// state. When store cannot be reached requests are let through, the limits must not take the API down with them.
+func RateLimit(store ratelimit.Store, budget string, limit ratelimit.Limit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit.Unlimited() {
+			return next
+		}
+		//The bucket refills from empty to full in the policy's window
+		policy := strconv.Itoa(limit.Burst) + ";w=" + strconv.Itoa(ceilSeconds(time.Duration(limit.Burst)*time.Minute/time.Duration(limit.PerMinute)))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := store.Take(r.Context(), budget+":"+rateLimitKey(r), limit)
+			if err != nil {
+				logging.FromContext(r.Context()).Warn("unable to check rate limit", slog.String("budget", budget), slog.String(logging.KeyError, err.Error()))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+			w.Header().Set("RateLimit-Policy", policy)
+			if !res.Allowed {
+				renderTooManyRequests(w, r, res.RetryAfter, "rate limit of "+budget+" requests exceeded")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ConcurrencyLimit answers ErrTooManyRequests to clients that already have the limiter's maximum of requests in
+// flight, so a client cannot hold the transfer lock queue to itself
+func ConcurrencyLimit(limiter *ratelimit.ConcurrencyLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := rateLimitKey(r)
+			if !limiter.Acquire(key) {
+				renderTooManyRequests(w, r, time.Second, "too many concurrent requests")
+				return
+			}
+			defer limiter.Release(key)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func renderTooManyRequests(w http.ResponseWriter, r *http.Request, retryAfter time.Duration, msg string) {
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(retryAfter))))
+	render.Status(r, http.StatusTooManyRequests)
+	render.Render(w, r, NewErrorResponse(ErrTooManyRequests, msg))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"aeshanw.com/accountApi/api/auth"
+	"aeshanw.com/accountApi/api/ratelimit"
+)
+
+// failingStore is a rate limit store whose database is unreachable
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+func TestRateLimit(t *testing.T) {
+	handler := RateLimit(ratelimit.NewMemoryStore(), ratelimit.BudgetTransfers, ratelimit.Limit{PerMinute: 60, Burst: 1})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }))
+	request := func(principal *auth.Principal, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/transactions", nil)
+		req.RemoteAddr = remoteAddr
+		if principal != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	payouts := &auth.Principal{ID: "0123456789ab", Method: auth.MethodAPIKey}
+
+	rr := request(payouts, "10.0.0.1")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "1", rr.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "1;w=1", rr.Header().Get("RateLimit-Policy"))
+
+	//The same client from another IP shares the bucket
+	rr = request(payouts, "10.0.0.2")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"status":429,"detail":"too_many_requests","message":"rate limit of transfers requests exceeded","retryable":true}`, rr.Body.String())
+
+	//Unauthenticated requests are keyed by IP
+	assert.Equal(t, http.StatusNoContent, request(nil, "10.0.0.1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, request(nil, "10.0.0.1").Code)
+	assert.Equal(t, http.StatusNoContent, request(nil, "10.0.0.2").Code)
+
+	//A host opening new connections from other ports shares the bucket
+	assert.Equal(t, http.StatusNoContent, request(nil, "10.0.0.3:51000").Code)
+	assert.Equal(t, http.StatusTooManyRequests, request(nil, "10.0.0.3:51001").Code)
+}
+
+func TestRateLimit_StoreUnavailable(t *testing.T) {
+	handler := RateLimit(failingStore{}, ratelimit.BudgetReads, ratelimit.Limit{PerMinute: 60, Burst: 1})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/accounts/1", nil))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Empty(t, rr.Header().Get("RateLimit-Limit"))
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := ConcurrencyLimit(ratelimit.NewConcurrencyLimiter(1))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Block") != "" {
+			close(started)
+			<-release
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	request := func(block bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/transactions", nil)
+		req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{ID: "0123456789ab", Method: auth.MethodAPIKey}))
+		if block {
+			req.Header.Set("X-Block", "1")
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- request(true) }()
+	<-started
+
+	rr := request(false)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"status":429,"detail":"too_many_requests","message":"too many concurrent requests","retryable":true}`, rr.Body.String())
+
+	close(release)
+	select {
+	case rr := <-done:
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+	case <-time.After(time.Second):
+		t.Fatal("blocked request did not finish")
+	}
+	assert.Equal(t, http.StatusNoContent, request(false).Code)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"aeshanw.com/accountApi/api/models"
+	transactionservice "aeshanw.com/accountApi/api/services/TransactionService"
+)
+
+// slowTransactionService fails CreateTransaction with the error returned by createErr, which may wait on ctx
+type slowTransactionService struct {
+	MockTransactionService
+	createErr func(ctx context.Context) error
+}
+
+func (s *slowTransactionService) CreateTransaction(ctx context.Context, db *sql.DB, req models.CreateTransactionRequest) (*transactionservice.TransactionModel, error) {
+	return nil, s.createErr(ctx)
+}
+
+func TestTimeout(t *testing.T) {
+	body := `{"source_account_id":1,"destination_account_id":2,"amount":"10.00"}`
+
+	tests := []struct {
+		name           string
+		serviceErr     func(ctx context.Context) error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "service cancelled by the deadline",
+			serviceErr: func(ctx context.Context) error {
+				<-ctx.Done()
+				return errors.New("pq: canceling statement due to user request")
+			},
+			expectedStatus: http.StatusGatewayTimeout,
+			expectedBody:   `{"status":504,"detail":"request_timeout","message":"The request did not complete in time and its changes were rolled back. Please try again.","retryable":true}`,
+		},
+		{
+			name: "service error within the deadline",
+			serviceErr: func(ctx context.Context) error {
+				return errors.New("account-count != 2")
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"account-count != 2"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &slowTransactionService{createErr: tt.serviceErr}
+			handler := Timeout(20 * time.Millisecond)(http.HandlerFunc(NewTransactionHandler(nil, service).CreateTransaction))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(body)))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
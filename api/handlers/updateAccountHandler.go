@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"aeshanw.com/accountApi/api/models"
+	accountservice "aeshanw.com/accountApi/api/services/AccountService"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// UpdateAccount applies a partial update of the account's profile fields, the balance can only change through transfers.
+// With an If-Match header the update only applies to the account version it names, otherwise it answers 412.
+func (ah *AccountHandler) UpdateAccount(w http.ResponseWriter, r *http.Request) {
+	accountID, err := strconv.ParseInt(chi.URLParam(r, "account_id"), 10, 64)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, NewErrorResponse(ErrBadRequest, "account_id parameter must be an integer"))
+		return
+	}
+	if !canAccessAccount(r, accountID) {
+		renderAccountForbidden(w, r, accountID)
+		return
+	}
+
+	var req models.UpdateAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, NewDefaultErrorResponse(ErrBadRequest))
+		return
+	}
+
+	if errRes := ValidateUpdateAccountRequest(req); errRes != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, errRes)
+		return
+	}
+
+	req.IfMatch = ifMatchVersions(r)
+	accountModel, err := ah.accountservice.UpdateAccount(r.Context(), ah.db, accountID, req)
+	if errors.Is(err, accountservice.ErrAccountNotFound) {
+		render.Status(r, http.StatusNotFound)
+		render.Render(w, r, NewErrorResponse(ErrNotFound, err.Error()))
+		return
+	}
+	if errors.Is(err, accountservice.ErrVersionMismatch) {
+		render.Status(r, http.StatusPreconditionFailed)
+		render.Render(w, r, NewErrorResponse(ErrPreconditionFailed, err.Error()))
+		return
+	}
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.Render(w, r, NewErrorResponse(ErrBadRequest, err.Error()))
+		return
+	}
+
+	resp, err := NewGetAccountDetailsResponse(accountModel)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.Render(w, r, NewErrorResponse(ErrInternalServerError, err.Error()))
+		return
+	}
+
+	w.Header().Set("ETag", accountETag(accountModel.Version))
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, resp)
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"aeshanw.com/accountApi/api/mocks"
+	"aeshanw.com/accountApi/api/models"
+	accountservice "aeshanw.com/accountApi/api/services/AccountService"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUpdateAccount(t *testing.T) {
+	displayName := "Savings"
+	currency := "SGD"
+	accountModel := &accountservice.AccountModel{
+		ID:          1,
+		Balance:     100,
+		DisplayName: "Savings",
+		Currency:    "SGD",
+		Status:      accountservice.AccountStatusActive,
+		CreatedAt:   time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		UpdatedAt:   time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC),
+		Version:     4,
+	}
+
+	tests := []struct {
+		name           string
+		url            string
+		ifMatch        string
+		body           string
+		mockSetup      func(m *mocks.MockAccountService)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "updated",
+			url:  "/accounts/1",
+			body: `{"display_name":"Savings","currency":"SGD"}`,
+			mockSetup: func(m *mocks.MockAccountService) {
+				m.On("UpdateAccount", mock.Anything, mock.Anything, int64(1), models.UpdateAccountRequest{DisplayName: &displayName, Currency: &currency}).
+					Return(accountModel, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"account_id":1,"balance":"100.00000","display_name":"Savings","currency":"SGD","status":"active",` +
+				`"created_at":"2024-05-01T00:00:00Z","updated_at":"2024-05-02T00:00:00Z","version":4}`,
+		},
+		{
+			name:    "conditional on the current version",
+			url:     "/accounts/1",
+			ifMatch: `"3"`,
+			body:    `{"display_name":"Savings"}`,
+			mockSetup: func(m *mocks.MockAccountService) {
+				m.On("UpdateAccount", mock.Anything, mock.Anything, int64(1), models.UpdateAccountRequest{DisplayName: &displayName, IfMatch: []int64{3}}).
+					Return(accountModel, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"account_id":1,"balance":"100.00000","display_name":"Savings","currency":"SGD","status":"active",` +
+				`"created_at":"2024-05-01T00:00:00Z","updated_at":"2024-05-02T00:00:00Z","version":4}`,
+		},
+		{
+			name:    "conditional on any version",
+			url:     "/accounts/1",
+			ifMatch: `*`,
+			body:    `{"display_name":"Savings"}`,
+			mockSetup: func(m *mocks.MockAccountService) {
+				m.On("UpdateAccount", mock.Anything, mock.Anything, int64(1), models.UpdateAccountRequest{DisplayName: &displayName}).
+					Return(accountModel, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"account_id":1,"balance":"100.00000","display_name":"Savings","currency":"SGD","status":"active",` +
+				`"created_at":"2024-05-01T00:00:00Z","updated_at":"2024-05-02T00:00:00Z","version":4}`,
+		},
+		{
+			name:    "version changed",
+			url:     "/accounts/1",
+			ifMatch: `"2"`,
+			body:    `{"display_name":"Savings"}`,
+			mockSetup: func(m *mocks.MockAccountService) {
+				m.On("UpdateAccount", mock.Anything, mock.Anything, int64(1), models.UpdateAccountRequest{DisplayName: &displayName, IfMatch: []int64{2}}).
+					Return(nil, accountservice.ErrVersionMismatch)
+			},
+			expectedStatus: http.StatusPreconditionFailed,
+			expectedBody:   `{"status":412,"detail":"precondition_failed","message":"account version does not match"}`,
+		},
+		{
+			name:    "weak tags never match",
+			url:     "/accounts/1",
+			ifMatch: `W/"3"`,
+			body:    `{"display_name":"Savings"}`,
+			mockSetup: func(m *mocks.MockAccountService) {
+				m.On("UpdateAccount", mock.Anything, mock.Anything, int64(1), models.UpdateAccountRequest{DisplayName: &displayName, IfMatch: []int64{}}).
+					Return(nil, accountservice.ErrVersionMismatch)
+			},
+			expectedStatus: http.StatusPreconditionFailed,
+			expectedBody:   `{"status":412,"detail":"precondition_failed","message":"account version does not match"}`,
+		},
+		{
+			name: "account not found",
+			url:  "/accounts/2",
+			body: `{"display_name":"Savings"}`,
+			mockSetup: func(m *mocks.MockAccountService) {
+				m.On("UpdateAccount", mock.Anything, mock.Anything, int64(2), mock.Anything).Return(nil, accountservice.ErrAccountNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"status":404,"detail":"not_found","message":"account not found"}`,
+		},
+		{
+			name: "service error",
+			url:  "/accounts/1",
+			body: `{"display_name":"Savings"}`,
+			mockSetup: func(m *mocks.MockAccountService) {
+				m.On("UpdateAccount", mock.Anything, mock.Anything, int64(1), mock.Anything).Return(nil, errors.New("database error"))
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"database error"}`,
+		},
+		{
+			name:           "invalid account id",
+			url:            "/accounts/abc",
+			body:           `{"display_name":"Savings"}`,
+			mockSetup:      func(m *mocks.MockAccountService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"account_id parameter must be an integer"}`,
+		},
+		{
+			name:           "empty update",
+			url:            "/accounts/1",
+			body:           `{}`,
+			mockSetup:      func(m *mocks.MockAccountService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"no account fields to update"}`,
+		},
+		{
+			name:           "invalid currency",
+			url:            "/accounts/1",
+			body:           `{"currency":"sgd"}`,
+			mockSetup:      func(m *mocks.MockAccountService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"Currency must be a 3-letter uppercase code"}`,
+		},
+		{
+			name:           "invalid status",
+			url:            "/accounts/1",
+			body:           `{"status":"deleted"}`,
+			mockSetup:      func(m *mocks.MockAccountService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"Status must be one of active, frozen or closed"}`,
+		},
+		{
+			name:           "invalid account type",
+			url:            "/accounts/1",
+			body:           `{"account_type":"Joint Account"}`,
+			mockSetup:      func(m *mocks.MockAccountService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"AccountType must be up to 32 lowercase letters, digits or underscores"}`,
+		},
+		{
+			name:           "display name too long",
+			url:            "/accounts/1",
+			body:           `{"display_name":"` + strings.Repeat("x", MaxAccountDisplayNameLength+1) + `"}`,
+			mockSetup:      func(m *mocks.MockAccountService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"DisplayName cannot exceed 128 characters"}`,
+		},
+		{
+			name:           "invalid JSON",
+			url:            "/accounts/1",
+			body:           `{`,
+			mockSetup:      func(m *mocks.MockAccountService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":400,"detail":"bad_request","message":"The request could not be understood or was missing required parameters."}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockAccountService := new(mocks.MockAccountService)
+			tt.mockSetup(mockAccountService)
+
+			r := chi.NewRouter()
+			r.Patch("/accounts/{account_id}", NewAccountHandler(new(sql.DB), mockAccountService).UpdateAccount)
+
+			req := httptest.NewRequest(http.MethodPatch, tt.url, strings.NewReader(tt.body))
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, `"4"`, rr.Header().Get("ETag"))
+			}
+			mockAccountService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+
+	"aeshanw.com/accountApi/api/models"
+	accountservice "aeshanw.com/accountApi/api/services/AccountService"
+)
+
+const (
+	MaxAccountDisplayNameLength    = 128
+	MaxAccountOwnerReferenceLength = 128
+	MaxAccountTypeLength           = 32
+)
+
+var (
+	accountTypePattern = regexp.MustCompile(`^[a-z0-9_]*$`)
+	// currencyPattern accepts an ISO 4217 style code, the currency is a label only and no conversion is applied
+	currencyPattern = regexp.MustCompile(`^([A-Z]{3})?$`)
+)
+
+func ValidateUpdateAccountRequest(req models.UpdateAccountRequest) *ErrorResponse {
+	if req.DisplayName == nil && req.OwnerReference == nil && req.AccountType == nil && req.Currency == nil && req.Status == nil && req.Metadata == nil {
+		return NewErrorResponse(ErrBadRequest, "no account fields to update")
+	}
+	if req.DisplayName != nil && len(*req.DisplayName) > MaxAccountDisplayNameLength {
+		return NewErrorResponse(ErrBadRequest, fmt.Sprintf("DisplayName cannot exceed %d characters", MaxAccountDisplayNameLength))
+	}
+	if req.OwnerReference != nil && len(*req.OwnerReference) > MaxAccountOwnerReferenceLength {
+		return NewErrorResponse(ErrBadRequest, fmt.Sprintf("OwnerReference cannot exceed %d characters", MaxAccountOwnerReferenceLength))
+	}
+	if req.AccountType != nil && (len(*req.AccountType) > MaxAccountTypeLength || !accountTypePattern.MatchString(*req.AccountType)) {
+		return NewErrorResponse(ErrBadRequest, fmt.Sprintf("AccountType must be up to %d lowercase letters, digits or underscores", MaxAccountTypeLength))
+	}
+	if req.Currency != nil && !currencyPattern.MatchString(*req.Currency) {
+		return NewErrorResponse(ErrBadRequest, "Currency must be a 3-letter uppercase code")
+	}
+	if req.Status != nil && !isAccountStatus(*req.Status) {
+		return NewErrorResponse(ErrBadRequest, "Status must be one of active, frozen or closed")
+	}
+	return validateMetadata(req.Metadata)
+}
+
+func isAccountStatus(status string) bool {
+	switch status {
+	case accountservice.AccountStatusActive, accountservice.AccountStatusFrozen, accountservice.AccountStatusClosed:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+// Package health serves the liveness (/healthz) and readiness (/readyz) probes.
+// Liveness only says the process is serving HTTP; readiness says whether it should receive traffic.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK           = "ok"
+	StatusFail         = "fail"
+	StatusReady        = "ready"
+	StatusUnready      = "unready"
+	StatusShuttingDown = "shutting_down"
+)
+
+// DefaultCheckTimeout bounds each readiness check so a hung dependency cannot hang the probe
+const DefaultCheckTimeout = 2 * time.Second
+
+// CheckFunc reports a dependency as healthy by returning nil
+type CheckFunc func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check CheckFunc
+}
+
+type CheckResult struct {
+	Status     string `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Checker runs the readiness checks. It reports unready as soon as shutdown starts, so the orchestrator stops
+// routing traffic here while in-flight requests drain.
+type Checker struct {
+	timeout      time.Duration
+	checks       []namedCheck
+	shuttingDown atomic.Bool
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers a readiness check. Checks must be added before the probe is served.
+func (c *Checker) Add(name string, check CheckFunc) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// SetShuttingDown makes every later readiness probe fail
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Check runs every check concurrently, each bounded by the checker's timeout
+func (c *Checker) Check(ctx context.Context) *Report {
+	report := &Report{Status: StatusReady, Checks: make(map[string]CheckResult, len(c.checks))}
+	if c.shuttingDown.Load() {
+		report.Status = StatusShuttingDown
+		return report
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, nc := range c.checks {
+		wg.Add(1)
+		go func(nc namedCheck) {
+			defer wg.Done()
+			result := c.run(ctx, nc.check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[nc.name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusUnready
+			}
+		}(nc)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check CheckFunc) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	//A check that ignores ctx is still reported as failed once the timeout passes
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{Status: StatusOK, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Readyz answers 200 when every check passes and 503 otherwise, with each check's result in the body
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := c.Check(r.Context())
+
+	status := http.StatusOK
+	if report.Status != StatusReady {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// Healthz answers 200 as long as the process can serve HTTP, it deliberately checks no dependencies
+// so a database outage makes the pod unready rather than restarting it
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, &Report{Status: StatusOK})
+}
+
+func writeJSON(w http.ResponseWriter, status int, report *Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// PingCheck checks that the database accepts connections
+func PingCheck(db *sql.DB) CheckFunc {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// SchemaVersionCheck checks that the migrations in initdb/init.sql have been applied up to at least expected.
+// A newer schema is accepted so replicas still running the previous release stay ready during a rollout.
+func SchemaVersionCheck(db *sql.DB, expected int) CheckFunc {
+	return func(ctx context.Context) error {
+		var version int
+		if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version),0) FROM schema_migrations`).Scan(&version); err != nil {
+			return fmt.Errorf("unable to read schema version due to :%w", err)
+		}
+		if version < expected {
+			return fmt.Errorf("schema version %d, expected at least %d", version, expected)
+		}
+		return nil
+	}
+}
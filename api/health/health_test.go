@@ -0,0 +1,130 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name           string
+		setup          func(c *Checker)
+		expectedStatus int
+		expectedReport Report
+	}{
+		{
+			name: "all checks pass",
+			setup: func(c *Checker) {
+				c.Add("database", func(ctx context.Context) error { return nil })
+				c.Add("worker", func(ctx context.Context) error { return nil })
+			},
+			expectedStatus: http.StatusOK,
+			expectedReport: Report{Status: StatusReady, Checks: map[string]CheckResult{
+				"database": {Status: StatusOK},
+				"worker":   {Status: StatusOK},
+			}},
+		},
+		{
+			name: "failing check",
+			setup: func(c *Checker) {
+				c.Add("database", func(ctx context.Context) error { return errors.New("connection refused") })
+				c.Add("worker", func(ctx context.Context) error { return nil })
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReport: Report{Status: StatusUnready, Checks: map[string]CheckResult{
+				"database": {Status: StatusFail, Error: "connection refused"},
+				"worker":   {Status: StatusOK},
+			}},
+		},
+		{
+			name: "check ignoring the timeout",
+			setup: func(c *Checker) {
+				c.Add("database", func(ctx context.Context) error {
+					time.Sleep(time.Second)
+					return nil
+				})
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReport: Report{Status: StatusUnready, Checks: map[string]CheckResult{
+				"database": {Status: StatusFail, Error: context.DeadlineExceeded.Error()},
+			}},
+		},
+		{
+			name: "shutting down",
+			setup: func(c *Checker) {
+				c.Add("database", func(ctx context.Context) error { return nil })
+				c.SetShuttingDown()
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReport: Report{Status: StatusShuttingDown, Checks: map[string]CheckResult{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker(20 * time.Millisecond)
+			tt.setup(checker)
+
+			report := checker.Check(context.Background())
+			//Durations vary from run to run
+			for name, result := range report.Checks {
+				result.DurationMS = 0
+				report.Checks[name] = result
+			}
+			assert.Equal(t, tt.expectedReport, *report)
+
+			rr := httptest.NewRecorder()
+			checker.Readyz(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Healthz(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, rr.Body.String())
+}
+
+func TestSchemaVersionCheck(t *testing.T) {
+	sqlSchemaVersion := regexp.QuoteMeta("SELECT COALESCE(MAX(version),0) FROM schema_migrations")
+
+	tests := []struct {
+		name        string
+		version     int
+		expectedErr string
+	}{
+		{name: "expected version", version: 2},
+		{name: "newer version", version: 3},
+		{name: "pending migrations", version: 1, expectedErr: "schema version 1, expected at least 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			mock.ExpectQuery(sqlSchemaVersion).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(tt.version))
+
+			err = SchemaVersionCheck(db, 2)(context.Background())
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Heartbeat is the readiness check of a background loop running every interval. The loop is alive while it beats:
+// one that has not beaten for three intervals outside of a unit of work, e.g. stuck on a hung DB call, is reported as
+// stalled. A long unit of work is not a stall.
+type Heartbeat struct {
+	name     string
+	interval time.Duration
+
+	running atomic.Bool
+	busy    atomic.Bool
+	// last is when the loop last beat, in UnixNano
+	last atomic.Int64
+}
+
+// NewHeartbeat returns the heartbeat of the loop called name, e.g. "transfer job worker"
+func NewHeartbeat(name string, interval time.Duration) *Heartbeat {
+	return &Heartbeat{name: name, interval: interval}
+}
+
+// Start marks the loop as running, it must call Stop when it returns
+func (h *Heartbeat) Start() {
+	h.Beat()
+	h.running.Store(true)
+}
+
+func (h *Heartbeat) Stop() {
+	h.running.Store(false)
+}
+
+// Beat records that the loop is alive
+func (h *Heartbeat) Beat() {
+	h.last.Store(time.Now().UnixNano())
+}
+
+// Busy marks the start of a unit of work, the returned func marks its end
+func (h *Heartbeat) Busy() (done func()) {
+	h.busy.Store(true)
+	return func() {
+		h.busy.Store(false)
+		h.Beat()
+	}
+}
+
+// Check is a CheckFunc failing while the loop is not running or has stalled
+func (h *Heartbeat) Check(ctx context.Context) error {
+	if !h.running.Load() {
+		return errors.New(h.name + " is not running")
+	}
+	if h.busy.Load() {
+		return nil
+	}
+	if since := time.Since(time.Unix(0, h.last.Load())); since > 3*h.interval {
+		return fmt.Errorf("%s has not run for %s", h.name, since.Round(time.Second))
+	}
+	return nil
+}
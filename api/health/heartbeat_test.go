@@ -0,0 +1,29 @@
+package health
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeartbeat(t *testing.T) {
+	heartbeat := NewHeartbeat("transfer job worker", time.Minute)
+	assert.EqualError(t, heartbeat.Check(context.Background()), "transfer job worker is not running")
+
+	heartbeat.Start()
+	assert.NoError(t, heartbeat.Check(context.Background()))
+
+	heartbeat.last.Store(time.Now().Add(-5 * time.Minute).UnixNano())
+	assert.EqualError(t, heartbeat.Check(context.Background()), "transfer job worker has not run for 5m0s")
+
+	//A long unit of work is not a stall, finishing it counts as a beat
+	done := heartbeat.Busy()
+	assert.NoError(t, heartbeat.Check(context.Background()))
+	done()
+	assert.NoError(t, heartbeat.Check(context.Background()))
+
+	heartbeat.Stop()
+	assert.EqualError(t, heartbeat.Check(context.Background()), "transfer job worker is not running")
+}
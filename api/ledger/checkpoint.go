@@ -0,0 +1,230 @@
+package ledger
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"aeshanw.com/accountApi/api/health"
+	"aeshanw.com/accountApi/api/logging"
+	"aeshanw.com/accountApi/api/tracing"
+)
+
+// ErrUnknownKey is returned when a checkpoint is signed by a key the verifier was not given
+var ErrUnknownKey = errors.New("checkpoint signed by an unknown key")
+
+// Checkpoint is a signed chain head, committing to every transaction up to Seq
+type Checkpoint struct {
+	Seq       int64     `json:"seq"`
+	Hash      string    `json:"hash"`
+	SignedAt  time.Time `json:"signed_at"`
+	KeyID     string    `json:"key_id"`
+	Signature []byte    `json:"signature"`
+}
+
+// Message is the text signed by the checkpoint's key
+func (cp *Checkpoint) Message() []byte {
+	return []byte("transaction-chain-checkpoint\n" + strconv.FormatInt(cp.Seq, 10) + "\n" + cp.Hash + "\n" + cp.SignedAt.UTC().Format(time.RFC3339Nano))
+}
+
+// VerifySignature checks the signature with the key named by KeyID among keys
+func (cp *Checkpoint) VerifySignature(keys map[string]ed25519.PublicKey) error {
+	key, ok := keys[cp.KeyID]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownKey, cp.KeyID)
+	}
+	if !ed25519.Verify(key, cp.Message(), cp.Signature) {
+		return fmt.Errorf("%w: checkpoint %d has an invalid signature", ErrChainBroken, cp.Seq)
+	}
+	return nil
+}
+
+// KeyID names a public key by the start of its SHA-256
+func KeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Signer signs checkpoints with an Ed25519 key
+type Signer struct {
+	key   ed25519.PrivateKey
+	keyID string
+}
+
+func NewSigner(key ed25519.PrivateKey) *Signer {
+	return &Signer{key: key, keyID: KeyID(key.Public().(ed25519.PublicKey))}
+}
+
+// LoadSigner reads an Ed25519 private key from a PKCS #8 PEM file, e.g. one written by
+// `openssl genpkey -algorithm ed25519`
+func LoadSigner(path string) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s holds no PEM block", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s does not hold an Ed25519 key", path)
+	}
+	return NewSigner(edKey), nil
+}
+
+// KeyID names the signer's public key
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+func (s *Signer) sign(cp *Checkpoint) {
+	cp.KeyID = s.keyID
+	cp.Signature = ed25519.Sign(s.key, cp.Message())
+}
+
+// LoadPublicKeys reads the Ed25519 public keys of a PEM file holding one or more PKIX blocks, keyed by KeyID. Keys
+// rotated out stay in the file so older checkpoints still verify.
+func LoadPublicKeys(path string) (map[string]ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]ed25519.PublicKey{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		edKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%s holds a key that is not Ed25519", path)
+		}
+		keys[KeyID(edKey)] = edKey
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s holds no PEM block", path)
+	}
+	return keys, nil
+}
+
+const sqlCheckpointColumns = `seq,hash,signed_at,key_id,signature`
+
+func scanCheckpoint(row rowScanner) (*Checkpoint, error) {
+	var cp Checkpoint
+	if err := row.Scan(&cp.Seq, &cp.Hash, &cp.SignedAt, &cp.KeyID, &cp.Signature); err != nil {
+		return nil, err
+	}
+	cp.SignedAt = cp.SignedAt.UTC()
+	return &cp, nil
+}
+
+// CreateCheckpoint signs the current chain head. It returns nil when the chain is empty or its head is already
+// checkpointed, e.g. by another instance.
+func CreateCheckpoint(ctx context.Context, db *sql.DB, signer *Signer) (*Checkpoint, error) {
+	ctx, span := tracing.Start(ctx, "ledger.CreateCheckpoint")
+	defer span.End()
+
+	//Transactions are linked one txn at a time, the committed ones always form a whole chain up to the head
+	sqlChainHead := `SELECT chain_seq,hash FROM transactions WHERE chain_seq IS NOT NULL ORDER BY chain_seq DESC LIMIT 1`
+	sqlInsertCheckpoint := `INSERT INTO transaction_chain_checkpoints(` + sqlCheckpointColumns + `) VALUES ($1,$2,$3,$4,$5) ON CONFLICT (seq) DO NOTHING`
+
+	cp := &Checkpoint{SignedAt: time.Now().UTC().Truncate(time.Microsecond)}
+	err := db.QueryRowContext(ctx, sqlChainHead).Scan(&cp.Seq, &cp.Hash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read transaction chain head due to :%w", err)
+	}
+	signer.sign(cp)
+
+	result, err := db.ExecContext(ctx, sqlInsertCheckpoint, cp.Seq, cp.Hash, cp.SignedAt, cp.KeyID, cp.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("unable to insert checkpoint due to :%w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// Checkpointer links pending transactions every interval and, given a signer, signs the chain head
+type Checkpointer struct {
+	db        *sql.DB
+	signer    *Signer
+	interval  time.Duration
+	heartbeat *health.Heartbeat
+}
+
+func NewCheckpointer(db *sql.DB, signer *Signer, interval time.Duration) *Checkpointer {
+	return &Checkpointer{db: db, signer: signer, interval: interval, heartbeat: health.NewHeartbeat("ledger checkpointer", interval)}
+}
+
+// Run checkpoints until ctx is cancelled, starting at once. It logs with the logger carried by ctx.
+func (c *Checkpointer) Run(ctx context.Context) {
+	c.heartbeat.Start()
+	defer c.heartbeat.Stop()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		done := c.heartbeat.Busy()
+		c.checkpoint(ctx)
+		done()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check is the checkpointer's readiness check, see health.Heartbeat
+func (c *Checkpointer) Check(ctx context.Context) error {
+	return c.heartbeat.Check(ctx)
+}
+
+func (c *Checkpointer) checkpoint(ctx context.Context) {
+	chained, err := ChainPending(ctx, c.db)
+	if err != nil {
+		logging.FromContext(ctx).Error("unable to chain pending transactions", slog.String(logging.KeyError, err.Error()))
+		return
+	}
+	if chained > 0 {
+		logging.FromContext(ctx).Info("pending transactions chained", slog.Int("count", chained))
+	}
+	if c.signer == nil {
+		return
+	}
+
+	cp, err := CreateCheckpoint(ctx, c.db, c.signer)
+	if err != nil {
+		logging.FromContext(ctx).Error("unable to checkpoint transaction chain", slog.String(logging.KeyError, err.Error()))
+		return
+	}
+	if cp != nil {
+		logging.FromContext(ctx).Info("transaction chain checkpointed", slog.Int64("seq", cp.Seq), slog.String("hash", cp.Hash))
+	}
+}
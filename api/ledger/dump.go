@@ -0,0 +1,131 @@
+package ledger
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"aeshanw.com/accountApi/api/tracing"
+)
+
+// DumpLine is one line of an exported dump, holding either a checkpoint or an entry
+type DumpLine struct {
+	Checkpoint *Checkpoint `json:"checkpoint,omitempty"`
+	Entry      *Entry      `json:"entry,omitempty"`
+}
+
+// Export writes the chain as NDJSON: every checkpoint, then every entry, both in sequence order. Checkpoints come
+// first so Verify can check them while streaming the entries.
+func Export(ctx context.Context, db *sql.DB, w io.Writer) error {
+	ctx, span := tracing.Start(ctx, "ledger.Export")
+	defer span.End()
+
+	sqlCheckpoints := `SELECT ` + sqlCheckpointColumns + ` FROM transaction_chain_checkpoints ORDER BY seq`
+	sqlEntries := `SELECT ` + sqlEntryColumns + ` FROM transactions WHERE chain_seq IS NOT NULL ORDER BY chain_seq`
+
+	enc := json.NewEncoder(w)
+	rows, err := db.QueryContext(ctx, sqlCheckpoints)
+	if err != nil {
+		return fmt.Errorf("unable to export checkpoints due to :%w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		cp, err := scanCheckpoint(rows)
+		if err != nil {
+			return fmt.Errorf("unable to export checkpoints due to :%w", err)
+		}
+		if err := enc.Encode(DumpLine{Checkpoint: cp}); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("unable to export checkpoints due to :%w", err)
+	}
+	rows.Close()
+
+	rows, err = db.QueryContext(ctx, sqlEntries)
+	if err != nil {
+		return fmt.Errorf("unable to export transactions due to :%w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return fmt.Errorf("unable to export transactions due to :%w", err)
+		}
+		if err := enc.Encode(DumpLine{Entry: entry}); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("unable to export transactions due to :%w", err)
+	}
+	return nil
+}
+
+// VerifyResult describes a verified dump
+type VerifyResult struct {
+	Transactions int64  `json:"transactions"`
+	HeadSeq      int64  `json:"head_seq"`
+	HeadHash     string `json:"head_hash"`
+	Checkpoints  int    `json:"checkpoints"`
+	// LastCheckpointSeq is the newest signed head, the history up to it cannot be rewritten without the signing key
+	LastCheckpointSeq int64 `json:"last_checkpoint_seq"`
+}
+
+// Verify walks a dump written by Export without touching the database. Every entry must follow from the one before
+// it, and every checkpoint must be validly signed by one of keys and match the entry at its seq, so a dump missing its
+// newest transactions fails against the checkpoints covering them.
+func Verify(r io.Reader, keys map[string]ed25519.PublicKey) (*VerifyResult, error) {
+	result := &VerifyResult{HeadHash: GenesisHash}
+	checkpoints := map[int64]*Checkpoint{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var dl DumpLine
+		if err := json.Unmarshal(scanner.Bytes(), &dl); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		switch {
+		case dl.Checkpoint != nil:
+			if result.Transactions > 0 {
+				return result, fmt.Errorf("line %d: checkpoints must come before the transactions", line)
+			}
+			if err := dl.Checkpoint.VerifySignature(keys); err != nil {
+				return result, err
+			}
+			checkpoints[dl.Checkpoint.Seq] = dl.Checkpoint
+			result.Checkpoints++
+			result.LastCheckpointSeq = max(result.LastCheckpointSeq, dl.Checkpoint.Seq)
+		case dl.Entry != nil:
+			entry := dl.Entry
+			switch {
+			case entry.Seq != result.HeadSeq+1:
+				return result, fmt.Errorf("%w: transaction %d follows transaction %d", ErrChainBroken, entry.Seq, result.HeadSeq)
+			case entry.PrevHash != result.HeadHash:
+				return result, fmt.Errorf("%w: transaction %d does not link to the hash of transaction %d", ErrChainBroken, entry.Seq, result.HeadSeq)
+			case !entry.Valid():
+				return result, fmt.Errorf("%w: transaction %d does not match its hash", ErrChainBroken, entry.Seq)
+			}
+			if cp, ok := checkpoints[entry.Seq]; ok && cp.Hash != entry.Hash {
+				return result, fmt.Errorf("%w: transaction %d differs from the history signed by checkpoint %d", ErrChainBroken, entry.Seq, cp.Seq)
+			}
+			result.Transactions++
+			result.HeadSeq, result.HeadHash = entry.Seq, entry.Hash
+		default:
+			return nil, fmt.Errorf("line %d: neither a checkpoint nor an entry", line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if result.LastCheckpointSeq > result.HeadSeq {
+		return result, fmt.Errorf("%w: checkpoint %d is past the last transaction %d", ErrChainBroken, result.LastCheckpointSeq, result.HeadSeq)
+	}
+	return result, nil
+}
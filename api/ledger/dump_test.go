@@ -0,0 +1,175 @@
+package ledger
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"database/sql/driver"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+// testChain links n transactions and signs a checkpoint after every second one
+func testChain(n int) ([]*Entry, []*Checkpoint) {
+	signer := NewSigner(testKey)
+	entries := []*Entry{}
+	checkpoints := []*Checkpoint{}
+	prevHash := GenesisHash
+	for i := 1; i <= n; i++ {
+		entry := &Entry{Seq: int64(i), Transaction: *testContents(i), PrevHash: prevHash}
+		//The first transaction is from before postings existed
+		if i > 1 {
+			postedAt := entry.Transaction.CreatedAt.Add(time.Millisecond)
+			entry.Transaction.PostedAt = &postedAt
+		}
+		entry.Hash = Link(prevHash, entry.Transaction.Digest())
+		prevHash = entry.Hash
+		entries = append(entries, entry)
+		if i%2 == 0 {
+			cp := &Checkpoint{Seq: entry.Seq, Hash: entry.Hash, SignedAt: time.Date(2024, 5, 2, 0, 0, i, 0, time.UTC)}
+			signer.sign(cp)
+			checkpoints = append(checkpoints, cp)
+		}
+	}
+	return entries, checkpoints
+}
+
+func writeDump(entries []*Entry, checkpoints []*Checkpoint) *bytes.Buffer {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, cp := range checkpoints {
+		enc.Encode(DumpLine{Checkpoint: cp})
+	}
+	for _, entry := range entries {
+		enc.Encode(DumpLine{Entry: entry})
+	}
+	return &buf
+}
+
+func TestExport(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	entries, checkpoints := testChain(2)
+	cpRows := sqlmock.NewRows([]string{"seq", "hash", "signed_at", "key_id", "signature"})
+	for _, cp := range checkpoints {
+		cpRows.AddRow(cp.Seq, cp.Hash, cp.SignedAt, cp.KeyID, cp.Signature)
+	}
+	entryRows := sqlmock.NewRows([]string{"chain_seq", "prev_hash", "hash", "id", "legacy_id", "source_account_id", "destination_account_id",
+		"amount", "reference", "description", "metadata", "created_at", "posted_at"})
+	for _, e := range entries {
+		c := e.Transaction
+		metadata, _ := json.Marshal(c.Metadata)
+		var postedAt driver.Value
+		if c.PostedAt != nil {
+			postedAt = *c.PostedAt
+		}
+		entryRows.AddRow(e.Seq, e.PrevHash, e.Hash, c.ID.String(), c.LegacyID, c.SourceAccountID, c.DestinationAccountID, c.Amount,
+			c.Reference, c.Description, metadata, c.CreatedAt, postedAt)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT seq,hash,signed_at,key_id,signature FROM transaction_chain_checkpoints ORDER BY seq")).WillReturnRows(cpRows)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM transactions WHERE chain_seq IS NOT NULL ORDER BY chain_seq")).WillReturnRows(entryRows)
+
+	var buf bytes.Buffer
+	assert.NoError(t, Export(context.Background(), db, &buf))
+	assert.Equal(t, writeDump(entries, checkpoints).String(), buf.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerify(t *testing.T) {
+	keys := map[string]ed25519.PublicKey{KeyID(testKey.Public().(ed25519.PublicKey)): testKey.Public().(ed25519.PublicKey)}
+
+	tests := []struct {
+		name        string
+		tamper      func(entries []*Entry, checkpoints []*Checkpoint) ([]*Entry, []*Checkpoint)
+		expectedErr string
+	}{
+		{
+			name: "intact chain",
+			tamper: func(entries []*Entry, checkpoints []*Checkpoint) ([]*Entry, []*Checkpoint) {
+				return entries, checkpoints
+			},
+		},
+		{
+			name: "unsigned tail",
+			tamper: func(entries []*Entry, checkpoints []*Checkpoint) ([]*Entry, []*Checkpoint) {
+				return entries[:3], checkpoints[:1]
+			},
+		},
+		{
+			name: "edited transaction",
+			tamper: func(entries []*Entry, checkpoints []*Checkpoint) ([]*Entry, []*Checkpoint) {
+				entries[2].Transaction.Amount = "5000.00"
+				return entries, checkpoints
+			},
+			expectedErr: "transaction chain broken: transaction 3 does not match its hash",
+		},
+		{
+			name: "rewritten history",
+			tamper: func(entries []*Entry, checkpoints []*Checkpoint) ([]*Entry, []*Checkpoint) {
+				//Relinking every later transaction keeps the chain whole, the signed checkpoints no longer match it
+				entries[2].Transaction.Amount = "5000.00"
+				for i := 2; i < len(entries); i++ {
+					entries[i].PrevHash = entries[i-1].Hash
+					entries[i].Hash = Link(entries[i].PrevHash, entries[i].Transaction.Digest())
+				}
+				return entries, checkpoints
+			},
+			expectedErr: "transaction chain broken: transaction 4 differs from the history signed by checkpoint 4",
+		},
+		{
+			name: "removed newest transactions",
+			tamper: func(entries []*Entry, checkpoints []*Checkpoint) ([]*Entry, []*Checkpoint) {
+				return entries[:3], checkpoints
+			},
+			expectedErr: "transaction chain broken: checkpoint 4 is past the last transaction 3",
+		},
+		{
+			name: "removed transaction",
+			tamper: func(entries []*Entry, checkpoints []*Checkpoint) ([]*Entry, []*Checkpoint) {
+				return append(entries[:1], entries[2:]...), checkpoints
+			},
+			expectedErr: "transaction chain broken: transaction 3 follows transaction 1",
+		},
+		{
+			name: "forged checkpoint",
+			tamper: func(entries []*Entry, checkpoints []*Checkpoint) ([]*Entry, []*Checkpoint) {
+				checkpoints[0].Hash = entries[0].Hash
+				return entries, checkpoints
+			},
+			expectedErr: "transaction chain broken: checkpoint 2 has an invalid signature",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, checkpoints := tt.tamper(testChain(4))
+			result, err := Verify(writeDump(entries, checkpoints), keys)
+			if tt.expectedErr != "" {
+				assert.ErrorIs(t, err, ErrChainBroken)
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			head := entries[len(entries)-1]
+			assert.Equal(t, &VerifyResult{Transactions: int64(len(entries)), HeadSeq: head.Seq, HeadHash: head.Hash,
+				Checkpoints: len(checkpoints), LastCheckpointSeq: checkpoints[len(checkpoints)-1].Seq}, result)
+		})
+	}
+}
+
+func TestVerifyUnknownKey(t *testing.T) {
+	entries, checkpoints := testChain(2)
+	_, err := Verify(writeDump(entries, checkpoints), map[string]ed25519.PublicKey{})
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	_, err = Verify(strings.NewReader(`{}`+"\n"), nil)
+	assert.EqualError(t, err, "line 1: neither a checkpoint nor an entry")
+}
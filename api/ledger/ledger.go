@@ -0,0 +1,256 @@
+// Package ledger makes the transactions table tamper-evident. Every transaction is linked into a hash chain in the DB
+// txn that creates it: its hash covers its contents and the previous transaction's hash, so rewriting, removing or
+// reordering a transaction breaks the chain from there on. Checkpoints sign the chain's head, and a transaction's
+// inclusion proof links its hash to the first signed head after it.
+package ledger
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"aeshanw.com/accountApi/api/tracing"
+)
+
+// GenesisHash is the previous hash of the first transaction
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+var (
+	// ErrChainBroken is returned by Verify when a transaction or checkpoint does not follow from the chain before it
+	ErrChainBroken = errors.New("transaction chain broken")
+	// ErrNotChained is returned for a transaction that is not linked into the chain yet
+	ErrNotChained = errors.New("transaction not chained yet")
+)
+
+// lockKey is the advisory lock serializing writers, the chain's order is then the order its transactions commit in
+const lockKey = 0x7478636861696e
+
+// pendingBatchSize is how many unchained transactions ChainPending links per DB txn
+const pendingBatchSize = 1000
+
+// Contents are the fields of a transaction covered by its hash. Amount is the database's text of the stored value, so
+// the hash does not depend on how a float is printed. PostedAt is set by Append, transactions from before postings
+// existed have none.
+type Contents struct {
+	ID                   uuid.UUID         `json:"id"`
+	LegacyID             int64             `json:"legacy_id,omitempty"`
+	SourceAccountID      int64             `json:"source_account_id"`
+	DestinationAccountID int64             `json:"destination_account_id"`
+	Amount               string            `json:"amount"`
+	Reference            string            `json:"reference,omitempty"`
+	Description          string            `json:"description,omitempty"`
+	Metadata             map[string]string `json:"metadata"`
+	CreatedAt            time.Time         `json:"created_at"`
+	PostedAt             *time.Time        `json:"posted_at,omitempty"`
+}
+
+// Digest hashes the contents alone
+func (c Contents) Digest() string {
+	//Timestamps are hashed in UTC and missing metadata as {}, map keys are encoded sorted
+	c.CreatedAt = c.CreatedAt.UTC()
+	if c.PostedAt != nil {
+		postedAt := c.PostedAt.UTC()
+		c.PostedAt = &postedAt
+	}
+	if c.Metadata == nil {
+		c.Metadata = map[string]string{}
+	}
+	encoded, _ := json.Marshal(c)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// Link is the hash of a transaction whose contents hash to digest, following the transaction hashed prevHash
+func Link(prevHash, digest string) string {
+	sum := sha256.Sum256([]byte(prevHash + "\n" + digest))
+	return hex.EncodeToString(sum[:])
+}
+
+// Entry is a transaction linked into the chain
+type Entry struct {
+	Seq         int64    `json:"seq"`
+	Transaction Contents `json:"transaction"`
+	PrevHash    string   `json:"prev_hash"`
+	Hash        string   `json:"hash"`
+}
+
+// Valid reports whether Hash follows from PrevHash and the contents
+func (e *Entry) Valid() bool {
+	return Link(e.PrevHash, e.Transaction.Digest()) == e.Hash
+}
+
+const sqlContentsColumns = `id,COALESCE(legacy_id,0),source_account_id,destination_account_id,amount::TEXT,COALESCE(reference,''),COALESCE(description,''),metadata,created_at,posted_at`
+
+const sqlEntryColumns = `chain_seq,prev_hash,hash,` + sqlContentsColumns
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanContents(row rowScanner, dest ...any) (*Contents, error) {
+	var c Contents
+	var metadata []byte
+	var postedAt sql.NullTime
+	if err := row.Scan(append(dest, &c.ID, &c.LegacyID, &c.SourceAccountID, &c.DestinationAccountID, &c.Amount, &c.Reference,
+		&c.Description, &metadata, &c.CreatedAt, &postedAt)...); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(metadata, &c.Metadata); err != nil {
+		return nil, fmt.Errorf("unable to decode metadata due to :%w", err)
+	}
+	c.CreatedAt = c.CreatedAt.UTC()
+	if postedAt.Valid {
+		postedAt.Time = postedAt.Time.UTC()
+		c.PostedAt = &postedAt.Time
+	}
+	return &c, nil
+}
+
+func scanEntry(row rowScanner) (*Entry, error) {
+	var e Entry
+	contents, err := scanContents(row, &e.Seq, &e.PrevHash, &e.Hash)
+	if err != nil {
+		return nil, err
+	}
+	e.Transaction = *contents
+	return &e, nil
+}
+
+// Append links transactions inserted within txn into the chain in the order given, they are only linked if txn
+// commits. Writers are serialized until txn ends, so callers append last, once their own row locks are held. The
+// transactions are posted when the chain's lock is taken, so they are posted in the order they commit. Their PostedAt
+// is set and hashed.
+func Append(ctx context.Context, txn *sql.Tx, transactions ...*Contents) error {
+	if len(transactions) == 0 {
+		return nil
+	}
+	ctx, span := tracing.Start(ctx, "ledger.Append")
+	defer span.End()
+
+	return link(ctx, txn, true, transactions)
+}
+
+// link links transactions into the chain, posting them if post is set
+func link(ctx context.Context, txn *sql.Tx, post bool, transactions []*Contents) error {
+
+	sqlLockChain := `SELECT pg_advisory_xact_lock($1)`
+	sqlPostedAt := `SELECT clock_timestamp()`
+	sqlChainHead := `SELECT chain_seq,hash FROM transactions WHERE chain_seq IS NOT NULL ORDER BY chain_seq DESC LIMIT 1`
+	sqlLinkTransactions := `UPDATE transactions t SET chain_seq=c.seq,prev_hash=c.prev_hash,hash=c.hash,posted_at=$5 ` +
+		`FROM unnest($1::UUID[],$2::BIGINT[],$3::TEXT[],$4::TEXT[]) AS c(id,seq,prev_hash,hash) WHERE t.id=c.id`
+
+	if _, err := txn.ExecContext(ctx, sqlLockChain, lockKey); err != nil {
+		return fmt.Errorf("unable to lock transaction chain due to :%w", err)
+	}
+	//Read once the lock is held: no transaction posted earlier is still uncommitted, see LockShared
+	var postedAt *time.Time
+	if post {
+		postedAt = new(time.Time)
+		if err := txn.QueryRowContext(ctx, sqlPostedAt).Scan(postedAt); err != nil {
+			return fmt.Errorf("unable to read posting time due to :%w", err)
+		}
+	}
+	var seq int64
+	prevHash := GenesisHash
+	if err := txn.QueryRowContext(ctx, sqlChainHead).Scan(&seq, &prevHash); err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("unable to read transaction chain head due to :%w", err)
+	}
+
+	//All links are written by one statement, a batch of any size costs a single round trip
+	ids := make([]string, len(transactions))
+	seqs := make([]int64, len(transactions))
+	prevHashes := make([]string, len(transactions))
+	hashes := make([]string, len(transactions))
+	for i, c := range transactions {
+		if post {
+			c.PostedAt = postedAt
+		}
+		seq++
+		ids[i], seqs[i], prevHashes[i], hashes[i] = c.ID.String(), seq, prevHash, Link(prevHash, c.Digest())
+		prevHash = hashes[i]
+	}
+	if _, err := txn.ExecContext(ctx, sqlLinkTransactions, pq.Array(ids), pq.Array(seqs), pq.Array(prevHashes), pq.Array(hashes), postedAt); err != nil {
+		return fmt.Errorf("unable to chain transactions due to :%w", err)
+	}
+	return nil
+}
+
+// LockShared waits for the transactions posted so far to commit and keeps new ones from being posted until txn ends.
+// Balances read within txn afterwards include exactly the transactions posted by then.
+func LockShared(ctx context.Context, txn *sql.Tx) error {
+	if _, err := txn.ExecContext(ctx, `SELECT pg_advisory_xact_lock_shared($1)`, lockKey); err != nil {
+		return fmt.Errorf("unable to lock transaction chain due to :%w", err)
+	}
+	return nil
+}
+
+// ChainPending links the transactions not in the chain yet, oldest first, and returns how many it linked. They are
+// the ones created before the chain existed, or by an instance running an older version during a rollout, and count
+// as posted when they were created.
+func ChainPending(ctx context.Context, db *sql.DB) (int, error) {
+	ctx, span := tracing.Start(ctx, "ledger.ChainPending")
+	defer span.End()
+
+	chained := 0
+	for {
+		n, err := chainPendingBatch(ctx, db)
+		chained += n
+		if err != nil || n < pendingBatchSize {
+			return chained, err
+		}
+	}
+}
+
+func chainPendingBatch(ctx context.Context, db *sql.DB) (int, error) {
+	sqlLockChain := `SELECT pg_advisory_xact_lock($1)`
+	sqlPending := `SELECT ` + sqlContentsColumns + ` FROM transactions WHERE chain_seq IS NULL ORDER BY created_at,legacy_id,id LIMIT $1`
+
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("txn for chainPending fail:%w", err)
+	}
+	defer txn.Rollback()
+
+	//Locked before looking, another instance may be linking the same transactions
+	if _, err := txn.ExecContext(ctx, sqlLockChain, lockKey); err != nil {
+		return 0, fmt.Errorf("unable to lock transaction chain due to :%w", err)
+	}
+	rows, err := txn.QueryContext(ctx, sqlPending, pendingBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("unable to find unchained transactions due to :%w", err)
+	}
+	defer rows.Close()
+	pending := []*Contents{}
+	for rows.Next() {
+		c, err := scanContents(rows)
+		if err != nil {
+			return 0, fmt.Errorf("unable to find unchained transactions due to :%w", err)
+		}
+		pending = append(pending, c)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("unable to find unchained transactions due to :%w", err)
+	}
+	rows.Close()
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	//They were applied to balances when created, posting them now would move them in the accounts' history
+	if err := link(ctx, txn, false, pending); err != nil {
+		return 0, err
+	}
+	if err := txn.Commit(); err != nil {
+		return 0, fmt.Errorf("unable to commit transaction chain txn due to :%w", err)
+	}
+	return len(pending), nil
+}
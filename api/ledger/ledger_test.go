@@ -0,0 +1,205 @@
+package ledger
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"database/sql/driver"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+var testKey = ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+
+func testContents(n int) *Contents {
+	return &Contents{
+		ID:                   uuid.MustParse(fmt.Sprintf("018f3c1e-8a40-7000-8000-%012d", n)),
+		SourceAccountID:      10,
+		DestinationAccountID: 20,
+		Amount:               "5.50",
+		Reference:            "inv-1",
+		Metadata:             map[string]string{"order": "42"},
+		CreatedAt:            time.Date(2024, 5, 1, 0, 0, n, 0, time.UTC),
+	}
+}
+
+func TestDigest(t *testing.T) {
+	c := testContents(1)
+	digest := c.Digest()
+	assert.Len(t, digest, 64)
+
+	//The same transaction read back in another zone or without metadata hashes the same
+	inZone := *c
+	inZone.CreatedAt = c.CreatedAt.In(time.FixedZone("UTC+8", 8*60*60))
+	assert.Equal(t, digest, inZone.Digest())
+	noMetadata, emptyMetadata := *c, *c
+	noMetadata.Metadata, emptyMetadata.Metadata = nil, map[string]string{}
+	assert.Equal(t, noMetadata.Digest(), emptyMetadata.Digest())
+
+	changed := *c
+	changed.Amount = "5.51"
+	assert.NotEqual(t, digest, changed.Digest())
+
+	//A transaction from before postings existed hashes as before, a posting time is hashed like the creation time
+	postedAt := c.CreatedAt.Add(time.Millisecond)
+	posted, postedInZone := *c, *c
+	posted.PostedAt = &postedAt
+	postedInZoneAt := postedAt.In(time.FixedZone("UTC+8", 8*60*60))
+	postedInZone.PostedAt = &postedInZoneAt
+	assert.NotEqual(t, digest, posted.Digest())
+	assert.Equal(t, posted.Digest(), postedInZone.Digest())
+}
+
+func TestAppend(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	headHash := Link(GenesisHash, testContents(1).Digest())
+	second, third := testContents(2), testContents(3)
+	//The posting time is hashed
+	postedAt := time.Date(2024, 5, 1, 0, 0, 4, 0, time.UTC)
+	postedSecond, postedThird := *second, *third
+	postedSecond.PostedAt, postedThird.PostedAt = &postedAt, &postedAt
+	secondHash := Link(headHash, postedSecond.Digest())
+	thirdHash := Link(secondHash, postedThird.Digest())
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	//Posted only once the chain's lock is held
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT clock_timestamp()")).WillReturnRows(sqlmock.NewRows([]string{"clock_timestamp"}).AddRow(postedAt))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT chain_seq,hash FROM transactions WHERE chain_seq IS NOT NULL ORDER BY chain_seq DESC LIMIT 1")).
+		WillReturnRows(sqlmock.NewRows([]string{"chain_seq", "hash"}).AddRow(1, headHash))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE transactions t SET chain_seq=c.seq,prev_hash=c.prev_hash,hash=c.hash,posted_at=$5 FROM unnest(")).
+		WithArgs(arrayArg(pq.Array([]string{second.ID.String(), third.ID.String()})), arrayArg(pq.Array([]int64{2, 3})),
+			arrayArg(pq.Array([]string{headHash, secondHash})), arrayArg(pq.Array([]string{secondHash, thirdHash})), postedAt).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, Append(context.Background(), txn, second, third))
+	assert.NoError(t, txn.Commit())
+	assert.Equal(t, &postedAt, second.PostedAt)
+	assert.Equal(t, &postedAt, third.PostedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChainPending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	pending := testContents(1)
+	metadata, _ := json.Marshal(pending.Metadata)
+
+	//Pending transactions moved balances when they were created, they are linked without being posted
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM transactions WHERE chain_seq IS NULL ORDER BY created_at,legacy_id,id LIMIT $1")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "legacy_id", "source_account_id", "destination_account_id", "amount", "reference",
+			"description", "metadata", "created_at", "posted_at"}).
+			AddRow(pending.ID.String(), 0, 10, 20, "5.50", "inv-1", "", metadata, pending.CreatedAt, nil))
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT chain_seq,hash FROM transactions WHERE chain_seq IS NOT NULL ORDER BY chain_seq DESC LIMIT 1")).
+		WillReturnRows(sqlmock.NewRows([]string{"chain_seq", "hash"}))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE transactions t SET chain_seq=c.seq,prev_hash=c.prev_hash,hash=c.hash,posted_at=$5 FROM unnest(")).
+		WithArgs(arrayArg(pq.Array([]string{pending.ID.String()})), arrayArg(pq.Array([]int64{1})),
+			arrayArg(pq.Array([]string{GenesisHash})), arrayArg(pq.Array([]string{Link(GenesisHash, pending.Digest())})), nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	chained, err := ChainPending(context.Background(), db)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, chained)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// arrayArg matches the text encoding of a pq array
+func arrayArg(array driver.Valuer) sqlmock.Argument {
+	expected, _ := array.Value()
+	return arrayMatcher{expected}
+}
+
+type arrayMatcher struct {
+	expected driver.Value
+}
+
+func (m arrayMatcher) Match(v driver.Value) bool {
+	return m.expected == v
+}
+
+func TestCreateCheckpoint(t *testing.T) {
+	sqlChainHead := regexp.QuoteMeta("SELECT chain_seq,hash FROM transactions WHERE chain_seq IS NOT NULL ORDER BY chain_seq DESC LIMIT 1")
+	sqlInsertCheckpoint := regexp.QuoteMeta("INSERT INTO transaction_chain_checkpoints(seq,hash,signed_at,key_id,signature) VALUES ($1,$2,$3,$4,$5) ON CONFLICT (seq) DO NOTHING")
+	signer := NewSigner(testKey)
+	keys := map[string]ed25519.PublicKey{signer.KeyID(): testKey.Public().(ed25519.PublicKey)}
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(sqlChainHead).WillReturnRows(sqlmock.NewRows([]string{"chain_seq", "hash"}).AddRow(7, "ab"))
+	mock.ExpectExec(sqlInsertCheckpoint).WithArgs(7, "ab", sqlmock.AnyArg(), signer.KeyID(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	//Another instance checkpointed the same head
+	mock.ExpectQuery(sqlChainHead).WillReturnRows(sqlmock.NewRows([]string{"chain_seq", "hash"}).AddRow(7, "ab"))
+	mock.ExpectExec(sqlInsertCheckpoint).WillReturnResult(sqlmock.NewResult(0, 0))
+	//Nothing to sign yet
+	mock.ExpectQuery(sqlChainHead).WillReturnRows(sqlmock.NewRows([]string{"chain_seq", "hash"}))
+
+	cp, err := CreateCheckpoint(context.Background(), db, signer)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), cp.Seq)
+	assert.NoError(t, cp.VerifySignature(keys))
+
+	tampered := *cp
+	tampered.Hash = "cd"
+	assert.ErrorIs(t, tampered.VerifySignature(keys), ErrChainBroken)
+	assert.ErrorIs(t, cp.VerifySignature(nil), ErrUnknownKey)
+
+	cp, err = CreateCheckpoint(context.Background(), db, signer)
+	assert.NoError(t, err)
+	assert.Nil(t, cp)
+	cp, err = CreateCheckpoint(context.Background(), db, signer)
+	assert.NoError(t, err)
+	assert.Nil(t, cp)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLoadKeys(t *testing.T) {
+	dir := t.TempDir()
+	privateDER, err := x509.MarshalPKCS8PrivateKey(testKey)
+	assert.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(testKey.Public())
+	assert.NoError(t, err)
+	otherKey := ed25519.NewKeyFromSeed(append(make([]byte, ed25519.SeedSize-1), 1))
+	otherDER, err := x509.MarshalPKIXPublicKey(otherKey.Public())
+	assert.NoError(t, err)
+
+	privatePath := filepath.Join(dir, "checkpoint.pem")
+	assert.NoError(t, os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0o600))
+	publicPath := filepath.Join(dir, "checkpoint.pub.pem")
+	assert.NoError(t, os.WriteFile(publicPath, append(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: otherDER})...), 0o644))
+
+	signer, err := LoadSigner(privatePath)
+	assert.NoError(t, err)
+	keys, err := LoadPublicKeys(publicPath)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Contains(t, keys, signer.KeyID())
+
+	_, err = LoadSigner(publicPath)
+	assert.Error(t, err)
+}
@@ -0,0 +1,26 @@
+// Package ledgertest sets up sqlmock expectations for the transactions services link into the chain
+package ledgertest
+
+import (
+	"regexp"
+	"time"
+
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+// PostedAt is the posting time ExpectAppend hands out
+var PostedAt = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// ExpectLockShared expects a ledger.LockShared
+func ExpectLockShared(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock_shared($1)")).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+// ExpectAppend expects a ledger.Append on an empty chain
+func ExpectAppend(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT clock_timestamp()")).WillReturnRows(sqlmock.NewRows([]string{"clock_timestamp"}).AddRow(PostedAt))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT chain_seq,hash FROM transactions WHERE chain_seq IS NOT NULL ORDER BY chain_seq DESC LIMIT 1")).
+		WillReturnRows(sqlmock.NewRows([]string{"chain_seq", "hash"}))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE transactions t SET chain_seq=c.seq")).WillReturnResult(sqlmock.NewResult(0, 1))
+}
@@ -0,0 +1,84 @@
+package ledger
+
+import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"aeshanw.com/accountApi/api/tracing"
+)
+
+// Proof shows a transaction is part of the history a checkpoint signed. Starting from the entry's hash, linking each
+// digest of Path in turn must reach the checkpoint's hash.
+type Proof struct {
+	Entry *Entry `json:"entry"`
+	// Path holds the digests of the transactions after the entry up to the checkpoint
+	Path []string `json:"path"`
+	// Checkpoint is the first checkpoint at or after the entry, nil until the next checkpoint is signed
+	Checkpoint *Checkpoint `json:"checkpoint,omitempty"`
+}
+
+// Verify checks the entry's own hash, the path and the checkpoint's signature
+func (p *Proof) Verify(keys map[string]ed25519.PublicKey) error {
+	if !p.Entry.Valid() {
+		return fmt.Errorf("%w: transaction %d does not match its hash", ErrChainBroken, p.Entry.Seq)
+	}
+	if p.Checkpoint == nil {
+		return fmt.Errorf("transaction %d is not checkpointed yet", p.Entry.Seq)
+	}
+	hash := p.Entry.Hash
+	for _, digest := range p.Path {
+		hash = Link(hash, digest)
+	}
+	if p.Checkpoint.Seq != p.Entry.Seq+int64(len(p.Path)) || p.Checkpoint.Hash != hash {
+		return fmt.Errorf("%w: path from transaction %d does not reach checkpoint %d", ErrChainBroken, p.Entry.Seq, p.Checkpoint.Seq)
+	}
+	return p.Checkpoint.VerifySignature(keys)
+}
+
+// FindProof builds the inclusion proof of a transaction, failing with ErrNotChained before it is linked
+func FindProof(ctx context.Context, db *sql.DB, id uuid.UUID) (*Proof, error) {
+	ctx, span := tracing.Start(ctx, "ledger.FindProof")
+	defer span.End()
+
+	sqlFindEntry := `SELECT ` + sqlEntryColumns + ` FROM transactions WHERE id=$1 AND chain_seq IS NOT NULL`
+	sqlFindCheckpoint := `SELECT ` + sqlCheckpointColumns + ` FROM transaction_chain_checkpoints WHERE seq>=$1 ORDER BY seq LIMIT 1`
+	sqlFindPath := `SELECT ` + sqlContentsColumns + ` FROM transactions WHERE chain_seq>$1 AND chain_seq<=$2 ORDER BY chain_seq`
+
+	entry, err := scanEntry(db.QueryRowContext(ctx, sqlFindEntry, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotChained
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch chained transaction due to :%w", err)
+	}
+	proof := &Proof{Entry: entry, Path: []string{}}
+
+	proof.Checkpoint, err = scanCheckpoint(db.QueryRowContext(ctx, sqlFindCheckpoint, entry.Seq))
+	if err == sql.ErrNoRows {
+		return proof, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch checkpoint due to :%w", err)
+	}
+
+	rows, err := db.QueryContext(ctx, sqlFindPath, entry.Seq, proof.Checkpoint.Seq)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch proof path due to :%w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		contents, err := scanContents(rows)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch proof path due to :%w", err)
+		}
+		proof.Path = append(proof.Path, contents.Digest())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to fetch proof path due to :%w", err)
+	}
+	return proof, nil
+}
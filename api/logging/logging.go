@@ -0,0 +1,152 @@
+// Package logging sets up the structured JSON logger shared by the API, its background workers and transferctl.
+// The logger travels in the request context so every line written while serving a request carries its request_id.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attribute keys shared by every log line so logs can be searched by request, account or transaction
+const (
+	KeyRequestID            = "request_id"
+	KeyTraceID              = "trace_id"
+	KeySpanID               = "span_id"
+	KeyAccountID            = "account_id"
+	KeySourceAccountID      = "source_account_id"
+	KeyDestinationAccountID = "destination_account_id"
+	KeyTransactionID        = "transaction_id"
+	KeyJobID                = "job_id"
+	KeyAPIKeyID             = "api_key_id"
+	KeyPrincipalID          = "principal_id"
+	KeyClientID             = "client_id"
+	KeyAmount               = "amount"
+	KeyBalance              = "balance"
+	KeyError                = "error"
+)
+
+// RedactedValue replaces monetary values when redaction is enabled
+const RedactedValue = "[REDACTED]"
+
+// redactedKeys are the attributes holding balances or amounts
+var redactedKeys = map[string]bool{
+	KeyAmount:         true,
+	KeyBalance:        true,
+	"initial_balance": true,
+}
+
+type Options struct {
+	Level slog.Level
+	// Redact hides balances and amounts so production logs do not leak customer funds
+	Redact bool
+}
+
+// New creates a JSON logger writing to w
+func New(w io.Writer, opts Options) *slog.Logger {
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level}
+	if opts.Redact {
+		handlerOpts.ReplaceAttr = redact
+	}
+	return slog.New(slog.NewJSONHandler(w, handlerOpts))
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if redactedKeys[a.Key] {
+		return slog.String(a.Key, RedactedValue)
+	}
+	return a
+}
+
+// OptionsFromEnv reads LOG_LEVEL (debug, info, warn or error, default info) and LOG_REDACT (default true)
+func OptionsFromEnv(getenv func(string) string) (Options, error) {
+	opts := Options{Level: slog.LevelInfo, Redact: true}
+
+	if level := getenv("LOG_LEVEL"); level != "" {
+		if err := opts.Level.UnmarshalText([]byte(strings.ToUpper(level))); err != nil {
+			return opts, fmt.Errorf("invalid LOG_LEVEL %q", level)
+		}
+	}
+
+	if redact := getenv("LOG_REDACT"); redact != "" {
+		value, err := strconv.ParseBool(redact)
+		if err != nil {
+			return opts, fmt.Errorf("invalid LOG_REDACT %q", redact)
+		}
+		opts.Redact = value
+	}
+
+	return opts, nil
+}
+
+type ctxKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or slog.Default() when there is none
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// traceAttrs identifies the span in ctx so log lines can be joined with traces
+func traceAttrs(ctx context.Context) []any {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return []any{slog.String(KeyTraceID, sc.TraceID().String()), slog.String(KeySpanID, sc.SpanID().String())}
+}
+
+// WithSpan tags the logger in ctx with the trace and span IDs of the span in ctx, for work started outside a request
+func WithSpan(ctx context.Context) context.Context {
+	attrs := traceAttrs(ctx)
+	if attrs == nil {
+		return ctx
+	}
+	return WithLogger(ctx, FromContext(ctx).With(attrs...))
+}
+
+// Middleware stores a logger tagged with the chi request ID and trace ID in the request context and logs each
+// completed request. It must be mounted after middleware.RequestID and tracing.Middleware.
+func Middleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqLogger := logger.With(slog.String(KeyRequestID, middleware.GetReqID(r.Context()))).With(traceAttrs(r.Context())...)
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			start := time.Now()
+
+			next.ServeHTTP(ww, r.WithContext(WithLogger(r.Context(), reqLogger)))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			reqLogger.LogAttrs(r.Context(), level, "request completed",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("remote_addr", r.RemoteAddr),
+			)
+		})
+	}
+}
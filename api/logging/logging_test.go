@@ -0,0 +1,124 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var line map[string]any
+		assert.NoError(t, dec.Decode(&line))
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestNewRedactsAmounts(t *testing.T) {
+	tests := []struct {
+		name           string
+		redact         bool
+		expectedAmount any
+	}{
+		{name: "redacted", redact: true, expectedAmount: RedactedValue},
+		{name: "in clear", redact: false, expectedAmount: 10.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := New(&buf, Options{Level: slog.LevelInfo, Redact: tt.redact})
+
+			logger.Info("transaction created", slog.Float64(KeyAmount, 10.5), slog.Group("source", slog.Float64(KeyBalance, 99)), slog.Int64(KeyAccountID, 7))
+
+			lines := decodeLines(t, &buf)
+			assert.Len(t, lines, 1)
+			assert.Equal(t, tt.expectedAmount, lines[0][KeyAmount])
+			assert.Equal(t, float64(7), lines[0][KeyAccountID])
+			if tt.redact {
+				assert.Equal(t, map[string]any{KeyBalance: RedactedValue}, lines[0]["source"])
+			}
+		})
+	}
+}
+
+func TestOptionsFromEnv(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		expectedOpts Options
+		expectedErr  string
+	}{
+		{name: "defaults", env: map[string]string{}, expectedOpts: Options{Level: slog.LevelInfo, Redact: true}},
+		{name: "debug in clear", env: map[string]string{"LOG_LEVEL": "debug", "LOG_REDACT": "false"}, expectedOpts: Options{Level: slog.LevelDebug, Redact: false}},
+		{name: "warn", env: map[string]string{"LOG_LEVEL": "WARN"}, expectedOpts: Options{Level: slog.LevelWarn, Redact: true}},
+		{name: "invalid level", env: map[string]string{"LOG_LEVEL": "loud"}, expectedErr: `invalid LOG_LEVEL "loud"`},
+		{name: "invalid redact", env: map[string]string{"LOG_REDACT": "maybe"}, expectedErr: `invalid LOG_REDACT "maybe"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := OptionsFromEnv(func(key string) string { return tt.env[key] })
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedOpts, opts)
+		})
+	}
+}
+
+func TestFromContextFallsBackToDefault(t *testing.T) {
+	assert.Equal(t, slog.Default(), FromContext(context.Background()))
+}
+
+func TestMiddlewareTagsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, Options{Level: slog.LevelInfo})
+
+	handler := middleware.RequestID(Middleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Info("handling", slog.Int64(KeyAccountID, 7))
+		w.WriteHeader(http.StatusCreated)
+	})))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/accounts", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-123")
+	handler.ServeHTTP(rr, req)
+
+	lines := decodeLines(t, &buf)
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "handling", lines[0]["msg"])
+	assert.Equal(t, "req-123", lines[0][KeyRequestID])
+	assert.Equal(t, "request completed", lines[1]["msg"])
+	assert.Equal(t, "req-123", lines[1][KeyRequestID])
+	assert.Equal(t, float64(http.StatusCreated), lines[1]["status"])
+	assert.Equal(t, "/accounts", lines[1]["path"])
+}
+
+func TestWithSpanTagsTraceID(t *testing.T) {
+	var buf bytes.Buffer
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(WithLogger(context.Background(), New(&buf, Options{})),
+		trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+
+	FromContext(WithSpan(ctx)).Info("processing job")
+
+	lines := decodeLines(t, &buf)
+	assert.Len(t, lines, 1)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", lines[0][KeyTraceID])
+	assert.Equal(t, "00f067aa0ba902b7", lines[0][KeySpanID])
+}
@@ -0,0 +1,255 @@
+// Package metrics exposes the API's Prometheus metrics on /metrics.
+// Labels only ever hold values from small fixed sets (route patterns, status codes, outcomes, operations),
+// never account or transaction IDs, so the number of series stays bounded.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "accountapi"
+
+// Transfer outcomes
+const (
+	OutcomeSuccess           = "success"
+	OutcomeInsufficientFunds = "insufficient_funds"
+	OutcomeValidation        = "validation"
+	OutcomeDBError           = "db_error"
+)
+
+// DB transaction operations
+const (
+	OpCreateAccount     = "create_account"
+	OpImportAccounts    = "import_accounts"
+	OpCreateTransaction = "create_transaction"
+	OpBatchTransaction  = "batch_transaction"
+	OpCreateTransferJob = "create_transfer_job"
+	OpTransferJobRow    = "transfer_job_row"
+)
+
+// Reasons a request is refused admission
+const (
+	RejectQueueFull   = "queue_full"
+	RejectWaitTimeout = "wait_timeout"
+)
+
+// unmatchedRoute labels requests that did not match any route, so scanners probing random paths add no new series
+const unmatchedRoute = "unmatched"
+
+// Registry holds every collector served on /metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by chi route pattern, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by chi route pattern, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	transfers = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfers_total",
+		Help:      "Transfers attempted by outcome.",
+	}, []string{"outcome"})
+
+	transferAmount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfer_amount_total",
+		Help:      "Sum of transfer amounts by outcome.",
+	}, []string{"outcome"})
+
+	transferLockWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "transfer_lock_wait_seconds",
+		Help:      "Time spent waiting for the transfer lock.",
+		Buckets:   []float64{.0001, .0005, .001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	})
+
+	dbTransactionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_transaction_duration_seconds",
+		Help:      "Duration of DB transactions from BEGIN until commit or rollback, by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	dbTransactionRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_transaction_retries_total",
+		Help:      "Statements retried within a DB transaction, by operation.",
+	}, []string{"operation"})
+
+	admissionQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "admission_queue_depth",
+		Help:      "Requests waiting for admission, by queue.",
+	}, []string{"queue"})
+
+	admissionInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "admission_in_flight",
+		Help:      "Admitted requests still being served, by queue.",
+	}, []string{"queue"})
+
+	admissionWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "admission_wait_seconds",
+		Help:      "Time admitted requests waited in the queue, by queue.",
+		Buckets:   []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"queue"})
+
+	admissionRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "admission_rejections_total",
+		Help:      "Requests refused admission and answered with a 503, by queue and reason.",
+	}, []string{"queue", "reason"})
+
+	dbCircuitState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_circuit_state",
+		Help:      "State of the database circuit breaker, 1 for the current state and 0 for the others.",
+	}, []string{"state"})
+
+	dbCircuitTrips = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_circuit_trips_total",
+		Help:      "Times the database circuit breaker opened after consecutive connection failures.",
+	})
+
+	dbCircuitRejections = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_circuit_rejections_total",
+		Help:      "Requests and connection attempts refused while the database circuit breaker was not closed.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpRequestDuration,
+		transfers,
+		transferAmount,
+		transferLockWait,
+		dbTransactionDuration,
+		dbTransactionRetries,
+		admissionQueueDepth,
+		admissionInFlight,
+		admissionWait,
+		admissionRejections,
+		dbCircuitState,
+		dbCircuitTrips,
+		dbCircuitRejections,
+	)
+}
+
+// Handler serves the registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDB exposes the connection pool statistics of db (sql.DB.Stats) as gauges
+func RegisterDB(db *sql.DB, name string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Middleware counts and times requests by their chi route pattern. It must be mounted on the root chi router.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r)
+
+		//The pattern is only complete once routing is done, e.g. /accounts/{account_id}
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		labels := prometheus.Labels{"route": route, "method": r.Method, "status": strconv.Itoa(status)}
+		httpRequests.With(labels).Inc()
+		httpRequestDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// RecordTransfer counts a transfer attempt and adds its amount to the outcome's total
+func RecordTransfer(outcome string, amount float64) {
+	transfers.WithLabelValues(outcome).Inc()
+	transferAmount.WithLabelValues(outcome).Add(amount)
+}
+
+// ObserveTransferLockWait records how long a transfer waited for the transfer lock since waitStart
+func ObserveTransferLockWait(waitStart time.Time) {
+	transferLockWait.Observe(time.Since(waitStart).Seconds())
+}
+
+// ObserveDBTransaction records the duration of a DB transaction started at start, meant to be deferred after BEGIN
+func ObserveDBTransaction(operation string, start time.Time) {
+	dbTransactionDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// RecordDBRetry counts a statement retried within a DB transaction
+func RecordDBRetry(operation string) {
+	dbTransactionRetries.WithLabelValues(operation).Inc()
+}
+
+// SetAdmissionQueue records how many requests of queue are waiting and how many are being served
+func SetAdmissionQueue(queue string, waiting int64, inFlight int) {
+	admissionQueueDepth.WithLabelValues(queue).Set(float64(waiting))
+	admissionInFlight.WithLabelValues(queue).Set(float64(inFlight))
+}
+
+// ObserveAdmissionWait records how long a request admitted by queue waited since waitStart
+func ObserveAdmissionWait(queue string, waitStart time.Time) {
+	admissionWait.WithLabelValues(queue).Observe(time.Since(waitStart).Seconds())
+}
+
+// RecordAdmissionRejection counts a request refused by queue for reason
+func RecordAdmissionRejection(queue string, reason string) {
+	admissionRejections.WithLabelValues(queue, reason).Inc()
+}
+
+// dbCircuitStates are the states SetDBCircuitState switches between, they match those of package breaker
+var dbCircuitStates = []string{"closed", "open", "half_open"}
+
+// SetDBCircuitState marks state as the database circuit breaker's current state
+func SetDBCircuitState(state string) {
+	for _, s := range dbCircuitStates {
+		v := 0.0
+		if s == state {
+			v = 1
+		}
+		dbCircuitState.WithLabelValues(s).Set(v)
+	}
+}
+
+// RecordDBCircuitTrip counts the database circuit breaker opening
+func RecordDBCircuitTrip() {
+	dbCircuitTrips.Inc()
+}
+
+// RecordDBCircuitRejection counts a request or connection attempt refused by the database circuit breaker
+func RecordDBCircuitRejection() {
+	dbCircuitRejections.Inc()
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareLabelsByRoutePattern(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/accounts/{account_id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	for _, url := range []string{"/accounts/1", "/accounts/2", "/no-such-route"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, url, nil))
+	}
+
+	//Both account IDs share a single series
+	assert.Equal(t, float64(2), testutil.ToFloat64(httpRequests.WithLabelValues("/accounts/{account_id}", http.MethodGet, "404")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(httpRequests.WithLabelValues(unmatchedRoute, http.MethodGet, "404")))
+}
+
+func TestRecordTransfer(t *testing.T) {
+	before := testutil.ToFloat64(transfers.WithLabelValues(OutcomeInsufficientFunds))
+
+	RecordTransfer(OutcomeInsufficientFunds, 10.5)
+	RecordTransfer(OutcomeInsufficientFunds, 0)
+
+	assert.Equal(t, before+2, testutil.ToFloat64(transfers.WithLabelValues(OutcomeInsufficientFunds)))
+	assert.Equal(t, 10.5, testutil.ToFloat64(transferAmount.WithLabelValues(OutcomeInsufficientFunds)))
+}
+
+func TestHandlerServesTextFormat(t *testing.T) {
+	RecordDBRetry(OpCreateAccount)
+
+	rr := httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, strings.Contains(rr.Body.String(), `accountapi_db_transaction_retries_total{operation="create_account"} 1`))
+}
+
+func TestAdmissionMetrics(t *testing.T) {
+	SetAdmissionQueue("writes", 3, 16)
+	RecordAdmissionRejection("writes", RejectQueueFull)
+
+	assert.Equal(t, float64(3), testutil.ToFloat64(admissionQueueDepth.WithLabelValues("writes")))
+	assert.Equal(t, float64(16), testutil.ToFloat64(admissionInFlight.WithLabelValues("writes")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(admissionRejections.WithLabelValues("writes", RejectQueueFull)))
+}
+
+func TestDBCircuitMetrics(t *testing.T) {
+	SetDBCircuitState("open")
+	assert.Equal(t, float64(1), testutil.ToFloat64(dbCircuitState.WithLabelValues("open")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(dbCircuitState.WithLabelValues("closed")))
+
+	SetDBCircuitState("closed")
+	assert.Equal(t, float64(0), testutil.ToFloat64(dbCircuitState.WithLabelValues("open")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(dbCircuitState.WithLabelValues("closed")))
+}
@@ -3,9 +3,12 @@ package mocks
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"aeshanw.com/accountApi/api/models"
+	apikeyservice "aeshanw.com/accountApi/api/services/APIKeyService"
 	accountservice "aeshanw.com/accountApi/api/services/AccountService"
+	transferjobservice "aeshanw.com/accountApi/api/services/TransferJobService"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -21,10 +24,122 @@ func (m *MockAccountService) GetAccount(ctx context.Context, db *sql.DB, account
 	return nil, args.Error(1)
 }
 
-func (m *MockAccountService) CreateAccount(ctx context.Context, db *sql.DB, req models.CreateAccountRequest) error {
+func (m *MockAccountService) CreateAccount(ctx context.Context, db *sql.DB, req models.CreateAccountRequest) (*accountservice.AccountModel, error) {
 	args := m.Called(ctx, db, req)
 	if args.Get(0) != nil {
-		return args.Error(0)
+		return args.Get(0).(*accountservice.AccountModel), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAccountService) ImportAccounts(ctx context.Context, db *sql.DB, src models.AccountImportSource) (*accountservice.AccountImportModel, error) {
+	args := m.Called(ctx, db, src)
+	if args.Get(0) != nil {
+		return args.Get(0).(*accountservice.AccountImportModel), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAccountService) UpdateAccount(ctx context.Context, db *sql.DB, accountID int64, req models.UpdateAccountRequest) (*accountservice.AccountModel, error) {
+	args := m.Called(ctx, db, accountID, req)
+	if args.Get(0) != nil {
+		return args.Get(0).(*accountservice.AccountModel), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAccountService) ListAccounts(ctx context.Context, db *sql.DB, query models.ListAccountsQuery, fn func(*accountservice.AccountModel) error) (*accountservice.AccountListModel, error) {
+	args := m.Called(ctx, db, query, fn)
+	if accounts, ok := args.Get(0).([]*accountservice.AccountModel); ok {
+		for _, account := range accounts {
+			if err := fn(account); err != nil {
+				return nil, err
+			}
+		}
 	}
+	if args.Get(1) != nil {
+		return args.Get(1).(*accountservice.AccountListModel), args.Error(2)
+	}
+	return nil, args.Error(2)
+}
+
+func (m *MockAccountService) GetBalance(ctx context.Context, db *sql.DB, accountID int64, asOf time.Time) (*accountservice.BalanceModel, error) {
+	args := m.Called(ctx, db, accountID, asOf)
+	if args.Get(0) != nil {
+		return args.Get(0).(*accountservice.BalanceModel), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type MockTransferJobService struct {
+	mock.Mock
+}
+
+func (m *MockTransferJobService) CreateJob(ctx context.Context, db *sql.DB, req models.CreateTransferJobRequest) (*transferjobservice.TransferJobModel, bool, error) {
+	args := m.Called(ctx, db, req)
+	if args.Get(0) != nil {
+		return args.Get(0).(*transferjobservice.TransferJobModel), args.Bool(1), args.Error(2)
+	}
+	return nil, args.Bool(1), args.Error(2)
+}
+
+func (m *MockTransferJobService) GetJob(ctx context.Context, db *sql.DB, jobID int64) (*transferjobservice.TransferJobModel, error) {
+	args := m.Called(ctx, db, jobID)
+	if args.Get(0) != nil {
+		return args.Get(0).(*transferjobservice.TransferJobModel), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTransferJobService) ListJobRows(ctx context.Context, db *sql.DB, jobID int64, fn func(*transferjobservice.TransferJobRowModel) error) error {
+	args := m.Called(ctx, db, jobID, fn)
+	if rows, ok := args.Get(0).([]*transferjobservice.TransferJobRowModel); ok {
+		for _, row := range rows {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockTransferJobService) ProcessJob(ctx context.Context, db *sql.DB, jobID int64) error {
+	args := m.Called(ctx, db, jobID)
 	return args.Error(0)
 }
+
+type MockAPIKeyService struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyService) CreateKey(ctx context.Context, db *sql.DB, req models.CreateAPIKeyRequest) (*apikeyservice.APIKeyModel, string, error) {
+	args := m.Called(ctx, db, req)
+	if args.Get(0) != nil {
+		return args.Get(0).(*apikeyservice.APIKeyModel), args.String(1), args.Error(2)
+	}
+	return nil, args.String(1), args.Error(2)
+}
+
+func (m *MockAPIKeyService) ListKeys(ctx context.Context, db *sql.DB) ([]*apikeyservice.APIKeyModel, error) {
+	args := m.Called(ctx, db)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*apikeyservice.APIKeyModel), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAPIKeyService) RevokeKey(ctx context.Context, db *sql.DB, keyID string) (*apikeyservice.APIKeyModel, error) {
+	args := m.Called(ctx, db, keyID)
+	if args.Get(0) != nil {
+		return args.Get(0).(*apikeyservice.APIKeyModel), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAPIKeyService) Authenticate(ctx context.Context, db *sql.DB, rawKey string) (*apikeyservice.APIKeyModel, error) {
+	args := m.Called(ctx, db, rawKey)
+	if args.Get(0) != nil {
+		return args.Get(0).(*apikeyservice.APIKeyModel), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
@@ -1,19 +1,109 @@
 package models
 
-import "net/http"
+import (
+	"net/http"
+	"time"
+)
 
 type CreateAccountRequest struct {
 	AccountID      int64  `json:"account_id"`
 	InitialBalance string `json:"initial_balance"`
 }
 
+// UpdateAccountRequest is a partial update of an account's profile, omitted (nil) fields are left unchanged
+type UpdateAccountRequest struct {
+	DisplayName    *string `json:"display_name"`
+	OwnerReference *string `json:"owner_reference"`
+	AccountType    *string `json:"account_type"`
+	Currency       *string `json:"currency"`
+	Status         *string `json:"status"`
+	// Metadata replaces the stored map as a whole, an empty object clears it
+	Metadata map[string]string `json:"metadata"`
+	// IfMatch makes the update conditional on the account being at one of these versions, nil applies it
+	// unconditionally. It is set from the If-Match header, not the body.
+	IfMatch []int64 `json:"-"`
+}
+
+const (
+	AccountSortID        = "id"
+	AccountSortBalance   = "balance"
+	AccountSortCreatedAt = "created_at"
+
+	SortOrderAsc  = "asc"
+	SortOrderDesc = "desc"
+)
+
+// ListAccountsQuery selects a page of accounts, nil/empty filters are not applied
+type ListAccountsQuery struct {
+	Sort  string
+	Order string
+	// Cursor is the opaque next_cursor of the previous page, it is only valid for the same sort and order
+	Cursor        string
+	Limit         int
+	MinBalance    *float64
+	MaxBalance    *float64
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Status        string
+	// Metadata matches accounts whose metadata contains every given key/value pair
+	Metadata map[string]string
+}
+
 type CreateTransactionRequest struct {
 	SourceAccountID      int64  `json:"source_account_id"`
 	DestinationAccountID int64  `json:"destination_account_id"`
 	Amount               string `json:"amount"`
+	// Reference is an optional caller-supplied ID (e.g. invoice number), unique per source account
+	Reference   string            `json:"reference,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
 }
 
 func (ctr CreateTransactionRequest) Render(w http.ResponseWriter, r *http.Request) error {
 	// TODO Pre-processing before a response is marshalled and sent across the wire
 	return nil
 }
+
+const (
+	// BatchModeAtomic executes every leg in a single DB transaction, rolling back all legs if any fails.
+	BatchModeAtomic = "atomic"
+	// BatchModeBestEffort commits each leg independently and reports the outcome per leg.
+	BatchModeBestEffort = "best_effort"
+)
+
+type CreateBatchTransactionRequest struct {
+	Mode         string                     `json:"mode"`
+	Transactions []CreateTransactionRequest `json:"transactions"`
+}
+
+// TransferJobRow is a single parsed row of a bulk transfer CSV upload
+type TransferJobRow struct {
+	RowNumber   int
+	Transaction CreateTransactionRequest
+	Reference   string
+	// Error is set when the row failed validation, it is recorded on the job but never executed
+	Error string
+}
+
+type CreateTransferJobRequest struct {
+	FileSHA256 string
+	Rows       []TransferJobRow
+}
+
+// AccountImportRow is a single parsed row of a bulk account import
+type AccountImportRow struct {
+	LineNumber int
+	Account    CreateAccountRequest
+	// Error is set when the row could not be parsed or failed validation, the row is reported and skipped
+	Error string
+}
+
+// AccountImportSource streams the rows of a bulk account import, returning io.EOF once exhausted
+type AccountImportSource interface {
+	Next() (AccountImportRow, error)
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
@@ -0,0 +1,214 @@
+// Package ratelimit holds the token buckets and in-flight counters that keep one client from starving the others.
+// Buckets live in process or in the database, the latter sharing each client's budget across replicas.
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"aeshanw.com/accountApi/api/tracing"
+)
+
+// Budgets routes draw from, each client has a bucket per budget
+const (
+	BudgetReads     = "reads"
+	BudgetWrites    = "writes"
+	BudgetTransfers = "transfers"
+	BudgetBulk      = "bulk"
+)
+
+// Limit is a token bucket refilling PerMinute tokens a minute up to Burst, each request takes one token.
+// A zero PerMinute means unlimited.
+type Limit struct {
+	PerMinute int
+	Burst     int
+}
+
+func (l Limit) perSecond() float64 {
+	return float64(l.PerMinute) / 60
+}
+
+// Unlimited reports whether the limit lets every request through
+func (l Limit) Unlimited() bool {
+	return l.PerMinute <= 0
+}
+
+// Result is the outcome of taking a token
+type Result struct {
+	Allowed bool
+	// Limit is the bucket's size and Remaining the whole tokens left in it
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until a token is available, zero when Allowed
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// Store takes tokens from the bucket of key
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take refills the bucket for the time elapsed since its last update and takes a token when one is available
+func (b *bucket) take(now time.Time, limit Limit) Result {
+	rate := limit.perSecond()
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*rate)
+	}
+	b.updated = now
+
+	res := Result{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsToDuration((1 - b.tokens) / rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = secondsToDuration((float64(limit.Burst) - b.tokens) / rate)
+	return res
+}
+
+func (b *bucket) full(now time.Time, limit Limit) bool {
+	return b.tokens+now.Sub(b.updated).Seconds()*limit.perSecond() >= float64(limit.Burst)
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
+
+// memorySweepEvery is how many takes MemoryStore makes between sweeps of its full buckets
+const memorySweepEvery = 1024
+
+// MemoryStore keeps buckets in process, each replica then enforces the limits on its own
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	takes   int
+	now     func() time.Time
+}
+
+type memoryBucket struct {
+	bucket
+	limit Limit
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryBucket), now: time.Now}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	//A full bucket is the same as no bucket, dropping them keeps memory bounded by the recently active clients
+	if s.takes++; s.takes%memorySweepEvery == 0 {
+		for k, b := range s.buckets {
+			if b.full(now, b.limit) {
+				delete(s.buckets, k)
+			}
+		}
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{bucket: bucket{tokens: float64(limit.Burst), updated: now}}
+		s.buckets[key] = b
+	}
+	b.limit = limit
+	return b.take(now, limit), nil
+}
+
+// dbPurgeEvery is how many takes DBStore makes between purges of idle buckets
+const dbPurgeEvery = 1000
+
+// dbIdleBucketAge is how long a bucket stays unused before it is purged, any configured bucket is full again by then
+const dbIdleBucketAge = time.Hour
+
+// DBStore keeps buckets in the rate_limit_buckets table, shared by every replica. The database clock is used so
+// replicas with skewed clocks refill buckets alike.
+type DBStore struct {
+	db    *sql.DB
+	takes atomic.Int64
+}
+
+func NewDBStore(db *sql.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+func (s *DBStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	ctx, span := tracing.Start(ctx, "DBStore.Take")
+	defer span.End()
+
+	if s.takes.Add(1)%dbPurgeEvery == 0 {
+		if _, err := s.db.ExecContext(ctx, "DELETE FROM rate_limit_buckets WHERE updated_at<NOW()-$1::interval", dbIdleBucketAge.String()); err != nil {
+			return Result{}, fmt.Errorf("unable to purge idle rate limit buckets due to :%w", err)
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Result{}, fmt.Errorf("unable to take rate limit token due to :%w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO rate_limit_buckets(key,tokens,updated_at) VALUES ($1,$2,NOW()) ON CONFLICT (key) DO NOTHING", key, limit.Burst); err != nil {
+		return Result{}, fmt.Errorf("unable to take rate limit token due to :%w", err)
+	}
+	//The row lock serializes concurrent requests of the client across replicas
+	var b bucket
+	var now time.Time
+	if err := tx.QueryRowContext(ctx, "SELECT tokens,updated_at,NOW() FROM rate_limit_buckets WHERE key=$1 FOR UPDATE", key).Scan(&b.tokens, &b.updated, &now); err != nil {
+		return Result{}, fmt.Errorf("unable to take rate limit token due to :%w", err)
+	}
+	res := b.take(now, limit)
+	if _, err := tx.ExecContext(ctx, "UPDATE rate_limit_buckets SET tokens=$2,updated_at=$3 WHERE key=$1", key, b.tokens, b.updated); err != nil {
+		return Result{}, fmt.Errorf("unable to take rate limit token due to :%w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return Result{}, fmt.Errorf("unable to take rate limit token due to :%w", err)
+	}
+	return res, nil
+}
+
+// ConcurrencyLimiter caps the requests each key has in flight on this replica
+type ConcurrencyLimiter struct {
+	mu       sync.Mutex
+	max      int
+	inFlight map[string]int
+}
+
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{max: max, inFlight: make(map[string]int)}
+}
+
+// Acquire reports whether key may start another request, which must then be ended with Release
+func (l *ConcurrencyLimiter) Acquire(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[key] >= l.max {
+		return false
+	}
+	l.inFlight[key]++
+	return true
+}
+
+func (l *ConcurrencyLimiter) Release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[key]--; l.inFlight[key] <= 0 {
+		delete(l.inFlight, key)
+	}
+}
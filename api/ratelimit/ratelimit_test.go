@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{PerMinute: 60, Burst: 2}
+	ctx := context.Background()
+
+	res, err := store.Take(ctx, "transfers:api_key:a", limit)
+	assert.NoError(t, err)
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}, res)
+	res, _ = store.Take(ctx, "transfers:api_key:a", limit)
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 2 * time.Second}, res)
+	res, _ = store.Take(ctx, "transfers:api_key:a", limit)
+	assert.Equal(t, Result{Allowed: false, Limit: 2, Remaining: 0, RetryAfter: time.Second, Reset: 2 * time.Second}, res)
+
+	//Other clients and budgets have their own buckets
+	res, _ = store.Take(ctx, "transfers:api_key:b", limit)
+	assert.True(t, res.Allowed)
+	res, _ = store.Take(ctx, "reads:api_key:a", limit)
+	assert.True(t, res.Allowed)
+
+	//The bucket refills at PerMinute, never beyond Burst
+	now = now.Add(500 * time.Millisecond)
+	res, _ = store.Take(ctx, "transfers:api_key:a", limit)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+	now = now.Add(time.Hour)
+	res, _ = store.Take(ctx, "transfers:api_key:a", limit)
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}, res)
+}
+
+func TestMemoryStoreSweepsFullBuckets(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{PerMinute: 60, Burst: 10}
+
+	store.Take(context.Background(), "idle", limit)
+	now = now.Add(time.Minute)
+	for i := 1; i < memorySweepEvery; i++ {
+		store.Take(context.Background(), "busy", limit)
+	}
+	assert.NotContains(t, store.buckets, "idle")
+	assert.Contains(t, store.buckets, "busy")
+}
+
+func TestDBStore(t *testing.T) {
+	sqlInsertBucket := regexp.QuoteMeta("INSERT INTO rate_limit_buckets(key,tokens,updated_at) VALUES ($1,$2,NOW()) ON CONFLICT (key) DO NOTHING")
+	sqlSelectBucket := regexp.QuoteMeta("SELECT tokens,updated_at,NOW() FROM rate_limit_buckets WHERE key=$1 FOR UPDATE")
+	sqlUpdateBucket := regexp.QuoteMeta("UPDATE rate_limit_buckets SET tokens=$2,updated_at=$3 WHERE key=$1")
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	limit := Limit{PerMinute: 60, Burst: 2}
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	//Half a token was left a second ago, 1.5 are available now
+	mock.ExpectBegin()
+	mock.ExpectExec(sqlInsertBucket).WithArgs("transfers:api_key:a", 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(sqlSelectBucket).WithArgs("transfers:api_key:a").
+		WillReturnRows(sqlmock.NewRows([]string{"tokens", "updated_at", "now"}).AddRow(0.5, now.Add(-time.Second), now))
+	mock.ExpectExec(sqlUpdateBucket).WithArgs("transfers:api_key:a", 0.5, now).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	//The database is unreachable
+	mock.ExpectBegin().WillReturnError(errors.New("connection refused"))
+
+	store := NewDBStore(db)
+	res, err := store.Take(context.Background(), "transfers:api_key:a", limit)
+	assert.NoError(t, err)
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 1500 * time.Millisecond}, res)
+
+	_, err = store.Take(context.Background(), "transfers:api_key:a", limit)
+	assert.ErrorContains(t, err, "unable to take rate limit token due to :connection refused")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	limiter := NewConcurrencyLimiter(2)
+
+	assert.True(t, limiter.Acquire("a"))
+	assert.True(t, limiter.Acquire("a"))
+	assert.False(t, limiter.Acquire("a"))
+	assert.True(t, limiter.Acquire("b"))
+
+	limiter.Release("a")
+	assert.True(t, limiter.Acquire("a"))
+	limiter.Release("a")
+	limiter.Release("a")
+	limiter.Release("b")
+	assert.Empty(t, limiter.inFlight)
+}
@@ -0,0 +1,235 @@
+package apikey_service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"aeshanw.com/accountApi/api/audit"
+	"aeshanw.com/accountApi/api/logging"
+	"aeshanw.com/accountApi/api/models"
+	"aeshanw.com/accountApi/api/tracing"
+)
+
+// KeyPrefix starts every API key so leaked keys are easy to recognise, e.g. by secret scanners
+const KeyPrefix = "aak_"
+
+// APIKeyServiceInt defines the methods for managing and checking API keys.
+type APIKeyServiceInt interface {
+	// CreateKey stores a new key and returns it along with the raw key, which is not stored and cannot be shown again
+	CreateKey(ctx context.Context, db *sql.DB, req models.CreateAPIKeyRequest) (key *APIKeyModel, rawKey string, err error)
+	ListKeys(ctx context.Context, db *sql.DB) ([]*APIKeyModel, error)
+	RevokeKey(ctx context.Context, db *sql.DB, keyID string) (*APIKeyModel, error)
+	// Authenticate returns the active key matching rawKey, or ErrInvalidAPIKey
+	Authenticate(ctx context.Context, db *sql.DB, rawKey string) (*APIKeyModel, error)
+}
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	// ErrInvalidAPIKey covers malformed, unknown and revoked keys alike so callers cannot probe which keys exist
+	ErrInvalidAPIKey = errors.New("invalid api key")
+)
+
+type APIKeyModel struct {
+	// ID is the public part of the key, safe to log and show
+	ID        string
+	Name      string
+	Scopes    []string
+	CreatedAt time.Time
+	RevokedAt sql.NullTime
+}
+
+// apiKeyState is a key as recorded in the audit log, its hash is never recorded
+type apiKeyState struct {
+	ID        string     `json:"key_id"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+func newAPIKeyState(key *APIKeyModel) *apiKeyState {
+	state := &apiKeyState{ID: key.ID, Name: key.Name, Scopes: key.Scopes}
+	if key.RevokedAt.Valid {
+		revokedAt := key.RevokedAt.Time.UTC()
+		state.RevokedAt = &revokedAt
+	}
+	return state
+}
+
+type APIKeyService struct{}
+
+func NewAPIKeyService() *APIKeyService {
+	return &APIKeyService{}
+}
+
+const sqlAPIKeyColumns = `id,name,scopes,created_at,revoked_at`
+
+func scanAPIKey(row interface{ Scan(...any) error }) (*APIKeyModel, error) {
+	var key APIKeyModel
+	if err := row.Scan(&key.ID, &key.Name, pq.Array(&key.Scopes), &key.CreatedAt, &key.RevokedAt); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// newRawKey generates a key shaped aak_<id>_<secret>: the 12 hex character id locates the key, the 256-bit secret
+// proves possession of it
+func newRawKey() (id string, rawKey string, err error) {
+	idBytes := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	id = hex.EncodeToString(idBytes)
+	return id, KeyPrefix + id + "_" + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// hashKey hashes the whole raw key. Keys are random with 256 bits of entropy, so a fast hash is enough, unlike for
+// passwords which need a slow one.
+func hashKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseKeyID extracts the public id from a raw key
+func parseKeyID(rawKey string) (string, bool) {
+	id, _, ok := strings.Cut(strings.TrimPrefix(rawKey, KeyPrefix), "_")
+	if !ok || !strings.HasPrefix(rawKey, KeyPrefix) || len(id) != 12 {
+		return "", false
+	}
+	return id, true
+}
+
+func (aks *APIKeyService) CreateKey(ctx context.Context, db *sql.DB, req models.CreateAPIKeyRequest) (*APIKeyModel, string, error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.CreateKey")
+	defer span.End()
+
+	sqlInsertKey := `INSERT INTO api_keys(id,name,key_hash,scopes) VALUES ($1,$2,$3,$4) RETURNING ` + sqlAPIKeyColumns
+
+	id, rawKey, err := newRawKey()
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to generate api key due to :%w", err)
+	}
+
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("txn for createKey fail:%w", err)
+	}
+	defer txn.Rollback()
+
+	key, err := scanAPIKey(txn.QueryRowContext(ctx, sqlInsertKey, id, req.Name, hashKey(rawKey), pq.Array(req.Scopes)))
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to create api key due to :%w", err)
+	}
+	if err := audit.Write(ctx, txn, audit.Entry{Action: audit.ActionAPIKeyCreate, ResourceType: audit.ResourceAPIKey, ResourceID: key.ID, After: newAPIKeyState(key)}); err != nil {
+		return nil, "", err
+	}
+	if err := txn.Commit(); err != nil {
+		return nil, "", fmt.Errorf("unable to commit api key creation txn due to :%w", err)
+	}
+	logging.FromContext(ctx).Info("api key created", slog.String(logging.KeyAPIKeyID, key.ID), slog.Any("scopes", key.Scopes))
+	return key, rawKey, nil
+}
+
+func (aks *APIKeyService) ListKeys(ctx context.Context, db *sql.DB) ([]*APIKeyModel, error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.ListKeys")
+	defer span.End()
+
+	sqlListKeys := `SELECT ` + sqlAPIKeyColumns + ` FROM api_keys ORDER BY created_at,id`
+
+	rows, err := db.QueryContext(ctx, sqlListKeys)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list api keys due to :%w", err)
+	}
+	defer rows.Close()
+
+	keys := []*APIKeyModel{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read api key due to :%w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to list api keys due to :%w", err)
+	}
+	return keys, nil
+}
+
+// RevokeKey disables a key immediately. Revoking an already revoked key keeps its original revocation time.
+func (aks *APIKeyService) RevokeKey(ctx context.Context, db *sql.DB, keyID string) (*APIKeyModel, error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.RevokeKey")
+	defer span.End()
+
+	sqlLockKey := `SELECT ` + sqlAPIKeyColumns + ` FROM api_keys WHERE id=$1 FOR UPDATE`
+	sqlRevokeKey := `UPDATE api_keys SET revoked_at=COALESCE(revoked_at,NOW()) WHERE id=$1 RETURNING ` + sqlAPIKeyColumns
+
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("txn for revokeKey fail:%w", err)
+	}
+	defer txn.Rollback()
+
+	before, err := scanAPIKey(txn.QueryRowContext(ctx, sqlLockKey, keyID))
+	if err == sql.ErrNoRows {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to revoke api key due to :%w", err)
+	}
+	key, err := scanAPIKey(txn.QueryRowContext(ctx, sqlRevokeKey, keyID))
+	if err != nil {
+		return nil, fmt.Errorf("unable to revoke api key due to :%w", err)
+	}
+	if err := audit.Write(ctx, txn, audit.Entry{Action: audit.ActionAPIKeyRevoke, ResourceType: audit.ResourceAPIKey, ResourceID: key.ID,
+		Before: newAPIKeyState(before), After: newAPIKeyState(key)}); err != nil {
+		return nil, err
+	}
+	if err := txn.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to commit api key revocation txn due to :%w", err)
+	}
+	logging.FromContext(ctx).Info("api key revoked", slog.String(logging.KeyAPIKeyID, key.ID))
+	return key, nil
+}
+
+func (aks *APIKeyService) Authenticate(ctx context.Context, db *sql.DB, rawKey string) (*APIKeyModel, error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.Authenticate")
+	defer span.End()
+
+	sqlFindKey := `SELECT key_hash,` + sqlAPIKeyColumns + ` FROM api_keys WHERE id=$1`
+
+	id, ok := parseKeyID(rawKey)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	var keyHash string
+	var key APIKeyModel
+	err := db.QueryRowContext(ctx, sqlFindKey, id).Scan(&keyHash, &key.ID, &key.Name, pq.Array(&key.Scopes), &key.CreatedAt, &key.RevokedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to look up api key due to :%w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(keyHash), []byte(hashKey(rawKey))) != 1 || key.RevokedAt.Valid {
+		return nil, ErrInvalidAPIKey
+	}
+	return &key, nil
+}
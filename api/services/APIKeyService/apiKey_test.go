@@ -0,0 +1,153 @@
+package apikey_service
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+
+	"aeshanw.com/accountApi/api/audit/audittest"
+	"aeshanw.com/accountApi/api/models"
+)
+
+var apiKeyColumns = []string{"id", "name", "scopes", "created_at", "revoked_at"}
+
+func TestCreateKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO api_keys(id,name,key_hash,scopes) VALUES ($1,$2,$3,$4) RETURNING id,name,scopes,created_at,revoked_at")).
+		WithArgs(sqlmock.AnyArg(), "payouts", sqlmock.AnyArg(), pq.Array([]string{"transactions:write"})).
+		WillReturnRows(sqlmock.NewRows(apiKeyColumns).AddRow("0123456789ab", "payouts", "{transactions:write}", time.Now(), nil))
+	audittest.ExpectWrite(mock, 1)
+	mock.ExpectCommit()
+
+	key, rawKey, err := NewAPIKeyService().CreateKey(context.Background(), db, models.CreateAPIKeyRequest{Name: "payouts", Scopes: []string{"transactions:write"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789ab", key.ID)
+	assert.Equal(t, []string{"transactions:write"}, key.Scopes)
+	assert.True(t, strings.HasPrefix(rawKey, KeyPrefix))
+	_, ok := parseKeyID(rawKey)
+	assert.True(t, ok)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNewRawKeyIsUnique(t *testing.T) {
+	id1, key1, err := newRawKey()
+	assert.NoError(t, err)
+	id2, key2, err := newRawKey()
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, id1, id2)
+	assert.NotEqual(t, key1, key2)
+	assert.Len(t, key1, len(KeyPrefix)+12+1+43)
+}
+
+func TestAuthenticate(t *testing.T) {
+	sqlFindKey := regexp.QuoteMeta("SELECT key_hash,id,name,scopes,created_at,revoked_at FROM api_keys WHERE id=$1")
+	const rawKey = KeyPrefix + "0123456789ab_c2VjcmV0LXNlY3JldC1zZWNyZXQtc2VjcmV0LXNlY3JldC0"
+
+	tests := []struct {
+		name        string
+		rawKey      string
+		setupMock   func(mock sqlmock.Sqlmock)
+		expectedErr error
+	}{
+		{
+			name:   "active key",
+			rawKey: rawKey,
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sqlFindKey).WithArgs("0123456789ab").WillReturnRows(sqlmock.NewRows(append([]string{"key_hash"}, apiKeyColumns...)).
+					AddRow(hashKey(rawKey), "0123456789ab", "payouts", "{transactions:write}", time.Now(), nil))
+			},
+		},
+		{
+			name:   "wrong secret",
+			rawKey: KeyPrefix + "0123456789ab_guessed",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sqlFindKey).WithArgs("0123456789ab").WillReturnRows(sqlmock.NewRows(append([]string{"key_hash"}, apiKeyColumns...)).
+					AddRow(hashKey(rawKey), "0123456789ab", "payouts", "{transactions:write}", time.Now(), nil))
+			},
+			expectedErr: ErrInvalidAPIKey,
+		},
+		{
+			name:   "revoked key",
+			rawKey: rawKey,
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sqlFindKey).WithArgs("0123456789ab").WillReturnRows(sqlmock.NewRows(append([]string{"key_hash"}, apiKeyColumns...)).
+					AddRow(hashKey(rawKey), "0123456789ab", "payouts", "{transactions:write}", time.Now(), time.Now()))
+			},
+			expectedErr: ErrInvalidAPIKey,
+		},
+		{
+			name:   "unknown key",
+			rawKey: rawKey,
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sqlFindKey).WithArgs("0123456789ab").WillReturnError(sql.ErrNoRows)
+			},
+			expectedErr: ErrInvalidAPIKey,
+		},
+		{
+			name:        "malformed key",
+			rawKey:      "not-a-key",
+			setupMock:   func(mock sqlmock.Sqlmock) {},
+			expectedErr: ErrInvalidAPIKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+			tt.setupMock(mock)
+
+			key, err := NewAPIKeyService().Authenticate(context.Background(), db, tt.rawKey)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Nil(t, key)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "payouts", key.Name)
+				assert.Equal(t, []string{"transactions:write"}, key.Scopes)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRevokeKey(t *testing.T) {
+	sqlLockKey := regexp.QuoteMeta("SELECT id,name,scopes,created_at,revoked_at FROM api_keys WHERE id=$1 FOR UPDATE")
+	sqlRevokeKey := regexp.QuoteMeta("UPDATE api_keys SET revoked_at=COALESCE(revoked_at,NOW()) WHERE id=$1 RETURNING id,name,scopes,created_at,revoked_at")
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(sqlLockKey).WithArgs("0123456789ab").
+		WillReturnRows(sqlmock.NewRows(apiKeyColumns).AddRow("0123456789ab", "payouts", "{transactions:write}", time.Now(), nil))
+	mock.ExpectQuery(sqlRevokeKey).WithArgs("0123456789ab").
+		WillReturnRows(sqlmock.NewRows(apiKeyColumns).AddRow("0123456789ab", "payouts", "{transactions:write}", time.Now(), time.Now()))
+	audittest.ExpectWrite(mock, 1)
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(sqlLockKey).WithArgs("ffffffffffff").WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	key, err := NewAPIKeyService().RevokeKey(context.Background(), db, "0123456789ab")
+	assert.NoError(t, err)
+	assert.True(t, key.RevokedAt.Valid)
+
+	_, err = NewAPIKeyService().RevokeKey(context.Background(), db, "ffffffffffff")
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
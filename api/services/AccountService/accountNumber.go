@@ -0,0 +1,100 @@
+package account_service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// AccountIDModeClient keeps the original behaviour where clients choose the account_id
+	AccountIDModeClient = "client"
+	// AccountIDModeGenerated allocates an account number from AccountNumberFormat when account_id is omitted
+	AccountIDModeGenerated = "generated"
+
+	// DefaultAccountNumberFormat yields 12-digit account numbers such as 100000000190
+	DefaultAccountNumberFormat = "10NNNNNNNNCC"
+
+	accountNumberCheckDigits = 2
+	// maxAccountNumberDigits keeps every account number within a BIGINT
+	maxAccountNumberDigits = 18
+)
+
+var ErrInvalidAccountNumber = errors.New("invalid account number check digits")
+
+// AccountNumberFormat describes server-allocated account numbers: a fixed prefix, a zero-padded sequence number and
+// two ISO 7064 mod-97 check digits (as used by IBAN), so a mistyped digit or swapped pair of digits is rejected.
+type AccountNumberFormat struct {
+	Prefix         string
+	SequenceDigits int
+}
+
+// ParseAccountNumberFormat reads a pattern such as "10NNNNNNNNCC": leading digits are the prefix, each N is a
+// sequence digit and the pattern ends with CC for the check digits
+func ParseAccountNumberFormat(pattern string) (*AccountNumberFormat, error) {
+	body, ok := strings.CutSuffix(pattern, "CC")
+	if !ok {
+		return nil, fmt.Errorf("account number format %q must end with CC", pattern)
+	}
+	prefix := strings.TrimRight(body, "N")
+	format := &AccountNumberFormat{Prefix: prefix, SequenceDigits: len(body) - len(prefix)}
+
+	if prefix == "" || prefix[0] == '0' || strings.Trim(prefix, "0123456789") != "" {
+		return nil, fmt.Errorf("account number format %q must start with digits that are not a leading 0", pattern)
+	}
+	if format.SequenceDigits == 0 {
+		return nil, fmt.Errorf("account number format %q has no sequence digits (N)", pattern)
+	}
+	if len(pattern) > maxAccountNumberDigits {
+		return nil, fmt.Errorf("account number format %q cannot exceed %d digits", pattern, maxAccountNumberDigits)
+	}
+	return format, nil
+}
+
+func (anf *AccountNumberFormat) String() string {
+	return anf.Prefix + strings.Repeat("N", anf.SequenceDigits) + "CC"
+}
+
+// Generate builds the account number for the given sequence value
+func (anf *AccountNumberFormat) Generate(sequence int64) (int64, error) {
+	if sequence < 1 || len(strconv.FormatInt(sequence, 10)) > anf.SequenceDigits {
+		return 0, fmt.Errorf("account number sequence %d does not fit format %s", sequence, anf)
+	}
+	base, err := strconv.ParseInt(fmt.Sprintf("%s%0*d", anf.Prefix, anf.SequenceDigits, sequence), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	base *= 100
+	return base + 98 - base%97, nil
+}
+
+// Matches reports whether the ID has the shape of a generated account number. IDs of another shape, e.g. chosen by
+// clients or imported from a legacy system, carry no check digits.
+func (anf *AccountNumberFormat) Matches(accountID int64) bool {
+	digits := strconv.FormatInt(accountID, 10)
+	return len(digits) == len(anf.Prefix)+anf.SequenceDigits+accountNumberCheckDigits && strings.HasPrefix(digits, anf.Prefix)
+}
+
+// Check rejects IDs shaped like a generated account number whose check digits do not match
+func (anf *AccountNumberFormat) Check(accountID int64) error {
+	if anf.Matches(accountID) && accountID%97 != 1 {
+		return fmt.Errorf("%w: %d", ErrInvalidAccountNumber, accountID)
+	}
+	return nil
+}
+
+// AccountNumberFormatForMode returns the format to allocate and check account numbers with, or nil in client mode.
+// An empty mode means client mode and an empty pattern means DefaultAccountNumberFormat.
+func AccountNumberFormatForMode(mode, pattern string) (*AccountNumberFormat, error) {
+	switch mode {
+	case "", AccountIDModeClient:
+		return nil, nil
+	case AccountIDModeGenerated:
+		if pattern == "" {
+			pattern = DefaultAccountNumberFormat
+		}
+		return ParseAccountNumberFormat(pattern)
+	}
+	return nil, fmt.Errorf("unsupported account id mode:%s", mode)
+}
@@ -0,0 +1,62 @@
+package account_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAccountNumberFormat(t *testing.T) {
+	tests := []struct {
+		pattern     string
+		expected    *AccountNumberFormat
+		expectedErr string
+	}{
+		{pattern: "10NNNNNNNNCC", expected: &AccountNumberFormat{Prefix: "10", SequenceDigits: 8}},
+		{pattern: "7NNNCC", expected: &AccountNumberFormat{Prefix: "7", SequenceDigits: 3}},
+		{pattern: "10NNNN", expectedErr: `account number format "10NNNN" must end with CC`},
+		{pattern: "NNNNCC", expectedErr: `account number format "NNNNCC" must start with digits that are not a leading 0`},
+		{pattern: "01NNNNCC", expectedErr: `account number format "01NNNNCC" must start with digits that are not a leading 0`},
+		{pattern: "1N0NCC", expectedErr: `account number format "1N0NCC" must start with digits that are not a leading 0`},
+		{pattern: "10CC", expectedErr: `account number format "10CC" has no sequence digits (N)`},
+		{pattern: "10NNNNNNNNNNNNNNNNCC", expectedErr: `account number format "10NNNNNNNNNNNNNNNNCC" cannot exceed 18 digits`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			format, err := ParseAccountNumberFormat(tt.pattern)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, format)
+			assert.Equal(t, tt.pattern, format.String())
+		})
+	}
+}
+
+func TestAccountNumberFormat(t *testing.T) {
+	format, err := ParseAccountNumberFormat(DefaultAccountNumberFormat)
+	assert.NoError(t, err)
+
+	accountID, err := format.Generate(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100000000190), accountID)
+	assert.Equal(t, int64(1), accountID%97)
+	assert.NoError(t, format.Check(accountID))
+
+	//A single mistyped digit and a swapped pair of digits are both caught
+	assert.ErrorIs(t, format.Check(100000000191), ErrInvalidAccountNumber)
+	assert.ErrorIs(t, format.Check(100000001090), ErrInvalidAccountNumber)
+
+	//IDs of another shape are not checked
+	assert.False(t, format.Matches(123))
+	assert.NoError(t, format.Check(123))
+	assert.False(t, format.Matches(200000000190))
+
+	_, err = format.Generate(100000000)
+	assert.EqualError(t, err, "account number sequence 100000000 does not fit format 10NNNNNNNNCC")
+	_, err = format.Generate(0)
+	assert.Error(t, err)
+}
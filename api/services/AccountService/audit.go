@@ -0,0 +1,70 @@
+package account_service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"aeshanw.com/accountApi/api/audit"
+)
+
+// accountState is an account as recorded in the audit log
+type accountState struct {
+	ID             int64             `json:"id"`
+	Balance        float64           `json:"balance"`
+	DisplayName    string            `json:"display_name"`
+	OwnerReference string            `json:"owner_reference"`
+	AccountType    string            `json:"account_type"`
+	Currency       string            `json:"currency"`
+	Status         string            `json:"status"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	Version        int64             `json:"version"`
+}
+
+func newAccountState(account *AccountModel) *accountState {
+	return &accountState{
+		ID:             account.ID,
+		Balance:        account.Balance,
+		DisplayName:    account.DisplayName,
+		OwnerReference: account.OwnerReference,
+		AccountType:    account.AccountType,
+		Currency:       account.Currency,
+		Status:         account.Status,
+		Metadata:       account.Metadata,
+		Version:        account.Version,
+	}
+}
+
+// auditAccountsCreated records the creation of the accounts matching where as they are in txn, with the database's
+// defaults filled in
+func auditAccountsCreated(ctx context.Context, txn *sql.Tx, where string, args ...any) error {
+	rows, err := txn.QueryContext(ctx, `SELECT `+sqlAccountColumns+` FROM accounts WHERE `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return fmt.Errorf("unable to read created accounts due to :%w", err)
+	}
+	defer rows.Close()
+
+	var entries []audit.Entry
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return fmt.Errorf("unable to read created accounts due to :%w", err)
+		}
+		entries = append(entries, accountCreatedEntry(account))
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("unable to read created accounts due to :%w", err)
+	}
+	return audit.Write(ctx, txn, entries...)
+}
+
+// accountCreatedEntry is the audit entry of an account as it was created
+func accountCreatedEntry(account *AccountModel) audit.Entry {
+	return audit.Entry{
+		Action:       audit.ActionAccountCreate,
+		ResourceType: audit.ResourceAccount,
+		ResourceID:   strconv.FormatInt(account.ID, 10),
+		After:        newAccountState(account),
+	}
+}
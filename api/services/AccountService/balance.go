@@ -0,0 +1,196 @@
+package account_service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+
+	"aeshanw.com/accountApi/api/health"
+	"aeshanw.com/accountApi/api/ledger"
+	"aeshanw.com/accountApi/api/logging"
+	"aeshanw.com/accountApi/api/tracing"
+)
+
+var (
+	// ErrAsOfInFuture is returned for a balance asked as of a time that has not happened yet
+	ErrAsOfInFuture = errors.New("as_of is in the future")
+	// ErrNotOpenAsOf is returned for a balance asked as of a time before the account was created
+	ErrNotOpenAsOf = errors.New("account did not exist at as_of")
+)
+
+// snapshotBatchSize is how many accounts SnapshotBalances considers per DB txn
+const snapshotBatchSize = 1000
+
+// BalanceModel is an account's balance at a point in time
+type BalanceModel struct {
+	AccountID int64
+	Balance   float64
+	AsOf      time.Time
+}
+
+// GetBalance returns the balance the account had at asOf, the current one when asOf is zero. It is the latest balance
+// snapshot taken by asOf plus the transfers posted after it up to asOf, so it equals the balance GetAccount returned at
+// asOf: transfers are posted in the order they commit and neither reads while one is posted but not yet committed.
+func (as *AccountService) GetBalance(ctx context.Context, db *sql.DB, accountID int64, asOf time.Time) (*BalanceModel, error) {
+	ctx, span := tracing.Start(ctx, "AccountService.GetBalance")
+	defer span.End()
+
+	sqlNow := `SELECT clock_timestamp() FROM accounts WHERE id=$1`
+	sqlBalanceAsOf := `WITH s AS (SELECT taken_at,balance FROM account_balance_snapshots WHERE account_id=$1 AND taken_at<=$2 ORDER BY taken_at DESC LIMIT 1) ` +
+		`SELECT s.balance` +
+		`+COALESCE((SELECT SUM(amount) FROM transactions WHERE destination_account_id=$1 AND COALESCE(posted_at,created_at)>s.taken_at AND COALESCE(posted_at,created_at)<=$2),0)` +
+		`-COALESCE((SELECT SUM(amount) FROM transactions WHERE source_account_id=$1 AND COALESCE(posted_at,created_at)>s.taken_at AND COALESCE(posted_at,created_at)<=$2),0) FROM s`
+
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("txn for getBalance fail:%w", err)
+	}
+	defer txn.Rollback()
+
+	//Once the lock is held every transfer posted by now has committed
+	if err := ledger.LockShared(ctx, txn); err != nil {
+		return nil, err
+	}
+	var now time.Time
+	if err := txn.QueryRowContext(ctx, sqlNow, accountID).Scan(&now); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAccountNotFound
+		}
+		return nil, fmt.Errorf("unable to fetch balance due to :%w", err)
+	}
+	//The database keeps microseconds, a finer asOf must not round up past a transfer posted just after it
+	asOf = asOf.Truncate(time.Microsecond)
+	if asOf.IsZero() {
+		asOf = now
+	}
+	if asOf.After(now) {
+		return nil, ErrAsOfInFuture
+	}
+
+	balance := &BalanceModel{AccountID: accountID, AsOf: asOf.UTC()}
+	if err := txn.QueryRowContext(ctx, sqlBalanceAsOf, accountID, asOf).Scan(&balance.Balance); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotOpenAsOf
+		}
+		return nil, fmt.Errorf("unable to fetch balance due to :%w", err)
+	}
+	if err := txn.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to commit balance txn due to :%w", err)
+	}
+	return balance, nil
+}
+
+// SnapshotBalances snapshots the balance of every account changed since its last snapshot and returns how many it
+// took. Accounts with a transfer in flight are skipped until the next run, every transfer posted by a snapshot is then
+// in its balance.
+func SnapshotBalances(ctx context.Context, db *sql.DB) (int, error) {
+	ctx, span := tracing.Start(ctx, "AccountService.SnapshotBalances")
+	defer span.End()
+
+	snapshots := 0
+	var afterID int64
+	for {
+		n, lastID, err := snapshotBalancesBatch(ctx, db, afterID)
+		snapshots += n
+		if err != nil || lastID == 0 {
+			return snapshots, err
+		}
+		afterID = lastID
+	}
+}
+
+// snapshotBalancesBatch snapshots the accounts of the next batch after afterID, returning the last account it
+// considered or 0 once there are none left
+func snapshotBalancesBatch(ctx context.Context, db *sql.DB, afterID int64) (int, int64, error) {
+	//SKIP LOCKED never waits, so a snapshot cannot deadlock with transfers locking the same accounts in another order
+	sqlLockAccounts := `SELECT id FROM accounts WHERE id>$1 ORDER BY id LIMIT $2 FOR SHARE SKIP LOCKED`
+	sqlSnapshotBalances := `INSERT INTO account_balance_snapshots(account_id,taken_at,balance) SELECT a.id,statement_timestamp(),a.balance FROM accounts a ` +
+		`WHERE a.id=ANY($1) AND NOT EXISTS (SELECT 1 FROM account_balance_snapshots s WHERE s.account_id=a.id AND s.taken_at>=a.updated_at)`
+
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("txn for snapshotBalances fail:%w", err)
+	}
+	defer txn.Rollback()
+
+	rows, err := txn.QueryContext(ctx, sqlLockAccounts, afterID, snapshotBatchSize)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to lock accounts due to :%w", err)
+	}
+	defer rows.Close()
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return 0, 0, fmt.Errorf("unable to lock accounts due to :%w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("unable to lock accounts due to :%w", err)
+	}
+	rows.Close()
+	if len(ids) == 0 {
+		return 0, 0, nil
+	}
+
+	result, err := txn.ExecContext(ctx, sqlSnapshotBalances, pq.Array(ids))
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to snapshot balances due to :%w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to snapshot balances due to :%w", err)
+	}
+	if err := txn.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("unable to commit balance snapshot txn due to :%w", err)
+	}
+	return int(n), ids[len(ids)-1], nil
+}
+
+// BalanceSnapshotter snapshots account balances every interval
+type BalanceSnapshotter struct {
+	db        *sql.DB
+	interval  time.Duration
+	heartbeat *health.Heartbeat
+}
+
+func NewBalanceSnapshotter(db *sql.DB, interval time.Duration) *BalanceSnapshotter {
+	return &BalanceSnapshotter{db: db, interval: interval, heartbeat: health.NewHeartbeat("balance snapshotter", interval)}
+}
+
+// Run snapshots until ctx is cancelled, starting at once. It logs with the logger carried by ctx.
+func (bs *BalanceSnapshotter) Run(ctx context.Context) {
+	bs.heartbeat.Start()
+	defer bs.heartbeat.Stop()
+
+	ticker := time.NewTicker(bs.interval)
+	defer ticker.Stop()
+
+	for {
+		done := bs.heartbeat.Busy()
+		snapshots, err := SnapshotBalances(ctx, bs.db)
+		done()
+		if err != nil {
+			logging.FromContext(ctx).Error("unable to snapshot balances", slog.String(logging.KeyError, err.Error()))
+		} else if snapshots > 0 {
+			logging.FromContext(ctx).Info("balances snapshotted", slog.Int("count", snapshots))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check is the snapshotter's readiness check, see health.Heartbeat
+func (bs *BalanceSnapshotter) Check(ctx context.Context) error {
+	return bs.heartbeat.Check(ctx)
+}
@@ -0,0 +1,163 @@
+package account_service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+
+	"aeshanw.com/accountApi/api/ledger/ledgertest"
+)
+
+func TestGetBalance(t *testing.T) {
+	sqlNow := regexp.QuoteMeta(`SELECT clock_timestamp() FROM accounts WHERE id=$1`)
+	sqlBalanceAsOf := regexp.QuoteMeta(`WITH s AS (SELECT taken_at,balance FROM account_balance_snapshots WHERE account_id=$1 AND taken_at<=$2`)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	monthEnd := time.Date(2024, 5, 31, 23, 59, 59, 999999999, time.UTC)
+
+	tests := []struct {
+		name            string
+		asOf            time.Time
+		mockSetup       func(sqlmock.Sqlmock)
+		expectedErr     error
+		expectedBalance *BalanceModel
+	}{
+		{
+			name: "as of a past time",
+			asOf: monthEnd,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				ledgertest.ExpectLockShared(mock)
+				mock.ExpectQuery(sqlNow).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"clock_timestamp"}).AddRow(now))
+				//The database keeps microseconds, the nanoseconds are dropped rather than rounded up
+				mock.ExpectQuery(sqlBalanceAsOf).WithArgs(1, monthEnd.Truncate(time.Microsecond)).WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(80.5))
+				mock.ExpectCommit()
+			},
+			expectedBalance: &BalanceModel{AccountID: 1, Balance: 80.5, AsOf: monthEnd.Truncate(time.Microsecond)},
+		},
+		{
+			name: "current balance without as of",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				ledgertest.ExpectLockShared(mock)
+				mock.ExpectQuery(sqlNow).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"clock_timestamp"}).AddRow(now))
+				mock.ExpectQuery(sqlBalanceAsOf).WithArgs(1, now).WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(100.0))
+				mock.ExpectCommit()
+			},
+			expectedBalance: &BalanceModel{AccountID: 1, Balance: 100.0, AsOf: now},
+		},
+		{
+			//A transfer posted at monthEnd commits while the lock is awaited, the time is only read once it has committed
+			name: "transfer posted by as of and committed after it",
+			asOf: monthEnd,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock_shared($1)")).
+					WillDelayFor(10 * time.Millisecond).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(sqlNow).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"clock_timestamp"}).AddRow(now))
+				mock.ExpectQuery(sqlBalanceAsOf).WithArgs(1, monthEnd.Truncate(time.Microsecond)).WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(70.5))
+				mock.ExpectCommit()
+			},
+			expectedBalance: &BalanceModel{AccountID: 1, Balance: 70.5, AsOf: monthEnd.Truncate(time.Microsecond)},
+		},
+		{
+			name: "as of in the future",
+			asOf: now.Add(time.Second),
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				ledgertest.ExpectLockShared(mock)
+				mock.ExpectQuery(sqlNow).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"clock_timestamp"}).AddRow(now))
+				mock.ExpectRollback()
+			},
+			expectedErr: ErrAsOfInFuture,
+		},
+		{
+			name: "before the account was created",
+			asOf: monthEnd,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				ledgertest.ExpectLockShared(mock)
+				mock.ExpectQuery(sqlNow).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"clock_timestamp"}).AddRow(now))
+				mock.ExpectQuery(sqlBalanceAsOf).WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedErr: ErrNotOpenAsOf,
+		},
+		{
+			name: "account not found",
+			asOf: monthEnd,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				ledgertest.ExpectLockShared(mock)
+				mock.ExpectQuery(sqlNow).WithArgs(1).WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedErr: ErrAccountNotFound,
+		},
+		{
+			name: "database error",
+			asOf: monthEnd,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				ledgertest.ExpectLockShared(mock)
+				mock.ExpectQuery(sqlNow).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"clock_timestamp"}).AddRow(now))
+				mock.ExpectQuery(sqlBalanceAsOf).WillReturnError(errors.New("database error"))
+				mock.ExpectRollback()
+			},
+			expectedErr: errors.New("unable to fetch balance due to :database error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			balance, err := NewAccountService().GetBalance(context.Background(), db, 1, tt.asOf)
+
+			if tt.expectedErr != nil {
+				assert.EqualError(t, err, tt.expectedErr.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedBalance, balance)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSnapshotBalances(t *testing.T) {
+	sqlLockAccounts := regexp.QuoteMeta(`SELECT id FROM accounts WHERE id>$1 ORDER BY id LIMIT $2 FOR SHARE SKIP LOCKED`)
+	sqlSnapshotBalances := regexp.QuoteMeta(`INSERT INTO account_balance_snapshots(account_id,taken_at,balance) SELECT a.id,statement_timestamp(),a.balance FROM accounts a`)
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	//Batches continue after the last account locked, until none are left
+	mock.ExpectBegin()
+	mock.ExpectQuery(sqlLockAccounts).WithArgs(0, snapshotBatchSize).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(4))
+	mock.ExpectExec(sqlSnapshotBalances).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(sqlLockAccounts).WithArgs(4, snapshotBatchSize).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec(sqlSnapshotBalances).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(sqlLockAccounts).WithArgs(7, snapshotBatchSize).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	snapshots, err := SnapshotBalances(context.Background(), db)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, snapshots)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -5,33 +5,66 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"strconv"
 	"sync"
 	"time"
 
+	"aeshanw.com/accountApi/api/audit"
+	"aeshanw.com/accountApi/api/logging"
+	"aeshanw.com/accountApi/api/metrics"
 	"aeshanw.com/accountApi/api/models"
+	"aeshanw.com/accountApi/api/tracing"
 )
 
 // AccountService defines the methods for interacting with the account service.
 type AccountServiceInt interface {
 	// Define methods for interacting with the database
-	CreateAccount(ctx context.Context, db *sql.DB, req models.CreateAccountRequest) error
+	// CreateAccount returns the new account, whose ID is allocated by the service when the request has none
+	CreateAccount(ctx context.Context, db *sql.DB, req models.CreateAccountRequest) (*AccountModel, error)
 	GetAccount(ctx context.Context, db *sql.DB, accountID int64) (*AccountModel, error)
+	ImportAccounts(ctx context.Context, db *sql.DB, src models.AccountImportSource) (*AccountImportModel, error)
+	UpdateAccount(ctx context.Context, db *sql.DB, accountID int64, req models.UpdateAccountRequest) (*AccountModel, error)
+	// ListAccounts streams a page of accounts in the requested order to fn
+	ListAccounts(ctx context.Context, db *sql.DB, query models.ListAccountsQuery, fn func(*AccountModel) error) (*AccountListModel, error)
+	// GetBalance returns the balance the account had at asOf, the current one when asOf is zero
+	GetBalance(ctx context.Context, db *sql.DB, accountID int64, asOf time.Time) (*BalanceModel, error)
 }
 
+const (
+	AccountStatusActive = "active"
+	AccountStatusFrozen = "frozen"
+	AccountStatusClosed = "closed"
+)
+
+var ErrAccountNotFound = errors.New("account not found")
+
+// ErrVersionMismatch is returned when a conditional update finds the account at a version it was not conditioned on
+var ErrVersionMismatch = errors.New("account version does not match")
+
 type AccountModel struct {
-	ID        int64
-	Balance   float64
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID             int64
+	Balance        float64
+	DisplayName    string
+	OwnerReference string
+	AccountType    string
+	Currency       string
+	Status         string
+	Metadata       map[string]string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	// Version starts at 1 and is bumped by the database on every change to the row
+	Version int64
 }
 
 func NewAccountModel() *AccountModel {
-	return &AccountModel{}
+	return &AccountModel{Version: 1}
 }
 
 func (am *AccountModel) SetFromRequest(req models.CreateAccountRequest) error {
+	if req.AccountID < 0 {
+		return fmt.Errorf("account_id cannot be negative, input:%v", req.AccountID)
+	}
 	am.ID = req.AccountID
 
 	floatIntialBalance, err := strconv.ParseFloat(req.InitialBalance, 64)
@@ -48,62 +81,146 @@ func (am *AccountModel) SetFromRequest(req models.CreateAccountRequest) error {
 	return nil
 }
 
-type AccountService struct{}
+// MaxAccountNumberAttempts bounds how many sequence values are skipped because a client already chose that number
+const MaxAccountNumberAttempts = 5
+
+type AccountService struct {
+	// accountNumbers is nil in client mode, where every request must carry its account_id
+	accountNumbers *AccountNumberFormat
+}
 
 func NewAccountService() *AccountService {
 	return &AccountService{}
 }
 
+// NewAccountServiceWithAccountNumbers allocates account numbers in the given format when a request has no account_id
+func NewAccountServiceWithAccountNumbers(format *AccountNumberFormat) *AccountService {
+	return &AccountService{accountNumbers: format}
+}
+
 // Mutex is required to handle race-conditions where 2 threads compete to UPDATE a account row in the DB
 var mutex sync.Mutex
 
-func (as *AccountService) CreateAccount(ctx context.Context, db *sql.DB, req models.CreateAccountRequest) error {
+func (as *AccountService) CreateAccount(ctx context.Context, db *sql.DB, req models.CreateAccountRequest) (*AccountModel, error) {
+	ctx, span := tracing.Start(ctx, "AccountService.CreateAccount")
+	defer span.End()
+
 	//Mutex-lock to avoid race-cases
 	mutex.Lock()
 	defer mutex.Unlock()
 
 	account := NewAccountModel()
 	if err := account.SetFromRequest(req); err != nil {
-		return fmt.Errorf("invalid create-account-request due to:%w", err)
+		return nil, fmt.Errorf("invalid create-account-request due to:%w", err)
 	}
 
-	fmt.Printf("account-model: %v\n", account)
+	if as.accountNumbers != nil {
+		if account.ID == 0 {
+			return as.createNumberedAccount(ctx, db, account)
+		}
+		if err := as.accountNumbers.Check(account.ID); err != nil {
+			return nil, fmt.Errorf("invalid create-account-request due to:%w", err)
+		}
+	} else if account.ID == 0 {
+		return nil, errors.New("invalid create-account-request due to:account_id is required")
+	}
 
 	// Begin a transaction with the specified options
 	txn, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("txn for createAccount fail:%w", err)
+		return nil, fmt.Errorf("txn for createAccount fail:%w", err)
 	}
+	defer metrics.ObserveDBTransaction(metrics.OpCreateAccount, time.Now())
 
 	//Confirm the account exists
 	sqlCheckForAccount := `SELECT COUNT (id) FROM accounts WHERE id=$1`
-	sqlInsertNewAccount := `INSERT INTO accounts(id,balance) VALUES ($1,$2)`
+	sqlInsertNewAccount := `INSERT INTO accounts(id,balance) VALUES ($1,$2) RETURNING ` + sqlAccountColumns
 
 	var count int
 	if err := txn.QueryRowContext(ctx, sqlCheckForAccount, req.AccountID).Scan(&count); err != nil {
 		txn.Rollback()
-		return fmt.Errorf("check for existing account:%w", err)
+		return nil, fmt.Errorf("check for existing account:%w", err)
 	}
 
-	log.Println("count done!")
-
 	if count > 0 {
 		//No existing account must exist
 		txn.Rollback()
-		return errors.New("account already exists")
+		return nil, errors.New("account already exists")
 	}
 
-	log.Println("count check ok!")
-
 	//Race conditions unlikely for this resource as the unique PK index ensures the 2nd try will fail hence data-consistency is maintained
-	if _, err = txn.ExecContext(ctx, sqlInsertNewAccount, account.ID, account.Balance); err != nil {
+	//The account is read back so the response carries the database's defaults, e.g. status and timestamps
+	account, err = scanAccount(txn.QueryRowContext(ctx, sqlInsertNewAccount, account.ID, account.Balance))
+	if err != nil {
 		txn.Rollback()
-		return fmt.Errorf("unable to insert new account due to :%w", err)
+		return nil, fmt.Errorf("unable to insert new account due to :%w", err)
+	}
+	if err := audit.Write(ctx, txn, accountCreatedEntry(account)); err != nil {
+		txn.Rollback()
+		return nil, err
 	}
 
 	if err = txn.Commit(); err != nil {
-		return fmt.Errorf("unable to commit account-creation txn due to :%w", err)
+		return nil, fmt.Errorf("unable to commit account-creation txn due to :%w", err)
 	}
 
-	return nil
+	logAccountCreated(ctx, account)
+	return account, nil
+}
+
+// createNumberedAccount allocates the next account number from a DB sequence, so concurrent requests and replicas
+// never race for the same ID. Numbers a client already took in client mode are skipped.
+func (as *AccountService) createNumberedAccount(ctx context.Context, db *sql.DB, account *AccountModel) (*AccountModel, error) {
+	sqlNextAccountNumber := `SELECT nextval('account_number_seq')`
+	sqlInsertNewAccount := `INSERT INTO accounts(id,balance) VALUES ($1,$2) ON CONFLICT (id) DO NOTHING RETURNING ` + sqlAccountColumns
+
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("txn for createAccount fail:%w", err)
+	}
+	defer metrics.ObserveDBTransaction(metrics.OpCreateAccount, time.Now())
+
+	for attempt := 0; attempt < MaxAccountNumberAttempts; attempt++ {
+		var sequence int64
+		if err := txn.QueryRowContext(ctx, sqlNextAccountNumber).Scan(&sequence); err != nil {
+			txn.Rollback()
+			return nil, fmt.Errorf("unable to allocate account number due to :%w", err)
+		}
+		accountID, err := as.accountNumbers.Generate(sequence)
+		if err != nil {
+			txn.Rollback()
+			return nil, fmt.Errorf("unable to allocate account number due to :%w", err)
+		}
+
+		created, err := scanAccount(txn.QueryRowContext(ctx, sqlInsertNewAccount, accountID, account.Balance))
+		if err == sql.ErrNoRows {
+			//A client-chosen account already holds this number
+			metrics.RecordDBRetry(metrics.OpCreateAccount)
+			continue
+		}
+		if err != nil {
+			txn.Rollback()
+			return nil, fmt.Errorf("unable to insert new account due to :%w", err)
+		}
+		if err := audit.Write(ctx, txn, accountCreatedEntry(created)); err != nil {
+			txn.Rollback()
+			return nil, err
+		}
+
+		if err = txn.Commit(); err != nil {
+			return nil, fmt.Errorf("unable to commit account-creation txn due to :%w", err)
+		}
+		logAccountCreated(ctx, created)
+		return created, nil
+	}
+
+	txn.Rollback()
+	return nil, fmt.Errorf("unable to allocate account number after %d attempts", MaxAccountNumberAttempts)
+}
+
+func logAccountCreated(ctx context.Context, account *AccountModel) {
+	logging.FromContext(ctx).Info("account created",
+		slog.Int64(logging.KeyAccountID, account.ID),
+		slog.Float64(logging.KeyBalance, account.Balance),
+	)
 }
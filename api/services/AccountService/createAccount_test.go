@@ -6,14 +6,33 @@ import (
 	"errors"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
 
+	"aeshanw.com/accountApi/api/audit/audittest"
 	"aeshanw.com/accountApi/api/models"
 )
 
+// expectAccountsAudited expects the accounts created with ids to be read back and recorded in the audit log
+func expectAccountsAudited(mock sqlmock.Sqlmock, where string, ids ...int64) {
+	rows := sqlmock.NewRows(accountColumns)
+	for _, id := range ids {
+		rows.AddRow(id, 100.0, "", "", "", "", "active", []byte(`{}`), time.Now(), time.Now(), 1)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT ` + sqlAccountColumns + ` FROM accounts WHERE ` + where + ` ORDER BY id`)).WillReturnRows(rows)
+	audittest.ExpectWrite(mock, len(ids))
+}
+
+// createdAccountRow is an account as the database returns it from the INSERT, with its defaults filled in
+func createdAccountRow(id int64) *sqlmock.Rows {
+	return sqlmock.NewRows(accountColumns).AddRow(id, 100.0, "", "", "", "", "active", []byte(`{}`), accountCreatedAt, accountCreatedAt, 1)
+}
+
+var accountCreatedAt = time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
 // MockDB is a mock database connection
 type MockDB struct {
 	mock.Mock
@@ -58,12 +77,19 @@ func (m *MockRow) Scan(dest ...interface{}) error {
 }
 
 func TestCreateAccount(t *testing.T) {
+	accountNumbers, err := ParseAccountNumberFormat(DefaultAccountNumberFormat)
+	if err != nil {
+		t.Fatalf("invalid account number format: %v", err)
+	}
+
 	tests := []struct {
 		name                 string
+		accountNumbers       *AccountNumberFormat
 		req                  models.CreateAccountRequest
 		mockSetup            func(sqlmock.Sqlmock)
 		expectError          bool
 		expectedErrorMessage string
+		expectedAccountID    int64
 	}{
 		{
 			name: "successful account creation",
@@ -74,11 +100,13 @@ func TestCreateAccount(t *testing.T) {
 			mockSetup: func(mock sqlmock.Sqlmock) {
 				mock.ExpectBegin()
 				mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT (id) FROM accounts WHERE id=$1")).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count(id)"}).AddRow(0))
-				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO accounts(id,balance) VALUES ($1,$2)")).WithArgs(1, 100.0).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO accounts(id,balance) VALUES ($1,$2) RETURNING "+sqlAccountColumns)).WithArgs(1, 100.0).WillReturnRows(createdAccountRow(1))
+				audittest.ExpectWrite(mock, 1)
 				mock.ExpectCommit()
 			},
 			expectError:          false,
 			expectedErrorMessage: "",
+			expectedAccountID:    1,
 		},
 		{
 			name: "account already exists",
@@ -128,6 +156,71 @@ func TestCreateAccount(t *testing.T) {
 			expectError:          true,
 			expectedErrorMessage: "invalid create-account-request due to:inital_balance cannot be less than 0",
 		},
+		{
+			name: "account id cannot be negative",
+			req: models.CreateAccountRequest{
+				AccountID:      -1,
+				InitialBalance: "100.0",
+			},
+			mockSetup:            func(mock sqlmock.Sqlmock) {},
+			expectError:          true,
+			expectedErrorMessage: "invalid create-account-request due to:account_id cannot be negative",
+		},
+		{
+			name: "client mode requires an account id",
+			req: models.CreateAccountRequest{
+				InitialBalance: "100.0",
+			},
+			mockSetup:            func(mock sqlmock.Sqlmock) {},
+			expectError:          true,
+			expectedErrorMessage: "invalid create-account-request due to:account_id is required",
+		},
+		{
+			name:           "generated mode allocates the next free account number",
+			accountNumbers: accountNumbers,
+			req: models.CreateAccountRequest{
+				InitialBalance: "100.0",
+			},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT nextval('account_number_seq')")).WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(1))
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO accounts(id,balance) VALUES ($1,$2) ON CONFLICT (id) DO NOTHING RETURNING "+sqlAccountColumns)).
+					WithArgs(100000000190, 100.0).WillReturnRows(sqlmock.NewRows(accountColumns))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT nextval('account_number_seq')")).WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(2))
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO accounts(id,balance) VALUES ($1,$2) ON CONFLICT (id) DO NOTHING RETURNING "+sqlAccountColumns)).
+					WithArgs(100000000287, 100.0).WillReturnRows(createdAccountRow(100000000287))
+				audittest.ExpectWrite(mock, 1)
+				mock.ExpectCommit()
+			},
+			expectedAccountID: 100000000287,
+		},
+		{
+			name:           "generated mode rejects mistyped check digits",
+			accountNumbers: accountNumbers,
+			req: models.CreateAccountRequest{
+				AccountID:      100000000191,
+				InitialBalance: "100.0",
+			},
+			mockSetup:            func(mock sqlmock.Sqlmock) {},
+			expectError:          true,
+			expectedErrorMessage: "invalid create-account-request due to:invalid account number check digits: 100000000191",
+		},
+		{
+			name:           "generated mode still accepts client-chosen ids of another shape",
+			accountNumbers: accountNumbers,
+			req: models.CreateAccountRequest{
+				AccountID:      1,
+				InitialBalance: "100.0",
+			},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT (id) FROM accounts WHERE id=$1")).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count(id)"}).AddRow(0))
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO accounts(id,balance) VALUES ($1,$2) RETURNING "+sqlAccountColumns)).WithArgs(1, 100.0).WillReturnRows(createdAccountRow(1))
+				audittest.ExpectWrite(mock, 1)
+				mock.ExpectCommit()
+			},
+			expectedAccountID: 1,
+		},
 	}
 
 	for _, tt := range tests {
@@ -146,9 +239,12 @@ func TestCreateAccount(t *testing.T) {
 
 			// Create a new account service with the mock database
 			as := NewAccountService()
+			if tt.accountNumbers != nil {
+				as = NewAccountServiceWithAccountNumbers(tt.accountNumbers)
+			}
 
 			// Invoke the CreateAccount method
-			err = as.CreateAccount(context.Background(), db, tt.req)
+			account, err := as.CreateAccount(context.Background(), db, tt.req)
 
 			// Assert error if expected
 			if tt.expectError {
@@ -156,6 +252,8 @@ func TestCreateAccount(t *testing.T) {
 				assert.Contains(t, err.Error(), tt.expectedErrorMessage)
 			} else {
 				assert.NoError(t, err)
+				assert.Equal(t, &AccountModel{ID: tt.expectedAccountID, Balance: 100.0, Status: AccountStatusActive, Metadata: map[string]string{},
+					CreatedAt: accountCreatedAt, UpdatedAt: accountCreatedAt, Version: 1}, account)
 			}
 
 			// Ensure all expectations were met
@@ -3,23 +3,60 @@ package account_service
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
-	"log"
+
+	"aeshanw.com/accountApi/api/ledger"
+	"aeshanw.com/accountApi/api/tracing"
 )
 
-func (as *AccountService) GetAccount(ctx context.Context, db *sql.DB, accountID int64) (*AccountModel, error) {
-	sqlGetAccount := `SELECT id,balance,created_at,updated_at FROM accounts WHERE id=$1`
+const sqlAccountColumns = `id,balance,display_name,owner_reference,account_type,currency,status,metadata,created_at,updated_at,version`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
+func scanAccount(row rowScanner) (*AccountModel, error) {
 	var account AccountModel
-	err := db.QueryRowContext(ctx, sqlGetAccount, accountID).Scan(&account.ID, &account.Balance, &account.CreatedAt, &account.UpdatedAt)
+	var metadata []byte
+	if err := row.Scan(&account.ID, &account.Balance, &account.DisplayName, &account.OwnerReference, &account.AccountType,
+		&account.Currency, &account.Status, &metadata, &account.CreatedAt, &account.UpdatedAt, &account.Version); err != nil {
+		return nil, err
+	}
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &account.Metadata); err != nil {
+			return nil, fmt.Errorf("unable to decode account metadata due to: %w", err)
+		}
+	}
+	return &account, nil
+}
+
+// GetAccount returns the account with the balance after exactly the transfers posted so far, see GetBalance
+func (as *AccountService) GetAccount(ctx context.Context, db *sql.DB, accountID int64) (*AccountModel, error) {
+	ctx, span := tracing.Start(ctx, "AccountService.GetAccount")
+	defer span.End()
+
+	sqlGetAccount := `SELECT ` + sqlAccountColumns + ` FROM accounts WHERE id=$1`
+
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("txn for getAccount fail:%w", err)
+	}
+	defer txn.Rollback()
+
+	if err := ledger.LockShared(ctx, txn); err != nil {
+		return nil, err
+	}
+	account, err := scanAccount(txn.QueryRowContext(ctx, sqlGetAccount, accountID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("unable to fetch account due to: %w", err)
 		}
 		return nil, err
 	}
+	if err := txn.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to commit getAccount txn due to :%w", err)
+	}
 
-	log.Printf("account: %v\n", account)
-
-	return &account, nil
+	return account, nil
 }
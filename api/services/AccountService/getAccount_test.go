@@ -10,8 +10,12 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+
+	"aeshanw.com/accountApi/api/ledger/ledgertest"
 )
 
+var accountColumns = []string{"id", "balance", "display_name", "owner_reference", "account_type", "currency", "status", "metadata", "created_at", "updated_at", "version"}
+
 func TestGetAccount(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -24,25 +28,38 @@ func TestGetAccount(t *testing.T) {
 			name:      "successfully retrieve account",
 			accountID: 1,
 			mockSetup: func(mock sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"id", "balance", "created_at", "updated_at"}).
-					AddRow(1, 100.23, time.Now(), time.Now())
-				mock.ExpectQuery(`SELECT id,balance,created_at,updated_at FROM accounts WHERE id=\$1`).
+				rows := sqlmock.NewRows(accountColumns).
+					AddRow(1, 100.23, "Main wallet", "cust-1", "personal", "SGD", "active", []byte(`{"tier":"gold"}`), time.Now(), time.Now(), 1)
+				mock.ExpectBegin()
+				ledgertest.ExpectLockShared(mock)
+				mock.ExpectQuery(`SELECT id,balance,display_name,owner_reference,account_type,currency,status,metadata,created_at,updated_at,version FROM accounts WHERE id=\$1`).
 					WithArgs(1).
 					WillReturnRows(rows)
+				mock.ExpectCommit()
 			},
 			expectedErr: nil,
 			expectedAcct: &AccountModel{
-				ID:      1,
-				Balance: 100.23,
+				ID:             1,
+				Balance:        100.23,
+				DisplayName:    "Main wallet",
+				OwnerReference: "cust-1",
+				AccountType:    "personal",
+				Currency:       "SGD",
+				Status:         AccountStatusActive,
+				Metadata:       map[string]string{"tier": "gold"},
+				Version:        1,
 			},
 		},
 		{
 			name:      "account not found",
 			accountID: 2,
 			mockSetup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(`SELECT id,balance,created_at,updated_at FROM accounts WHERE id=\$1`).
+				mock.ExpectBegin()
+				ledgertest.ExpectLockShared(mock)
+				mock.ExpectQuery(`SELECT id,balance,display_name,owner_reference,account_type,currency,status,metadata,created_at,updated_at,version FROM accounts WHERE id=\$1`).
 					WithArgs(2).
 					WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
 			},
 			expectedErr:  fmt.Errorf("unable to fetch account due to: %w", sql.ErrNoRows),
 			expectedAcct: nil,
@@ -51,9 +68,12 @@ func TestGetAccount(t *testing.T) {
 			name:      "database error",
 			accountID: 3,
 			mockSetup: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(`SELECT id,balance,created_at,updated_at FROM accounts WHERE id=\$1`).
+				mock.ExpectBegin()
+				ledgertest.ExpectLockShared(mock)
+				mock.ExpectQuery(`SELECT id,balance,display_name,owner_reference,account_type,currency,status,metadata,created_at,updated_at,version FROM accounts WHERE id=\$1`).
 					WithArgs(3).
 					WillReturnError(errors.New("database error"))
+				mock.ExpectRollback()
 			},
 			expectedErr:  errors.New("database error"),
 			expectedAcct: nil,
@@ -78,6 +98,12 @@ func TestGetAccount(t *testing.T) {
 				assert.NoError(t, err)
 				assert.Equal(t, tt.expectedAcct.ID, account.ID)
 				assert.Equal(t, tt.expectedAcct.Balance, account.Balance)
+				assert.Equal(t, tt.expectedAcct.DisplayName, account.DisplayName)
+				assert.Equal(t, tt.expectedAcct.OwnerReference, account.OwnerReference)
+				assert.Equal(t, tt.expectedAcct.AccountType, account.AccountType)
+				assert.Equal(t, tt.expectedAcct.Currency, account.Currency)
+				assert.Equal(t, tt.expectedAcct.Status, account.Status)
+				assert.Equal(t, tt.expectedAcct.Metadata, account.Metadata)
 			}
 
 			assert.NoError(t, mock.ExpectationsWereMet())
@@ -0,0 +1,200 @@
+package account_service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+
+	"aeshanw.com/accountApi/api/logging"
+	"aeshanw.com/accountApi/api/metrics"
+	"aeshanw.com/accountApi/api/models"
+	"aeshanw.com/accountApi/api/tracing"
+)
+
+const (
+	ImportIssueInvalid   = "invalid"
+	ImportIssueDuplicate = "duplicate"
+
+	// MaxImportIssues caps how many problem rows are itemised in an import report, the counts always cover every row
+	MaxImportIssues = 1000
+	// MaxAccountBalance is the largest balance that fits the accounts.balance NUMERIC(10,2) column
+	MaxAccountBalance = 99999999.99
+)
+
+type AccountImportIssue struct {
+	LineNumber int
+	AccountID  int64
+	Kind       string
+	Error      string
+}
+
+type AccountImportModel struct {
+	TotalRows       int
+	Imported        int
+	Duplicates      int
+	Invalid         int
+	Issues          []AccountImportIssue
+	IssuesTruncated bool
+}
+
+func (aim *AccountImportModel) addIssue(issue AccountImportIssue) {
+	switch issue.Kind {
+	case ImportIssueInvalid:
+		aim.Invalid++
+	case ImportIssueDuplicate:
+		aim.Duplicates++
+	}
+	if len(aim.Issues) == MaxImportIssues {
+		aim.IssuesTruncated = true
+		return
+	}
+	aim.Issues = append(aim.Issues, issue)
+}
+
+// ImportAccounts loads accounts in bulk. Valid rows are streamed with COPY into a staging table and then inserted in a
+// single statement, so neither the per-account mutex nor a round-trip per row is paid. Invalid rows and account IDs
+// that already exist (or repeat within the input) are reported without aborting the import.
+func (as *AccountService) ImportAccounts(ctx context.Context, db *sql.DB, src models.AccountImportSource) (*AccountImportModel, error) {
+	ctx, span := tracing.Start(ctx, "AccountService.ImportAccounts")
+	defer span.End()
+
+	sqlCreateStaging := `CREATE TEMP TABLE accounts_import_staging (line_number INT NOT NULL, id BIGINT NOT NULL, balance NUMERIC(10, 2) NOT NULL, imported BOOLEAN NOT NULL DEFAULT FALSE) ON COMMIT DROP`
+	sqlInsertAccounts := `WITH inserted AS (` +
+		`INSERT INTO accounts(id,balance) SELECT DISTINCT ON (id) id,balance FROM accounts_import_staging ORDER BY id,line_number ON CONFLICT (id) DO NOTHING RETURNING id` +
+		`) UPDATE accounts_import_staging s SET imported=TRUE FROM inserted i WHERE s.id=i.id`
+	sqlListDuplicates := `SELECT line_number,id,imported FROM (` +
+		`SELECT line_number,id,imported,ROW_NUMBER() OVER (PARTITION BY id ORDER BY line_number) AS rn FROM accounts_import_staging` +
+		`) s WHERE rn>1 OR NOT imported ORDER BY line_number`
+
+	report := &AccountImportModel{}
+
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("txn for importAccounts fail:%w", err)
+	}
+	defer metrics.ObserveDBTransaction(metrics.OpImportAccounts, time.Now())
+
+	if _, err := txn.ExecContext(ctx, sqlCreateStaging); err != nil {
+		txn.Rollback()
+		return nil, fmt.Errorf("unable to create import staging table due to :%w", err)
+	}
+
+	stmt, err := txn.PrepareContext(ctx, pq.CopyIn("accounts_import_staging", "line_number", "id", "balance"))
+	if err != nil {
+		txn.Rollback()
+		return nil, fmt.Errorf("unable to start import copy due to :%w", err)
+	}
+
+	for {
+		row, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			stmt.Close()
+			txn.Rollback()
+			return nil, fmt.Errorf("unable to read import row due to :%w", err)
+		}
+		report.TotalRows++
+
+		account, err := as.newImportedAccount(row)
+		if err != nil {
+			report.addIssue(AccountImportIssue{LineNumber: row.LineNumber, AccountID: row.Account.AccountID, Kind: ImportIssueInvalid, Error: err.Error()})
+			continue
+		}
+
+		if _, err := stmt.ExecContext(ctx, row.LineNumber, account.ID, account.Balance); err != nil {
+			stmt.Close()
+			txn.Rollback()
+			return nil, fmt.Errorf("unable to copy import row %d due to :%w", row.LineNumber, err)
+		}
+	}
+
+	//Flush the buffered COPY data
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		txn.Rollback()
+		return nil, fmt.Errorf("unable to flush import copy due to :%w", err)
+	}
+	if err := stmt.Close(); err != nil {
+		txn.Rollback()
+		return nil, fmt.Errorf("unable to finish import copy due to :%w", err)
+	}
+
+	//Existing IDs are skipped rather than failing the insert, they are reported as duplicates below
+	if _, err := txn.ExecContext(ctx, sqlInsertAccounts); err != nil {
+		txn.Rollback()
+		return nil, fmt.Errorf("unable to insert imported accounts due to :%w", err)
+	}
+
+	duplicates, err := txn.QueryContext(ctx, sqlListDuplicates)
+	if err != nil {
+		txn.Rollback()
+		return nil, fmt.Errorf("unable to list duplicate accounts due to :%w", err)
+	}
+	for duplicates.Next() {
+		issue := AccountImportIssue{Kind: ImportIssueDuplicate}
+		var imported bool
+		if err := duplicates.Scan(&issue.LineNumber, &issue.AccountID, &imported); err != nil {
+			duplicates.Close()
+			txn.Rollback()
+			return nil, fmt.Errorf("unable to scan duplicate account due to :%w", err)
+		}
+		issue.Error = "account already exists"
+		if imported {
+			issue.Error = "account_id repeated in import"
+		}
+		report.addIssue(issue)
+	}
+	duplicates.Close()
+	if err := duplicates.Err(); err != nil {
+		txn.Rollback()
+		return nil, fmt.Errorf("unable to list duplicate accounts due to :%w", err)
+	}
+	if err := auditAccountsCreated(ctx, txn, "id IN (SELECT id FROM accounts_import_staging WHERE imported)"); err != nil {
+		txn.Rollback()
+		return nil, err
+	}
+
+	if err := txn.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to commit account-import txn due to :%w", err)
+	}
+
+	//Every staged row is either the first occurrence of a newly inserted ID or one of the duplicates
+	report.Imported = report.TotalRows - report.Invalid - report.Duplicates
+	logging.FromContext(ctx).Info("accounts imported",
+		slog.Int("total_rows", report.TotalRows),
+		slog.Int("imported", report.Imported),
+		slog.Int("invalid", report.Invalid),
+		slog.Int("duplicates", report.Duplicates),
+	)
+	return report, nil
+}
+
+// newImportedAccount applies the same rules as CreateAccount to an import row
+func (as *AccountService) newImportedAccount(row models.AccountImportRow) (*AccountModel, error) {
+	if row.Error != "" {
+		return nil, errors.New(row.Error)
+	}
+
+	account := NewAccountModel()
+	if err := account.SetFromRequest(row.Account); err != nil {
+		return nil, err
+	}
+	if account.Balance > MaxAccountBalance {
+		return nil, fmt.Errorf("initial_balance cannot exceed %.2f", MaxAccountBalance)
+	}
+	//An imported ID shaped like a generated account number must carry valid check digits, transfers refuse it otherwise
+	if as.accountNumbers != nil {
+		if err := as.accountNumbers.Check(account.ID); err != nil {
+			return nil, err
+		}
+	}
+	return account, nil
+}
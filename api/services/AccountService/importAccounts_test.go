@@ -0,0 +1,184 @@
+package account_service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+
+	"aeshanw.com/accountApi/api/models"
+)
+
+// sliceImportSource replays a fixed list of rows, then the optional err
+type sliceImportSource struct {
+	rows []models.AccountImportRow
+	err  error
+}
+
+func (s *sliceImportSource) Next() (models.AccountImportRow, error) {
+	if len(s.rows) == 0 {
+		if s.err != nil {
+			return models.AccountImportRow{}, s.err
+		}
+		return models.AccountImportRow{}, io.EOF
+	}
+	row := s.rows[0]
+	s.rows = s.rows[1:]
+	return row, nil
+}
+
+func TestImportAccounts(t *testing.T) {
+	sqlCreateStaging := regexp.QuoteMeta("CREATE TEMP TABLE accounts_import_staging")
+	sqlCopy := regexp.QuoteMeta(`COPY "accounts_import_staging" ("line_number", "id", "balance") FROM STDIN`)
+	sqlInsertAccounts := regexp.QuoteMeta("WITH inserted AS (INSERT INTO accounts(id,balance) SELECT DISTINCT ON (id) id,balance FROM accounts_import_staging")
+	sqlListDuplicates := regexp.QuoteMeta("SELECT line_number,id,imported FROM (")
+
+	rows := []models.AccountImportRow{
+		{LineNumber: 2, Account: models.CreateAccountRequest{AccountID: 1, InitialBalance: "100.50"}},
+		{LineNumber: 3, Account: models.CreateAccountRequest{AccountID: 2, InitialBalance: "-1"}},
+		{LineNumber: 4, Account: models.CreateAccountRequest{AccountID: 3, InitialBalance: "5"}},
+		{LineNumber: 5, Error: "account_id must be an integer"},
+		{LineNumber: 6, Account: models.CreateAccountRequest{AccountID: 1, InitialBalance: "7"}},
+		{LineNumber: 7, Account: models.CreateAccountRequest{AccountID: 9, InitialBalance: "7"}},
+		{LineNumber: 8, Account: models.CreateAccountRequest{AccountID: 10, InitialBalance: "100000000"}},
+	}
+
+	tests := []struct {
+		name                 string
+		src                  *sliceImportSource
+		mockSetup            func(sqlmock.Sqlmock)
+		expectError          bool
+		expectedErrorMessage string
+		expectedReport       *AccountImportModel
+	}{
+		{
+			name: "valid rows are copied, invalid and duplicate rows are reported",
+			src:  &sliceImportSource{rows: rows},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(sqlCreateStaging).WillReturnResult(sqlmock.NewResult(0, 0))
+				prep := mock.ExpectPrepare(sqlCopy)
+				prep.ExpectExec().WithArgs(2, 1, 100.50).WillReturnResult(sqlmock.NewResult(0, 0))
+				prep.ExpectExec().WithArgs(4, 3, 5.0).WillReturnResult(sqlmock.NewResult(0, 0))
+				prep.ExpectExec().WithArgs(6, 1, 7.0).WillReturnResult(sqlmock.NewResult(0, 0))
+				prep.ExpectExec().WithArgs(7, 9, 7.0).WillReturnResult(sqlmock.NewResult(0, 0))
+				prep.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(sqlInsertAccounts).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectQuery(sqlListDuplicates).WillReturnRows(sqlmock.NewRows([]string{"line_number", "id", "imported"}).
+					AddRow(6, 1, true).
+					AddRow(7, 9, false))
+				expectAccountsAudited(mock, "id IN (SELECT id FROM accounts_import_staging WHERE imported)", 1, 3)
+				mock.ExpectCommit()
+			},
+			expectedReport: &AccountImportModel{
+				TotalRows:  7,
+				Imported:   2,
+				Duplicates: 2,
+				Invalid:    3,
+				Issues: []AccountImportIssue{
+					{LineNumber: 3, AccountID: 2, Kind: ImportIssueInvalid, Error: "inital_balance cannot be less than 0, input:-1"},
+					{LineNumber: 5, Kind: ImportIssueInvalid, Error: "account_id must be an integer"},
+					{LineNumber: 8, AccountID: 10, Kind: ImportIssueInvalid, Error: "initial_balance cannot exceed 99999999.99"},
+					{LineNumber: 6, AccountID: 1, Kind: ImportIssueDuplicate, Error: "account_id repeated in import"},
+					{LineNumber: 7, AccountID: 9, Kind: ImportIssueDuplicate, Error: "account already exists"},
+				},
+			},
+		},
+		{
+			name: "read failure rolls back the import",
+			src:  &sliceImportSource{rows: rows[:1], err: errors.New("unexpected EOF")},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(sqlCreateStaging).WillReturnResult(sqlmock.NewResult(0, 0))
+				prep := mock.ExpectPrepare(sqlCopy)
+				prep.ExpectExec().WithArgs(2, 1, 100.50).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			expectError:          true,
+			expectedErrorMessage: "unable to read import row due to :unexpected EOF",
+		},
+		{
+			name: "insert failure rolls back the import",
+			src:  &sliceImportSource{rows: rows[:1]},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(sqlCreateStaging).WillReturnResult(sqlmock.NewResult(0, 0))
+				prep := mock.ExpectPrepare(sqlCopy)
+				prep.ExpectExec().WithArgs(2, 1, 100.50).WillReturnResult(sqlmock.NewResult(0, 0))
+				prep.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(sqlInsertAccounts).WillReturnError(errors.New("database error"))
+				mock.ExpectRollback()
+			},
+			expectError:          true,
+			expectedErrorMessage: "unable to insert imported accounts due to :database error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			report, err := NewAccountService().ImportAccounts(context.Background(), db, tt.src)
+
+			if tt.expectError {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErrorMessage)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedReport, report)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestImportAccounts_AccountNumbers(t *testing.T) {
+	sqlCopy := regexp.QuoteMeta(`COPY "accounts_import_staging" ("line_number", "id", "balance") FROM STDIN`)
+
+	format, err := ParseAccountNumberFormat(DefaultAccountNumberFormat)
+	assert.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	//A legacy ID of another shape is imported as is, a generated-looking one with a mistyped digit is not
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TEMP TABLE accounts_import_staging")).WillReturnResult(sqlmock.NewResult(0, 0))
+	prep := mock.ExpectPrepare(sqlCopy)
+	prep.ExpectExec().WithArgs(2, 100000000190, 10.0).WillReturnResult(sqlmock.NewResult(0, 0))
+	prep.ExpectExec().WithArgs(4, 123, 10.0).WillReturnResult(sqlmock.NewResult(0, 0))
+	prep.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("WITH inserted AS (INSERT INTO accounts(id,balance)")).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT line_number,id,imported FROM (")).WillReturnRows(sqlmock.NewRows([]string{"line_number", "id", "imported"}))
+	expectAccountsAudited(mock, "id IN (SELECT id FROM accounts_import_staging WHERE imported)", 100000000190, 123)
+	mock.ExpectCommit()
+
+	src := &sliceImportSource{rows: []models.AccountImportRow{
+		{LineNumber: 2, Account: models.CreateAccountRequest{AccountID: 100000000190, InitialBalance: "10"}},
+		{LineNumber: 3, Account: models.CreateAccountRequest{AccountID: 100000000191, InitialBalance: "10"}},
+		{LineNumber: 4, Account: models.CreateAccountRequest{AccountID: 123, InitialBalance: "10"}},
+	}}
+	report, err := NewAccountServiceWithAccountNumbers(format).ImportAccounts(context.Background(), db, src)
+	assert.NoError(t, err)
+	assert.Equal(t, &AccountImportModel{
+		TotalRows: 3,
+		Imported:  2,
+		Invalid:   1,
+		Issues: []AccountImportIssue{
+			{LineNumber: 3, AccountID: 100000000191, Kind: ImportIssueInvalid, Error: "invalid account number check digits: 100000000191"},
+		},
+	}, report)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,207 @@
+package account_service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"aeshanw.com/accountApi/api/models"
+	"aeshanw.com/accountApi/api/tracing"
+)
+
+// MaxAccountCountTotal bounds the count run for the first page, larger result sets are reported without a total
+const MaxAccountCountTotal = 10000
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// accountSortColumns maps the sort options to their column, every column has a (column, id) index for keyset pagination
+var accountSortColumns = map[string]string{
+	models.AccountSortID:        "id",
+	models.AccountSortBalance:   "balance",
+	models.AccountSortCreatedAt: "created_at",
+}
+
+type AccountListModel struct {
+	// NextCursor is empty on the last page
+	NextCursor string
+	// Total is nil when counting the matching accounts is not cheap
+	Total *int
+}
+
+// accountCursor is the position after the last account of a page, encoded as base64 JSON
+type accountCursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+func encodeAccountCursor(query models.ListAccountsQuery, account *AccountModel) string {
+	cursor := accountCursor{Sort: query.Sort, Order: query.Order, ID: account.ID}
+	switch query.Sort {
+	case models.AccountSortBalance:
+		cursor.Value = strconv.FormatFloat(account.Balance, 'f', -1, 64)
+	case models.AccountSortCreatedAt:
+		cursor.Value = account.CreatedAt.Format(time.RFC3339Nano)
+	}
+	encoded, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func decodeAccountCursor(query models.ListAccountsQuery) (*accountCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor accountCursor
+	if err := json.Unmarshal(decoded, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != query.Sort || cursor.Order != query.Order {
+		return nil, fmt.Errorf("%w: cursor was issued for sort=%s order=%s", ErrInvalidCursor, cursor.Sort, cursor.Order)
+	}
+	return &cursor, nil
+}
+
+// accountFilter builds the WHERE clause shared by the page and count queries
+type accountFilter struct {
+	conditions []string
+	args       []any
+}
+
+// add appends a condition, each $? in it is numbered in turn for the given args
+func (af *accountFilter) add(condition string, args ...any) {
+	for _, arg := range args {
+		af.args = append(af.args, arg)
+		condition = strings.Replace(condition, "$?", fmt.Sprintf("$%d", len(af.args)), 1)
+	}
+	af.conditions = append(af.conditions, condition)
+}
+
+func (af *accountFilter) where() string {
+	if len(af.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(af.conditions, " AND ")
+}
+
+func newAccountFilter(query models.ListAccountsQuery) (*accountFilter, error) {
+	filter := &accountFilter{}
+	if query.MinBalance != nil {
+		filter.add("balance>=$?", *query.MinBalance)
+	}
+	if query.MaxBalance != nil {
+		filter.add("balance<=$?", *query.MaxBalance)
+	}
+	if query.CreatedAfter != nil {
+		filter.add("created_at>=$?", *query.CreatedAfter)
+	}
+	if query.CreatedBefore != nil {
+		filter.add("created_at<$?", *query.CreatedBefore)
+	}
+	if query.Status != "" {
+		filter.add("status=$?", query.Status)
+	}
+	if len(query.Metadata) > 0 {
+		encoded, err := json.Marshal(query.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode metadata filter due to :%w", err)
+		}
+		filter.add("metadata@>$?", string(encoded))
+	}
+	return filter, nil
+}
+
+// ListAccounts streams a page of accounts to fn in sort order. Pages are fetched by keyset on (sort column, id) so
+// deep pages cost the same as the first one, and rows are never held in memory.
+func (as *AccountService) ListAccounts(ctx context.Context, db *sql.DB, query models.ListAccountsQuery, fn func(*AccountModel) error) (*AccountListModel, error) {
+	ctx, span := tracing.Start(ctx, "AccountService.ListAccounts")
+	defer span.End()
+
+	column, ok := accountSortColumns[query.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort:%s", query.Sort)
+	}
+	comparison, direction := ">", "ASC"
+	switch query.Order {
+	case models.SortOrderAsc:
+	case models.SortOrderDesc:
+		comparison, direction = "<", "DESC"
+	default:
+		return nil, fmt.Errorf("unsupported order:%s", query.Order)
+	}
+	if query.Limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	filter, err := newAccountFilter(query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AccountListModel{}
+
+	//Only the first page is counted, and only up to MaxAccountCountTotal rows so the count stays cheap
+	if query.Cursor == "" {
+		sqlCountAccounts := `SELECT COUNT(*) FROM (SELECT 1 FROM accounts` + filter.where() + fmt.Sprintf(` LIMIT %d) matching`, MaxAccountCountTotal+1)
+		var total int
+		if err := db.QueryRowContext(ctx, sqlCountAccounts, filter.args...).Scan(&total); err != nil {
+			return nil, fmt.Errorf("unable to count accounts due to :%w", err)
+		}
+		if total <= MaxAccountCountTotal {
+			result.Total = &total
+		}
+	} else {
+		cursor, err := decodeAccountCursor(query)
+		if err != nil {
+			return nil, err
+		}
+		if column == "id" {
+			filter.add("id"+comparison+"$?", cursor.ID)
+		} else {
+			//id breaks ties between accounts with the same balance or creation time
+			filter.add(fmt.Sprintf("(%s,id)%s($?,$?)", column, comparison), cursor.Value, cursor.ID)
+		}
+	}
+
+	orderBy := fmt.Sprintf(" ORDER BY %s %s", column, direction)
+	if column != "id" {
+		orderBy += ",id " + direction
+	}
+	//One extra row tells whether there is a next page
+	sqlListAccounts := `SELECT ` + sqlAccountColumns + ` FROM accounts` + filter.where() + orderBy + fmt.Sprintf(" LIMIT %d", query.Limit+1)
+
+	rows, err := db.QueryContext(ctx, sqlListAccounts, filter.args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list accounts due to :%w", err)
+	}
+	defer rows.Close()
+
+	var last *AccountModel
+	count := 0
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan account due to :%w", err)
+		}
+		count++
+		if count > query.Limit {
+			result.NextCursor = encodeAccountCursor(query, last)
+			break
+		}
+		if err := fn(account); err != nil {
+			return nil, err
+		}
+		last = account
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to list accounts due to :%w", err)
+	}
+	return result, nil
+}
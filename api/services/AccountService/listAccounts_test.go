@@ -0,0 +1,134 @@
+package account_service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+
+	"aeshanw.com/accountApi/api/models"
+)
+
+func TestListAccounts(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	minBalance := 10.0
+	balanceCursor := encodeAccountCursor(models.ListAccountsQuery{Sort: models.AccountSortBalance, Order: models.SortOrderDesc},
+		&AccountModel{ID: 7, Balance: 50.5})
+
+	tests := []struct {
+		name                 string
+		query                models.ListAccountsQuery
+		mockSetup            func(sqlmock.Sqlmock)
+		expectedErr          error
+		expectedIDs          []int64
+		expectedTotal        *int
+		expectNextCursor     bool
+		expectedNextCursorID int64
+	}{
+		{
+			name: "first page is counted and has a next cursor",
+			query: models.ListAccountsQuery{Sort: models.AccountSortID, Order: models.SortOrderAsc, Limit: 2, MinBalance: &minBalance,
+				Status: AccountStatusActive, Metadata: map[string]string{"tier": "gold"}},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM (SELECT 1 FROM accounts WHERE balance>=$1 AND status=$2 AND metadata@>$3 LIMIT 10001) matching`)).
+					WithArgs(10.0, "active", `{"tier":"gold"}`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT `+sqlAccountColumns+` FROM accounts WHERE balance>=$1 AND status=$2 AND metadata@>$3 ORDER BY id ASC LIMIT 3`)).
+					WithArgs(10.0, "active", `{"tier":"gold"}`).
+					WillReturnRows(sqlmock.NewRows(accountColumns).
+						AddRow(1, 10.0, "", "", "", "", "active", []byte(`{"tier":"gold"}`), createdAt, createdAt, 1).
+						AddRow(2, 20.0, "", "", "", "", "active", []byte(`{"tier":"gold"}`), createdAt, createdAt, 1).
+						AddRow(3, 30.0, "", "", "", "", "active", []byte(`{"tier":"gold"}`), createdAt, createdAt, 1))
+			},
+			expectedIDs:          []int64{1, 2},
+			expectedTotal:        intPtr(3),
+			expectNextCursor:     true,
+			expectedNextCursorID: 2,
+		},
+		{
+			name:  "large result sets are not counted",
+			query: models.ListAccountsQuery{Sort: models.AccountSortID, Order: models.SortOrderAsc, Limit: 2},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM (SELECT 1 FROM accounts LIMIT 10001) matching`)).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(MaxAccountCountTotal + 1))
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT ` + sqlAccountColumns + ` FROM accounts ORDER BY id ASC LIMIT 3`)).
+					WillReturnRows(sqlmock.NewRows(accountColumns).
+						AddRow(1, 10.0, "", "", "", "", "active", []byte(`{}`), createdAt, createdAt, 1))
+			},
+			expectedIDs: []int64{1},
+		},
+		{
+			name:        "later pages continue after the cursor",
+			query:       models.ListAccountsQuery{Sort: models.AccountSortBalance, Order: models.SortOrderDesc, Limit: 2, Cursor: balanceCursor},
+			expectedIDs: []int64{5},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT `+sqlAccountColumns+` FROM accounts WHERE (balance,id)<($1,$2) ORDER BY balance DESC,id DESC LIMIT 3`)).
+					WithArgs("50.5", 7).
+					WillReturnRows(sqlmock.NewRows(accountColumns).
+						AddRow(5, 40.0, "", "", "", "", "active", []byte(`{}`), createdAt, createdAt, 1))
+			},
+		},
+		{
+			name:        "cursor from a different sort",
+			query:       models.ListAccountsQuery{Sort: models.AccountSortID, Order: models.SortOrderDesc, Limit: 2, Cursor: balanceCursor},
+			mockSetup:   func(mock sqlmock.Sqlmock) {},
+			expectedErr: errors.New("invalid cursor: cursor was issued for sort=balance order=desc"),
+		},
+		{
+			name:        "malformed cursor",
+			query:       models.ListAccountsQuery{Sort: models.AccountSortID, Order: models.SortOrderAsc, Limit: 2, Cursor: "!!"},
+			mockSetup:   func(mock sqlmock.Sqlmock) {},
+			expectedErr: ErrInvalidCursor,
+		},
+		{
+			name:  "database error",
+			query: models.ListAccountsQuery{Sort: models.AccountSortID, Order: models.SortOrderAsc, Limit: 2},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*)`)).WillReturnError(errors.New("database error"))
+			},
+			expectedErr: errors.New("unable to count accounts due to :database error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			var ids []int64
+			as := NewAccountService()
+			result, err := as.ListAccounts(context.Background(), db, tt.query, func(account *AccountModel) error {
+				ids = append(ids, account.ID)
+				return nil
+			})
+
+			if tt.expectedErr != nil {
+				assert.EqualError(t, err, tt.expectedErr.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedIDs, ids)
+				assert.Equal(t, tt.expectedTotal, result.Total)
+				if tt.expectNextCursor {
+					cursor, err := decodeAccountCursor(models.ListAccountsQuery{Sort: tt.query.Sort, Order: tt.query.Order, Cursor: result.NextCursor})
+					assert.NoError(t, err)
+					assert.Equal(t, tt.expectedNextCursorID, cursor.ID)
+				} else {
+					assert.Empty(t, result.NextCursor)
+				}
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -0,0 +1,85 @@
+package account_service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+
+	"aeshanw.com/accountApi/api/audit"
+	"aeshanw.com/accountApi/api/logging"
+	"aeshanw.com/accountApi/api/models"
+	"aeshanw.com/accountApi/api/tracing"
+)
+
+// UpdateAccount applies a partial update to the account's profile. The balance is never touched so the per-account
+// mutex is not needed, the row lock taken by the UPDATE is enough. updated_at and version are maintained by triggers.
+func (as *AccountService) UpdateAccount(ctx context.Context, db *sql.DB, accountID int64, req models.UpdateAccountRequest) (*AccountModel, error) {
+	ctx, span := tracing.Start(ctx, "AccountService.UpdateAccount")
+	defer span.End()
+
+	//The row is locked up front so the audit log records the state the update was applied to
+	sqlLockAccount := `SELECT ` + sqlAccountColumns + ` FROM accounts WHERE id=$1 FOR UPDATE`
+	sqlUpdateAccount := `UPDATE accounts SET display_name=COALESCE($2,display_name),owner_reference=COALESCE($3,owner_reference),` +
+		`account_type=COALESCE($4,account_type),currency=COALESCE($5,currency),status=COALESCE($6,status),metadata=COALESCE($7,metadata) ` +
+		`WHERE id=$1 RETURNING ` + sqlAccountColumns
+
+	var metadata sql.NullString
+	if req.Metadata != nil {
+		encoded, err := json.Marshal(req.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode account metadata due to :%w", err)
+		}
+		metadata = sql.NullString{String: string(encoded), Valid: true}
+	}
+
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("txn for updateAccount fail:%w", err)
+	}
+	defer txn.Rollback()
+
+	before, err := scanAccount(txn.QueryRowContext(ctx, sqlLockAccount, accountID))
+	if err == sql.ErrNoRows {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to update account due to :%w", err)
+	}
+	//Checked under the row lock, no other writer can bump the version before the UPDATE
+	if req.IfMatch != nil && !slices.Contains(req.IfMatch, before.Version) {
+		return nil, ErrVersionMismatch
+	}
+
+	account, err := scanAccount(txn.QueryRowContext(ctx, sqlUpdateAccount, accountID, nullIfUnset(req.DisplayName), nullIfUnset(req.OwnerReference),
+		nullIfUnset(req.AccountType), nullIfUnset(req.Currency), nullIfUnset(req.Status), metadata))
+	if err != nil {
+		return nil, fmt.Errorf("unable to update account due to :%w", err)
+	}
+
+	if err := audit.Write(ctx, txn, audit.Entry{
+		Action:       audit.ActionAccountUpdate,
+		ResourceType: audit.ResourceAccount,
+		ResourceID:   strconv.FormatInt(account.ID, 10),
+		Before:       newAccountState(before),
+		After:        newAccountState(account),
+	}); err != nil {
+		return nil, err
+	}
+	if err := txn.Commit(); err != nil {
+		return nil, fmt.Errorf("unable to commit account-update txn due to :%w", err)
+	}
+	logging.FromContext(ctx).Info("account updated", slog.Int64(logging.KeyAccountID, account.ID))
+	return account, nil
+}
+
+// nullIfUnset leaves the column unchanged (via COALESCE) when the field was omitted from the request
+func nullIfUnset(s *string) sql.NullString {
+	if s == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *s, Valid: true}
+}
@@ -0,0 +1,148 @@
+package account_service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+
+	"aeshanw.com/accountApi/api/audit/audittest"
+	"aeshanw.com/accountApi/api/models"
+)
+
+func TestUpdateAccount(t *testing.T) {
+	sqlUpdateAccount := regexp.QuoteMeta(`UPDATE accounts SET display_name=COALESCE($2,display_name),owner_reference=COALESCE($3,owner_reference),` +
+		`account_type=COALESCE($4,account_type),currency=COALESCE($5,currency),status=COALESCE($6,status),metadata=COALESCE($7,metadata) WHERE id=$1 RETURNING ` + sqlAccountColumns)
+	sqlLockAccount := regexp.QuoteMeta(`SELECT ` + sqlAccountColumns + ` FROM accounts WHERE id=$1 FOR UPDATE`)
+	existingAccount := func() *sqlmock.Rows {
+		return sqlmock.NewRows(accountColumns).AddRow(1, 100.0, "", "cust-1", "personal", "", "active", []byte(`{}`), time.Now(), time.Now(), 3)
+	}
+	displayName := "Savings"
+	currency := "SGD"
+
+	tests := []struct {
+		name         string
+		req          models.UpdateAccountRequest
+		mockSetup    func(sqlmock.Sqlmock)
+		expectedErr  error
+		expectedAcct *AccountModel
+	}{
+		{
+			name: "omitted fields are left unchanged",
+			req:  models.UpdateAccountRequest{DisplayName: &displayName, Currency: &currency},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlLockAccount).WithArgs(1).WillReturnRows(existingAccount())
+				mock.ExpectQuery(sqlUpdateAccount).
+					WithArgs(1, "Savings", nil, nil, "SGD", nil, nil).
+					WillReturnRows(sqlmock.NewRows(accountColumns).
+						AddRow(1, 100.0, "Savings", "cust-1", "personal", "SGD", "active", []byte(`{}`), time.Now(), time.Now(), 4))
+				audittest.ExpectWrite(mock, 1)
+				mock.ExpectCommit()
+			},
+			expectedAcct: &AccountModel{ID: 1, Balance: 100.0, DisplayName: "Savings", OwnerReference: "cust-1", AccountType: "personal",
+				Currency: "SGD", Status: AccountStatusActive, Metadata: map[string]string{}, Version: 4},
+		},
+		{
+			name: "metadata is replaced as a whole",
+			req:  models.UpdateAccountRequest{Metadata: map[string]string{"tier": "gold"}},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlLockAccount).WithArgs(1).WillReturnRows(existingAccount())
+				mock.ExpectQuery(sqlUpdateAccount).
+					WithArgs(1, nil, nil, nil, nil, nil, `{"tier":"gold"}`).
+					WillReturnRows(sqlmock.NewRows(accountColumns).
+						AddRow(1, 100.0, "", "", "", "", "frozen", []byte(`{"tier":"gold"}`), time.Now(), time.Now(), 4))
+				audittest.ExpectWrite(mock, 1)
+				mock.ExpectCommit()
+			},
+			expectedAcct: &AccountModel{ID: 1, Balance: 100.0, Status: AccountStatusFrozen, Metadata: map[string]string{"tier": "gold"}, Version: 4},
+		},
+		{
+			name: "matching version",
+			req:  models.UpdateAccountRequest{DisplayName: &displayName, IfMatch: []int64{2, 3}},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlLockAccount).WithArgs(1).WillReturnRows(existingAccount())
+				mock.ExpectQuery(sqlUpdateAccount).
+					WithArgs(1, "Savings", nil, nil, nil, nil, nil).
+					WillReturnRows(sqlmock.NewRows(accountColumns).
+						AddRow(1, 100.0, "Savings", "cust-1", "personal", "", "active", []byte(`{}`), time.Now(), time.Now(), 4))
+				audittest.ExpectWrite(mock, 1)
+				mock.ExpectCommit()
+			},
+			expectedAcct: &AccountModel{ID: 1, Balance: 100.0, DisplayName: "Savings", OwnerReference: "cust-1", AccountType: "personal",
+				Status: AccountStatusActive, Metadata: map[string]string{}, Version: 4},
+		},
+		{
+			name: "version mismatch",
+			req:  models.UpdateAccountRequest{DisplayName: &displayName, IfMatch: []int64{2}},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlLockAccount).WithArgs(1).WillReturnRows(existingAccount())
+				mock.ExpectRollback()
+			},
+			expectedErr: ErrVersionMismatch,
+		},
+		{
+			name: "no version matches an empty If-Match",
+			req:  models.UpdateAccountRequest{DisplayName: &displayName, IfMatch: []int64{}},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlLockAccount).WithArgs(1).WillReturnRows(existingAccount())
+				mock.ExpectRollback()
+			},
+			expectedErr: ErrVersionMismatch,
+		},
+		{
+			name: "account not found",
+			req:  models.UpdateAccountRequest{DisplayName: &displayName},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlLockAccount).WithArgs(1).WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedErr: ErrAccountNotFound,
+		},
+		{
+			name: "database error",
+			req:  models.UpdateAccountRequest{DisplayName: &displayName},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlLockAccount).WithArgs(1).WillReturnRows(existingAccount())
+				mock.ExpectQuery(sqlUpdateAccount).WillReturnError(errors.New("database error"))
+				mock.ExpectRollback()
+			},
+			expectedErr: errors.New("unable to update account due to :database error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			as := NewAccountService()
+			account, err := as.UpdateAccount(context.Background(), db, 1, tt.req)
+
+			if tt.expectedErr != nil {
+				assert.EqualError(t, err, tt.expectedErr.Error())
+			} else {
+				assert.NoError(t, err)
+				tt.expectedAcct.CreatedAt = account.CreatedAt
+				tt.expectedAcct.UpdatedAt = account.UpdatedAt
+				assert.Equal(t, tt.expectedAcct, account)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
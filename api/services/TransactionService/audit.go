@@ -0,0 +1,47 @@
+package transaction_service
+
+import (
+	"aeshanw.com/accountApi/api/audit"
+)
+
+// transferBalances are the balances of a transfer's accounts, as recorded in the audit log
+type transferBalances struct {
+	before, after balancesState
+}
+
+type balancesState struct {
+	SourceBalance      float64 `json:"source_balance"`
+	DestinationBalance float64 `json:"destination_balance"`
+}
+
+// transactionState is a transaction as recorded in the audit log, along with the balances it left behind
+type transactionState struct {
+	ID                   string            `json:"id"`
+	SourceAccountID      int64             `json:"source_account_id"`
+	DestinationAccountID int64             `json:"destination_account_id"`
+	Amount               float64           `json:"amount"`
+	Reference            string            `json:"reference,omitempty"`
+	Description          string            `json:"description,omitempty"`
+	Metadata             map[string]string `json:"metadata,omitempty"`
+	balancesState
+}
+
+// auditEntry records an applied transfer, its before state is the balances it started from
+func (tm *TransactionModel) auditEntry() audit.Entry {
+	return audit.Entry{
+		Action:       audit.ActionTransactionCreate,
+		ResourceType: audit.ResourceTransaction,
+		ResourceID:   tm.ID.String(),
+		Before:       tm.balances.before,
+		After: transactionState{
+			ID:                   tm.ID.String(),
+			SourceAccountID:      tm.SourceAccountID,
+			DestinationAccountID: tm.DestinationAccountID,
+			Amount:               tm.Amount,
+			Reference:            tm.Reference,
+			Description:          tm.Description,
+			Metadata:             tm.Metadata,
+			balancesState:        tm.balances.after,
+		},
+	}
+}
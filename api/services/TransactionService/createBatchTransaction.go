@@ -0,0 +1,200 @@
+package transaction_service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"aeshanw.com/accountApi/api/metrics"
+	"aeshanw.com/accountApi/api/models"
+	"aeshanw.com/accountApi/api/tracing"
+)
+
+const (
+	BatchStatusCommitted  = "committed"
+	BatchStatusPartial    = "partial"
+	BatchStatusFailed     = "failed"
+	BatchStatusRolledBack = "rolled_back"
+
+	LegStatusSucceeded    = "succeeded"
+	LegStatusFailed       = "failed"
+	LegStatusRolledBack   = "rolled_back"
+	LegStatusNotAttempted = "not_attempted"
+)
+
+// BatchLegModel holds the outcome of a single leg within a batch
+type BatchLegModel struct {
+	Index       int
+	Request     models.CreateTransactionRequest
+	Status      string
+	Transaction *TransactionModel
+	Err         error
+}
+
+type BatchTransactionModel struct {
+	Mode   string
+	Status string
+	Legs   []*BatchLegModel
+}
+
+func NewBatchTransactionModel(req models.CreateBatchTransactionRequest) *BatchTransactionModel {
+	mode := req.Mode
+	if mode == "" {
+		mode = models.BatchModeAtomic
+	}
+
+	legs := make([]*BatchLegModel, len(req.Transactions))
+	for i, legReq := range req.Transactions {
+		legs[i] = &BatchLegModel{
+			Index:   i,
+			Request: legReq,
+			Status:  LegStatusNotAttempted,
+		}
+	}
+
+	return &BatchTransactionModel{
+		Mode: mode,
+		Legs: legs,
+	}
+}
+
+func (leg *BatchLegModel) fail(err error) {
+	leg.Status = LegStatusFailed
+	leg.Transaction = nil
+	leg.Err = err
+}
+
+// rollback marks every leg that had already been applied as rolled-back, as none of them were committed
+func (btm *BatchTransactionModel) rollback() {
+	for _, leg := range btm.Legs {
+		if leg.Status == LegStatusSucceeded {
+			leg.Status = LegStatusRolledBack
+			leg.Transaction = nil
+		}
+	}
+	btm.Status = BatchStatusRolledBack
+}
+
+func (ts *TransactionService) CreateBatchTransaction(ctx context.Context, db *sql.DB, req models.CreateBatchTransactionRequest) (*BatchTransactionModel, error) {
+	ctx, span := tracing.Start(ctx, "TransactionService.CreateBatchTransaction")
+	defer span.End()
+
+	batch := NewBatchTransactionModel(req)
+	if len(batch.Legs) == 0 {
+		return nil, fmt.Errorf("%w due to:batch has no transactions", ErrInvalidTransaction)
+	}
+
+	switch batch.Mode {
+	case models.BatchModeAtomic:
+		if err := ts.createAtomicBatch(ctx, db, batch); err != nil {
+			return nil, err
+		}
+	case models.BatchModeBestEffort:
+		ts.createBestEffortBatch(ctx, db, batch)
+	default:
+		return nil, fmt.Errorf("%w due to:unsupported batch mode:%s", ErrInvalidTransaction, batch.Mode)
+	}
+
+	return batch, nil
+}
+
+// createAtomicBatch applies all legs within a single DB txn. A failing leg is reported on the batch and rolls back
+// the whole txn; only DB-level failures to begin/commit are returned as errors.
+func (ts *TransactionService) createAtomicBatch(ctx context.Context, db *sql.DB, batch *BatchTransactionModel) error {
+	//Validate every leg before touching the DB so a bad leg never holds the mutex
+	transactions := make([]*TransactionModel, len(batch.Legs))
+	for i, leg := range batch.Legs {
+		transaction, err := ts.newTransaction(leg.Request)
+		if err != nil {
+			recordTransfer(nil, err)
+			leg.fail(err)
+		}
+		transactions[i] = transaction
+	}
+	for _, leg := range batch.Legs {
+		if leg.Status == LegStatusFailed {
+			batch.rollback()
+			return nil
+		}
+	}
+
+	//Mutex-lock to avoid race-cases, held for the whole batch so legs see each other's balance changes
+	if err := lockTransfers(ctx); err != nil {
+		batch.rollback()
+		return err
+	}
+	defer unlockTransfers()
+
+	txn, err := db.BeginTx(ctx, ts.txOptions)
+	if err != nil {
+		return fmt.Errorf("txn for createBatchTransaction fail:%w", err)
+	}
+	defer metrics.ObserveDBTransaction(metrics.OpBatchTransaction, time.Now())
+
+	for i, leg := range batch.Legs {
+		if err := executeTransaction(ctx, txn, transactions[i]); err != nil {
+			txn.Rollback()
+			recordTransfer(transactions[i], err)
+			leg.fail(err)
+			batch.rollback()
+			//The leg did not fail on its own merits, the request ran out of time and can be retried as a whole
+			if ctx.Err() != nil {
+				return fmt.Errorf("batch-transaction rolled back due to :%w", ctx.Err())
+			}
+			return nil
+		}
+		leg.Status = LegStatusSucceeded
+		leg.Transaction = transactions[i]
+	}
+
+	//Recorded once every leg holds its row locks
+	if err := recordTransactions(ctx, txn, transactions...); err != nil {
+		txn.Rollback()
+		batch.rollback()
+		for _, transaction := range transactions {
+			recordTransfer(transaction, err)
+		}
+		return err
+	}
+
+	if err = txn.Commit(); err != nil {
+		txn.Rollback()
+		batch.rollback()
+		for _, transaction := range transactions {
+			recordTransfer(transaction, err)
+		}
+		return fmt.Errorf("unable to commit batch-transaction txn due to :%w", err)
+	}
+
+	for _, transaction := range transactions {
+		recordTransfer(transaction, nil)
+		LogTransactionCreated(ctx, transaction)
+	}
+	batch.Status = BatchStatusCommitted
+	return nil
+}
+
+// createBestEffortBatch commits each leg independently so one failing leg does not affect the others
+func (ts *TransactionService) createBestEffortBatch(ctx context.Context, db *sql.DB, batch *BatchTransactionModel) {
+	succeeded := 0
+	for _, leg := range batch.Legs {
+		transaction, err := ts.CreateTransaction(ctx, db, leg.Request)
+		if err != nil {
+			leg.fail(err)
+			continue
+		}
+		leg.Status = LegStatusSucceeded
+		leg.Transaction = transaction
+		succeeded++
+	}
+
+	switch succeeded {
+	case len(batch.Legs):
+		batch.Status = BatchStatusCommitted
+	case 0:
+		batch.Status = BatchStatusFailed
+	default:
+		batch.Status = BatchStatusPartial
+	}
+}
@@ -0,0 +1,201 @@
+package transaction_service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+
+	"aeshanw.com/accountApi/api/audit/audittest"
+	"aeshanw.com/accountApi/api/ledger/ledgertest"
+	"aeshanw.com/accountApi/api/models"
+)
+
+// expectLeg sets up the statements executed for a single successful transfer leg
+func expectLeg(mock sqlmock.Sqlmock, sourceID, destinationID int64, sourceBalance, amount float64) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT (id) FROM accounts WHERE id IN ($1,$2)")).
+		WithArgs(sourceID, destinationID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlLockActiveAccounts)).
+		WithArgs(sourceID, destinationID).
+		WillReturnRows(activeAccountRows(sourceID, sourceBalance, destinationID))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlDebitSource)).
+		WithArgs(amount, sourceID).
+		WillReturnRows(balanceRows())
+	mock.ExpectQuery(regexp.QuoteMeta(sqlCreditDestination)).
+		WithArgs(amount, destinationID).
+		WillReturnRows(balanceRows())
+	mock.ExpectQuery(regexp.QuoteMeta(sqlInsertTransaction)).
+		WithArgs(sqlmock.AnyArg(), sourceID, destinationID, amount, nil, nil, "{}").
+		WillReturnRows(insertedTransactionRows())
+}
+
+func TestCreateBatchTransaction(t *testing.T) {
+	tests := []struct {
+		name                 string
+		req                  models.CreateBatchTransactionRequest
+		mockSetup            func(sqlmock.Sqlmock)
+		expectError          bool
+		expectedErrorMessage string
+		expectedStatus       string
+		expectedLegStatuses  []string
+		// expectedCommitted lists which legs must hold a transaction
+		expectedCommitted []bool
+	}{
+		{
+			name: "atomic: all legs committed",
+			req: models.CreateBatchTransactionRequest{
+				Transactions: []models.CreateTransactionRequest{
+					{SourceAccountID: 1, DestinationAccountID: 2, Amount: "10.00"},
+					{SourceAccountID: 1, DestinationAccountID: 3, Amount: "20.00"},
+				},
+			},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectLeg(mock, 1, 2, 100.0, 10.0)
+				expectLeg(mock, 1, 3, 90.0, 20.0)
+				ledgertest.ExpectAppend(mock)
+				audittest.ExpectWrite(mock, 2)
+				mock.ExpectCommit()
+			},
+			expectedStatus:      BatchStatusCommitted,
+			expectedLegStatuses: []string{LegStatusSucceeded, LegStatusSucceeded},
+			expectedCommitted:   []bool{true, true},
+		},
+		{
+			name: "atomic: failing leg rolls back the whole batch",
+			req: models.CreateBatchTransactionRequest{
+				Mode: models.BatchModeAtomic,
+				Transactions: []models.CreateTransactionRequest{
+					{SourceAccountID: 1, DestinationAccountID: 2, Amount: "10.00"},
+					{SourceAccountID: 1, DestinationAccountID: 3, Amount: "200.00"},
+					{SourceAccountID: 3, DestinationAccountID: 2, Amount: "5.00"},
+				},
+			},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectLeg(mock, 1, 2, 100.0, 10.0)
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT (id) FROM accounts WHERE id IN ($1,$2)")).
+					WithArgs(1, 3).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+				mock.ExpectQuery(regexp.QuoteMeta(sqlLockActiveAccounts)).
+					WithArgs(1, 3).
+					WillReturnRows(activeAccountRows(1, 90.0, 3))
+				mock.ExpectRollback()
+			},
+			expectedStatus:      BatchStatusRolledBack,
+			expectedLegStatuses: []string{LegStatusRolledBack, LegStatusFailed, LegStatusNotAttempted},
+			expectedCommitted:   []bool{false, false, false},
+		},
+		{
+			name: "atomic: invalid legs are rejected before the DB is touched",
+			req: models.CreateBatchTransactionRequest{
+				Mode: models.BatchModeAtomic,
+				Transactions: []models.CreateTransactionRequest{
+					{SourceAccountID: 1, DestinationAccountID: 1, Amount: "10.00"},
+					{SourceAccountID: 1, DestinationAccountID: 3, Amount: "20.00"},
+					{SourceAccountID: 1, DestinationAccountID: 3, Amount: "abc"},
+				},
+			},
+			mockSetup:           func(mock sqlmock.Sqlmock) {},
+			expectedStatus:      BatchStatusRolledBack,
+			expectedLegStatuses: []string{LegStatusFailed, LegStatusNotAttempted, LegStatusFailed},
+			expectedCommitted:   []bool{false, false, false},
+		},
+		{
+			name: "atomic: begin failure",
+			req: models.CreateBatchTransactionRequest{
+				Transactions: []models.CreateTransactionRequest{
+					{SourceAccountID: 1, DestinationAccountID: 2, Amount: "10.00"},
+				},
+			},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(errors.New("database error"))
+			},
+			expectError:          true,
+			expectedErrorMessage: "txn for createBatchTransaction fail:database error",
+		},
+		{
+			name: "best effort: legs commit independently",
+			req: models.CreateBatchTransactionRequest{
+				Mode: models.BatchModeBestEffort,
+				Transactions: []models.CreateTransactionRequest{
+					{SourceAccountID: 1, DestinationAccountID: 2, Amount: "10.00"},
+					{SourceAccountID: 1, DestinationAccountID: 4, Amount: "20.00"},
+				},
+			},
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectLeg(mock, 1, 2, 100.0, 10.0)
+				ledgertest.ExpectAppend(mock)
+				audittest.ExpectWrite(mock, 1)
+				mock.ExpectCommit()
+
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT (id) FROM accounts WHERE id IN ($1,$2)")).
+					WithArgs(1, 4).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mock.ExpectRollback()
+			},
+			expectedStatus:      BatchStatusPartial,
+			expectedLegStatuses: []string{LegStatusSucceeded, LegStatusFailed},
+			expectedCommitted:   []bool{true, false},
+		},
+		{
+			name: "unsupported mode",
+			req: models.CreateBatchTransactionRequest{
+				Mode: "eventually",
+				Transactions: []models.CreateTransactionRequest{
+					{SourceAccountID: 1, DestinationAccountID: 2, Amount: "10.00"},
+				},
+			},
+			mockSetup:            func(mock sqlmock.Sqlmock) {},
+			expectError:          true,
+			expectedErrorMessage: "invalid create-transaction-request due to:unsupported batch mode:eventually",
+		},
+		{
+			name:                 "empty batch",
+			req:                  models.CreateBatchTransactionRequest{},
+			mockSetup:            func(mock sqlmock.Sqlmock) {},
+			expectError:          true,
+			expectedErrorMessage: "invalid create-transaction-request due to:batch has no transactions",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			transactionService := NewTransactionService()
+			batch, err := transactionService.CreateBatchTransaction(context.Background(), db, tt.req)
+
+			if tt.expectError {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErrorMessage)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedStatus, batch.Status)
+				for i, leg := range batch.Legs {
+					assert.Equal(t, tt.expectedLegStatuses[i], leg.Status, "leg %d", i)
+					if tt.expectedCommitted[i] {
+						assert.NotEqual(t, uuid.Nil, leg.Transaction.ID, "leg %d", i)
+					} else {
+						assert.Nil(t, leg.Transaction, "leg %d", i)
+					}
+				}
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
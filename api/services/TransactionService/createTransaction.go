@@ -3,29 +3,75 @@ package transaction_service
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"sync"
+
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"aeshanw.com/accountApi/api/logging"
+	"aeshanw.com/accountApi/api/metrics"
 	"aeshanw.com/accountApi/api/models"
+	accountservice "aeshanw.com/accountApi/api/services/AccountService"
+	"aeshanw.com/accountApi/api/tracing"
 )
 
 // TransactionServiceInt defines the methods for interacting with the account service.
 type TransactionServiceInt interface {
 	// Define methods for interacting with the database
 	CreateTransaction(ctx context.Context, db *sql.DB, req models.CreateTransactionRequest) (*TransactionModel, error)
+	CreateBatchTransaction(ctx context.Context, db *sql.DB, req models.CreateBatchTransactionRequest) (*BatchTransactionModel, error)
+	// GetTransaction accepts a transaction UUID, or the integer ID of a transaction created before UUIDs were introduced
+	GetTransaction(ctx context.Context, db *sql.DB, transactionID string) (*TransactionModel, error)
+	FindTransactionsByReference(ctx context.Context, db *sql.DB, reference string) ([]*TransactionModel, error)
+}
+
+var (
+	// ErrDuplicateReference is returned when the source account already has a transaction with the same reference
+	ErrDuplicateReference = errors.New("reference already used for this source account")
+	ErrInvalidTransaction = errors.New("invalid create-transaction-request")
+	ErrInsufficientFunds  = errors.New("source account has insufficent funds")
+	// ErrAccountsNotFound is returned when the source or destination account does not exist
+	ErrAccountsNotFound = errors.New("account-count != 2")
+	// ErrAccountNotActive is returned when the source or destination account is frozen or closed
+	ErrAccountNotActive = errors.New("account is not active")
+)
+
+// IsRejected reports whether err refused the transfer on its own merits, e.g. insufficient funds. Sending the same
+// request again cannot succeed, any other error is a failure of the service and may.
+func IsRejected(err error) bool {
+	return errors.Is(err, ErrInsufficientFunds) ||
+		errors.Is(err, ErrInvalidTransaction) ||
+		errors.Is(err, ErrAccountsNotFound) ||
+		errors.Is(err, ErrAccountNotActive) ||
+		errors.Is(err, ErrDuplicateReference)
 }
 
 type TransactionModel struct {
-	ID                   int64
+	// ID is a time-ordered UUIDv7 allocated by the service, so it is known before the transfer is committed
+	ID uuid.UUID
+	// LegacyID is the SERIAL ID of transactions created before the switch to UUIDs, 0 for newer ones
+	LegacyID             int64
 	SourceAccountID      int64
 	DestinationAccountID int64
 	Amount               float64
+	Reference            string
+	Description          string
+	Metadata             map[string]string
 	CreatedAt            time.Time
 	UpdatedAt            time.Time
+	// balances are the accounts' balances around the transfer, known once it has been applied
+	balances *transferBalances
+	// storedAmount is the database's text of the amount, known once inserted
+	storedAmount string
 }
 
 func NewTransactionModel() *TransactionModel {
@@ -55,97 +101,304 @@ func (tm *TransactionModel) SetFromRequest(req models.CreateTransactionRequest)
 	}
 
 	tm.Amount = floatAmount
+	tm.Reference = req.Reference
+	tm.Description = req.Description
+	tm.Metadata = req.Metadata
 
 	return nil
 }
 
-type TransactionService struct{}
+type TransactionService struct {
+	// accountNumbers, when set, rejects account IDs shaped like a generated account number with wrong check digits
+	accountNumbers *accountservice.AccountNumberFormat
+	// txOptions is passed to BeginTx for transfers, nil uses the database's default isolation level
+	txOptions *sql.TxOptions
+}
 
 func NewTransactionService() *TransactionService {
 	return &TransactionService{}
 }
 
-// Mutex is required to handle race-conditions where 2 threads compete to UPDATE a account row in the DB
-var mutex sync.Mutex
+// NewTransactionServiceWithAccountNumbers validates the check digits of generated account numbers before any transfer,
+// catching mistyped destination accounts that would otherwise credit someone else's account
+func NewTransactionServiceWithAccountNumbers(format *accountservice.AccountNumberFormat) *TransactionService {
+	return &TransactionService{accountNumbers: format}
+}
 
-func (ts *TransactionService) CreateTransaction(ctx context.Context, db *sql.DB, req models.CreateTransactionRequest) (*TransactionModel, error) {
-	//Mutex-lock to avoid race-cases
-	mutex.Lock()
-	defer mutex.Unlock()
+// SetIsolationLevel runs transfers at level instead of the database's default
+func (ts *TransactionService) SetIsolationLevel(level sql.IsolationLevel) {
+	ts.txOptions = &sql.TxOptions{Isolation: level}
+}
 
+// newTransaction builds and validates the transaction for a request
+func (ts *TransactionService) newTransaction(req models.CreateTransactionRequest) (*TransactionModel, error) {
 	transaction := NewTransactionModel()
 	if err := transaction.SetFromRequest(req); err != nil {
-		return nil, fmt.Errorf("invalid create-transaction-request due to:%w", err)
+		return nil, fmt.Errorf("%w due to:%w", ErrInvalidTransaction, err)
+	}
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, fmt.Errorf("unable to allocate transaction id due to:%w", err)
+	}
+	transaction.ID = id
+	if ts.accountNumbers != nil {
+		if err := ts.accountNumbers.Check(transaction.SourceAccountID); err != nil {
+			return nil, fmt.Errorf("%w due to:source %w", ErrInvalidTransaction, err)
+		}
+		if err := ts.accountNumbers.Check(transaction.DestinationAccountID); err != nil {
+			return nil, fmt.Errorf("%w due to:destination %w", ErrInvalidTransaction, err)
+		}
+	}
+	return transaction, nil
+}
+
+// transferLock serializes transfers. It is a channel rather than a sync.Mutex so a request whose deadline passes
+// while queued gives up instead of waiting for its turn.
+var transferLock = make(chan struct{}, 1)
+
+// lockTransfers takes the transfer lock, recording how long it had to wait. It fails with ctx's error if ctx is done
+// first, otherwise the caller must call unlockTransfers.
+func lockTransfers(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "transfer lock wait")
+	defer span.End()
+
+	waitStart := time.Now()
+	select {
+	case transferLock <- struct{}{}:
+		metrics.ObserveTransferLockWait(waitStart)
+		return nil
+	case <-ctx.Done():
+		span.SetStatus(codes.Error, ctx.Err().Error())
+		return fmt.Errorf("unable to acquire transfer lock due to :%w", ctx.Err())
 	}
+}
+
+func unlockTransfers() {
+	<-transferLock
+}
 
-	fmt.Printf("transaction-model: %v\n", transaction)
+// transactionAttributes identify a transfer on its spans
+func transactionAttributes(transaction *TransactionModel) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("transaction.id", transaction.ID.String()),
+		attribute.Int64("transaction.source_account_id", transaction.SourceAccountID),
+		attribute.Int64("transaction.destination_account_id", transaction.DestinationAccountID),
+	}
+}
+
+// transferOutcome classifies the result of a transfer for the transfers_total metric
+func transferOutcome(err error) string {
+	switch {
+	case err == nil:
+		return metrics.OutcomeSuccess
+	case errors.Is(err, ErrInsufficientFunds):
+		return metrics.OutcomeInsufficientFunds
+	case IsRejected(err):
+		return metrics.OutcomeValidation
+	default:
+		return metrics.OutcomeDBError
+	}
+}
+
+// recordTransfer counts a transfer attempt, transaction is nil when the request could not be parsed
+func recordTransfer(transaction *TransactionModel, err error) {
+	var amount float64
+	if transaction != nil {
+		amount = transaction.Amount
+	}
+	metrics.RecordTransfer(transferOutcome(err), amount)
+}
+
+func (ts *TransactionService) CreateTransaction(ctx context.Context, db *sql.DB, req models.CreateTransactionRequest) (*TransactionModel, error) {
+	ctx, span := tracing.Start(ctx, "TransactionService.CreateTransaction")
+	defer span.End()
+
+	transaction, err := ts.newTransaction(req)
+	if err != nil {
+		recordTransfer(nil, err)
+		return nil, err
+	}
+	span.SetAttributes(transactionAttributes(transaction)...)
+
+	//Mutex-lock to avoid race-cases
+	if err := lockTransfers(ctx); err != nil {
+		recordTransfer(transaction, err)
+		return nil, err
+	}
+	defer unlockTransfers()
 
 	// Begin a transaction with the specified options
-	txn, err := db.BeginTx(ctx, nil)
+	txn, err := db.BeginTx(ctx, ts.txOptions)
 	if err != nil {
+		recordTransfer(transaction, err)
 		return nil, fmt.Errorf("txn for createTransaction fail:%w", err)
 	}
+	defer metrics.ObserveDBTransaction(metrics.OpCreateTransaction, time.Now())
+
+	if err := executeTransaction(ctx, txn, transaction); err != nil {
+		txn.Rollback()
+		recordTransfer(transaction, err)
+		return nil, err
+	}
+	if err := recordTransactions(ctx, txn, transaction); err != nil {
+		txn.Rollback()
+		recordTransfer(transaction, err)
+		return nil, err
+	}
+
+	if err = txn.Commit(); err != nil {
+		txn.Rollback()
+		recordTransfer(transaction, err)
+		return nil, fmt.Errorf("unable to commit account-creation txn due to :%w", err)
+	}
 
+	recordTransfer(transaction, nil)
+	LogTransactionCreated(ctx, transaction)
+	return transaction, nil
+}
+
+// CreateTransactionInTxn applies a transfer within a txn owned by the caller, who must commit or roll it back.
+// The source balance row stays locked until then so concurrent transfers cannot overdraw it.
+// The transfer is counted in the metrics once applied, a later failure to commit is the caller's to report.
+func (ts *TransactionService) CreateTransactionInTxn(ctx context.Context, txn *sql.Tx, req models.CreateTransactionRequest) (*TransactionModel, error) {
+	ctx, span := tracing.Start(ctx, "TransactionService.CreateTransactionInTxn")
+	defer span.End()
+
+	transaction, err := ts.newTransaction(req)
+	if err != nil {
+		recordTransfer(nil, err)
+		return nil, err
+	}
+	span.SetAttributes(transactionAttributes(transaction)...)
+
+	//Mutex-lock to avoid race-cases
+	if err := lockTransfers(ctx); err != nil {
+		recordTransfer(transaction, err)
+		return nil, err
+	}
+	defer unlockTransfers()
+
+	if err := executeTransaction(ctx, txn, transaction); err != nil {
+		recordTransfer(transaction, err)
+		return nil, err
+	}
+	if err := recordTransactions(ctx, txn, transaction); err != nil {
+		recordTransfer(transaction, err)
+		return nil, err
+	}
+
+	recordTransfer(transaction, nil)
+	return transaction, nil
+}
+
+// executeTransaction moves the funds for a single transfer within an already open txn.
+// The caller owns the txn and is responsible for rolling it back when an error is returned.
+func executeTransaction(ctx context.Context, txn *sql.Tx, transaction *TransactionModel) error {
 	//Confirm the account exists
 	sqlCheckForAccounts := `SELECT COUNT (id) FROM accounts WHERE id IN ($1,$2)`
-	sqlCheckSourceBalance := `SELECT balance FROM accounts WHERE id=$1`
-	sqlDebitSourceAccountBalance := `UPDATE accounts SET balance = balance - $1 WHERE id=$2`
-	sqlCreditDestinationAccountBalance := `UPDATE accounts SET balance = balance + $1 WHERE id=$2`
-	sqlInsertNewTransaction := `INSERT INTO transactions(source_account_id,destination_account_id,amount) VALUES ($1,$2,$3) RETURNING id`
+	//Both accounts are locked in ID order, so transfers in opposite directions cannot deadlock
+	sqlLockActiveAccounts := `SELECT id,balance FROM accounts WHERE id IN ($1,$2) AND status='active' ORDER BY id FOR UPDATE`
+	//The amount is rounded the way the transactions row stores it, a balance always equals its history's sum
+	sqlDebitSourceAccountBalance := `UPDATE accounts SET balance = balance - $1::NUMERIC(15,2) WHERE id=$2 RETURNING balance + $1::NUMERIC(15,2),balance`
+	sqlCreditDestinationAccountBalance := `UPDATE accounts SET balance = balance + $1::NUMERIC(15,2) WHERE id=$2 RETURNING balance - $1::NUMERIC(15,2),balance`
+	sqlInsertNewTransaction := `INSERT INTO transactions(id,source_account_id,destination_account_id,amount,reference,description,metadata) VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING created_at,updated_at,amount::TEXT`
 
 	var count int
-	if err := txn.QueryRow(sqlCheckForAccounts, req.SourceAccountID, req.DestinationAccountID).Scan(&count); err != nil {
-		txn.Rollback()
-		return nil, fmt.Errorf("check for existing account:%w", err)
+	if err := txn.QueryRowContext(ctx, sqlCheckForAccounts, transaction.SourceAccountID, transaction.DestinationAccountID).Scan(&count); err != nil {
+		return fmt.Errorf("check for existing account:%w", err)
 	}
 
-	log.Println("count done!")
-
 	if count != 2 {
 		//Both accounts must exist
-		txn.Rollback()
-		return nil, fmt.Errorf("account-count != 2 count:%d", count)
+		return fmt.Errorf("%w count:%d", ErrAccountsNotFound, count)
 	}
 
-	log.Println("count check ok!")
-
-	//Check SourceBalance
+	//Only active accounts are locked, a frozen or closed one is missing from the rows
+	rows, err := txn.QueryContext(ctx, sqlLockActiveAccounts, transaction.SourceAccountID, transaction.DestinationAccountID)
+	if err != nil {
+		return fmt.Errorf("check for source account balance:%w", err)
+	}
+	defer rows.Close()
 	var sourceAccountBalance float64
-	if err := txn.QueryRow(sqlCheckSourceBalance, req.SourceAccountID).Scan(&sourceAccountBalance); err != nil {
-		txn.Rollback()
-		return nil, fmt.Errorf("check for source account balance:%w", err)
+	active := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		var balance float64
+		if err := rows.Scan(&id, &balance); err != nil {
+			return fmt.Errorf("check for source account balance:%w", err)
+		}
+		active[id] = true
+		if id == transaction.SourceAccountID {
+			sourceAccountBalance = balance
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("check for source account balance:%w", err)
+	}
+	rows.Close()
+	if !active[transaction.SourceAccountID] {
+		return fmt.Errorf("%w: source account %d", ErrAccountNotActive, transaction.SourceAccountID)
 	}
+	if !active[transaction.DestinationAccountID] {
+		return fmt.Errorf("%w: destination account %d", ErrAccountNotActive, transaction.DestinationAccountID)
+	}
+
+	//Check SourceBalance
 
 	finalSourceAccountBalance := sourceAccountBalance - transaction.Amount
 
 	if finalSourceAccountBalance < 0 {
 		//balance cannot fall below 0
-		txn.Rollback()
-		return nil, fmt.Errorf("source account has insufficent funds: finalSourceAccountBalance:%v", finalSourceAccountBalance)
+		return fmt.Errorf("%w: finalSourceAccountBalance:%v", ErrInsufficientFunds, finalSourceAccountBalance)
 	}
 
-	//Debit Source
-	if _, err = txn.Exec(sqlDebitSourceAccountBalance, transaction.Amount, transaction.SourceAccountID); err != nil {
-		txn.Rollback()
-		return nil, fmt.Errorf("unable to debit source account due to :%w", err)
+	//Debit Source, the balances before and after are computed by the database so the audit log records them exactly
+	var balances transferBalances
+	if err := txn.QueryRowContext(ctx, sqlDebitSourceAccountBalance, transaction.Amount, transaction.SourceAccountID).
+		Scan(&balances.before.SourceBalance, &balances.after.SourceBalance); err != nil {
+		return fmt.Errorf("unable to debit source account due to :%w", err)
 	}
 
 	//Credit Destination
-	if _, err = txn.Exec(sqlCreditDestinationAccountBalance, transaction.Amount, transaction.DestinationAccountID); err != nil {
-		txn.Rollback()
-		return nil, fmt.Errorf("unable to credit destination account due to :%w", err)
+	if err := txn.QueryRowContext(ctx, sqlCreditDestinationAccountBalance, transaction.Amount, transaction.DestinationAccountID).
+		Scan(&balances.before.DestinationBalance, &balances.after.DestinationBalance); err != nil {
+		return fmt.Errorf("unable to credit destination account due to :%w", err)
 	}
 
-	//No other issues can proceed to lock-in the transaction
-	if err = txn.QueryRow(sqlInsertNewTransaction, transaction.SourceAccountID, transaction.DestinationAccountID, transaction.Amount).Scan(&transaction.ID); err != nil {
-		txn.Rollback()
-		return nil, fmt.Errorf("unable to insert new account due to :%w", err)
+	metadata, err := json.Marshal(transaction.Metadata)
+	if err != nil {
+		return fmt.Errorf("unable to encode metadata due to :%w", err)
+	}
+	if transaction.Metadata == nil {
+		metadata = []byte("{}")
 	}
 
-	if err = txn.Commit(); err != nil {
-		txn.Rollback()
-		return nil, fmt.Errorf("unable to commit account-creation txn due to :%w", err)
+	//No other issues can proceed to lock-in the transaction
+	if err := txn.QueryRowContext(ctx, sqlInsertNewTransaction, transaction.ID, transaction.SourceAccountID, transaction.DestinationAccountID, transaction.Amount,
+		nullIfEmpty(transaction.Reference), nullIfEmpty(transaction.Description), string(metadata)).Scan(&transaction.CreatedAt, &transaction.UpdatedAt, &transaction.storedAmount); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "idx_transactions_source_reference" {
+			return fmt.Errorf("unable to insert new transaction due to :%w", ErrDuplicateReference)
+		}
+		return fmt.Errorf("unable to insert new account due to :%w", err)
 	}
 
-	return transaction, nil
+	transaction.balances = &balances
+	return nil
+}
+
+// LogTransactionCreated records a committed transfer. Callers of CreateTransactionInTxn log it once they commit.
+func LogTransactionCreated(ctx context.Context, transaction *TransactionModel, attrs ...slog.Attr) {
+	attrs = append(attrs,
+		slog.String(logging.KeyTransactionID, transaction.ID.String()),
+		slog.Int64(logging.KeySourceAccountID, transaction.SourceAccountID),
+		slog.Int64(logging.KeyDestinationAccountID, transaction.DestinationAccountID),
+		slog.Float64(logging.KeyAmount, transaction.Amount),
+	)
+	logging.FromContext(ctx).LogAttrs(ctx, slog.LevelInfo, "transaction created", attrs...)
+}
+
+// nullIfEmpty stores optional text columns as NULL rather than an empty string
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
 }
@@ -3,24 +3,60 @@ package transaction_service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"regexp"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
+
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
 
+	"aeshanw.com/accountApi/api/audit/audittest"
+	"aeshanw.com/accountApi/api/ledger/ledgertest"
+	"aeshanw.com/accountApi/api/metrics"
 	"aeshanw.com/accountApi/api/models"
+	accountservice "aeshanw.com/accountApi/api/services/AccountService"
+)
+
+const sqlInsertTransaction = "INSERT INTO transactions(id,source_account_id,destination_account_id,amount,reference,description,metadata) VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING created_at,updated_at,amount::TEXT"
+
+const (
+	sqlDebitSource       = "UPDATE accounts SET balance = balance - $1::NUMERIC(15,2) WHERE id=$2 RETURNING balance + $1::NUMERIC(15,2),balance"
+	sqlCreditDestination = "UPDATE accounts SET balance = balance + $1::NUMERIC(15,2) WHERE id=$2 RETURNING balance - $1::NUMERIC(15,2),balance"
 )
 
+const sqlLockActiveAccounts = "SELECT id,balance FROM accounts WHERE id IN ($1,$2) AND status='active' ORDER BY id FOR UPDATE"
+
+// activeAccountRows is what the DB returns when locking the accounts of a transfer that are both active
+func activeAccountRows(sourceID int64, sourceBalance float64, destinationID int64) *sqlmock.Rows {
+	if destinationID < sourceID {
+		return sqlmock.NewRows([]string{"id", "balance"}).AddRow(destinationID, 0.0).AddRow(sourceID, sourceBalance)
+	}
+	return sqlmock.NewRows([]string{"id", "balance"}).AddRow(sourceID, sourceBalance).AddRow(destinationID, 0.0)
+}
+
+// balanceRows is what the DB returns for a debit or credit, the account's balance before and after it
+func balanceRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"before", "after"}).AddRow(200.0, 99.5)
+}
+
+// insertedTransactionRows is what the DB returns for a successful transaction insert
+func insertedTransactionRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"created_at", "updated_at", "amount"}).AddRow(time.Now(), time.Now(), "100.50")
+}
+
 func TestCreateTransaction(t *testing.T) {
 	tests := []struct {
 		name                 string
 		req                  models.CreateTransactionRequest
-		expectedID           int64
-		mockSetup            func(sqlmock.Sqlmock, models.CreateTransactionRequest, float64, int64)
+		mockSetup            func(sqlmock.Sqlmock, models.CreateTransactionRequest, float64)
 		expectError          bool
 		expectedErrorMessage string
+		accountNumbers       string
 	}{
 		{
 			name: "successful transaction",
@@ -29,8 +65,7 @@ func TestCreateTransaction(t *testing.T) {
 				DestinationAccountID: 2,
 				Amount:               "100.50",
 			},
-			expectedID: 1,
-			mockSetup: func(mock sqlmock.Sqlmock, req models.CreateTransactionRequest, amountFloat float64, expectedID int64) {
+			mockSetup: func(mock sqlmock.Sqlmock, req models.CreateTransactionRequest, amountFloat float64) {
 				// Expect BeginTx method to be called and return a transaction
 				mock.ExpectBegin()
 
@@ -40,27 +75,27 @@ func TestCreateTransaction(t *testing.T) {
 					WithArgs(req.SourceAccountID, req.DestinationAccountID).
 					WillReturnRows(rows)
 
-				// Expect QueryRowContext method to be called for checking source account balance
-				rows = sqlmock.NewRows([]string{"balance"}).AddRow(200.0)
-				mock.ExpectQuery(regexp.QuoteMeta("SELECT balance FROM accounts WHERE id=$1")).
-					WithArgs(req.SourceAccountID).
-					WillReturnRows(rows)
+				// Expect QueryRowContext method to be called for locking both accounts and checking source account balance
+				mock.ExpectQuery(regexp.QuoteMeta(sqlLockActiveAccounts)).
+					WithArgs(req.SourceAccountID, req.DestinationAccountID).
+					WillReturnRows(activeAccountRows(req.SourceAccountID, 200.0, req.DestinationAccountID))
 
 				// Expect ExecContext method to be called for debiting source account balance
-				mock.ExpectExec(regexp.QuoteMeta("UPDATE accounts SET balance = balance - $1 WHERE id=$2")).
+				mock.ExpectQuery(regexp.QuoteMeta(sqlDebitSource)).
 					WithArgs(amountFloat, req.SourceAccountID).
-					WillReturnResult(sqlmock.NewResult(0, 1))
+					WillReturnRows(balanceRows())
 
 				// Expect ExecContext method to be called for crediting destination account balance
-				mock.ExpectExec(regexp.QuoteMeta("UPDATE accounts SET balance = balance + $1 WHERE id=$2")).
+				mock.ExpectQuery(regexp.QuoteMeta(sqlCreditDestination)).
 					WithArgs(amountFloat, req.DestinationAccountID).
-					WillReturnResult(sqlmock.NewResult(0, 1))
+					WillReturnRows(balanceRows())
 
 				// Expect QueryRowContext method to be called for inserting new transaction
-				rows = sqlmock.NewRows([]string{"id"}).AddRow(expectedID)
-				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO transactions(source_account_id,destination_account_id,amount) VALUES ($1,$2,$3) RETURNING id")).
-					WithArgs(req.SourceAccountID, req.DestinationAccountID, amountFloat).
-					WillReturnRows(rows)
+				mock.ExpectQuery(regexp.QuoteMeta(sqlInsertTransaction)).
+					WithArgs(sqlmock.AnyArg(), req.SourceAccountID, req.DestinationAccountID, amountFloat, nil, nil, "{}").
+					WillReturnRows(insertedTransactionRows())
+				ledgertest.ExpectAppend(mock)
+				audittest.ExpectWrite(mock, 1)
 
 				// Expect Commit method to be called
 				mock.ExpectCommit()
@@ -75,8 +110,7 @@ func TestCreateTransaction(t *testing.T) {
 				DestinationAccountID: 2,
 				Amount:               "100.50",
 			},
-			expectedID: 1,
-			mockSetup: func(mock sqlmock.Sqlmock, req models.CreateTransactionRequest, amountFloat float64, expectedID int64) {
+			mockSetup: func(mock sqlmock.Sqlmock, req models.CreateTransactionRequest, amountFloat float64) {
 				// Expect BeginTx method to be called and return a transaction
 				mock.ExpectBegin()
 
@@ -86,11 +120,10 @@ func TestCreateTransaction(t *testing.T) {
 					WithArgs(req.SourceAccountID, req.DestinationAccountID).
 					WillReturnRows(rows)
 
-				// Expect QueryRowContext method to be called for checking source account balance
-				rows = sqlmock.NewRows([]string{"balance"}).AddRow(20.0)
-				mock.ExpectQuery(regexp.QuoteMeta("SELECT balance FROM accounts WHERE id=$1")).
-					WithArgs(req.SourceAccountID).
-					WillReturnRows(rows)
+				// Expect QueryRowContext method to be called for locking both accounts and checking source account balance
+				mock.ExpectQuery(regexp.QuoteMeta(sqlLockActiveAccounts)).
+					WithArgs(req.SourceAccountID, req.DestinationAccountID).
+					WillReturnRows(activeAccountRows(req.SourceAccountID, 20.0, req.DestinationAccountID))
 
 				// Expect Commit method to be called
 				mock.ExpectRollback()
@@ -98,6 +131,48 @@ func TestCreateTransaction(t *testing.T) {
 			expectError:          true,
 			expectedErrorMessage: "source account has insufficent funds: finalSourceAccountBalance:",
 		},
+		{
+			name: "failed transaction: frozen source account",
+			req: models.CreateTransactionRequest{
+				SourceAccountID:      1,
+				DestinationAccountID: 2,
+				Amount:               "100.50",
+			},
+			mockSetup: func(mock sqlmock.Sqlmock, req models.CreateTransactionRequest, amountFloat float64) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT (id) FROM accounts WHERE id IN ($1,$2)")).
+					WithArgs(req.SourceAccountID, req.DestinationAccountID).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+				//The frozen account 1 is not locked, only the destination is returned
+				mock.ExpectQuery(regexp.QuoteMeta(sqlLockActiveAccounts)).
+					WithArgs(req.SourceAccountID, req.DestinationAccountID).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "balance"}).AddRow(2, 50.0))
+				mock.ExpectRollback()
+			},
+			expectError:          true,
+			expectedErrorMessage: "account is not active: source account 1",
+		},
+		{
+			name: "failed transaction: closed destination account",
+			req: models.CreateTransactionRequest{
+				SourceAccountID:      1,
+				DestinationAccountID: 2,
+				Amount:               "100.50",
+			},
+			mockSetup: func(mock sqlmock.Sqlmock, req models.CreateTransactionRequest, amountFloat float64) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT (id) FROM accounts WHERE id IN ($1,$2)")).
+					WithArgs(req.SourceAccountID, req.DestinationAccountID).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+				//The closed account 2 is not locked, only the source is returned
+				mock.ExpectQuery(regexp.QuoteMeta(sqlLockActiveAccounts)).
+					WithArgs(req.SourceAccountID, req.DestinationAccountID).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "balance"}).AddRow(1, 200.0))
+				mock.ExpectRollback()
+			},
+			expectError:          true,
+			expectedErrorMessage: "account is not active: destination account 2",
+		},
 		{
 			name: "failed transaction: missing accounts",
 			req: models.CreateTransactionRequest{
@@ -105,8 +180,7 @@ func TestCreateTransaction(t *testing.T) {
 				DestinationAccountID: 2,
 				Amount:               "100.50",
 			},
-			expectedID: 1,
-			mockSetup: func(mock sqlmock.Sqlmock, req models.CreateTransactionRequest, amountFloat float64, expectedID int64) {
+			mockSetup: func(mock sqlmock.Sqlmock, req models.CreateTransactionRequest, amountFloat float64) {
 				// Expect BeginTx method to be called and return a transaction
 				mock.ExpectBegin()
 
@@ -129,8 +203,7 @@ func TestCreateTransaction(t *testing.T) {
 				DestinationAccountID: 2,
 				Amount:               "100.50",
 			},
-			expectedID: 1,
-			mockSetup: func(mock sqlmock.Sqlmock, req models.CreateTransactionRequest, amountFloat float64, expectedID int64) {
+			mockSetup: func(mock sqlmock.Sqlmock, req models.CreateTransactionRequest, amountFloat float64) {
 				// Expect BeginTx method to be called and return a transaction
 				mock.ExpectBegin()
 
@@ -145,7 +218,80 @@ func TestCreateTransaction(t *testing.T) {
 			expectError:          true,
 			expectedErrorMessage: "check for existing account:database error",
 		},
-		// Add more test cases here if needed
+		{
+			name: "successful transaction with reference and metadata",
+			req: models.CreateTransactionRequest{
+				SourceAccountID:      1,
+				DestinationAccountID: 2,
+				Amount:               "100.50",
+				Reference:            "inv-1",
+				Description:          "May invoice",
+				Metadata:             map[string]string{"order": "42"},
+			},
+			mockSetup: func(mock sqlmock.Sqlmock, req models.CreateTransactionRequest, amountFloat float64) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT (id) FROM accounts WHERE id IN ($1,$2)")).
+					WithArgs(req.SourceAccountID, req.DestinationAccountID).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+				mock.ExpectQuery(regexp.QuoteMeta(sqlLockActiveAccounts)).
+					WithArgs(req.SourceAccountID, req.DestinationAccountID).
+					WillReturnRows(activeAccountRows(req.SourceAccountID, 200.0, req.DestinationAccountID))
+				mock.ExpectQuery(regexp.QuoteMeta(sqlDebitSource)).
+					WithArgs(amountFloat, req.SourceAccountID).
+					WillReturnRows(balanceRows())
+				mock.ExpectQuery(regexp.QuoteMeta(sqlCreditDestination)).
+					WithArgs(amountFloat, req.DestinationAccountID).
+					WillReturnRows(balanceRows())
+				mock.ExpectQuery(regexp.QuoteMeta(sqlInsertTransaction)).
+					WithArgs(sqlmock.AnyArg(), req.SourceAccountID, req.DestinationAccountID, amountFloat, "inv-1", "May invoice", `{"order":"42"}`).
+					WillReturnRows(insertedTransactionRows())
+				ledgertest.ExpectAppend(mock)
+				audittest.ExpectWrite(mock, 1)
+				mock.ExpectCommit()
+			},
+			expectError:          false,
+			expectedErrorMessage: "",
+		},
+		{
+			name: "failed transaction: duplicate reference",
+			req: models.CreateTransactionRequest{
+				SourceAccountID:      1,
+				DestinationAccountID: 2,
+				Amount:               "100.50",
+				Reference:            "inv-1",
+			},
+			mockSetup: func(mock sqlmock.Sqlmock, req models.CreateTransactionRequest, amountFloat float64) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT (id) FROM accounts WHERE id IN ($1,$2)")).
+					WithArgs(req.SourceAccountID, req.DestinationAccountID).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+				mock.ExpectQuery(regexp.QuoteMeta(sqlLockActiveAccounts)).
+					WithArgs(req.SourceAccountID, req.DestinationAccountID).
+					WillReturnRows(activeAccountRows(req.SourceAccountID, 200.0, req.DestinationAccountID))
+				mock.ExpectQuery(regexp.QuoteMeta(sqlDebitSource)).
+					WithArgs(amountFloat, req.SourceAccountID).
+					WillReturnRows(balanceRows())
+				mock.ExpectQuery(regexp.QuoteMeta(sqlCreditDestination)).
+					WithArgs(amountFloat, req.DestinationAccountID).
+					WillReturnRows(balanceRows())
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO transactions")).
+					WillReturnError(&pq.Error{Code: "23505", Constraint: "idx_transactions_source_reference"})
+				mock.ExpectRollback()
+			},
+			expectError:          true,
+			expectedErrorMessage: "reference already used for this source account",
+		},
+		{
+			name: "failed transaction: mistyped destination account number",
+			req: models.CreateTransactionRequest{
+				SourceAccountID:      100000000190,
+				DestinationAccountID: 100000000278,
+				Amount:               "100.50",
+			},
+			accountNumbers:       accountservice.DefaultAccountNumberFormat,
+			expectError:          true,
+			expectedErrorMessage: "invalid create-transaction-request due to:destination invalid account number check digits: 100000000278",
+		},
 	}
 
 	for _, tt := range tests {
@@ -159,6 +305,13 @@ func TestCreateTransaction(t *testing.T) {
 
 			// Create a new transaction service with the mock database
 			transactionService := NewTransactionService()
+			if tt.accountNumbers != "" {
+				format, err := accountservice.ParseAccountNumberFormat(tt.accountNumbers)
+				if err != nil {
+					t.Fatalf("invalid account number format: %v", err)
+				}
+				transactionService = NewTransactionServiceWithAccountNumbers(format)
+			}
 
 			// Convert the Amount string to float64
 			amountFloat, err := strconv.ParseFloat(tt.req.Amount, 64)
@@ -169,7 +322,7 @@ func TestCreateTransaction(t *testing.T) {
 
 			// Setup mock expectations based on the test case
 			if tt.mockSetup != nil {
-				tt.mockSetup(mock, tt.req, amountFloat, tt.expectedID)
+				tt.mockSetup(mock, tt.req, amountFloat)
 			}
 
 			// Invoke the CreateTransaction method
@@ -182,7 +335,8 @@ func TestCreateTransaction(t *testing.T) {
 			} else {
 				assert.NoError(t, err)
 				assert.NotNil(t, actualTransaction)
-				assert.Equal(t, tt.expectedID, actualTransaction.ID)
+				//IDs are allocated by the service as time-ordered UUIDv7s
+				assert.Equal(t, uuid.Version(7), actualTransaction.ID.Version())
 			}
 
 			// Ensure all expectations were met
@@ -190,3 +344,45 @@ func TestCreateTransaction(t *testing.T) {
 		})
 	}
 }
+
+func TestTransferOutcome(t *testing.T) {
+	tests := []struct {
+		name            string
+		err             error
+		expectedOutcome string
+	}{
+		{name: "success", expectedOutcome: metrics.OutcomeSuccess},
+		{name: "insufficient funds", err: fmt.Errorf("%w: finalSourceAccountBalance:-1", ErrInsufficientFunds), expectedOutcome: metrics.OutcomeInsufficientFunds},
+		{name: "invalid request", err: fmt.Errorf("%w due to:%w", ErrInvalidTransaction, errors.New("bad amount")), expectedOutcome: metrics.OutcomeValidation},
+		{name: "missing account", err: fmt.Errorf("%w count:1", ErrAccountsNotFound), expectedOutcome: metrics.OutcomeValidation},
+		{name: "account not active", err: fmt.Errorf("%w: source account 1", ErrAccountNotActive), expectedOutcome: metrics.OutcomeValidation},
+		{name: "duplicate reference", err: fmt.Errorf("unable to insert new transaction due to :%w", ErrDuplicateReference), expectedOutcome: metrics.OutcomeValidation},
+		{name: "db error", err: errors.New("connection reset"), expectedOutcome: metrics.OutcomeDBError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedOutcome, transferOutcome(tt.err))
+		})
+	}
+}
+
+func TestCreateTransaction_GivesUpWaitingForLockAtDeadline(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	//Another transfer holds the lock past this request's deadline
+	assert.NoError(t, lockTransfers(context.Background()))
+	defer unlockTransfers()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req := models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "10.00"}
+
+	transaction, err := NewTransactionService().CreateTransaction(ctx, db, req)
+	assert.Nil(t, transaction)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	//No txn was started
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,98 @@
+package transaction_service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/google/uuid"
+
+	"aeshanw.com/accountApi/api/tracing"
+)
+
+// MaxReferenceSearchResults caps how many transactions a reference search returns
+const MaxReferenceSearchResults = 100
+
+var (
+	ErrTransactionNotFound  = errors.New("transaction not found")
+	ErrInvalidTransactionID = errors.New("transaction_id must be a UUID or a legacy integer ID")
+)
+
+const sqlSelectTransaction = `SELECT id,COALESCE(legacy_id,0),source_account_id,destination_account_id,amount,COALESCE(reference,''),COALESCE(description,''),metadata,created_at,updated_at FROM transactions`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTransaction(row rowScanner) (*TransactionModel, error) {
+	var transaction TransactionModel
+	var metadata []byte
+	if err := row.Scan(&transaction.ID, &transaction.LegacyID, &transaction.SourceAccountID, &transaction.DestinationAccountID, &transaction.Amount,
+		&transaction.Reference, &transaction.Description, &metadata, &transaction.CreatedAt, &transaction.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &transaction.Metadata); err != nil {
+			return nil, fmt.Errorf("unable to decode metadata due to: %w", err)
+		}
+	}
+	return &transaction, nil
+}
+
+func (ts *TransactionService) GetTransaction(ctx context.Context, db *sql.DB, transactionID string) (*TransactionModel, error) {
+	ctx, span := tracing.Start(ctx, "TransactionService.GetTransaction")
+	defer span.End()
+
+	sqlGetTransaction := sqlSelectTransaction + ` WHERE id=$1`
+	sqlGetLegacyTransaction := sqlSelectTransaction + ` WHERE legacy_id=$1`
+
+	var row *sql.Row
+	if id, err := uuid.Parse(transactionID); err == nil {
+		row = db.QueryRowContext(ctx, sqlGetTransaction, id)
+	} else if legacyID, err := strconv.ParseInt(transactionID, 10, 64); err == nil {
+		row = db.QueryRowContext(ctx, sqlGetLegacyTransaction, legacyID)
+	} else {
+		return nil, ErrInvalidTransactionID
+	}
+
+	transaction, err := scanTransaction(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTransactionNotFound
+		}
+		return nil, fmt.Errorf("unable to fetch transaction due to: %w", err)
+	}
+
+	return transaction, nil
+}
+
+// FindTransactionsByReference returns the transactions whose reference matches exactly, oldest first
+func (ts *TransactionService) FindTransactionsByReference(ctx context.Context, db *sql.DB, reference string) ([]*TransactionModel, error) {
+	ctx, span := tracing.Start(ctx, "TransactionService.FindTransactionsByReference")
+	defer span.End()
+
+	sqlFindByReference := sqlSelectTransaction + ` WHERE reference=$1 ORDER BY id LIMIT $2`
+
+	rows, err := db.QueryContext(ctx, sqlFindByReference, reference, MaxReferenceSearchResults)
+	if err != nil {
+		return nil, fmt.Errorf("unable to search transactions due to: %w", err)
+	}
+	defer rows.Close()
+
+	transactions := []*TransactionModel{}
+	for rows.Next() {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan transaction due to: %w", err)
+		}
+		transactions = append(transactions, transaction)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to search transactions due to: %w", err)
+	}
+
+	return transactions, nil
+}
@@ -0,0 +1,130 @@
+package transaction_service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+var transactionColumns = []string{"id", "legacy_id", "source_account_id", "destination_account_id", "amount", "reference", "description", "metadata", "created_at", "updated_at"}
+
+func TestGetTransaction(t *testing.T) {
+	sqlGetTransaction := regexp.QuoteMeta(sqlSelectTransaction + " WHERE id=$1")
+	sqlGetLegacyTransaction := regexp.QuoteMeta(sqlSelectTransaction + " WHERE legacy_id=$1")
+	createdAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	transactionID := uuid.MustParse("018f3c1e-8a40-7000-8000-000000000001")
+
+	tests := []struct {
+		name                string
+		transactionID       string
+		mockSetup           func(sqlmock.Sqlmock)
+		expectedErr         error
+		expectedTransaction *TransactionModel
+	}{
+		{
+			name:          "successfully retrieve transaction",
+			transactionID: transactionID.String(),
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sqlGetTransaction).WithArgs(transactionID.String()).WillReturnRows(sqlmock.NewRows(transactionColumns).
+					AddRow(transactionID.String(), 0, 10, 20, 5.5, "inv-1", "May invoice", []byte(`{"order":"42"}`), createdAt, createdAt))
+			},
+			expectedTransaction: &TransactionModel{
+				ID:                   transactionID,
+				SourceAccountID:      10,
+				DestinationAccountID: 20,
+				Amount:               5.5,
+				Reference:            "inv-1",
+				Description:          "May invoice",
+				Metadata:             map[string]string{"order": "42"},
+				CreatedAt:            createdAt,
+				UpdatedAt:            createdAt,
+			},
+		},
+		{
+			name:          "retrieve a transaction by its legacy integer id",
+			transactionID: "42",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sqlGetLegacyTransaction).WithArgs(42).WillReturnRows(sqlmock.NewRows(transactionColumns).
+					AddRow(transactionID.String(), 42, 10, 20, 5.5, "", "", []byte(`{}`), createdAt, createdAt))
+			},
+			expectedTransaction: &TransactionModel{
+				ID:                   transactionID,
+				LegacyID:             42,
+				SourceAccountID:      10,
+				DestinationAccountID: 20,
+				Amount:               5.5,
+				Metadata:             map[string]string{},
+				CreatedAt:            createdAt,
+				UpdatedAt:            createdAt,
+			},
+		},
+		{
+			name:          "transaction not found",
+			transactionID: transactionID.String(),
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sqlGetTransaction).WithArgs(transactionID.String()).WillReturnError(sql.ErrNoRows)
+			},
+			expectedErr: ErrTransactionNotFound,
+		},
+		{
+			name:          "invalid transaction id",
+			transactionID: "abc",
+			mockSetup:     func(mock sqlmock.Sqlmock) {},
+			expectedErr:   ErrInvalidTransactionID,
+		},
+		{
+			name:          "database error",
+			transactionID: transactionID.String(),
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sqlGetTransaction).WithArgs(transactionID.String()).WillReturnError(errors.New("database error"))
+			},
+			expectedErr: errors.New("unable to fetch transaction due to: database error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			transaction, err := NewTransactionService().GetTransaction(context.Background(), db, tt.transactionID)
+
+			if tt.expectedErr != nil {
+				assert.EqualError(t, err, tt.expectedErr.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedTransaction, transaction)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestFindTransactionsByReference(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(sqlSelectTransaction+" WHERE reference=$1 ORDER BY id LIMIT $2")).
+		WithArgs("inv-1", MaxReferenceSearchResults).
+		WillReturnRows(sqlmock.NewRows(transactionColumns).
+			AddRow(uuid.New().String(), 0, 10, 20, 5.5, "inv-1", "", []byte(`{}`), time.Now(), time.Now()).
+			AddRow(uuid.New().String(), 0, 11, 20, 7.5, "inv-1", "", []byte(`{}`), time.Now(), time.Now()))
+
+	transactions, err := NewTransactionService().FindTransactionsByReference(context.Background(), db, "inv-1")
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 2)
+	assert.Equal(t, int64(11), transactions[1].SourceAccountID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
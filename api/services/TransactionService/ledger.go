@@ -0,0 +1,38 @@
+package transaction_service
+
+import (
+	"context"
+	"database/sql"
+
+	"aeshanw.com/accountApi/api/audit"
+	"aeshanw.com/accountApi/api/ledger"
+)
+
+// chainContents are the transaction's hashed fields, as stored by the database
+func (tm *TransactionModel) chainContents() *ledger.Contents {
+	return &ledger.Contents{
+		ID:                   tm.ID,
+		SourceAccountID:      tm.SourceAccountID,
+		DestinationAccountID: tm.DestinationAccountID,
+		Amount:               tm.storedAmount,
+		Reference:            tm.Reference,
+		Description:          tm.Description,
+		Metadata:             tm.Metadata,
+		CreatedAt:            tm.CreatedAt,
+	}
+}
+
+// recordTransactions links applied transfers into the transaction chain and audits them. Both serialize their writers
+// until txn ends, so it runs once every row lock is held, and always takes the chain's lock before the audit log's.
+func recordTransactions(ctx context.Context, txn *sql.Tx, transactions ...*TransactionModel) error {
+	contents := make([]*ledger.Contents, len(transactions))
+	entries := make([]audit.Entry, len(transactions))
+	for i, transaction := range transactions {
+		contents[i] = transaction.chainContents()
+		entries[i] = transaction.auditEntry()
+	}
+	if err := ledger.Append(ctx, txn, contents...); err != nil {
+		return err
+	}
+	return audit.Write(ctx, txn, entries...)
+}
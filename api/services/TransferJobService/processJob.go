@@ -0,0 +1,172 @@
+package transfer_job_service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"aeshanw.com/accountApi/api/audit"
+	"aeshanw.com/accountApi/api/logging"
+	"aeshanw.com/accountApi/api/metrics"
+	transactionservice "aeshanw.com/accountApi/api/services/TransactionService"
+	"aeshanw.com/accountApi/api/tracing"
+)
+
+// JobLeaseDuration bounds how long a worker may go without renewing its claim before another worker resumes the job
+const JobLeaseDuration = time.Minute
+
+// ClaimJob leases the oldest pending job, or a processing job whose worker stopped renewing its lease.
+// ok is false when there is nothing to process.
+func (tjs *TransferJobService) ClaimJob(ctx context.Context, db *sql.DB) (jobID int64, ok bool, err error) {
+	ctx, span := tracing.Start(ctx, "TransferJobService.ClaimJob")
+	defer span.End()
+
+	sqlClaimJob := `UPDATE transfer_jobs SET status='processing',lease_expires_at=NOW()+make_interval(secs => $1),updated_at=NOW() ` +
+		`WHERE id=(SELECT id FROM transfer_jobs WHERE status='pending' OR (status='processing' AND lease_expires_at<NOW()) ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED) RETURNING id`
+
+	err = db.QueryRowContext(ctx, sqlClaimJob, int(JobLeaseDuration.Seconds())).Scan(&jobID)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("unable to claim job due to :%w", err)
+	}
+	return jobID, true, nil
+}
+
+// ProcessJob executes every pending row of the job in row order. Each row is paid in its own DB txn together with
+// its status update, so a job interrupted half-way can be resumed without paying any row twice. The transfers are
+// audited as made by whoever uploaded the job.
+func (tjs *TransferJobService) ProcessJob(ctx context.Context, db *sql.DB, jobID int64) error {
+	ctx, span := tracing.Start(ctx, "TransferJobService.ProcessJob")
+	defer span.End()
+
+	sqlJobSource := `SELECT actor,request_id,source_ip FROM transfer_jobs WHERE id=$1`
+	sqlPendingRows := `SELECT row_number FROM transfer_job_rows WHERE job_id=$1 AND status='pending' ORDER BY row_number`
+	sqlRenewLease := `UPDATE transfer_jobs SET lease_expires_at=NOW()+make_interval(secs => $2),updated_at=NOW() WHERE id=$1`
+	sqlCompleteJob := `UPDATE transfer_jobs SET status='completed',lease_expires_at=NULL,updated_at=NOW() WHERE id=$1`
+
+	var actor, requestID, sourceIP sql.NullString
+	if err := db.QueryRowContext(ctx, sqlJobSource, jobID).Scan(&actor, &requestID, &sourceIP); err != nil {
+		return fmt.Errorf("unable to fetch job uploader due to :%w", err)
+	}
+	//Jobs uploaded before the uploader was stored keep the caller's source
+	if actor.Valid {
+		ctx = audit.WithSource(ctx, audit.Source{Actor: actor.String, RequestID: requestID.String, SourceIP: sourceIP.String})
+	}
+
+	rows, err := db.QueryContext(ctx, sqlPendingRows, jobID)
+	if err != nil {
+		return fmt.Errorf("unable to list pending rows due to :%w", err)
+	}
+	var rowNumbers []int
+	for rows.Next() {
+		var rowNumber int
+		if err := rows.Scan(&rowNumber); err != nil {
+			rows.Close()
+			return fmt.Errorf("unable to scan pending row due to :%w", err)
+		}
+		rowNumbers = append(rowNumbers, rowNumber)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("unable to list pending rows due to :%w", err)
+	}
+
+	for _, rowNumber := range rowNumbers {
+		if err := tjs.processRow(ctx, db, jobID, rowNumber); err != nil {
+			return fmt.Errorf("job %d row %d:%w", jobID, rowNumber, err)
+		}
+		if _, err := db.ExecContext(ctx, sqlRenewLease, jobID, int(JobLeaseDuration.Seconds())); err != nil {
+			return fmt.Errorf("unable to renew job lease due to :%w", err)
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, sqlCompleteJob, jobID); err != nil {
+		return fmt.Errorf("unable to complete job due to :%w", err)
+	}
+	logging.FromContext(ctx).Info("transfer job completed", slog.Int64(logging.KeyJobID, jobID), slog.Int("processed_rows", len(rowNumbers)))
+	return nil
+}
+
+// processRow pays a single row. Business failures (e.g. insufficient funds) are recorded on the row as failed, any other
+// error is returned so the job is resumed from this row once its lease expires.
+func (tjs *TransferJobService) processRow(ctx context.Context, db *sql.DB, jobID int64, rowNumber int) error {
+	ctx, span := tracing.Start(ctx, "TransferJobService.processRow")
+	defer span.End()
+
+	sqlLockRow := `SELECT source_account_id,destination_account_id,amount,reference,status FROM transfer_job_rows WHERE job_id=$1 AND row_number=$2 FOR UPDATE`
+	sqlFindPaidRow := `SELECT transaction_id FROM transfer_job_rows WHERE source_account_id=$1 AND reference=$2 AND status='succeeded'`
+	sqlMarkRow := `UPDATE transfer_job_rows SET status=$3,transaction_id=$4,error=$5,updated_at=NOW() WHERE job_id=$1 AND row_number=$2`
+
+	txn, err := db.BeginTx(ctx, tjs.txOptions)
+	if err != nil {
+		return fmt.Errorf("txn for processRow fail:%w", err)
+	}
+	defer metrics.ObserveDBTransaction(metrics.OpTransferJobRow, time.Now())
+
+	var row TransferJobRowModel
+	if err := txn.QueryRowContext(ctx, sqlLockRow, jobID, rowNumber).Scan(&row.Transaction.SourceAccountID, &row.Transaction.DestinationAccountID,
+		&row.Transaction.Amount, &row.Reference, &row.Status); err != nil {
+		txn.Rollback()
+		return fmt.Errorf("unable to lock job row due to :%w", err)
+	}
+
+	if row.Status != RowStatusPending {
+		//Another worker got here first
+		txn.Rollback()
+		return nil
+	}
+
+	//The same payout may appear in a previously uploaded file, point at the original transfer instead of paying again
+	var paidTransactionID uuid.UUID
+	err = txn.QueryRowContext(ctx, sqlFindPaidRow, row.Transaction.SourceAccountID, row.Reference).Scan(&paidTransactionID)
+	switch {
+	case err == nil:
+		if _, err := txn.ExecContext(ctx, sqlMarkRow, jobID, rowNumber, RowStatusDuplicate, paidTransactionID, nil); err != nil {
+			txn.Rollback()
+			return fmt.Errorf("unable to mark duplicate row due to :%w", err)
+		}
+		return txn.Commit()
+	case err != sql.ErrNoRows:
+		txn.Rollback()
+		return fmt.Errorf("check for paid row:%w", err)
+	}
+
+	//The row's reference is stored on the transfer, whose unique (source_account_id, reference) index is the last line of defence
+	row.Transaction.Reference = row.Reference
+	transaction, transferErr := tjs.transferer.CreateTransactionInTxn(ctx, txn, row.Transaction)
+	if transferErr != nil {
+		txn.Rollback()
+		if !transactionservice.IsRejected(transferErr) {
+			return fmt.Errorf("unable to pay row due to :%w", transferErr)
+		}
+		if _, err := db.ExecContext(ctx, sqlMarkRow, jobID, rowNumber, RowStatusFailed, nil, transferErr.Error()); err != nil {
+			return fmt.Errorf("unable to mark failed row due to :%w", err)
+		}
+		logging.FromContext(ctx).Warn("transfer job row failed",
+			slog.Int64(logging.KeyJobID, jobID),
+			slog.Int("row_number", rowNumber),
+			slog.Int64(logging.KeySourceAccountID, row.Transaction.SourceAccountID),
+			slog.Int64(logging.KeyDestinationAccountID, row.Transaction.DestinationAccountID),
+			slog.String(logging.KeyError, transferErr.Error()),
+		)
+		return nil
+	}
+
+	//Marking the row as succeeded in the same txn as the transfer is what makes a resumed job safe
+	if _, err := txn.ExecContext(ctx, sqlMarkRow, jobID, rowNumber, RowStatusSucceeded, transaction.ID, nil); err != nil {
+		txn.Rollback()
+		return fmt.Errorf("unable to mark succeeded row due to :%w", err)
+	}
+
+	if err := txn.Commit(); err != nil {
+		return fmt.Errorf("unable to commit row txn due to :%w", err)
+	}
+	transactionservice.LogTransactionCreated(ctx, transaction, slog.Int64(logging.KeyJobID, jobID), slog.Int("row_number", rowNumber))
+	return nil
+}
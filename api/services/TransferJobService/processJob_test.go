@@ -0,0 +1,210 @@
+package transfer_job_service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+
+	"aeshanw.com/accountApi/api/audit"
+	"aeshanw.com/accountApi/api/models"
+	transactionservice "aeshanw.com/accountApi/api/services/TransactionService"
+)
+
+var (
+	paidTransactionID    = uuid.MustParse("018f3c1e-8a40-7000-8000-000000000011")
+	earlierTransactionID = uuid.MustParse("018f3c1e-8a40-7000-8000-000000000005")
+)
+
+// fakeTransferer records the transfers it was asked to apply and who they were audited as
+type fakeTransferer struct {
+	err     error
+	calls   []models.CreateTransactionRequest
+	sources []audit.Source
+}
+
+func (f *fakeTransferer) CreateTransactionInTxn(ctx context.Context, txn *sql.Tx, req models.CreateTransactionRequest) (*transactionservice.TransactionModel, error) {
+	f.calls = append(f.calls, req)
+	f.sources = append(f.sources, audit.SourceFromContext(ctx))
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &transactionservice.TransactionModel{ID: paidTransactionID}, nil
+}
+
+func expectLockRow(mock sqlmock.Sqlmock, status string) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT source_account_id,destination_account_id,amount,reference,status FROM transfer_job_rows WHERE job_id=$1 AND row_number=$2 FOR UPDATE")).
+		WithArgs(7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"source_account_id", "destination_account_id", "amount", "reference", "status"}).
+			AddRow(1, 2, "10.00", "inv-1", status))
+}
+
+// expectJobSource expects the lookup of job 7's uploader, a nil source is a job uploaded before it was stored
+func expectJobSource(mock sqlmock.Sqlmock, source *audit.Source) {
+	rows := sqlmock.NewRows([]string{"actor", "request_id", "source_ip"})
+	if source != nil {
+		rows.AddRow(source.Actor, source.RequestID, source.SourceIP)
+	} else {
+		rows.AddRow(nil, nil, nil)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT actor,request_id,source_ip FROM transfer_jobs WHERE id=$1")).WithArgs(7).WillReturnRows(rows)
+}
+
+func TestProcessJob_AuditsRowsAsUploader(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	uploader := audit.Source{Actor: "api_key:0123456789ab", RequestID: "req-1", SourceIP: "10.0.0.1"}
+	expectJobSource(mock, &uploader)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT row_number FROM transfer_job_rows WHERE job_id=$1 AND status='pending' ORDER BY row_number")).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"row_number"}).AddRow(1))
+	mock.ExpectBegin()
+	expectLockRow(mock, RowStatusPending)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT transaction_id FROM transfer_job_rows")).WithArgs(1, "inv-1").WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE transfer_job_rows SET status=$3")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE transfer_jobs SET lease_expires_at=")).WithArgs(7, 60).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE transfer_jobs SET status='completed'")).WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	transferer := &fakeTransferer{}
+	//The worker runs without a source of its own
+	err = NewTransferJobService(transferer).ProcessJob(context.Background(), db, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, []audit.Source{uploader}, transferer.sources)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProcessRow(t *testing.T) {
+	sqlFindPaidRow := regexp.QuoteMeta("SELECT transaction_id FROM transfer_job_rows WHERE source_account_id=$1 AND reference=$2 AND status='succeeded'")
+	sqlMarkRow := regexp.QuoteMeta("UPDATE transfer_job_rows SET status=$3,transaction_id=$4,error=$5,updated_at=NOW() WHERE job_id=$1 AND row_number=$2")
+
+	tests := []struct {
+		name          string
+		transferErr   error
+		mockSetup     func(sqlmock.Sqlmock)
+		expectError   bool
+		expectedCalls int
+	}{
+		{
+			name: "pending row is paid",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectLockRow(mock, RowStatusPending)
+				mock.ExpectQuery(sqlFindPaidRow).WithArgs(1, "inv-1").WillReturnError(sql.ErrNoRows)
+				mock.ExpectExec(sqlMarkRow).WithArgs(7, 1, RowStatusSucceeded, paidTransactionID.String(), nil).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			expectedCalls: 1,
+		},
+		{
+			name: "row already paid by an earlier upload is a duplicate",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectLockRow(mock, RowStatusPending)
+				mock.ExpectQuery(sqlFindPaidRow).WithArgs(1, "inv-1").WillReturnRows(sqlmock.NewRows([]string{"transaction_id"}).AddRow(earlierTransactionID.String()))
+				mock.ExpectExec(sqlMarkRow).WithArgs(7, 1, RowStatusDuplicate, earlierTransactionID.String(), nil).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			expectedCalls: 0,
+		},
+		{
+			name: "row processed by another worker is skipped",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectLockRow(mock, RowStatusSucceeded)
+				mock.ExpectRollback()
+			},
+			expectedCalls: 0,
+		},
+		{
+			name:        "failed transfer is recorded on the row",
+			transferErr: fmt.Errorf("%w: finalSourceAccountBalance:-1", transactionservice.ErrInsufficientFunds),
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectLockRow(mock, RowStatusPending)
+				mock.ExpectQuery(sqlFindPaidRow).WithArgs(1, "inv-1").WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+				mock.ExpectExec(sqlMarkRow).WithArgs(7, 1, RowStatusFailed, nil, "source account has insufficent funds: finalSourceAccountBalance:-1").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectedCalls: 1,
+		},
+		{
+			name:        "transient transfer failure is returned so the row is retried",
+			transferErr: errors.New("unable to lock accounts due to :driver: bad connection"),
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectLockRow(mock, RowStatusPending)
+				mock.ExpectQuery(sqlFindPaidRow).WithArgs(1, "inv-1").WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectError:   true,
+			expectedCalls: 1,
+		},
+		{
+			name: "db failure is returned so the job is resumed later",
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectLockRow(mock, RowStatusPending)
+				mock.ExpectQuery(sqlFindPaidRow).WithArgs(1, "inv-1").WillReturnError(sql.ErrNoRows)
+				mock.ExpectExec(sqlMarkRow).WithArgs(7, 1, RowStatusSucceeded, paidTransactionID.String(), nil).WillReturnError(errors.New("unique violation"))
+				mock.ExpectRollback()
+			},
+			expectError:   true,
+			expectedCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			transferer := &fakeTransferer{err: tt.transferErr}
+			err = NewTransferJobService(transferer).processRow(context.Background(), db, 7, 1)
+
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Len(t, transferer.calls, tt.expectedCalls)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestClaimJob(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlClaimJob := regexp.QuoteMeta("UPDATE transfer_jobs SET status='processing'")
+	mock.ExpectQuery(sqlClaimJob).WithArgs(60).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery(sqlClaimJob).WithArgs(60).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	tjs := NewTransferJobService(nil)
+
+	jobID, ok, err := tjs.ClaimJob(context.Background(), db)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(7), jobID)
+
+	_, ok, err = tjs.ClaimJob(context.Background(), db)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,233 @@
+package transfer_job_service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"aeshanw.com/accountApi/api/audit"
+	"aeshanw.com/accountApi/api/metrics"
+	"aeshanw.com/accountApi/api/models"
+	transactionservice "aeshanw.com/accountApi/api/services/TransactionService"
+	"aeshanw.com/accountApi/api/tracing"
+)
+
+const (
+	JobStatusPending    = "pending"
+	JobStatusProcessing = "processing"
+	JobStatusCompleted  = "completed"
+
+	RowStatusPending   = "pending"
+	RowStatusSucceeded = "succeeded"
+	RowStatusFailed    = "failed"
+	RowStatusDuplicate = "duplicate"
+	RowStatusInvalid   = "invalid"
+)
+
+// TransferJobServiceInt defines the methods for interacting with bulk transfer jobs.
+type TransferJobServiceInt interface {
+	// CreateJob stores the parsed rows as a pending job. Uploading the same file again returns the existing job with created=false.
+	CreateJob(ctx context.Context, db *sql.DB, req models.CreateTransferJobRequest) (job *TransferJobModel, created bool, err error)
+	GetJob(ctx context.Context, db *sql.DB, jobID int64) (*TransferJobModel, error)
+	// ListJobRows streams the rows of a job in row order to fn
+	ListJobRows(ctx context.Context, db *sql.DB, jobID int64, fn func(*TransferJobRowModel) error) error
+	ProcessJob(ctx context.Context, db *sql.DB, jobID int64) error
+}
+
+// Transferer applies a single transfer within a txn owned by the caller
+type Transferer interface {
+	CreateTransactionInTxn(ctx context.Context, txn *sql.Tx, req models.CreateTransactionRequest) (*transactionservice.TransactionModel, error)
+}
+
+var (
+	ErrJobNotFound = errors.New("transfer job not found")
+	// ErrInvalidJob is returned for an upload that cannot be queued as a job, e.g. one without rows
+	ErrInvalidJob = errors.New("invalid transfer job")
+)
+
+type TransferJobModel struct {
+	ID         int64
+	FileSHA256 string
+	Status     string
+	TotalRows  int
+	// RowCounts holds the number of rows per row-status
+	RowCounts map[string]int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type TransferJobRowModel struct {
+	JobID         int64
+	RowNumber     int
+	Transaction   models.CreateTransactionRequest
+	Reference     string
+	Status        string
+	TransactionID uuid.NullUUID
+	Error         sql.NullString
+}
+
+type TransferJobService struct {
+	transferer Transferer
+	// wake nudges the Worker so freshly uploaded jobs are picked up without waiting for the next poll
+	wake chan struct{}
+	// txOptions is passed to BeginTx for each row's transfer, nil uses the database's default isolation level
+	txOptions *sql.TxOptions
+}
+
+func NewTransferJobService(transferer Transferer) *TransferJobService {
+	return &TransferJobService{
+		transferer: transferer,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// SetIsolationLevel runs each row's transfer at level instead of the database's default
+func (tjs *TransferJobService) SetIsolationLevel(level sql.IsolationLevel) {
+	tjs.txOptions = &sql.TxOptions{Isolation: level}
+}
+
+func (tjs *TransferJobService) CreateJob(ctx context.Context, db *sql.DB, req models.CreateTransferJobRequest) (*TransferJobModel, bool, error) {
+	ctx, span := tracing.Start(ctx, "TransferJobService.CreateJob")
+	defer span.End()
+
+	if req.FileSHA256 == "" {
+		return nil, false, fmt.Errorf("%w: file checksum is empty", ErrInvalidJob)
+	}
+	if len(req.Rows) == 0 {
+		return nil, false, fmt.Errorf("%w: transfer job has no rows", ErrInvalidJob)
+	}
+
+	sqlInsertJob := `INSERT INTO transfer_jobs(file_sha256,total_rows,actor,request_id,source_ip) VALUES ($1,$2,$3,NULLIF($4,''),NULLIF($5,'')) ` +
+		`ON CONFLICT (file_sha256) DO NOTHING RETURNING id`
+	sqlFindJob := `SELECT id FROM transfer_jobs WHERE file_sha256=$1`
+	sqlInsertRow := `INSERT INTO transfer_job_rows(job_id,row_number,source_account_id,destination_account_id,amount,reference,status,error) VALUES ($1,$2,$3,$4,$5,$6,$7,$8)`
+
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("txn for createJob fail:%w", err)
+	}
+	defer metrics.ObserveDBTransaction(metrics.OpCreateTransferJob, time.Now())
+
+	//The rows are paid later by the worker, which audits them as made by the uploader
+	source := audit.SourceFromContext(ctx)
+	var jobID int64
+	err = txn.QueryRowContext(ctx, sqlInsertJob, req.FileSHA256, len(req.Rows), source.Actor, source.RequestID, source.SourceIP).Scan(&jobID)
+	if err == sql.ErrNoRows {
+		//Same file was uploaded before, hand back that job rather than paying the rows again
+		txn.Rollback()
+		if err := db.QueryRowContext(ctx, sqlFindJob, req.FileSHA256).Scan(&jobID); err != nil {
+			return nil, false, fmt.Errorf("unable to find existing job due to :%w", err)
+		}
+		job, err := tjs.GetJob(ctx, db, jobID)
+		return job, false, err
+	}
+	if err != nil {
+		txn.Rollback()
+		return nil, false, fmt.Errorf("unable to insert new job due to :%w", err)
+	}
+
+	stmt, err := txn.PrepareContext(ctx, sqlInsertRow)
+	if err != nil {
+		txn.Rollback()
+		return nil, false, fmt.Errorf("unable to prepare job rows due to :%w", err)
+	}
+	defer stmt.Close()
+
+	for _, row := range req.Rows {
+		status := RowStatusPending
+		rowErr := sql.NullString{}
+		if row.Error != "" {
+			status = RowStatusInvalid
+			rowErr = sql.NullString{String: row.Error, Valid: true}
+		}
+		if _, err := stmt.ExecContext(ctx, jobID, row.RowNumber, row.Transaction.SourceAccountID, row.Transaction.DestinationAccountID,
+			row.Transaction.Amount, row.Reference, status, rowErr); err != nil {
+			txn.Rollback()
+			return nil, false, fmt.Errorf("unable to insert job row %d due to :%w", row.RowNumber, err)
+		}
+	}
+
+	if err = txn.Commit(); err != nil {
+		return nil, false, fmt.Errorf("unable to commit job-creation txn due to :%w", err)
+	}
+
+	tjs.Notify()
+
+	job, err := tjs.GetJob(ctx, db, jobID)
+	return job, true, err
+}
+
+// Notify wakes the Worker without blocking if it is already due to run
+func (tjs *TransferJobService) Notify() {
+	select {
+	case tjs.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (tjs *TransferJobService) GetJob(ctx context.Context, db *sql.DB, jobID int64) (*TransferJobModel, error) {
+	ctx, span := tracing.Start(ctx, "TransferJobService.GetJob")
+	defer span.End()
+
+	sqlGetJob := `SELECT id,file_sha256,status,total_rows,created_at,updated_at FROM transfer_jobs WHERE id=$1`
+	sqlCountRows := `SELECT status,COUNT(*) FROM transfer_job_rows WHERE job_id=$1 GROUP BY status`
+
+	var job TransferJobModel
+	err := db.QueryRowContext(ctx, sqlGetJob, jobID).Scan(&job.ID, &job.FileSHA256, &job.Status, &job.TotalRows, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("unable to fetch job due to: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx, sqlCountRows, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to count job rows due to: %w", err)
+	}
+	defer rows.Close()
+
+	job.RowCounts = map[string]int{}
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("unable to count job rows due to: %w", err)
+		}
+		job.RowCounts[status] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to count job rows due to: %w", err)
+	}
+
+	return &job, nil
+}
+
+func (tjs *TransferJobService) ListJobRows(ctx context.Context, db *sql.DB, jobID int64, fn func(*TransferJobRowModel) error) error {
+	ctx, span := tracing.Start(ctx, "TransferJobService.ListJobRows")
+	defer span.End()
+
+	sqlListRows := `SELECT job_id,row_number,source_account_id,destination_account_id,amount,reference,status,transaction_id,error FROM transfer_job_rows WHERE job_id=$1 ORDER BY row_number`
+
+	rows, err := db.QueryContext(ctx, sqlListRows, jobID)
+	if err != nil {
+		return fmt.Errorf("unable to list job rows due to: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row TransferJobRowModel
+		if err := rows.Scan(&row.JobID, &row.RowNumber, &row.Transaction.SourceAccountID, &row.Transaction.DestinationAccountID,
+			&row.Transaction.Amount, &row.Reference, &row.Status, &row.TransactionID, &row.Error); err != nil {
+			return fmt.Errorf("unable to scan job row due to: %w", err)
+		}
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,167 @@
+package transfer_job_service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+
+	"aeshanw.com/accountApi/api/audit"
+	"aeshanw.com/accountApi/api/models"
+)
+
+func expectGetJob(mock sqlmock.Sqlmock, jobID int64, status string) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,file_sha256,status,total_rows,created_at,updated_at FROM transfer_jobs WHERE id=$1")).
+		WithArgs(jobID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "file_sha256", "status", "total_rows", "created_at", "updated_at"}).
+			AddRow(jobID, "abc123", status, 2, time.Now(), time.Now()))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT status,COUNT(*) FROM transfer_job_rows WHERE job_id=$1 GROUP BY status")).
+		WithArgs(jobID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "count"}).AddRow(RowStatusPending, 1).AddRow(RowStatusInvalid, 1))
+}
+
+func TestCreateJob(t *testing.T) {
+	sqlInsertJob := regexp.QuoteMeta("INSERT INTO transfer_jobs(file_sha256,total_rows,actor,request_id,source_ip) VALUES ($1,$2,$3,NULLIF($4,''),NULLIF($5,'')) ON CONFLICT (file_sha256) DO NOTHING RETURNING id")
+	req := models.CreateTransferJobRequest{
+		FileSHA256: "abc123",
+		Rows: []models.TransferJobRow{
+			{RowNumber: 1, Transaction: models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 2, Amount: "10.00"}, Reference: "inv-1"},
+			{RowNumber: 2, Transaction: models.CreateTransactionRequest{SourceAccountID: 1, DestinationAccountID: 0, Amount: "10.00"}, Reference: "inv-2", Error: "invalid DestinationAccountID"},
+		},
+	}
+
+	tests := []struct {
+		name                 string
+		req                  models.CreateTransferJobRequest
+		mockSetup            func(sqlmock.Sqlmock)
+		expectError          bool
+		expectedErrorMessage string
+		expectedCreated      bool
+	}{
+		{
+			name: "new file creates a job",
+			req:  req,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlInsertJob).
+					WithArgs("abc123", 2, "api_key:0123456789ab", "req-1", "10.0.0.1").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+				prep := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO transfer_job_rows(job_id,row_number,source_account_id,destination_account_id,amount,reference,status,error) VALUES ($1,$2,$3,$4,$5,$6,$7,$8)"))
+				prep.ExpectExec().WithArgs(7, 1, 1, 2, "10.00", "inv-1", RowStatusPending, nil).WillReturnResult(sqlmock.NewResult(0, 1))
+				prep.ExpectExec().WithArgs(7, 2, 1, 0, "10.00", "inv-2", RowStatusInvalid, "invalid DestinationAccountID").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				expectGetJob(mock, 7, JobStatusPending)
+			},
+			expectedCreated: true,
+		},
+		{
+			name: "re-uploaded file returns the existing job",
+			req:  req,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlInsertJob).
+					WithArgs("abc123", 2, "api_key:0123456789ab", "req-1", "10.0.0.1").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectRollback()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM transfer_jobs WHERE file_sha256=$1")).
+					WithArgs("abc123").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+				expectGetJob(mock, 7, JobStatusCompleted)
+			},
+			expectedCreated: false,
+		},
+		{
+			name: "row insert failure rolls back",
+			req:  req,
+			mockSetup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(sqlInsertJob).
+					WithArgs("abc123", 2, "api_key:0123456789ab", "req-1", "10.0.0.1").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+				prep := mock.ExpectPrepare(regexp.QuoteMeta("INSERT INTO transfer_job_rows"))
+				prep.ExpectExec().WillReturnError(errors.New("database error"))
+				mock.ExpectRollback()
+			},
+			expectError:          true,
+			expectedErrorMessage: "unable to insert job row 1 due to :database error",
+		},
+		{
+			name:                 "empty job",
+			req:                  models.CreateTransferJobRequest{FileSHA256: "abc123"},
+			mockSetup:            func(mock sqlmock.Sqlmock) {},
+			expectError:          true,
+			expectedErrorMessage: "transfer job has no rows",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to create mock database: %v", err)
+			}
+			defer db.Close()
+
+			tt.mockSetup(mock)
+
+			tjs := NewTransferJobService(nil)
+			ctx := audit.WithSource(context.Background(), audit.Source{Actor: "api_key:0123456789ab", RequestID: "req-1", SourceIP: "10.0.0.1"})
+			job, created, err := tjs.CreateJob(ctx, db, tt.req)
+
+			if tt.expectError {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErrorMessage)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedCreated, created)
+				assert.Equal(t, int64(7), job.ID)
+				assert.Equal(t, map[string]int{RowStatusPending: 1, RowStatusInvalid: 1}, job.RowCounts)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetJob_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,file_sha256,status,total_rows,created_at,updated_at FROM transfer_jobs WHERE id=$1")).
+		WithArgs(9).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = NewTransferJobService(nil).GetJob(context.Background(), db, 9)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListJobRows(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT job_id,row_number,source_account_id,destination_account_id,amount,reference,status,transaction_id,error FROM transfer_job_rows WHERE job_id=$1 ORDER BY row_number")).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"job_id", "row_number", "source_account_id", "destination_account_id", "amount", "reference", "status", "transaction_id", "error"}).
+			AddRow(7, 1, 1, 2, "10.00", "inv-1", RowStatusSucceeded, paidTransactionID.String(), nil).
+			AddRow(7, 2, 1, 3, "20.00", "inv-2", RowStatusFailed, nil, "insufficent funds"))
+
+	var rows []*TransferJobRowModel
+	err = NewTransferJobService(nil).ListJobRows(context.Background(), db, 7, func(row *TransferJobRowModel) error {
+		rows = append(rows, row)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, uuid.NullUUID{UUID: paidTransactionID, Valid: true}, rows[0].TransactionID)
+	assert.Equal(t, sql.NullString{String: "insufficent funds", Valid: true}, rows[1].Error)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
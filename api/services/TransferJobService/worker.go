@@ -0,0 +1,94 @@
+package transfer_job_service
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"aeshanw.com/accountApi/api/health"
+	"aeshanw.com/accountApi/api/logging"
+	"aeshanw.com/accountApi/api/tracing"
+)
+
+// Worker processes uploaded transfer jobs in the background
+type Worker struct {
+	db       *sql.DB
+	service  *TransferJobService
+	interval time.Duration
+	// heartbeat beats on every poll and after every job
+	heartbeat *health.Heartbeat
+}
+
+func NewWorker(db *sql.DB, service *TransferJobService, interval time.Duration) *Worker {
+	return &Worker{
+		db:        db,
+		service:   service,
+		interval:  interval,
+		heartbeat: health.NewHeartbeat("transfer job worker", interval),
+	}
+}
+
+// Run polls for claimable jobs until ctx is cancelled. It logs with the logger carried by ctx.
+func (w *Worker) Run(ctx context.Context) {
+	w.heartbeat.Start()
+	defer w.heartbeat.Stop()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.heartbeat.Beat()
+		w.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.service.wake:
+		}
+	}
+}
+
+// drain keeps claiming jobs until none are left
+func (w *Worker) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		jobID, ok, err := w.service.ClaimJob(ctx, w.db)
+		if err != nil {
+			logging.FromContext(ctx).Error("unable to claim transfer job", slog.String(logging.KeyError, err.Error()))
+			return
+		}
+		if !ok {
+			return
+		}
+
+		//Each job is its own trace, its log lines carry the trace ID
+		jobCtx, span := tracing.Start(ctx, "transfer job", attribute.Int64("transfer_job.id", jobID))
+		jobCtx = logging.WithSpan(jobCtx)
+		done := w.heartbeat.Busy()
+		err = w.service.ProcessJob(jobCtx, w.db, jobID)
+		done()
+		if err != nil && ctx.Err() != nil {
+			//Stopped for shutdown, the job is resumed from its last processed row once the lease expires
+			span.End()
+			logging.FromContext(jobCtx).Info("transfer job paused for shutdown", slog.Int64(logging.KeyJobID, jobID))
+			return
+		}
+		if err != nil {
+			//The lease will expire and the job is resumed on a later poll
+			span.SetStatus(codes.Error, err.Error())
+			span.End()
+			logging.FromContext(jobCtx).Error("transfer job interrupted", slog.Int64(logging.KeyJobID, jobID), slog.String(logging.KeyError, err.Error()))
+			return
+		}
+		span.End()
+	}
+}
+
+// Check is the worker's readiness check, see health.Heartbeat
+func (w *Worker) Check(ctx context.Context) error {
+	return w.heartbeat.Check(ctx)
+}
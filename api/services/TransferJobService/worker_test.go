@@ -0,0 +1,44 @@
+package transfer_job_service
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestWorker_ProcessesClaimedJobs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	sqlClaimJob := regexp.QuoteMeta("UPDATE transfer_jobs SET status='processing'")
+	mock.ExpectQuery(sqlClaimJob).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	expectJobSource(mock, nil)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT row_number FROM transfer_job_rows WHERE job_id=$1 AND status='pending' ORDER BY row_number")).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"row_number"}))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE transfer_jobs SET status='completed',lease_expires_at=NULL,updated_at=NOW() WHERE id=$1")).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(sqlClaimJob).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewWorker(db, NewTransferJobService(nil), time.Hour).Run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return mock.ExpectationsWereMet() == nil }, time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("worker did not stop after cancellation")
+	}
+}
@@ -0,0 +1,153 @@
+// Package tracing sets up OpenTelemetry tracing for the API. Incoming W3C traceparent headers are continued, so a
+// transfer can be followed from the caller through the handler, service and each SQL statement.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "aeshanw.com/accountApi/api"
+
+// Exporters
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+const DefaultServiceName = "account-api"
+
+type Options struct {
+	// Exporter is one of none, stdout or file. With none, trace IDs from incoming requests are still propagated to logs.
+	Exporter    string
+	File        string
+	ServiceName string
+}
+
+// OptionsFromEnv reads OTEL_TRACES_EXPORTER (none, stdout or file, default none), OTEL_TRACES_FILE and OTEL_SERVICE_NAME
+func OptionsFromEnv(getenv func(string) string) (Options, error) {
+	opts := Options{
+		Exporter:    getenv("OTEL_TRACES_EXPORTER"),
+		File:        getenv("OTEL_TRACES_FILE"),
+		ServiceName: getenv("OTEL_SERVICE_NAME"),
+	}
+	if opts.Exporter == "" {
+		opts.Exporter = ExporterNone
+	}
+	if opts.ServiceName == "" {
+		opts.ServiceName = DefaultServiceName
+	}
+
+	switch opts.Exporter {
+	case ExporterNone, ExporterStdout:
+	case ExporterFile:
+		if opts.File == "" {
+			return opts, errors.New("OTEL_TRACES_FILE is required when OTEL_TRACES_EXPORTER=file")
+		}
+	default:
+		return opts, fmt.Errorf("invalid OTEL_TRACES_EXPORTER %q", opts.Exporter)
+	}
+	return opts, nil
+}
+
+// Setup installs the global tracer provider and W3C trace-context propagator.
+// The returned shutdown flushes pending spans and must be called before the process exits.
+func Setup(opts Options) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var out io.Writer
+	var file *os.File
+	switch opts.Exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		out = os.Stdout
+	case ExporterFile:
+		file, err = os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open trace file due to :%w", err)
+		}
+		out = file
+	default:
+		return nil, fmt.Errorf("invalid trace exporter %q", opts.Exporter)
+	}
+
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(out))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create trace exporter due to :%w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(opts.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			err = errors.Join(err, file.Close())
+		}
+		return err
+	}, nil
+}
+
+// Start starts a span named name as a child of the span in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// TraceID returns the trace ID of the span in ctx, or "" when there is none
+func TraceID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
+
+// Middleware starts a server span per request, continuing the trace from the incoming traceparent header.
+// The span is named after the chi route pattern once routing is done, e.g. "GET /accounts/{account_id}".
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := otel.Tracer(instrumentationName).Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			))
+		defer span.End()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			span.SetName(r.Method + " " + rctx.RoutePattern())
+			span.SetAttributes(semconv.HTTPRoute(rctx.RoutePattern()))
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
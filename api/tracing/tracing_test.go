@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+func TestOptionsFromEnv(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		expectedOpts Options
+		expectedErr  string
+	}{
+		{name: "defaults", env: map[string]string{}, expectedOpts: Options{Exporter: ExporterNone, ServiceName: DefaultServiceName}},
+		{name: "stdout", env: map[string]string{"OTEL_TRACES_EXPORTER": "stdout", "OTEL_SERVICE_NAME": "api-1"}, expectedOpts: Options{Exporter: ExporterStdout, ServiceName: "api-1"}},
+		{name: "file", env: map[string]string{"OTEL_TRACES_EXPORTER": "file", "OTEL_TRACES_FILE": "/tmp/traces.json"}, expectedOpts: Options{Exporter: ExporterFile, File: "/tmp/traces.json", ServiceName: DefaultServiceName}},
+		{name: "file without path", env: map[string]string{"OTEL_TRACES_EXPORTER": "file"}, expectedErr: "OTEL_TRACES_FILE is required when OTEL_TRACES_EXPORTER=file"},
+		{name: "unknown exporter", env: map[string]string{"OTEL_TRACES_EXPORTER": "jaeger"}, expectedErr: `invalid OTEL_TRACES_EXPORTER "jaeger"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := OptionsFromEnv(func(key string) string { return tt.env[key] })
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedOpts, opts)
+		})
+	}
+}
+
+func TestMiddlewareContinuesIncomingTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var traceID string
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/accounts/{account_id}", func(w http.ResponseWriter, r *http.Request) {
+		traceID = TraceID(r.Context())
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/accounts/7", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceID)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "GET /accounts/{account_id}", spans[0].Name())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+	assert.Contains(t, spans[0].Attributes(), semconv.HTTPRoute("/accounts/{account_id}"))
+	assert.Contains(t, spans[0].Attributes(), semconv.HTTPResponseStatusCode(http.StatusNotFound))
+}
+
+func TestTraceIDWithoutSpan(t *testing.T) {
+	assert.Equal(t, "", TraceID(context.Background()))
+}
@@ -18,4 +18,286 @@ CREATE TABLE IF NOT EXISTS transactions (
 );
 
 CREATE INDEX IF NOT EXISTS idx_source_account_id ON transactions(source_account_id);
-CREATE INDEX IF NOT EXISTS idx_destination_account_id ON transactions(destination_account_id);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_destination_account_id ON transactions(destination_account_id);
+
+-- Bulk transfer jobs uploaded as CSV and processed asynchronously
+CREATE TABLE IF NOT EXISTS transfer_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    file_sha256 TEXT NOT NULL UNIQUE,
+    status TEXT NOT NULL DEFAULT 'pending',
+    total_rows INT NOT NULL DEFAULT 0,
+    lease_expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS transfer_job_rows (
+    job_id BIGINT NOT NULL,
+    row_number INT NOT NULL,
+    source_account_id BIGINT NOT NULL DEFAULT 0,
+    destination_account_id BIGINT NOT NULL DEFAULT 0,
+    amount TEXT NOT NULL DEFAULT '',
+    reference TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'pending',
+    transaction_id INT,
+    error TEXT,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (job_id, row_number),
+    CONSTRAINT fk_transfer_job FOREIGN KEY (job_id) REFERENCES transfer_jobs(id),
+    CONSTRAINT fk_transfer_job_transaction FOREIGN KEY (transaction_id) REFERENCES transactions(id)
+);
+
+-- A (source_account_id, reference) pair can only ever be paid once across all uploaded files
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transfer_job_rows_paid ON transfer_job_rows(source_account_id, reference) WHERE status = 'succeeded';
+
+-- Caller-supplied reference, memo and metadata on transfers
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS reference TEXT;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_transactions_reference ON transactions(reference);
+-- A reference can only be used once per source account, letting callers safely retry a transfer
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_source_reference ON transactions(source_account_id, reference) WHERE reference IS NOT NULL;
+
+-- Account profile, balance changes are only ever made through transfers
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS display_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS owner_reference TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS account_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_accounts_owner_reference ON accounts(owner_reference);
+
+-- Keep updated_at accurate for every UPDATE, including balance changes made by transfers
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_accounts_updated_at ON accounts;
+CREATE TRIGGER trg_accounts_updated_at BEFORE UPDATE ON accounts FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+DROP TRIGGER IF EXISTS trg_transactions_updated_at ON transactions;
+CREATE TRIGGER trg_transactions_updated_at BEFORE UPDATE ON transactions FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- Account status and the indexes backing GET /accounts keyset pagination and filters
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active';
+
+CREATE INDEX IF NOT EXISTS idx_accounts_balance ON accounts(balance, id);
+CREATE INDEX IF NOT EXISTS idx_accounts_created_at ON accounts(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_accounts_status ON accounts(status);
+CREATE INDEX IF NOT EXISTS idx_accounts_metadata ON accounts USING GIN (metadata jsonb_path_ops);
+
+-- Allocates server-generated account numbers (ACCOUNT_ID_MODE=generated)
+CREATE SEQUENCE IF NOT EXISTS account_number_seq;
+
+-- Transaction IDs are UUIDv7 allocated by the API. Databases created with SERIAL IDs are migrated in place: the old
+-- integer is kept as legacy_id so existing clients can still look transactions up by it, and each row gets a
+-- v7-shaped UUID built from its created_at timestamp so the new IDs keep the original ordering.
+DO $$
+BEGIN
+    IF (SELECT data_type FROM information_schema.columns WHERE table_name = 'transactions' AND column_name = 'id') <> 'uuid' THEN
+        ALTER TABLE transfer_job_rows DROP CONSTRAINT IF EXISTS fk_transfer_job_transaction;
+
+        ALTER TABLE transactions RENAME COLUMN id TO legacy_id;
+        ALTER TABLE transactions ALTER COLUMN legacy_id DROP DEFAULT;
+        ALTER TABLE transactions ADD COLUMN id UUID;
+        UPDATE transactions SET id = (
+            lpad(to_hex((extract(epoch FROM created_at) * 1000)::BIGINT), 12, '0') || '7000' || '8' || lpad(to_hex(legacy_id), 15, '0')
+        )::UUID;
+        ALTER TABLE transactions DROP CONSTRAINT transactions_pkey;
+        ALTER TABLE transactions ALTER COLUMN legacy_id DROP NOT NULL;
+        ALTER TABLE transactions ALTER COLUMN id SET NOT NULL;
+        ALTER TABLE transactions ADD CONSTRAINT transactions_pkey PRIMARY KEY (id);
+        CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_legacy_id ON transactions(legacy_id);
+        DROP SEQUENCE IF EXISTS transactions_id_seq;
+
+        ALTER TABLE transfer_job_rows ADD COLUMN transaction_uuid UUID;
+        UPDATE transfer_job_rows r SET transaction_uuid = t.id FROM transactions t WHERE t.legacy_id = r.transaction_id;
+        ALTER TABLE transfer_job_rows DROP COLUMN transaction_id;
+        ALTER TABLE transfer_job_rows RENAME COLUMN transaction_uuid TO transaction_id;
+        ALTER TABLE transfer_job_rows ADD CONSTRAINT fk_transfer_job_transaction FOREIGN KEY (transaction_id) REFERENCES transactions(id);
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Schema version checked by GET /readyz. Bump it together with schemaVersion in api/cmd/main.go when adding DDL here.
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INT PRIMARY KEY,
+    applied_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO schema_migrations(version) VALUES (1) ON CONFLICT (version) DO NOTHING;
+
+-- API keys, only a SHA-256 hash of each key is stored. id is the key's public part, used to look it up.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    key_hash TEXT NOT NULL,
+    scopes TEXT[] NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+INSERT INTO schema_migrations(version) VALUES (2) ON CONFLICT (version) DO NOTHING;
+
+-- Nonces of HMAC signed requests, kept until the request's timestamp leaves the allowed window so a replay is refused
+CREATE TABLE IF NOT EXISTS request_nonces (
+    client_id TEXT NOT NULL,
+    nonce TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (client_id, nonce)
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_nonces_expires_at ON request_nonces(expires_at);
+
+INSERT INTO schema_migrations(version) VALUES (3) ON CONFLICT (version) DO NOTHING;
+
+-- Rate limit token buckets shared by every replica, keyed by budget and client. Idle buckets are purged by the API.
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_updated_at ON rate_limit_buckets(updated_at);
+
+INSERT INTO schema_migrations(version) VALUES (4) ON CONFLICT (version) DO NOTHING;
+
+-- Append-only audit trail of every mutation, written in the DB txn of the change. Each record hashes its contents
+-- together with the previous record's hash, `transferctl verify-audit-log` walks the chain. The states are of type
+-- json rather than jsonb so they keep the exact text that was hashed.
+CREATE TABLE IF NOT EXISTS audit_log (
+    seq BIGINT PRIMARY KEY,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    actor TEXT NOT NULL,
+    request_id TEXT,
+    source_ip TEXT,
+    action TEXT NOT NULL,
+    resource_type TEXT NOT NULL,
+    resource_id TEXT NOT NULL,
+    before_state JSON,
+    after_state JSON,
+    prev_hash CHAR(64) NOT NULL,
+    hash CHAR(64) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_resource ON audit_log(resource_type, resource_id, seq);
+CREATE INDEX IF NOT EXISTS idx_audit_log_occurred_at ON audit_log(occurred_at);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_audit_log_append_only ON audit_log;
+CREATE TRIGGER trg_audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+DROP TRIGGER IF EXISTS trg_audit_log_no_truncate ON audit_log;
+CREATE TRIGGER trg_audit_log_no_truncate BEFORE TRUNCATE ON audit_log FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+
+INSERT INTO schema_migrations(version) VALUES (5) ON CONFLICT (version) DO NOTHING;
+
+-- Hash chain over the transactions, see api/ledger. Each transaction is linked in the DB txn that creates it, chain_seq
+-- follows commit order and hash covers the transaction's contents and the previous transaction's hash. Transactions
+-- created before the chain existed are linked after it by the API on startup.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS chain_seq BIGINT;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS prev_hash CHAR(64);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS hash CHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_chain_seq ON transactions(chain_seq);
+CREATE INDEX IF NOT EXISTS idx_transactions_unchained ON transactions(created_at) WHERE chain_seq IS NULL;
+
+-- A linked transaction can no longer change
+CREATE OR REPLACE FUNCTION transactions_chained_immutable() RETURNS TRIGGER AS $$
+BEGIN
+    IF OLD.chain_seq IS NOT NULL THEN
+        RAISE EXCEPTION 'transaction % is chained and cannot change', OLD.id;
+    END IF;
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_transactions_chained_immutable ON transactions;
+CREATE TRIGGER trg_transactions_chained_immutable BEFORE UPDATE OR DELETE ON transactions FOR EACH ROW EXECUTE FUNCTION transactions_chained_immutable();
+
+-- Signed chain heads. A checkpoint commits to every transaction up to seq, counterparties holding one can tell when
+-- that part of the history is rewritten or removed.
+CREATE TABLE IF NOT EXISTS transaction_chain_checkpoints (
+    seq BIGINT PRIMARY KEY,
+    hash CHAR(64) NOT NULL,
+    signed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    key_id TEXT NOT NULL,
+    signature BYTEA NOT NULL
+);
+
+INSERT INTO schema_migrations(version) VALUES (6) ON CONFLICT (version) DO NOTHING;
+
+-- Optimistic concurrency on accounts, served as the ETag of GET /accounts/{id}. Every UPDATE bumps the version,
+-- including balance changes made by transfers.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION bump_version() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version = OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_accounts_version ON accounts;
+CREATE TRIGGER trg_accounts_version BEFORE UPDATE ON accounts FOR EACH ROW EXECUTE FUNCTION bump_version();
+
+INSERT INTO schema_migrations(version) VALUES (7) ON CONFLICT (version) DO NOTHING;
+
+-- Point-in-time balances, see GET /accounts/{id}/balance. posted_at is set and hashed when the transfer is linked into
+-- the chain, under the chain's lock held until it commits, so it follows the order transfers commit in. Transactions
+-- from before it existed have none and count as posted when they were created, which leaves the chain untouched.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS posted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_source_posted_at ON transactions(source_account_id, (COALESCE(posted_at, created_at)));
+CREATE INDEX IF NOT EXISTS idx_transactions_destination_posted_at ON transactions(destination_account_id, (COALESCE(posted_at, created_at)));
+
+-- The balance of an account including every transfer posted at or before taken_at. The opening balance is the
+-- snapshot taken at created_at, later ones are taken periodically by the API so a query only sums recent transfers.
+CREATE TABLE IF NOT EXISTS account_balance_snapshots (
+    account_id BIGINT NOT NULL REFERENCES accounts(id),
+    taken_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    balance NUMERIC(10, 2) NOT NULL,
+    PRIMARY KEY (account_id, taken_at)
+);
+
+CREATE OR REPLACE FUNCTION snapshot_opening_balances() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO account_balance_snapshots(account_id, taken_at, balance)
+    SELECT id, COALESCE(created_at, NOW()), balance FROM inserted_accounts;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_accounts_opening_balance ON accounts;
+CREATE TRIGGER trg_accounts_opening_balance AFTER INSERT ON accounts REFERENCING NEW TABLE AS inserted_accounts
+    FOR EACH STATEMENT EXECUTE FUNCTION snapshot_opening_balances();
+
+-- Accounts opened before the snapshots existed get the opening balance their history implies
+INSERT INTO account_balance_snapshots(account_id, taken_at, balance)
+SELECT a.id, COALESCE(a.created_at, NOW()), a.balance
+    - COALESCE((SELECT SUM(amount) FROM transactions WHERE destination_account_id = a.id), 0)
+    + COALESCE((SELECT SUM(amount) FROM transactions WHERE source_account_id = a.id), 0)
+FROM accounts a
+WHERE NOT EXISTS (SELECT 1 FROM account_balance_snapshots s WHERE s.account_id = a.id)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO schema_migrations(version) VALUES (8) ON CONFLICT (version) DO NOTHING;
+
+-- Who uploaded a transfer job, its transfers are audited as theirs when the worker pays the rows. Jobs uploaded before
+-- it existed have no actor and are audited as the system.
+ALTER TABLE transfer_jobs ADD COLUMN IF NOT EXISTS actor TEXT;
+ALTER TABLE transfer_jobs ADD COLUMN IF NOT EXISTS request_id TEXT;
+ALTER TABLE transfer_jobs ADD COLUMN IF NOT EXISTS source_ip TEXT;
+
+INSERT INTO schema_migrations(version) VALUES (9) ON CONFLICT (version) DO NOTHING;